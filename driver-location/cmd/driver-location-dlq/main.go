@@ -0,0 +1,181 @@
+// Command driver-location-dlq inspects and replays the dead letters of the driver location service.
+//
+// Usage:
+//
+//	driver-location-dlq inspect [flags]  prints dead letters as JSON lines and leaves them in the queue
+//	driver-location-dlq replay [flags]   publishes the original messages back and removes the dead letters
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/nsqio/go-nsq"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+)
+
+const (
+	commandInspect = "inspect"
+	commandReplay  = "replay"
+)
+
+func main() {
+	if len(os.Args) < 2 || (os.Args[1] != commandInspect && os.Args[1] != commandReplay) {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s|%s [flags]\n", os.Args[0], commandInspect, commandReplay)
+		os.Exit(2)
+	}
+	command := os.Args[1]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	nsqdAddress := flags.String("nsqd", "nsqd:4150", "nsqd TCP address")
+	topic := flags.String("topic", "locations-dead-letters", "dead letter topic")
+	channel := flags.String("channel", "dead-letters-tool", "channel to read dead letters from")
+	limit := flags.Int("limit", 0, "max number of dead letters to process, 0 means no limit")
+	idleTimeout := flags.Duration("idle-timeout", 2*time.Second, "stop once no dead letter arrives for that long")
+	_ = flags.Parse(os.Args[2:]) // nolint: errcheck
+
+	logger := logging.NewLogger()
+	var handler nsq.Handler
+	if command == commandInspect {
+		handler = &inspector{output: os.Stdout, logger: logger, seen: make(map[nsq.MessageID]bool)}
+	} else {
+		producer, err := nsq.NewProducer(*nsqdAddress, nsq.NewConfig())
+		if err != nil {
+			logger.WithError(err).Fatal("Couldn't initialize nsq producer")
+		}
+		defer producer.Stop()
+		handler = driverloc.NewDeadLetterReplayer(producer, logger)
+	}
+
+	processed, err := consume(*nsqdAddress, *topic, *channel, handler, *limit, *idleTimeout)
+	if err != nil {
+		logger.WithError(err).Fatal("Couldn't consume dead letters")
+	}
+	logger.WithField("processed", processed).Info("Done")
+}
+
+// consume feeds dead letters to the handler one by one until the limit is reached,
+// the handler ends the session or no dead letter arrives for idleTimeout.
+func consume(
+	nsqdAddress, topic, channel string, handler nsq.Handler, limit int, idleTimeout time.Duration,
+) (int, error) {
+	nsqConf := nsq.NewConfig()
+	nsqConf.MaxAttempts = 0
+	consumer, err := nsq.NewConsumer(topic, channel, nsqConf)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to initialize nsq consumer")
+	}
+	session := &session{handler: handler, limit: limit, activity: make(chan struct{}, 1)}
+	consumer.AddHandler(session)
+	if err := consumer.ConnectToNSQD(nsqdAddress); err != nil {
+		return 0, errors.Wrap(err, "failed to connect nsq consumer to nsqd")
+	}
+	defer func() {
+		consumer.Stop()
+		<-consumer.StopChan
+	}()
+
+	idle := time.NewTimer(idleTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case <-session.activity:
+			if session.isOver() {
+				return session.processedNum(), nil
+			}
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(idleTimeout)
+		case <-idle.C:
+			return session.processedNum(), nil
+		}
+	}
+}
+
+// errSessionOver is returned by a handler that has seen all dead letters.
+var errSessionOver = errors.New("session is over")
+
+type session struct {
+	handler  nsq.Handler
+	limit    int
+	activity chan struct{}
+
+	mu        sync.Mutex
+	processed int
+	over      bool
+}
+
+func (s *session) HandleMessage(m *nsq.Message) error {
+	defer s.notify()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.over {
+		m.DisableAutoResponse()
+		m.RequeueWithoutBackoff(0)
+		return nil
+	}
+	err := s.handler.HandleMessage(m)
+	if errors.Is(err, errSessionOver) {
+		s.over = true
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.processed++
+	if s.limit > 0 && s.processed >= s.limit {
+		s.over = true
+	}
+	return nil
+}
+
+func (s *session) notify() {
+	select {
+	case s.activity <- struct{}{}:
+	default:
+	}
+}
+
+func (s *session) isOver() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.over
+}
+
+func (s *session) processedNum() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.processed
+}
+
+// inspector prints dead letters and puts them back into the queue.
+// Requeued letters come back, so the session is over once a letter is seen for the second time.
+type inspector struct {
+	output io.Writer
+	logger log.FieldLogger
+	seen   map[nsq.MessageID]bool
+}
+
+func (i *inspector) HandleMessage(m *nsq.Message) error {
+	m.DisableAutoResponse()
+	m.RequeueWithoutBackoff(0)
+	if i.seen[m.ID] {
+		return errSessionOver
+	}
+	i.seen[m.ID] = true
+	letter, err := driverloc.ParseDeadLetter(m.Body)
+	if err != nil {
+		i.logger.WithError(err).WithField("message_body", string(m.Body)).Info("Skip malformed dead letter")
+		return nil
+	}
+	return errors.Wrap(json.NewEncoder(i.output).Encode(letter), "failed to print dead letter")
+}
@@ -0,0 +1,39 @@
+// Command driver-location-migrate-scores rescores the driver locations saved by older versions of the service
+// with the unix time in seconds, so locations saved within the same second are ordered by their time.
+// It's safe to run it multiple times, it must run after all service replicas are upgraded.
+//
+// Usage:
+//
+//	driver-location-migrate-scores [flags]
+package main
+
+import (
+	"context"
+	"flag"
+
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/go-redis/redis/v8"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+)
+
+func main() {
+	redisAddress := flag.String("redis", "redis:6379", "Redis address")
+	scanBatchSize := flag.Int64("scan-batch-size", 1000, "number of keys to scan at once")
+	flag.Parse()
+
+	logger := logging.NewLogger()
+	redisClient := redis.NewClient(&redis.Options{Addr: *redisAddress})
+	defer redisClient.Close() // nolint: errcheck
+
+	stats, err := driverloc.MigrateScores(context.Background(), redisClient, logger, *scanBatchSize)
+	if err != nil {
+		logger.WithError(err).Fatal("Couldn't migrate driver locations scores")
+	}
+	logger.WithFields(log.Fields{
+		"drivers_num":      stats.DriversNum,
+		"rescored_num":     stats.RescoredNum,
+		"skipped_keys_num": stats.SkippedKeysNum,
+	}).Info("Done")
+}
@@ -2,77 +2,64 @@ package main
 
 import (
 	"context"
-	"fmt"
-	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 
+	"github.com/georgysavva/driver-app/platform/runner"
 	"github.com/go-redis/redis/v8"
-	"github.com/nsqio/go-nsq"
-	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
 
 	"github.com/georgysavva/driver-app/driver-location/pkg/config"
 	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
-	"github.com/georgysavva/driver-app/driver-location/pkg/httpmiddleware"
 )
 
-// Improvement: allow to pass a custom config path.
-const defaultConfigPath = "config.yaml"
+const configEnvPrefix = "DRIVERLOC"
 
 func main() {
-	logger := log.New()
-	logger.SetFormatter(&log.JSONFormatter{})
-	conf, err := config.ParseConfig(defaultConfigPath)
-	if err != nil {
-		logger.WithError(err).Fatal("Failed to parse config")
-	}
-	redisClient := redis.NewClient(&redis.Options{Addr: conf.Redis.Address})
-	defer redisClient.Close() // nolint: errcheck
-	service := driverloc.NewService(redisClient, logger.WithField("component", "service"), conf.App.DriverLocationsLimit)
-
-	// HTTP server
-	httpHandler := driverloc.MakeHTTPHandler(service, logger.WithField("component", "http-handler"))
+	app := runner.New()
+	logger := app.Logger()
+	conf := &config.Config{}
+	app.LoadConfig(configEnvPrefix, conf)
 
-	httpHandler = httpmiddleware.NewLoggingMiddleware(httpHandler, logger)
-	httpServer := http.Server{Addr: fmt.Sprintf(":%d", conf.HTTPServer.Port), Handler: httpHandler}
-	go func() {
-		if err := httpServer.ListenAndServe(); err != nil && errors.Is(err, http.ErrServerClosed) {
-			logger.WithError(err).Fatal("HTTP server unexpectedly stopped")
-		}
-	}()
-	logger.WithField("http_port", conf.HTTPServer.Port).Info("HTTP server successfully started")
-
-	// NSQ consumer
-	nsqHandler := driverloc.NewNSQHandler(service, logger.WithField("component", "nsq-handler"))
-	nsqConsumer, err := nsq.NewConsumer(conf.NSQ.Topic, conf.NSQ.Channel, nsq.NewConfig())
-	if err != nil {
-		logger.WithError(err).Fatal("Couldn't initialize nsq consumer")
-	}
-	nsqConsumer.AddConcurrentHandlers(nsqHandler, conf.NSQ.WorkersNum)
-	if err := nsqConsumer.ConnectToNSQDs(conf.NSQ.DaemonAddresses); err != nil {
-		logger.WithError(err).Fatal("Couldn't connect nsq consumer to nsqds")
+	redisClient := redis.NewClient(&redis.Options{Addr: conf.Redis.Address})
+	app.AddCloser("Redis client", redisClient)
+	hub := driverloc.NewHub(logger.WithField("component", "hub"), conf.App.StreamBufferSize)
+	var codec driverloc.LocationCodec = driverloc.JSONLocationCodec{}
+	if conf.App.LocationEncoding == config.LocationEncodingBinary {
+		codec = driverloc.BinaryLocationCodec{}
 	}
-	logger.Info("NSQ consumer successfully started")
-
-	terminationChan := make(chan os.Signal, 1)
-	signal.Notify(terminationChan, syscall.SIGINT, syscall.SIGTERM)
-	<-terminationChan
-
-	logger.Info("Stopping NSQ consumer")
-	nsqConsumer.Stop()
-	logger.Info("NSQ consumer stopped")
+	service := driverloc.NewService(
+		redisClient, hub, codec, logger.WithField("component", "service"), conf.App.DriverLocationsLimit,
+		conf.App.OnlineTimeout,
+	)
 
-	logger.WithField("shutdown_timeout", conf.HTTPServer.ShutdownTimeout).Info("Stopping http server")
-	ctx, cancel := context.WithTimeout(context.Background(), conf.HTTPServer.ShutdownTimeout)
-	defer cancel()
-	if err := httpServer.Shutdown(ctx); err != nil {
-		logger.WithError(err).Fatal("Couldn't properly shutdown http server")
+	httpHandler := driverloc.MakeHTTPHandler(service, hub, logger.WithField("component", "http-handler"))
+	app.StartHTTPServer(conf.HTTPServer, httpHandler)
+	if conf.GRPCServer.Enabled {
+		grpcServer := driverloc.NewGRPCServer(service, hub, logger.WithField("component", "grpc-server"))
+		app.StartGRPCServer(conf.GRPCServer, grpcServer.Register)
 	}
-	logger.Info("HTTP server was successfully shutdown")
+	// Streams are never-ending requests, so the hub is closed before the servers wait for them to finish.
+	app.OnShutdown("Location hub", func(_ context.Context) error {
+		hub.Close()
+		return nil
+	})
 
-	if err := redisClient.Close(); err != nil {
-		logger.WithError(err).Fatal("Couldn't close redis client")
+	var deadLetters driverloc.DeadLetterQueue = driverloc.NopDeadLetterQueue{}
+	if conf.DeadLetterQueue.Enabled {
+		nsqProducer := app.StartNSQProducer(conf.DeadLetterQueue.Producer)
+		deadLetters = driverloc.NewNSQDeadLetterQueue(nsqProducer, conf.DeadLetterQueue.Topic, conf.NSQ.Topic)
 	}
+	var dedup driverloc.DedupStore = driverloc.NopDedupStore{}
+	if conf.Dedup.Enabled {
+		switch conf.Dedup.Backend {
+		case config.DedupBackendRedis:
+			dedup = driverloc.NewRedisDedupStore(redisClient, conf.Dedup.TTL, conf.Dedup.LeaseTTL)
+		case config.DedupBackendMemory:
+			dedup = driverloc.NewMemoryDedupStore(conf.Dedup.TTL, conf.Dedup.LeaseTTL)
+		}
+	}
+	nsqLogger := logger.WithField("component", "nsq-handler")
+	commands := driverloc.NewUpdaterCommandRegistry(service, nsqLogger)
+	nsqHandler := driverloc.NewNSQHandler(commands, dedup, deadLetters, nsqLogger, conf.MessageRetry)
+	app.StartNSQConsumer(conf.NSQ, nsqHandler)
+
+	app.Wait()
 }
@@ -4,11 +4,17 @@ go 1.14
 
 require (
 	github.com/alicebob/miniredis/v2 v2.14.1
+	github.com/georgysavva/driver-app/platform v0.0.0-00010101000000-000000000000
 	github.com/go-redis/redis/v8 v8.3.4
 	github.com/gorilla/mux v1.8.0
+	github.com/gorilla/websocket v1.4.2
 	github.com/nsqio/go-nsq v1.0.8
 	github.com/pkg/errors v0.9.1
 	github.com/sirupsen/logrus v1.7.0
-	github.com/stretchr/testify v1.6.1
-	gopkg.in/yaml.v2 v2.3.0
+	github.com/stretchr/testify v1.7.0
+	golang.org/x/sync v0.0.0-20201020160332-67f06af15bc9
+	google.golang.org/grpc v1.43.0
+	google.golang.org/protobuf v1.25.0
 )
+
+replace github.com/georgysavva/driver-app/platform => ../platform
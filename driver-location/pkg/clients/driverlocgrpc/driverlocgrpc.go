@@ -0,0 +1,125 @@
+package driverlocgrpc
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverlocpb"
+)
+
+// Client implements driverloc.GetterService over the gRPC API of the driver-location service.
+type Client struct {
+	client driverlocpb.DriverLocationClient
+}
+
+func NewClient(conn grpc.ClientConnInterface) *Client {
+	return &Client{client: driverlocpb.NewDriverLocationClient(conn)}
+}
+
+// Dial returns a connection to the driver-location service, it's established lazily on the first call.
+func Dial(address string) (*grpc.ClientConn, error) {
+	conn, err := grpc.Dial(address, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	return conn, errors.Wrap(err, "couldn't initialize driver-location service grpc connection")
+}
+
+func (c *Client) GetLocations(ctx context.Context, driverID string, timeInterval time.Duration) (
+	[]*driverloc.Location, error) {
+	resp, err := c.client.GetLocations(ctx, &driverlocpb.GetLocationsRequest{
+		DriverId: driverID,
+		Window:   durationpb.New(timeInterval),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "grpc call to driver-location service failed")
+	}
+	return locationsFromProto(resp.Locations), nil
+}
+
+// BatchGetLocations returns the locations of the drivers by their ids.
+func (c *Client) BatchGetLocations(ctx context.Context, driverIDs []string, timeInterval time.Duration) (
+	map[string][]*driverloc.Location, error) {
+	resp, err := c.client.BatchGetLocations(ctx, &driverlocpb.BatchGetLocationsRequest{
+		DriverIds: driverIDs,
+		Window:    durationpb.New(timeInterval),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "grpc call to driver-location service failed")
+	}
+	result := make(map[string][]*driverloc.Location, len(resp.Drivers))
+	for _, driver := range resp.Drivers {
+		result[driver.DriverId] = locationsFromProto(driver.Locations)
+	}
+	return result, nil
+}
+
+// GetStatus returns whether the driver is online and when they were last seen.
+func (c *Client) GetStatus(ctx context.Context, driverID string) (*driverloc.DriverStatus, error) {
+	resp, err := c.client.GetStatus(ctx, &driverlocpb.GetStatusRequest{DriverId: driverID})
+	if err != nil {
+		return nil, errors.Wrap(err, "grpc call to driver-location service failed")
+	}
+	status := &driverloc.DriverStatus{Status: resp.Status}
+	if resp.LastSeenAt != nil {
+		lastSeenAt := resp.LastSeenAt.AsTime()
+		status.LastSeenAt = &lastSeenAt
+	}
+	return status, nil
+}
+
+// StreamLocations calls fn for every location update matching the filter until the context is canceled,
+// fn returns an error or the stream ends. It returns nil only if the context is canceled.
+func (c *Client) StreamLocations(ctx context.Context, filter *driverloc.SubscriptionFilter,
+	fn func(update *driverloc.LocationUpdate) error) error {
+	req := &driverlocpb.StreamLocationsRequest{DriverIds: filter.DriverIDs}
+	if bbox := filter.BoundingBox; bbox != nil {
+		req.Bbox = &driverlocpb.BoundingBox{
+			MinLatitude:  bbox.MinLatitude,
+			MinLongitude: bbox.MinLongitude,
+			MaxLatitude:  bbox.MaxLatitude,
+			MaxLongitude: bbox.MaxLongitude,
+		}
+	}
+	stream, err := c.client.StreamLocations(ctx, req)
+	if err != nil {
+		return errors.Wrap(err, "grpc call to driver-location service failed")
+	}
+	for {
+		update, err := stream.Recv()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err == io.EOF {
+			return errors.New("driver-location service ended the locations stream")
+		}
+		if err != nil {
+			return errors.Wrap(err, "failed to receive location update from the stream")
+		}
+		err = fn(&driverloc.LocationUpdate{
+			DriverID: update.DriverId,
+			Location: &driverloc.Location{
+				Coordinates: &driverloc.Coordinates{Latitude: update.Latitude, Longitude: update.Longitude},
+				Time:        update.UpdatedAt.AsTime(),
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func locationsFromProto(locations []*driverlocpb.Location) []*driverloc.Location {
+	result := make([]*driverloc.Location, len(locations))
+	for i, location := range locations {
+		result[i] = &driverloc.Location{
+			Coordinates: &driverloc.Coordinates{Latitude: location.Latitude, Longitude: location.Longitude},
+			Time:        location.UpdatedAt.AsTime(),
+		}
+	}
+	return result
+}
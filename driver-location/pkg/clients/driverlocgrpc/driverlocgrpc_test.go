@@ -0,0 +1,194 @@
+package driverlocgrpc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/clients/driverlocgrpc"
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc/mocks"
+)
+
+const defaultDriverID = "foo"
+
+var (
+	_ driverloc.GetterService = (*driverlocgrpc.Client)(nil)
+
+	baseTime = time.Date(2018, 04, 05, 22, 36, 16, 00, time.UTC)
+)
+
+func TestClient_GetLocations(t *testing.T) {
+	t.Parallel()
+	client, serviceMock, _ := setupGRPCClient(t)
+
+	timeInterval := 90 * time.Second // Isn't rounded to minutes.
+	expected := []*driverloc.Location{
+		{
+			Coordinates: &driverloc.Coordinates{
+				Latitude:  48.864193,
+				Longitude: 2.350498,
+			},
+			Time: baseTime,
+		},
+		{
+			Coordinates: &driverloc.Coordinates{
+				Latitude:  48.863921,
+				Longitude: 2.349211,
+			},
+			Time: baseTime.Add(5*time.Second + time.Millisecond),
+		},
+	}
+
+	serviceMock.On(
+		"GetLocations",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+		defaultDriverID, timeInterval,
+	).Return(expected, nil)
+
+	actual, err := client.GetLocations(context.Background(), defaultDriverID, timeInterval)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, actual)
+	serviceMock.AssertExpectations(t)
+}
+
+func TestClient_GetLocations_ServiceFails(t *testing.T) {
+	t.Parallel()
+	client, serviceMock, _ := setupGRPCClient(t)
+	serviceMock.On(
+		"GetLocations",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+		defaultDriverID, time.Minute,
+	).Return(nil, errors.New("redis is down"))
+
+	_, err := client.GetLocations(context.Background(), defaultDriverID, time.Minute)
+
+	assert.Error(t, err)
+}
+
+func TestClient_BatchGetLocations(t *testing.T) {
+	t.Parallel()
+	client, serviceMock, _ := setupGRPCClient(t)
+	expected := map[string][]*driverloc.Location{
+		defaultDriverID: {{Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498}, Time: baseTime}},
+		"bar":           {},
+	}
+	for driverID, locations := range expected {
+		serviceMock.On(
+			"GetLocations",
+			mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+			driverID, 5*time.Minute,
+		).Return(locations, nil)
+	}
+
+	actual, err := client.BatchGetLocations(context.Background(), []string{defaultDriverID, "bar"}, 5*time.Minute)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, actual)
+	serviceMock.AssertExpectations(t)
+}
+
+func TestClient_GetStatus(t *testing.T) {
+	t.Parallel()
+	lastSeenAt := baseTime
+	cases := []struct {
+		name     string
+		expected *driverloc.DriverStatus
+	}{
+		{name: "online", expected: &driverloc.DriverStatus{Status: driverloc.DriverStatusOnline, LastSeenAt: &lastSeenAt}},
+		{name: "never seen", expected: &driverloc.DriverStatus{Status: driverloc.DriverStatusNeverSeen}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			client, serviceMock, _ := setupGRPCClient(t)
+			serviceMock.On(
+				"GetStatus",
+				mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+				defaultDriverID,
+			).Return(tc.expected, nil)
+
+			actual, err := client.GetStatus(context.Background(), defaultDriverID)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, actual)
+			serviceMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestClient_StreamLocations(t *testing.T) {
+	t.Parallel()
+	client, _, hub := setupGRPCClient(t)
+	expected := &driverloc.LocationUpdate{
+		DriverID: defaultDriverID,
+		Location: &driverloc.Location{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			Time:        baseTime,
+		},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := make(chan *driverloc.LocationUpdate, 1)
+	streamErr := make(chan error, 1)
+	go func() {
+		filter := &driverloc.SubscriptionFilter{DriverIDs: []string{defaultDriverID}}
+		streamErr <- client.StreamLocations(ctx, filter, func(update *driverloc.LocationUpdate) error {
+			select {
+			case updates <- update:
+			default:
+			}
+			return nil
+		})
+	}()
+
+	// The client doesn't tell when it's subscribed, so the update is published until it's received.
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	var actual *driverloc.LocationUpdate
+	for actual == nil {
+		select {
+		case <-ticker.C:
+			hub.Publish(expected)
+		case actual = <-updates:
+		}
+	}
+	assert.Equal(t, expected, actual)
+
+	cancel()
+	assert.NoError(t, <-streamErr)
+}
+
+func setupGRPCClient(t *testing.T) (*driverlocgrpc.Client, *mocks.GetterService, *driverloc.Hub) {
+	logger := log.New()
+	logger.Level = log.ErrorLevel
+	serviceMock := &mocks.GetterService{}
+	hub := driverloc.NewHub(logger, 16 /* bufferSize */)
+	server := grpc.NewServer()
+	driverloc.NewGRPCServer(serviceMock, hub, logger).Register(server)
+	listener := bufconn.Listen(1024 * 1024)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(
+		"bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return driverlocgrpc.NewClient(conn), serviceMock, hub
+}
@@ -5,10 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"math"
 	"net/http"
 	"net/url"
-	"strconv"
 	"time"
 
 	"github.com/pkg/errors"
@@ -31,29 +29,88 @@ func NewClient(httpClient *http.Client, baseURL string) (*Client, error) {
 
 func (c *Client) GetLocations(ctx context.Context, driverID string, timeInterval time.Duration) (
 	[]*driverloc.Location, error) {
-	timeIntervalMinutes := int(math.Round(timeInterval.Minutes()))
+	var locations []*driverloc.Location
+	if err := c.getLocations(ctx, driverID, timeInterval, driverloc.FormatJSON, &locations); err != nil {
+		return nil, err
+	}
+	return locations, nil
+}
+
+// GetLocationsGeoJSON returns the locations as a GeoJSON FeatureCollection of points.
+func (c *Client) GetLocationsGeoJSON(ctx context.Context, driverID string, timeInterval time.Duration) (
+	*driverloc.LocationsFeatureCollection, error) {
+	fc := &driverloc.LocationsFeatureCollection{}
+	if err := c.getLocations(ctx, driverID, timeInterval, driverloc.FormatGeoJSON, fc); err != nil {
+		return nil, err
+	}
+	return fc, nil
+}
+
+// GetTrack returns the locations as a GeoJSON LineString feature.
+func (c *Client) GetTrack(ctx context.Context, driverID string, timeInterval time.Duration) (
+	*driverloc.TrackFeature, error) {
+	track := &driverloc.TrackFeature{}
+	if err := c.getLocations(ctx, driverID, timeInterval, driverloc.FormatLineString, track); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// GetPolylineTrack returns the locations as a Google encoded polyline.
+func (c *Client) GetPolylineTrack(ctx context.Context, driverID string, timeInterval time.Duration) (
+	*driverloc.PolylineTrack, error) {
+	track := &driverloc.PolylineTrack{}
+	if err := c.getLocations(ctx, driverID, timeInterval, driverloc.FormatPolyline, track); err != nil {
+		return nil, err
+	}
+	return track, nil
+}
+
+// GetStatus returns whether the driver is online and when they were last seen.
+func (c *Client) GetStatus(ctx context.Context, driverID string) (*driverloc.DriverStatus, error) {
+	status := &driverloc.DriverStatus{}
+	path := fmt.Sprintf("drivers/%s/status", driverID)
+	if err := c.getJSON(ctx, path, nil /* queryParams */, driverloc.MediaTypeJSON, status); err != nil {
+		return nil, errors.Wrap(err, "failed to get driver status")
+	}
+	return status, nil
+}
+
+// getLocations requests the locations in the format and decodes the response into v.
+func (c *Client) getLocations(ctx context.Context, driverID string, timeInterval time.Duration, format string,
+	v interface{}) error {
 	queryParams := url.Values{}
-	queryParams.Set("minutes", strconv.Itoa(timeIntervalMinutes))
-	reqURL := c.baseURL.ResolveReference(&url.URL{
-		Path:     fmt.Sprintf("drivers/%s/locations", driverID),
-		RawQuery: queryParams.Encode(),
-	})
+	// The exact interval, e.g. "1m30s".
+	queryParams.Set("window", timeInterval.String())
+	if format != driverloc.FormatJSON {
+		queryParams.Set("format", format)
+	}
+	path := fmt.Sprintf("drivers/%s/locations", driverID)
+	err := c.getJSON(ctx, path, queryParams, driverloc.FormatMediaType(format), v)
+	return errors.Wrapf(err, "failed to get %s locations", format)
+}
+
+// getJSON sends a GET request to the path and decodes the json response into v.
+func (c *Client) getJSON(ctx context.Context, path string, queryParams url.Values, accept string,
+	v interface{}) error {
+	reqURL := c.baseURL.ResolveReference(&url.URL{Path: path, RawQuery: queryParams.Encode()})
 
 	req, err := http.NewRequestWithContext(ctx, "GET", reqURL.String(), nil /* body */)
 	if err != nil {
-		return nil, errors.Wrap(err, "couldn't initialize a new http request with base url")
+		return errors.Wrap(err, "couldn't initialize a new http request with base url")
 	}
+	req.Header.Set("Accept", accept)
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, errors.Wrap(err, "http get request to driver locations endpoint failed")
+		return errors.Wrap(err, "http get request to driver-location service failed")
 	}
 	defer resp.Body.Close() // nolint: errcheck
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
-		return nil, errors.Wrap(err, "couldn't read http response content")
+		return errors.Wrap(err, "couldn't read http response content")
 	}
 	if err := resp.Body.Close(); err != nil {
-		return nil, errors.Wrap(err, "couldn't close http response")
+		return errors.Wrap(err, "couldn't close http response")
 	}
 	if resp.StatusCode != http.StatusOK {
 		err := &url.Error{
@@ -61,11 +118,10 @@ func (c *Client) GetLocations(ctx context.Context, driverID string, timeInterval
 			URL: req.URL.String(),
 			Err: errors.Errorf("not OK http status code %d: %s", resp.StatusCode, body),
 		}
-		return nil, errors.WithStack(err)
+		return errors.WithStack(err)
 	}
-	var locations []*driverloc.Location
-	if err := json.Unmarshal(body, &locations); err != nil {
-		return nil, errors.Wrapf(err, "failed to decode json into locations list: %s", body)
+	if err := json.Unmarshal(body, v); err != nil {
+		return errors.Wrapf(err, "failed to decode json response: %s", body)
 	}
-	return locations, nil
+	return nil
 }
@@ -24,7 +24,7 @@ func TestClient_GetLocations(t *testing.T) {
 	ts, serviceMock := setupHTTPServer()
 	defer ts.Close()
 
-	timeInterval := 5 * time.Minute
+	timeInterval := 90 * time.Second // Isn't rounded to minutes.
 	expected := []*driverloc.Location{
 		{
 			Coordinates: &driverloc.Coordinates{
@@ -57,11 +57,70 @@ func TestClient_GetLocations(t *testing.T) {
 	serviceMock.AssertExpectations(t)
 }
 
-func setupHTTPServer() (*httptest.Server, *mocks.GetterService) {
+func TestClient_GetLocations_Formats(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupHTTPServer()
+	defer ts.Close()
+
+	timeInterval := 5 * time.Minute
+	expected := []*driverloc.Location{
+		{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.86419, Longitude: 2.3505},
+			Time:        time.Date(2018, 04, 05, 22, 36, 16, 00, time.UTC),
+		},
+		{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.86392, Longitude: 2.34921},
+			Time:        time.Date(2018, 04, 05, 22, 36, 21, 00, time.UTC),
+		},
+	}
+	serviceMock.On("GetLocations", mock.Anything /* ctx */, defaultDriverID, timeInterval).Return(expected, nil)
+	client, err := driverlochttp.NewClient(http.DefaultClient, ts.URL)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	fc, err := client.GetLocationsGeoJSON(ctx, defaultDriverID, timeInterval)
+	require.NoError(t, err)
+	assert.Equal(t, "FeatureCollection", fc.Type)
+	actual, err := fc.Locations()
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	track, err := client.GetTrack(ctx, defaultDriverID, timeInterval)
+	require.NoError(t, err)
+	assert.Equal(t, "LineString", track.Geometry.Type)
+	actual, err = track.Locations()
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+
+	polylineTrack, err := client.GetPolylineTrack(ctx, defaultDriverID, timeInterval)
+	require.NoError(t, err)
+	actual, err = polylineTrack.Locations()
+	require.NoError(t, err)
+	assert.Equal(t, expected, actual)
+}
+
+func TestClient_GetStatus(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupHTTPServer()
+	defer ts.Close()
+	lastSeenAt := time.Date(2018, 04, 05, 22, 36, 21, 00, time.UTC)
+	expected := &driverloc.DriverStatus{Status: driverloc.DriverStatusOnline, LastSeenAt: &lastSeenAt}
+	serviceMock.On("GetStatus", mock.Anything /* ctx */, defaultDriverID).Return(expected, nil)
+
+	client, err := driverlochttp.NewClient(http.DefaultClient, ts.URL)
+	require.NoError(t, err)
+	actual, err := client.GetStatus(context.Background(), defaultDriverID)
+	require.NoError(t, err)
+
+	assert.Equal(t, expected, actual)
+	serviceMock.AssertExpectations(t)
+}
+
+func setupHTTPServer() (*httptest.Server, *mocks.ReaderService) {
 	logger := log.New()
 	logger.Level = log.ErrorLevel
-	serviceMock := &mocks.GetterService{}
-	hh := driverloc.MakeHTTPHandler(serviceMock, logger)
+	serviceMock := &mocks.ReaderService{}
+	hh := driverloc.MakeHTTPHandler(serviceMock, driverloc.NewHub(logger, 1 /* bufferSize */), logger)
 	ts := httptest.NewServer(hh)
 	return ts, serviceMock
 }
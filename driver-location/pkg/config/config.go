@@ -1,46 +1,83 @@
 package config
 
 import (
-	"io/ioutil"
-	"path/filepath"
 	"time"
 
+	"github.com/georgysavva/driver-app/platform/runner"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
 )
 
 type Config struct {
 	App *struct {
-		DriverLocationsLimit int `yaml:"driver_locations_limit"`
+		DriverLocationsLimit int `yaml:"driver_locations_limit" default:"1000" validate:"min=1"`
+		StreamBufferSize     int `yaml:"stream_buffer_size" default:"64" validate:"min=1"`
+		// OnlineTimeout is how long a driver stays online after their latest location.
+		OnlineTimeout time.Duration `yaml:"online_timeout" default:"1m" validate:"min=1"`
+		// LocationEncoding must stay json until all replicas can read binary locations.
+		LocationEncoding string `yaml:"location_encoding" default:"json"`
 	} `yaml:"app"`
 
 	Redis *struct {
-		Address string `yaml:"address"`
+		Address string `yaml:"address" validate:"required"`
 	} `yaml:"redis"`
 
-	HTTPServer *struct {
-		Port            int           `yaml:"port"`
-		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
-	} `yaml:"http_server"`
-
-	NSQ *struct {
-		Topic           string   `yaml:"topic"`
-		Channel         string   `yaml:"channel"`
-		DaemonAddresses []string `yaml:"daemon_addresses"`
-		WorkersNum      int      `yaml:"workers_num"`
-	} `yaml:"nsq"`
+	HTTPServer *runner.HTTPServerConfig `yaml:"http_server"`
+
+	GRPCServer *runner.GRPCServerConfig `yaml:"grpc_server"`
+
+	NSQ *runner.NSQConsumerConfig `yaml:"nsq"`
+
+	MessageRetry *driverloc.RetryPolicy `yaml:"message_retry"`
+
+	Dedup *DedupConfig `yaml:"dedup"`
+
+	DeadLetterQueue *struct {
+		Enabled  bool                      `yaml:"enabled"`
+		Topic    string                    `yaml:"topic" validate:"required"`
+		Producer *runner.NSQProducerConfig `yaml:"producer"`
+	} `yaml:"dead_letter_queue"`
 }
 
-func ParseConfig(configPath string) (*Config, error) {
-	fileContent, err := ioutil.ReadFile(filepath.Clean(configPath))
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to read config file content")
+func (c *Config) Validate() error {
+	if c.App.LocationEncoding != LocationEncodingJSON && c.App.LocationEncoding != LocationEncodingBinary {
+		return errors.Errorf("unknown app.location_encoding %q, must be %q or %q",
+			c.App.LocationEncoding, LocationEncodingJSON, LocationEncodingBinary)
 	}
-
-	conf := &Config{}
-	if err := yaml.Unmarshal(fileContent, conf); err != nil {
-		return nil, errors.Wrap(err, "failed to parse yaml content into Config struct")
+	if c.NSQ.MaxAttempts != 0 && c.NSQ.MaxAttempts <= c.MessageRetry.MaxAttempts {
+		return errors.New("nsq.max_attempts must be 0 or greater than message_retry.max_attempts, " +
+			"otherwise nsq drops messages before they reach the dead letter queue")
 	}
+	return nil
+}
 
-	return conf, nil
+const (
+	LocationEncodingJSON   = "json"
+	LocationEncodingBinary = "binary"
+)
+
+const (
+	DedupBackendMemory = "memory"
+	DedupBackendRedis  = "redis"
+)
+
+// DedupConfig configures deduplication of redelivered NSQ messages, the redis backend uses the main Redis.
+type DedupConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl" default:"10m" validate:"min=1"`
+	// LeaseTTL is how long a message stays in progress. It must exceed the message handling time
+	// and stay below the nsqd msg timeout, so a message interrupted by a crash is applied on redelivery.
+	LeaseTTL time.Duration `yaml:"lease_ttl" default:"30s" validate:"min=1"`
+	Backend  string        `yaml:"backend" default:"redis"`
+}
+
+func (dc *DedupConfig) Validate() error {
+	if dc.Backend != DedupBackendMemory && dc.Backend != DedupBackendRedis {
+		return errors.Errorf("unknown backend %q, must be %q or %q", dc.Backend, DedupBackendMemory, DedupBackendRedis)
+	}
+	if dc.LeaseTTL > dc.TTL {
+		return errors.New("lease_ttl must not exceed ttl")
+	}
+	return nil
 }
@@ -0,0 +1,137 @@
+package driverloc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+)
+
+var codecs = []struct {
+	name  string
+	codec driverloc.LocationCodec
+}{
+	{name: "json", codec: driverloc.JSONLocationCodec{}},
+	{name: "binary", codec: driverloc.BinaryLocationCodec{}},
+}
+
+func TestLocationCodec(t *testing.T) {
+	t.Parallel()
+	expected := &driverloc.Location{
+		Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: -2.350498},
+		Time:        time.Date(2018, 04, 05, 22, 36, 16, 123456789, time.UTC),
+	}
+	for _, c := range codecs {
+		data, err := c.codec.Encode(expected)
+		require.NoError(t, err, c.name)
+
+		actual, err := c.codec.Decode(data)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, expected, actual, c.name)
+
+		actual, err = driverloc.DecodeLocation(data)
+		require.NoError(t, err, c.name)
+		assert.Equal(t, expected, actual, c.name)
+	}
+}
+
+func TestBinaryLocationCodec_IsCompact(t *testing.T) {
+	t.Parallel()
+	loc := &driverloc.Location{
+		Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+		Time:        time.Date(2018, 04, 05, 22, 36, 16, 123456789, time.UTC),
+	}
+	jsonData, err := driverloc.JSONLocationCodec{}.Encode(loc)
+	require.NoError(t, err)
+	binaryData, err := driverloc.BinaryLocationCodec{}.Encode(loc)
+	require.NoError(t, err)
+
+	assert.Len(t, binaryData, 25)
+	assert.Less(t, 3*len(binaryData), len(jsonData))
+}
+
+func TestDecodeLocation_InvalidData(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: []byte{}},
+		{name: "truncated binary", data: []byte{1, 2, 3}},
+		{name: "not a json", data: []byte("foo")},
+		{name: "json without coordinates", data: []byte(`{"updated_at":"2018-04-05T22:36:16Z"}`)},
+	}
+	for _, tc := range cases {
+		_, err := driverloc.DecodeLocation(tc.data)
+		assert.Error(t, err, tc.name)
+	}
+}
+
+func TestService_BinaryCodec_ReadsJSONLocations(t *testing.T) {
+	t.Parallel()
+	service, fakeRedis := setupServiceWith(t, driverloc.BinaryLocationCodec{}, driverLocationsLimit)
+	defer fakeRedis.Close()
+	// Saved before the codec was switched.
+	_, err := fakeRedis.ZAdd(defaultDriverID, float64(baseTime.UnixNano()/int64(time.Millisecond)),
+		`{"latitude":48.864193,"longitude":2.350498,"updated_at":"2020-11-07T00:00:00Z"}`)
+	require.NoError(t, err)
+	insertLocations(t, service, []*toInsert{
+		{
+			coords:       &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
+			locationTime: baseTime.Add(5 * time.Second),
+		},
+	})
+
+	service.SetTimeNowFn(func() time.Time {
+		return baseTime.Add(10 * time.Second)
+	})
+	actual, err := service.GetLocations(ctx, defaultDriverID, time.Minute)
+	require.NoError(t, err)
+
+	expected := []*driverloc.Location{
+		{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			Time:        baseTime,
+		},
+		{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
+			Time:        baseTime.Add(5 * time.Second),
+		},
+	}
+	assert.Equal(t, expected, actual)
+	members, err := fakeRedis.ZMembers(defaultDriverID)
+	require.NoError(t, err)
+	assert.Len(t, members[1], 25, "new locations are binary")
+}
+
+// BenchmarkLocationCodec reports the size of a stored location as member-bytes,
+// multiplied by the locations limit and the number of drivers it's the Redis memory taken by the members.
+func BenchmarkLocationCodec(b *testing.B) {
+	loc := &driverloc.Location{
+		Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+		Time:        time.Date(2018, 04, 05, 22, 36, 16, 123456789, time.UTC),
+	}
+	for _, c := range codecs {
+		c := c
+		b.Run(c.name+"/encode", func(b *testing.B) {
+			var data []byte
+			for i := 0; i < b.N; i++ {
+				data, _ = c.codec.Encode(loc)
+			}
+			b.ReportMetric(float64(len(data)), "member-bytes")
+		})
+		b.Run(c.name+"/decode", func(b *testing.B) {
+			data, err := c.codec.Encode(loc)
+			require.NoError(b, err)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := driverloc.DecodeLocation(data); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package driverloc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/georgysavva/driver-app/platform/envelope"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// CommandHandler processes the NSQ messages of a single command.
+type CommandHandler interface {
+	// Decode parses and validates the command payload.
+	// Messages failing it can never be processed, so they aren't retried.
+	Decode(payload json.RawMessage) (interface{}, error)
+	// Handle applies the decoded data, it's retried on failure. messageTime is when the message was produced,
+	// data stamped with it is the same on every delivery of the message.
+	Handle(ctx context.Context, payload interface{}, messageTime time.Time) error
+}
+
+// CommandRegistry routes NSQ messages to the handlers of their commands.
+type CommandRegistry struct {
+	handlers map[string]CommandHandler
+}
+
+func NewCommandRegistry() *CommandRegistry {
+	return &CommandRegistry{handlers: make(map[string]CommandHandler)}
+}
+
+// NewUpdaterCommandRegistry returns a registry with all commands changing the drivers' locations.
+func NewUpdaterCommandRegistry(service UpdaterService, logger log.FieldLogger) *CommandRegistry {
+	cr := NewCommandRegistry()
+	cr.Register(envelope.CommandUpdateDriverLocations, &updateLocationsHandler{service: service, logger: logger})
+	cr.Register(
+		envelope.CommandBatchUpdateDriverLocations, &batchUpdateLocationsHandler{service: service, logger: logger},
+	)
+	cr.Register(envelope.CommandDeleteDriverLocations, &deleteLocationsHandler{service: service, logger: logger})
+	return cr
+}
+
+// Register panics if the command already has a handler, the same way http.ServeMux does for patterns.
+func (cr *CommandRegistry) Register(command string, handler CommandHandler) {
+	if _, ok := cr.handlers[command]; ok {
+		panic(fmt.Sprintf("driverloc: multiple registrations for command %q", command))
+	}
+	cr.handlers[command] = handler
+}
+
+func (cr *CommandRegistry) lookup(command string) (CommandHandler, bool) {
+	handler, ok := cr.handlers[command]
+	return handler, ok
+}
+
+func locationCoordinates(location *envelope.DriverLocationPayload) *Coordinates {
+	return &Coordinates{Latitude: *location.Latitude, Longitude: *location.Longitude}
+}
+
+type updateLocationsHandler struct {
+	service UpdaterService
+	logger  log.FieldLogger
+}
+
+func (h *updateLocationsHandler) Decode(data json.RawMessage) (interface{}, error) {
+	location := &envelope.DriverLocationPayload{}
+	if err := envelope.DecodePayload(data, location); err != nil {
+		return nil, err
+	}
+	return location, nil
+}
+
+func (h *updateLocationsHandler) Handle(ctx context.Context, payload interface{}, messageTime time.Time) error {
+	location := payload.(*envelope.DriverLocationPayload)
+	coordinates := locationCoordinates(location)
+	h.logger.WithFields(log.Fields{
+		"driver_id":   *location.DriverID,
+		"coordinates": coordinates,
+	}).Info("Call service to update driver locations")
+	err := h.service.UpdateLocations(ctx, *location.DriverID, coordinates, messageTime)
+	return errors.Wrap(err, "failed to call service to update driver locations")
+}
+
+// batchUpdateLocationsHandler applies multiple locations, possibly of different drivers, from a single message.
+// Locations are applied all at once, so a retried batch doesn't store any of them twice.
+type batchUpdateLocationsHandler struct {
+	service UpdaterService
+	logger  log.FieldLogger
+}
+
+func (h *batchUpdateLocationsHandler) Decode(data json.RawMessage) (interface{}, error) {
+	batch := &envelope.BatchDriverLocationsPayload{}
+	if err := envelope.DecodePayload(data, batch); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+func (h *batchUpdateLocationsHandler) Handle(ctx context.Context, payload interface{}, messageTime time.Time) error {
+	batch := payload.(*envelope.BatchDriverLocationsPayload)
+	h.logger.WithField("locations_num", len(batch.Locations)).
+		Info("Call service to update a batch of driver locations")
+	locations := make([]*DriverCoordinates, len(batch.Locations))
+	for i, location := range batch.Locations {
+		locations[i] = &DriverCoordinates{DriverID: *location.DriverID, Coordinates: locationCoordinates(location)}
+	}
+	err := h.service.BatchUpdateLocations(ctx, locations, messageTime)
+	return errors.Wrap(err, "failed to call service to update a batch of driver locations")
+}
+
+type deleteLocationsHandler struct {
+	service UpdaterService
+	logger  log.FieldLogger
+}
+
+func (h *deleteLocationsHandler) Decode(data json.RawMessage) (interface{}, error) {
+	driver := &envelope.DriverPayload{}
+	if err := envelope.DecodePayload(data, driver); err != nil {
+		return nil, err
+	}
+	return driver, nil
+}
+
+func (h *deleteLocationsHandler) Handle(ctx context.Context, payload interface{}, _ time.Time) error {
+	driver := payload.(*envelope.DriverPayload)
+	h.logger.WithField("driver_id", *driver.DriverID).Info("Call service to delete driver locations")
+	err := h.service.DeleteLocations(ctx, *driver.DriverID)
+	return errors.Wrap(err, "failed to call service to delete driver locations")
+}
@@ -0,0 +1,116 @@
+package driverloc
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/nsqio/go-nsq"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type NSQProducer interface {
+	Publish(topic string, body []byte) error
+}
+
+//go:generate mockery --name NSQProducer
+
+// DeadLetter wraps a message that couldn't be processed, so it can be inspected and replayed later.
+type DeadLetter struct {
+	MessageID string    `json:"message_id"`
+	Topic     string    `json:"topic"` // The topic the message was consumed from.
+	Body      []byte    `json:"body"`  // Base64 encoded in json, Protobuf envelopes aren't valid UTF-8.
+	Attempts  uint16    `json:"attempts"`
+	Reason    string    `json:"reason"`
+	FailedAt  time.Time `json:"failed_at"`
+}
+
+type DeadLetterQueue interface {
+	Send(letter *DeadLetter) error
+}
+
+//go:generate mockery --name DeadLetterQueue
+
+// NopDeadLetterQueue is used when the dead letter queue is disabled, failed messages are only logged.
+type NopDeadLetterQueue struct{}
+
+func (NopDeadLetterQueue) Send(_ *DeadLetter) error { return nil }
+
+// NSQDeadLetterQueue publishes dead letters of messages consumed from the source topic to the dead letter topic.
+type NSQDeadLetterQueue struct {
+	producer    NSQProducer
+	topic       string
+	sourceTopic string
+}
+
+func NewNSQDeadLetterQueue(producer NSQProducer, topic, sourceTopic string) *NSQDeadLetterQueue {
+	return &NSQDeadLetterQueue{producer: producer, topic: topic, sourceTopic: sourceTopic}
+}
+
+func (q *NSQDeadLetterQueue) Send(letter *DeadLetter) error {
+	letter.Topic = q.sourceTopic
+	data, err := json.Marshal(letter)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode dead letter into json")
+	}
+	err = q.producer.Publish(q.topic, data)
+	return errors.Wrap(err, "failed to publish dead letter")
+}
+
+// RetryPolicy limits the processing attempts of a message.
+// Failed attempts are retried after a delay doubling with every attempt.
+type RetryPolicy struct {
+	MaxAttempts     int           `yaml:"max_attempts" default:"5" validate:"min=1,max=65535"`
+	RequeueDelay    time.Duration `yaml:"requeue_delay" default:"1s" validate:"min=1"`
+	MaxRequeueDelay time.Duration `yaml:"max_requeue_delay" default:"1m" validate:"min=1"`
+}
+
+func (rp *RetryPolicy) delayAfter(attempts uint16) time.Duration {
+	delay := rp.RequeueDelay
+	for i := uint16(1); i < attempts && delay < rp.MaxRequeueDelay; i++ {
+		delay *= 2
+	}
+	if delay > rp.MaxRequeueDelay {
+		return rp.MaxRequeueDelay
+	}
+	return delay
+}
+
+// DeadLetterReplayer consumes dead letters and publishes their original messages back to the topics they came from.
+type DeadLetterReplayer struct {
+	producer NSQProducer
+	logger   log.FieldLogger
+}
+
+func NewDeadLetterReplayer(producer NSQProducer, logger log.FieldLogger) *DeadLetterReplayer {
+	return &DeadLetterReplayer{producer: producer, logger: logger}
+}
+
+func (dr *DeadLetterReplayer) HandleMessage(m *nsq.Message) error {
+	ctxLogger := dr.logger.WithField("message_id", getMessageID(m))
+	letter, err := ParseDeadLetter(m.Body)
+	if err != nil {
+		ctxLogger.WithError(err).Info("Couldn't parse dead letter, drop it")
+		return nil
+	}
+	ctxLogger = ctxLogger.WithFields(log.Fields{"topic": letter.Topic, "original_message_id": letter.MessageID})
+	if err := dr.producer.Publish(letter.Topic, letter.Body); err != nil {
+		err = errors.Wrap(err, "failed to replay dead letter")
+		logging.LogUnhandledError(ctxLogger, err)
+		return err
+	}
+	ctxLogger.Info("Dead letter replayed")
+	return nil
+}
+
+func ParseDeadLetter(data []byte) (*DeadLetter, error) {
+	letter := &DeadLetter{}
+	if err := json.Unmarshal(data, letter); err != nil {
+		return nil, errors.Wrap(err, "cannot decode dead letter")
+	}
+	if letter.Topic == "" {
+		return nil, errors.New("dead letter has no topic")
+	}
+	return letter, nil
+}
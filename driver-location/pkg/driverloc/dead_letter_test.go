@@ -0,0 +1,123 @@
+package driverloc_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/georgysavva/driver-app/platform/envelope"
+	"github.com/nsqio/go-nsq"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc/mocks"
+)
+
+func TestNSQDeadLetterQueue_Send(t *testing.T) {
+	t.Parallel()
+	producerMock := &mocks.NSQProducer{}
+	producerMock.On("Publish", "locations-dead-letters", mock.Anything /* body */).Return(nil)
+	queue := driverloc.NewNSQDeadLetterQueue(producerMock, "locations-dead-letters", "locations")
+	failedAt := time.Date(2020, 12, 1, 10, 0, 0, 0, time.UTC)
+
+	err := queue.Send(&driverloc.DeadLetter{
+		MessageID: "1234",
+		Body:      []byte("foo"),
+		Attempts:  5,
+		Reason:    "bar",
+		FailedAt:  failedAt,
+	})
+	require.NoError(t, err)
+
+	require.Len(t, producerMock.Calls, 1)
+	actual, err := driverloc.ParseDeadLetter(producerMock.Calls[0].Arguments.Get(1).([]byte))
+	require.NoError(t, err)
+	expected := &driverloc.DeadLetter{
+		MessageID: "1234",
+		Topic:     "locations",
+		Body:      []byte("foo"),
+		Attempts:  5,
+		Reason:    "bar",
+		FailedAt:  failedAt,
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestDeadLetterReplayer_HandleMessage(t *testing.T) {
+	t.Parallel()
+	producerMock := &mocks.NSQProducer{}
+	producerMock.On("Publish", "locations", []byte(updateLocationsBody)).Return(nil)
+	replayer := newDeadLetterReplayer(producerMock)
+	letter, err := json.Marshal(&driverloc.DeadLetter{Topic: "locations", Body: []byte(updateLocationsBody)})
+	require.NoError(t, err)
+
+	err = replayer.HandleMessage(nsq.NewMessage(nsq.MessageID{1, 2, 3, 4}, letter))
+	require.NoError(t, err)
+
+	producerMock.AssertExpectations(t)
+}
+
+func TestDeadLetterReplayer_HandleMessage_ProtobufEnvelope(t *testing.T) {
+	t.Parallel()
+	driverID, latitude, longitude := defaultDriverID, 1.0, 2.0
+	msg, err := envelope.New(
+		envelope.CommandUpdateDriverLocations,
+		&envelope.DriverLocationPayload{DriverID: &driverID, Latitude: &latitude, Longitude: &longitude},
+		nil, /* trace */
+	)
+	require.NoError(t, err)
+	body, err := envelope.Marshal(msg, envelope.EncodingProtobuf)
+	require.NoError(t, err)
+	queueProducerMock := &mocks.NSQProducer{}
+	queueProducerMock.On("Publish", "locations-dead-letters", mock.Anything /* body */).Return(nil)
+	queue := driverloc.NewNSQDeadLetterQueue(queueProducerMock, "locations-dead-letters", "locations")
+	require.NoError(t, queue.Send(&driverloc.DeadLetter{Body: body}))
+	replayProducerMock := &mocks.NSQProducer{}
+	replayProducerMock.On("Publish", "locations", mock.Anything /* body */).Return(nil)
+	replayer := newDeadLetterReplayer(replayProducerMock)
+
+	letter := queueProducerMock.Calls[0].Arguments.Get(1).([]byte)
+	err = replayer.HandleMessage(nsq.NewMessage(nsq.MessageID{1, 2, 3, 4}, letter))
+	require.NoError(t, err)
+
+	replayed := replayProducerMock.Calls[0].Arguments.Get(1).([]byte)
+	assert.Equal(t, body, replayed)
+	actual, err := envelope.Unmarshal(replayed)
+	require.NoError(t, err)
+	assert.Equal(t, msg.ID, actual.ID)
+}
+
+func TestDeadLetterReplayer_HandleMessage_PublishError(t *testing.T) {
+	t.Parallel()
+	producerMock := &mocks.NSQProducer{}
+	producerMock.On("Publish", mock.Anything /* topic */, mock.Anything /* body */).Return(errors.New("nsqd is down"))
+	replayer := newDeadLetterReplayer(producerMock)
+	letter, err := json.Marshal(&driverloc.DeadLetter{Topic: "locations", Body: []byte(updateLocationsBody)})
+	require.NoError(t, err)
+
+	err = replayer.HandleMessage(nsq.NewMessage(nsq.MessageID{1, 2, 3, 4}, letter))
+	assert.Error(t, err)
+}
+
+func TestDeadLetterReplayer_HandleMessage_MalformedLetter(t *testing.T) {
+	t.Parallel()
+	producerMock := &mocks.NSQProducer{}
+	replayer := newDeadLetterReplayer(producerMock)
+
+	for _, body := range []string{`foo`, `{"body": "bar"}`} {
+		err := replayer.HandleMessage(nsq.NewMessage(nsq.MessageID{1, 2, 3, 4}, []byte(body)))
+		require.NoError(t, err, body)
+	}
+
+	producerMock.AssertNumberOfCalls(t, "Publish", 0)
+}
+
+func newDeadLetterReplayer(producerMock *mocks.NSQProducer) *driverloc.DeadLetterReplayer {
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	return driverloc.NewDeadLetterReplayer(producerMock, logger)
+}
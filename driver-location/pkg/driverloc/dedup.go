@@ -0,0 +1,166 @@
+package driverloc
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	redisDedupKeyPrefix = "driver-location:message:"
+
+	redisDedupInProgress = "in-progress"
+	redisDedupProcessed  = "processed"
+)
+
+// DedupStatus tells whether the message is new to the dedup store.
+type DedupStatus int
+
+const (
+	// DedupAcquired means the message is new and is marked as in progress now.
+	DedupAcquired DedupStatus = iota
+	// DedupInProgress means another delivery of the message is being processed.
+	DedupInProgress
+	// DedupProcessed means the message has already been processed.
+	DedupProcessed
+)
+
+// DedupStore remembers the ids of processed messages for a limited time, so redelivered messages are skipped.
+// Messages are marked as in progress for a short lease first and as processed once they are applied,
+// so a message whose processing is interrupted, e.g. by a crash, is processed again once the lease expires.
+type DedupStore interface {
+	// Acquire marks the message as in progress, unless it's in progress or processed already.
+	Acquire(ctx context.Context, messageID string) (DedupStatus, error)
+	// Confirm marks the message in progress as processed.
+	Confirm(ctx context.Context, messageID string) error
+	// Release unmarks the message whose processing failed, so it's processed again on redelivery.
+	Release(ctx context.Context, messageID string) error
+}
+
+//go:generate mockery --name DedupStore
+
+// NopDedupStore is used when deduplication is disabled, every message is processed.
+type NopDedupStore struct{}
+
+func (NopDedupStore) Acquire(_ context.Context, _ string) (DedupStatus, error) {
+	return DedupAcquired, nil
+}
+
+func (NopDedupStore) Confirm(_ context.Context, _ string) error { return nil }
+
+func (NopDedupStore) Release(_ context.Context, _ string) error { return nil }
+
+// MemoryDedupStore keeps message ids in process memory, so it only deduplicates messages
+// redelivered to the same service replica.
+type MemoryDedupStore struct {
+	ttl       time.Duration
+	leaseTTL  time.Duration
+	timeNowFn func() time.Time
+
+	mu        sync.Mutex
+	messages  map[string]*dedupEntry
+	nextPurge time.Time
+}
+
+type dedupEntry struct {
+	processed bool
+	expiresAt time.Time
+}
+
+func NewMemoryDedupStore(ttl, leaseTTL time.Duration) *MemoryDedupStore {
+	return &MemoryDedupStore{
+		ttl:       ttl,
+		leaseTTL:  leaseTTL,
+		timeNowFn: time.Now,
+		messages:  make(map[string]*dedupEntry),
+	}
+}
+
+func (s *MemoryDedupStore) Acquire(_ context.Context, messageID string) (DedupStatus, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.timeNowFn()
+	s.purgeExpired(now)
+	if entry, ok := s.messages[messageID]; ok && now.Before(entry.expiresAt) {
+		if entry.processed {
+			return DedupProcessed, nil
+		}
+		return DedupInProgress, nil
+	}
+	s.messages[messageID] = &dedupEntry{expiresAt: now.Add(s.leaseTTL)}
+	return DedupAcquired, nil
+}
+
+func (s *MemoryDedupStore) Confirm(_ context.Context, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages[messageID] = &dedupEntry{processed: true, expiresAt: s.timeNowFn().Add(s.ttl)}
+	return nil
+}
+
+func (s *MemoryDedupStore) Release(_ context.Context, messageID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.messages, messageID)
+	return nil
+}
+
+// purgeExpired drops expired ids at most once per lease ttl to not scan all ids on every message.
+func (s *MemoryDedupStore) purgeExpired(now time.Time) {
+	if now.Before(s.nextPurge) {
+		return
+	}
+	for messageID, entry := range s.messages {
+		if !now.Before(entry.expiresAt) {
+			delete(s.messages, messageID)
+		}
+	}
+	s.nextPurge = now.Add(s.leaseTTL)
+}
+
+// RedisDedupStore shares message ids between all service replicas via SET NX, Redis expires them after ttl.
+type RedisDedupStore struct {
+	redis    *redis.Client
+	ttl      time.Duration
+	leaseTTL time.Duration
+}
+
+func NewRedisDedupStore(r *redis.Client, ttl, leaseTTL time.Duration) *RedisDedupStore {
+	return &RedisDedupStore{redis: r, ttl: ttl, leaseTTL: leaseTTL}
+}
+
+func (s *RedisDedupStore) Acquire(ctx context.Context, messageID string) (DedupStatus, error) {
+	key := redisDedupKeyPrefix + messageID
+	acquired, err := s.redis.SetNX(ctx, key, redisDedupInProgress, s.leaseTTL).Result()
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to mark message as in progress in Redis")
+	}
+	if acquired {
+		return DedupAcquired, nil
+	}
+	status, err := s.redis.Get(ctx, key).Result()
+	if err == redis.Nil {
+		// The mark has just expired, the message is acquired on its next delivery.
+		return DedupInProgress, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get message mark from Redis")
+	}
+	if status == redisDedupProcessed {
+		return DedupProcessed, nil
+	}
+	return DedupInProgress, nil
+}
+
+func (s *RedisDedupStore) Confirm(ctx context.Context, messageID string) error {
+	err := s.redis.Set(ctx, redisDedupKeyPrefix+messageID, redisDedupProcessed, s.ttl).Err()
+	return errors.Wrap(err, "failed to mark message as processed in Redis")
+}
+
+func (s *RedisDedupStore) Release(ctx context.Context, messageID string) error {
+	err := s.redis.Del(ctx, redisDedupKeyPrefix+messageID).Err()
+	return errors.Wrap(err, "failed to unmark message in Redis")
+}
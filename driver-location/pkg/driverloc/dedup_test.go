@@ -0,0 +1,66 @@
+package driverloc_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+)
+
+func TestMemoryDedupStore(t *testing.T) {
+	t.Parallel()
+	store := driverloc.NewMemoryDedupStore(time.Minute, 10*time.Second)
+	store.SetTimeNowFn(func() time.Time { return baseTime })
+
+	testDedupStore(t, store, func(d time.Duration) {
+		store.SetTimeNowFn(func() time.Time { return baseTime.Add(d) })
+	})
+}
+
+func TestRedisDedupStore(t *testing.T) {
+	t.Parallel()
+	fakeRedis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer fakeRedis.Close()
+	redisClient := redis.NewClient(&redis.Options{Addr: fakeRedis.Addr()})
+	store := driverloc.NewRedisDedupStore(redisClient, time.Minute, 10*time.Second)
+
+	testDedupStore(t, store, fakeRedis.FastForward)
+}
+
+// testDedupStore checks a store with a one minute ttl and a ten seconds lease,
+// fastForward moves the store time forward.
+func testDedupStore(t *testing.T, store driverloc.DedupStore, fastForward func(d time.Duration)) {
+	t.Helper()
+	ctx := context.Background()
+	assertAcquire := func(messageID string, expected driverloc.DedupStatus, msg string) {
+		t.Helper()
+		status, err := store.Acquire(ctx, messageID)
+		require.NoError(t, err)
+		assert.Equal(t, expected, status, msg)
+	}
+
+	assertAcquire("foo", driverloc.DedupAcquired, "first delivery")
+	assertAcquire("foo", driverloc.DedupInProgress, "redelivery while in progress")
+	assertAcquire("bar", driverloc.DedupAcquired, "another message")
+
+	require.NoError(t, store.Release(ctx, "foo"))
+	assertAcquire("foo", driverloc.DedupAcquired, "redelivery after release")
+
+	require.NoError(t, store.Confirm(ctx, "bar"))
+	assertAcquire("bar", driverloc.DedupProcessed, "redelivery after confirm")
+
+	// foo is never confirmed, as if the process crashed while handling it.
+	fastForward(10 * time.Second)
+	assertAcquire("foo", driverloc.DedupAcquired, "redelivery after lease")
+	assertAcquire("bar", driverloc.DedupProcessed, "redelivery after lease of processed message")
+
+	fastForward(time.Minute)
+	assertAcquire("bar", driverloc.DedupAcquired, "redelivery after ttl")
+}
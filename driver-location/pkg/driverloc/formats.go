@@ -0,0 +1,350 @@
+package driverloc
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Formats the locations endpoints can return, selected by the "format" query param or the Accept header.
+const (
+	FormatJSON = "json"
+	// FormatGeoJSON is a LocationsFeatureCollection.
+	FormatGeoJSON = "geojson"
+	// FormatLineString is a TrackFeature.
+	FormatLineString = "linestring"
+	// FormatPolyline is a PolylineTrack.
+	FormatPolyline = "polyline"
+)
+
+const (
+	MediaTypeJSON     = "application/json"
+	MediaTypeGeoJSON  = "application/geo+json"
+	MediaTypePolyline = "application/vnd.polyline+json"
+)
+
+const polylinePrecision = 1e5
+
+var ErrNotAcceptable = errors.New("none of the accepted media types is supported")
+
+// FormatMediaType returns the Content-Type of the format.
+func FormatMediaType(format string) string {
+	switch format {
+	case FormatGeoJSON, FormatLineString:
+		return MediaTypeGeoJSON
+	case FormatPolyline:
+		return MediaTypePolyline
+	default:
+		return MediaTypeJSON
+	}
+}
+
+// NegotiateFormat picks the format from the "format" query param value if it's set,
+// or from the first supported media type in the Accept header otherwise. Quality values are ignored.
+// It returns ErrNotAcceptable if the Accept header lists no supported media type.
+func NegotiateFormat(formatParam, acceptHeader string) (string, error) {
+	if formatParam != "" {
+		switch formatParam {
+		case FormatJSON, FormatGeoJSON, FormatLineString, FormatPolyline:
+			return formatParam, nil
+		default:
+			return "", errors.Errorf("'format' query param must be one of %s, %s, %s, %s",
+				FormatJSON, FormatGeoJSON, FormatLineString, FormatPolyline)
+		}
+	}
+	if strings.TrimSpace(acceptHeader) == "" {
+		return FormatJSON, nil
+	}
+	for _, mediaRange := range strings.Split(acceptHeader, ",") {
+		mediaType := strings.ToLower(strings.TrimSpace(strings.SplitN(mediaRange, ";", 2)[0]))
+		switch mediaType {
+		case MediaTypeJSON, "application/*", "*/*":
+			return FormatJSON, nil
+		case MediaTypeGeoJSON:
+			return FormatGeoJSON, nil
+		case MediaTypePolyline:
+			return FormatPolyline, nil
+		}
+	}
+	return "", errors.WithStack(ErrNotAcceptable)
+}
+
+// FormatLocations converts the locations of a driver into the format, oldest locations must go first.
+func FormatLocations(locations []*Location, format string) interface{} {
+	switch format {
+	case FormatGeoJSON:
+		return NewLocationsFeatureCollection(locations)
+	case FormatLineString:
+		return NewTrackFeature(locations)
+	case FormatPolyline:
+		return NewPolylineTrack(locations)
+	default:
+		return locations
+	}
+}
+
+// GeoJSON positions are longitude first.
+type Position [2]float64
+
+func newPosition(c *Coordinates) Position {
+	return Position{c.Longitude, c.Latitude}
+}
+
+func (p Position) coordinates() *Coordinates {
+	return &Coordinates{Latitude: p[1], Longitude: p[0]}
+}
+
+// LocationsFeatureCollection is a GeoJSON FeatureCollection with a Point feature per location.
+type LocationsFeatureCollection struct {
+	Type     string             `json:"type"`
+	Features []*LocationFeature `json:"features"`
+}
+
+func NewLocationsFeatureCollection(locations []*Location) *LocationsFeatureCollection {
+	fc := &LocationsFeatureCollection{Type: "FeatureCollection", Features: make([]*LocationFeature, len(locations))}
+	for i, loc := range locations {
+		fc.Features[i] = NewLocationFeature("" /* driverID */, loc)
+	}
+	return fc
+}
+
+// Locations converts the features back into locations.
+func (fc *LocationsFeatureCollection) Locations() ([]*Location, error) {
+	locations := make([]*Location, len(fc.Features))
+	for i, feature := range fc.Features {
+		if feature.Geometry == nil || feature.Properties == nil {
+			return nil, errors.Errorf("features.%d: 'geometry' and 'properties' fields must be set", i)
+		}
+		locations[i] = &Location{Coordinates: feature.Geometry.Coordinates.coordinates(), Time: feature.Properties.UpdatedAt}
+	}
+	return locations, nil
+}
+
+type LocationFeature struct {
+	Type       string              `json:"type"`
+	Geometry   *PointGeometry      `json:"geometry"`
+	Properties *LocationProperties `json:"properties"`
+}
+
+// NewLocationFeature returns a Point feature of the location, driverID is omitted if it's empty.
+func NewLocationFeature(driverID string, loc *Location) *LocationFeature {
+	return &LocationFeature{
+		Type:       "Feature",
+		Geometry:   &PointGeometry{Type: "Point", Coordinates: newPosition(loc.Coordinates)},
+		Properties: &LocationProperties{DriverID: driverID, UpdatedAt: loc.Time},
+	}
+}
+
+type PointGeometry struct {
+	Type        string   `json:"type"`
+	Coordinates Position `json:"coordinates"`
+}
+
+type LocationProperties struct {
+	DriverID  string    `json:"driver_id,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TrackFeature is a GeoJSON Feature drawing the locations as a single track.
+// Its geometry is a LineString, but a Point for a single location and null for no locations,
+// since a LineString must have at least two positions. Times hold the time of every position.
+type TrackFeature struct {
+	Type       string           `json:"type"`
+	Geometry   *TrackGeometry   `json:"geometry"`
+	Properties *TrackProperties `json:"properties"`
+}
+
+func NewTrackFeature(locations []*Location) *TrackFeature {
+	f := &TrackFeature{Type: "Feature", Properties: &TrackProperties{Times: make([]time.Time, len(locations))}}
+	positions := make([]Position, len(locations))
+	for i, loc := range locations {
+		positions[i] = newPosition(loc.Coordinates)
+		f.Properties.Times[i] = loc.Time
+	}
+	switch len(locations) {
+	case 0:
+	case 1:
+		f.Geometry = &TrackGeometry{Type: "Point", Positions: positions}
+	default:
+		f.Geometry = &TrackGeometry{Type: "LineString", Positions: positions}
+	}
+	return f
+}
+
+// Locations converts the track back into locations.
+func (f *TrackFeature) Locations() ([]*Location, error) {
+	if f.Properties == nil {
+		return nil, errors.New("'properties' field must be set")
+	}
+	var positions []Position
+	if f.Geometry != nil {
+		positions = f.Geometry.Positions
+	}
+	if len(positions) != len(f.Properties.Times) {
+		return nil, errors.Errorf("track has %d positions, but %d times", len(positions), len(f.Properties.Times))
+	}
+	locations := make([]*Location, len(positions))
+	for i, position := range positions {
+		locations[i] = &Location{Coordinates: position.coordinates(), Time: f.Properties.Times[i]}
+	}
+	return locations, nil
+}
+
+// TrackGeometry is either a Point geometry with a single position or a LineString geometry.
+type TrackGeometry struct {
+	Type      string
+	Positions []Position
+}
+
+type trackGeometryJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+}
+
+func (g *TrackGeometry) MarshalJSON() ([]byte, error) {
+	var coordinates interface{} = g.Positions
+	if g.Type == "Point" && len(g.Positions) == 1 {
+		coordinates = g.Positions[0]
+	}
+	coordinatesData, err := json.Marshal(coordinates)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode track geometry coordinates")
+	}
+	data, err := json.Marshal(&trackGeometryJSON{Type: g.Type, Coordinates: coordinatesData})
+	return data, errors.Wrap(err, "failed to encode track geometry")
+}
+
+func (g *TrackGeometry) UnmarshalJSON(data []byte) error {
+	raw := &trackGeometryJSON{}
+	if err := json.Unmarshal(data, raw); err != nil {
+		return errors.Wrap(err, "cannot decode track geometry")
+	}
+	g.Type = raw.Type
+	switch raw.Type {
+	case "Point":
+		var position Position
+		if err := json.Unmarshal(raw.Coordinates, &position); err != nil {
+			return errors.Wrap(err, "cannot decode point coordinates")
+		}
+		g.Positions = []Position{position}
+	case "LineString":
+		if err := json.Unmarshal(raw.Coordinates, &g.Positions); err != nil {
+			return errors.Wrap(err, "cannot decode line string coordinates")
+		}
+	default:
+		return errors.Errorf("unsupported track geometry type %q", raw.Type)
+	}
+	return nil
+}
+
+type TrackProperties struct {
+	Times []time.Time `json:"times"`
+}
+
+// PolylineTrack is the locations as a Google encoded polyline, with a precision of 5 decimal places,
+// and the time of every point.
+type PolylineTrack struct {
+	Polyline string      `json:"polyline"`
+	Times    []time.Time `json:"times"`
+}
+
+func NewPolylineTrack(locations []*Location) *PolylineTrack {
+	coordinates := make([]*Coordinates, len(locations))
+	times := make([]time.Time, len(locations))
+	for i, loc := range locations {
+		coordinates[i] = loc.Coordinates
+		times[i] = loc.Time
+	}
+	return &PolylineTrack{Polyline: EncodePolyline(coordinates), Times: times}
+}
+
+// Locations converts the track back into locations.
+func (p *PolylineTrack) Locations() ([]*Location, error) {
+	coordinates, err := DecodePolyline(p.Polyline)
+	if err != nil {
+		return nil, err
+	}
+	if len(coordinates) != len(p.Times) {
+		return nil, errors.Errorf("polyline has %d points, but %d times", len(coordinates), len(p.Times))
+	}
+	locations := make([]*Location, len(coordinates))
+	for i, c := range coordinates {
+		locations[i] = &Location{Coordinates: c, Time: p.Times[i]}
+	}
+	return locations, nil
+}
+
+// EncodePolyline encodes the coordinates with the Google encoded polyline algorithm.
+func EncodePolyline(coordinates []*Coordinates) string {
+	var sb strings.Builder
+	var prevLatitude, prevLongitude int64
+	for _, c := range coordinates {
+		latitude := int64(math.Round(c.Latitude * polylinePrecision))
+		longitude := int64(math.Round(c.Longitude * polylinePrecision))
+		encodePolylineValue(&sb, latitude-prevLatitude)
+		encodePolylineValue(&sb, longitude-prevLongitude)
+		prevLatitude, prevLongitude = latitude, longitude
+	}
+	return sb.String()
+}
+
+func encodePolylineValue(sb *strings.Builder, value int64) {
+	shifted := value << 1
+	if value < 0 {
+		shifted = ^shifted
+	}
+	for shifted >= 0x20 {
+		sb.WriteByte(byte((0x20 | (shifted & 0x1f)) + 63))
+		shifted >>= 5
+	}
+	sb.WriteByte(byte(shifted + 63))
+}
+
+// DecodePolyline decodes coordinates encoded with the Google encoded polyline algorithm.
+func DecodePolyline(polyline string) ([]*Coordinates, error) {
+	var coordinates []*Coordinates
+	var latitude, longitude int64
+	for i := 0; i < len(polyline); {
+		latitudeDelta, n, err := decodePolylineValue(polyline[i:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "polyline position %d", i)
+		}
+		i += n
+		longitudeDelta, n, err := decodePolylineValue(polyline[i:])
+		if err != nil {
+			return nil, errors.Wrapf(err, "polyline position %d", i)
+		}
+		i += n
+		latitude += latitudeDelta
+		longitude += longitudeDelta
+		coordinates = append(coordinates, &Coordinates{
+			Latitude:  float64(latitude) / polylinePrecision,
+			Longitude: float64(longitude) / polylinePrecision,
+		})
+	}
+	return coordinates, nil
+}
+
+// decodePolylineValue returns the value at the beginning of the data and the number of bytes it takes.
+func decodePolylineValue(data string) (int64, int, error) {
+	var result int64
+	var shift uint
+	for i := 0; i < len(data); i++ {
+		b := int64(data[i]) - 63
+		if b < 0 || b > 0x3f || shift > 60 {
+			return 0, 0, errors.New("polyline is malformed")
+		}
+		result |= (b & 0x1f) << shift
+		shift += 5
+		if b < 0x20 {
+			if result&1 != 0 {
+				return ^(result >> 1), i + 1, nil
+			}
+			return result >> 1, i + 1, nil
+		}
+	}
+	return 0, 0, errors.New("polyline is truncated")
+}
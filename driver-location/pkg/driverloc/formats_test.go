@@ -0,0 +1,174 @@
+package driverloc_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+)
+
+func TestNegotiateFormat(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name            string
+		formatParam     string
+		acceptHeader    string
+		expected        string
+		expectedErr     string
+		isNotAcceptable bool
+	}{
+		{name: "defaults to json", expected: driverloc.FormatJSON},
+		{name: "format param", formatParam: "linestring", expected: driverloc.FormatLineString},
+		{
+			name:         "format param takes precedence over accept header",
+			formatParam:  "polyline",
+			acceptHeader: "application/geo+json",
+			expected:     driverloc.FormatPolyline,
+		},
+		{name: "geojson media type", acceptHeader: "application/geo+json", expected: driverloc.FormatGeoJSON},
+		{
+			name:         "first supported media type",
+			acceptHeader: "text/html, application/vnd.polyline+json;q=0.9, application/json;q=0.8",
+			expected:     driverloc.FormatPolyline,
+		},
+		{name: "wildcard", acceptHeader: "text/html, */*;q=0.1", expected: driverloc.FormatJSON},
+		{
+			name:        "unknown format param",
+			formatParam: "kml",
+			expectedErr: "'format' query param must be one of json, geojson, linestring, polyline",
+		},
+		{name: "unsupported media types", acceptHeader: "text/html, text/csv", isNotAcceptable: true},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := driverloc.NegotiateFormat(tc.formatParam, tc.acceptHeader)
+			switch {
+			case tc.isNotAcceptable:
+				assert.True(t, errors.Is(err, driverloc.ErrNotAcceptable))
+			case tc.expectedErr != "":
+				assert.EqualError(t, err, tc.expectedErr)
+			default:
+				require.NoError(t, err)
+				assert.Equal(t, tc.expected, actual)
+			}
+		})
+	}
+}
+
+func TestEncodePolyline(t *testing.T) {
+	t.Parallel()
+	// The example of the polyline algorithm documentation.
+	coordinates := []*driverloc.Coordinates{
+		{Latitude: 38.5, Longitude: -120.2},
+		{Latitude: 40.7, Longitude: -120.95},
+		{Latitude: 43.252, Longitude: -126.453},
+	}
+	expected := "_p~iF~ps|U_ulLnnqC_mqNvxq`@"
+
+	actual := driverloc.EncodePolyline(coordinates)
+	assert.Equal(t, expected, actual)
+
+	decoded, err := driverloc.DecodePolyline(actual)
+	require.NoError(t, err)
+	assert.Equal(t, coordinates, decoded)
+}
+
+func TestDecodePolyline_Malformed(t *testing.T) {
+	t.Parallel()
+	for _, polyline := range []string{"_p~iF~ps|", "_p~iF", "_p~iF ps|U"} {
+		_, err := driverloc.DecodePolyline(polyline)
+		assert.Error(t, err, polyline)
+	}
+}
+
+func TestFormatLocations(t *testing.T) {
+	t.Parallel()
+	locations := []*driverloc.Location{
+		{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.86419, Longitude: 2.3505},
+			Time:        time.Date(2018, 04, 05, 22, 36, 16, 00, time.UTC),
+		},
+		{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.86392, Longitude: 2.34921},
+			Time:        time.Date(2018, 04, 05, 22, 36, 21, 00, time.UTC),
+		},
+	}
+	cases := []struct {
+		format    string
+		locations []*driverloc.Location
+		expected  string
+		decoded   interface {
+			Locations() ([]*driverloc.Location, error)
+		}
+	}{
+		{
+			format:    driverloc.FormatGeoJSON,
+			locations: locations,
+			expected: `
+			{
+				"type": "FeatureCollection",
+				"features": [{
+					"type": "Feature",
+					"geometry": {"type": "Point", "coordinates": [2.3505, 48.86419]},
+					"properties": {"updated_at": "2018-04-05T22:36:16Z"}
+				}, {
+					"type": "Feature",
+					"geometry": {"type": "Point", "coordinates": [2.34921, 48.86392]},
+					"properties": {"updated_at": "2018-04-05T22:36:21Z"}
+				}]
+			}`,
+			decoded: &driverloc.LocationsFeatureCollection{},
+		},
+		{
+			format:    driverloc.FormatLineString,
+			locations: locations,
+			expected: `
+			{
+				"type": "Feature",
+				"geometry": {"type": "LineString", "coordinates": [[2.3505, 48.86419], [2.34921, 48.86392]]},
+				"properties": {"times": ["2018-04-05T22:36:16Z", "2018-04-05T22:36:21Z"]}
+			}`,
+			decoded: &driverloc.TrackFeature{},
+		},
+		{
+			format:    driverloc.FormatLineString,
+			locations: locations[:1],
+			expected: `
+			{
+				"type": "Feature",
+				"geometry": {"type": "Point", "coordinates": [2.3505, 48.86419]},
+				"properties": {"times": ["2018-04-05T22:36:16Z"]}
+			}`,
+			decoded: &driverloc.TrackFeature{},
+		},
+		{
+			format:    driverloc.FormatLineString,
+			locations: []*driverloc.Location{},
+			expected:  `{"type": "Feature", "geometry": null, "properties": {"times": []}}`,
+			decoded:   &driverloc.TrackFeature{},
+		},
+		{
+			format:    driverloc.FormatPolyline,
+			locations: locations,
+			expected:  "{\"polyline\": \"exfiHsajMt@`G\", \"times\": [\"2018-04-05T22:36:16Z\", \"2018-04-05T22:36:21Z\"]}",
+			decoded:   &driverloc.PolylineTrack{},
+		},
+	}
+	for _, tc := range cases {
+		data, err := json.Marshal(driverloc.FormatLocations(tc.locations, tc.format))
+		require.NoError(t, err)
+		assert.JSONEq(t, tc.expected, string(data), tc.format)
+
+		require.NoError(t, json.Unmarshal(data, tc.decoded))
+		actual, err := tc.decoded.Locations()
+		require.NoError(t, err)
+		assert.Equal(t, tc.locations, actual, tc.format)
+	}
+}
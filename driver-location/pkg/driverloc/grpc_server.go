@@ -0,0 +1,200 @@
+package driverloc
+
+import (
+	"context"
+	"time"
+
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverlocpb"
+)
+
+// maxBatchSize bounds the number of drivers requested by a single batch call.
+const maxBatchSize = 100
+
+// GRPCServer exposes the locations and the real-time stream over gRPC, alongside the HTTP API.
+type GRPCServer struct {
+	driverlocpb.UnimplementedDriverLocationServer
+	service GetterService
+	hub     *Hub
+	logger  log.FieldLogger
+}
+
+func NewGRPCServer(service GetterService, hub *Hub, logger log.FieldLogger) *GRPCServer {
+	return &GRPCServer{service: service, hub: hub, logger: logger}
+}
+
+// Register registers the server on the gRPC server.
+func (gs *GRPCServer) Register(server *grpc.Server) {
+	driverlocpb.RegisterDriverLocationServer(server, gs)
+}
+
+func (gs *GRPCServer) GetLocations(ctx context.Context, req *driverlocpb.GetLocationsRequest) (
+	*driverlocpb.GetLocationsResponse, error) {
+	ctxLogger := gs.logger.WithField("driver_id", req.DriverId)
+	timeInterval, err := windowFromProto(req.Window)
+	if err != nil {
+		ctxLogger.WithError(err).Info("Request is invalid, return InvalidArgument")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ctxLogger.WithField("time_interval", timeInterval).Info("Request driver locations from the service")
+	locations, err := gs.service.GetLocations(ctx, req.DriverId, timeInterval)
+	if err != nil {
+		return nil, gs.internalError(ctxLogger, errors.Wrap(err, "failed to request locations from the service"))
+	}
+	return &driverlocpb.GetLocationsResponse{Locations: locationsToProto(locations)}, nil
+}
+
+// BatchGetLocations requests the locations of all drivers concurrently and fails if any of the requests fails.
+func (gs *GRPCServer) BatchGetLocations(ctx context.Context, req *driverlocpb.BatchGetLocationsRequest) (
+	*driverlocpb.BatchGetLocationsResponse, error) {
+	timeInterval, err := windowFromProto(req.Window)
+	if err == nil && len(req.DriverIds) > maxBatchSize {
+		err = errors.Errorf("driver_ids must not contain more than %d ids", maxBatchSize)
+	}
+	if err != nil {
+		gs.logger.WithError(err).Info("Request is invalid, return InvalidArgument")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	gs.logger.WithFields(log.Fields{
+		"drivers_num":   len(req.DriverIds),
+		"time_interval": timeInterval,
+	}).Info("Request locations of the drivers from the service")
+	drivers := make([]*driverlocpb.DriverLocations, len(req.DriverIds))
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, driverID := range req.DriverIds {
+		i, driverID := i, driverID
+		group.Go(func() error {
+			locations, err := gs.service.GetLocations(groupCtx, driverID, timeInterval)
+			if err != nil {
+				return errors.Wrapf(err, "failed to request locations of driver %s from the service", driverID)
+			}
+			drivers[i] = &driverlocpb.DriverLocations{DriverId: driverID, Locations: locationsToProto(locations)}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, gs.internalError(gs.logger, err)
+	}
+	return &driverlocpb.BatchGetLocationsResponse{Drivers: drivers}, nil
+}
+
+func (gs *GRPCServer) GetStatus(ctx context.Context, req *driverlocpb.GetStatusRequest) (
+	*driverlocpb.DriverStatus, error) {
+	ctxLogger := gs.logger.WithField("driver_id", req.DriverId)
+
+	ctxLogger.Info("Request driver status from the service")
+	driverStatus, err := gs.service.GetStatus(ctx, req.DriverId)
+	if err != nil {
+		return nil, gs.internalError(ctxLogger, errors.Wrap(err, "failed to request status from the service"))
+	}
+	result := &driverlocpb.DriverStatus{Status: driverStatus.Status}
+	if driverStatus.LastSeenAt != nil {
+		result.LastSeenAt = timestamppb.New(*driverStatus.LastSeenAt)
+	}
+	return result, nil
+}
+
+// StreamLocations sends the headers once subscribed, so clients know that no later update is missed.
+// The stream ends with Unavailable when the subscription ends: the client fell behind or the hub was closed.
+func (gs *GRPCServer) StreamLocations(
+	req *driverlocpb.StreamLocationsRequest, stream driverlocpb.DriverLocation_StreamLocationsServer,
+) error {
+	filter := &SubscriptionFilter{DriverIDs: req.DriverIds}
+	if bbox := req.Bbox; bbox != nil {
+		filter.BoundingBox = &BoundingBox{
+			MinLatitude:  bbox.MinLatitude,
+			MinLongitude: bbox.MinLongitude,
+			MaxLatitude:  bbox.MaxLatitude,
+			MaxLongitude: bbox.MaxLongitude,
+		}
+	}
+	if err := filter.Validate(); err != nil {
+		gs.logger.WithError(err).Info("Subscription filter is invalid, return InvalidArgument")
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	sub, err := gs.hub.Subscribe(filter)
+	if err != nil {
+		if errors.Is(err, ErrHubClosed) {
+			return status.Error(codes.Unavailable, err.Error())
+		}
+		return gs.internalError(gs.logger, errors.Wrap(err, "failed to subscribe to the hub"))
+	}
+	defer sub.Close()
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		gs.logger.WithError(err).Info("Couldn't send gRPC stream headers, close it")
+		return errors.WithStack(err)
+	}
+
+	for {
+		select {
+		case update, ok := <-sub.Updates():
+			if !ok {
+				gs.logger.Info("Subscription ended, close gRPC stream")
+				return status.Error(codes.Unavailable, "subscription ended")
+			}
+			if err := stream.Send(locationUpdateToProto(update)); err != nil {
+				gs.logger.WithError(err).Info("Couldn't send into gRPC stream, close it")
+				return errors.WithStack(err)
+			}
+		case <-stream.Context().Done():
+			gs.logger.Info("Client canceled gRPC stream")
+			return nil
+		}
+	}
+}
+
+func (gs *GRPCServer) internalError(logger log.FieldLogger, err error) error {
+	logging.LogUnhandledError(logger, err)
+	return status.Error(codes.Internal, "internal server error")
+}
+
+// windowFromProto validates the window like the "window" query param of the HTTP API.
+func windowFromProto(window *durationpb.Duration) (time.Duration, error) {
+	if window == nil {
+		return 0, errors.New("window is missing")
+	}
+	if err := window.CheckValid(); err != nil {
+		return 0, errors.Wrap(err, "window is invalid")
+	}
+	timeInterval := window.AsDuration()
+	if timeInterval <= 0 {
+		return 0, errors.New("window must be positive")
+	}
+	if timeInterval > MaxTimeWindow {
+		return 0, errors.Errorf("window must not exceed %s", MaxTimeWindow)
+	}
+	return timeInterval, nil
+}
+
+func locationsToProto(locations []*Location) []*driverlocpb.Location {
+	result := make([]*driverlocpb.Location, len(locations))
+	for i, location := range locations {
+		result[i] = &driverlocpb.Location{
+			Latitude:  location.Latitude,
+			Longitude: location.Longitude,
+			UpdatedAt: timestamppb.New(location.Time),
+		}
+	}
+	return result
+}
+
+func locationUpdateToProto(update *LocationUpdate) *driverlocpb.LocationUpdate {
+	return &driverlocpb.LocationUpdate{
+		DriverId:  update.DriverID,
+		Latitude:  update.Latitude,
+		Longitude: update.Longitude,
+		UpdatedAt: timestamppb.New(update.Time),
+	}
+}
@@ -0,0 +1,197 @@
+package driverloc_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc/mocks"
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverlocpb"
+)
+
+func TestGRPC_GetLocations_InvalidWindow(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		window *durationpb.Duration
+	}{
+		{name: "missing", window: nil},
+		{name: "zero", window: durationpb.New(0)},
+		{name: "negative", window: durationpb.New(-time.Minute)},
+		{name: "too big", window: durationpb.New(25 * time.Hour)},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			client, serviceMock, _ := setupGRPCServer(t)
+
+			_, err := client.GetLocations(context.Background(), &driverlocpb.GetLocationsRequest{
+				DriverId: defaultDriverID, Window: tc.window,
+			})
+
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			serviceMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGRPC_GetLocations_ServiceFails(t *testing.T) {
+	t.Parallel()
+	client, serviceMock, _ := setupGRPCServer(t)
+	serviceMock.On(
+		"GetLocations",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+		defaultDriverID, 5*time.Minute,
+	).Return(nil, errors.New("redis is down"))
+
+	_, err := client.GetLocations(context.Background(), &driverlocpb.GetLocationsRequest{
+		DriverId: defaultDriverID, Window: durationpb.New(5 * time.Minute),
+	})
+
+	assert.Equal(t, codes.Internal, status.Code(err))
+	serviceMock.AssertExpectations(t)
+}
+
+func TestGRPC_BatchGetLocations(t *testing.T) {
+	t.Parallel()
+	client, serviceMock, _ := setupGRPCServer(t)
+	for _, driverID := range []string{defaultDriverID, "bar"} {
+		serviceMock.On(
+			"GetLocations",
+			mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+			driverID, 5*time.Minute,
+		).Return([]*driverloc.Location{{Coordinates: insideParis, Time: baseTime}}, nil)
+	}
+
+	actual, err := client.BatchGetLocations(context.Background(), &driverlocpb.BatchGetLocationsRequest{
+		DriverIds: []string{defaultDriverID, "bar"}, Window: durationpb.New(5 * time.Minute),
+	})
+	require.NoError(t, err)
+
+	require.Len(t, actual.Drivers, 2)
+	for i, driverID := range []string{defaultDriverID, "bar"} {
+		driver := actual.Drivers[i]
+		assert.Equal(t, driverID, driver.DriverId)
+		require.Len(t, driver.Locations, 1)
+		assert.Equal(t, insideParis.Latitude, driver.Locations[0].Latitude)
+		assert.Equal(t, insideParis.Longitude, driver.Locations[0].Longitude)
+		assert.Equal(t, baseTime, driver.Locations[0].UpdatedAt.AsTime())
+	}
+	serviceMock.AssertExpectations(t)
+}
+
+func TestGRPC_BatchGetLocations_TooManyDrivers(t *testing.T) {
+	t.Parallel()
+	client, serviceMock, _ := setupGRPCServer(t)
+
+	_, err := client.BatchGetLocations(context.Background(), &driverlocpb.BatchGetLocationsRequest{
+		DriverIds: make([]string, 101), Window: durationpb.New(5 * time.Minute),
+	})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	serviceMock.AssertExpectations(t)
+}
+
+func TestGRPC_GetStatus_NeverSeen(t *testing.T) {
+	t.Parallel()
+	client, serviceMock, _ := setupGRPCServer(t)
+	serviceMock.On(
+		"GetStatus",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+		defaultDriverID,
+	).Return(&driverloc.DriverStatus{Status: driverloc.DriverStatusNeverSeen}, nil)
+
+	actual, err := client.GetStatus(context.Background(), &driverlocpb.GetStatusRequest{DriverId: defaultDriverID})
+	require.NoError(t, err)
+
+	assert.Equal(t, driverloc.DriverStatusNeverSeen, actual.Status)
+	assert.Nil(t, actual.LastSeenAt)
+}
+
+func TestGRPC_StreamLocations(t *testing.T) {
+	t.Parallel()
+	client, _, hub := setupGRPCServer(t)
+	stream, err := client.StreamLocations(context.Background(), &driverlocpb.StreamLocationsRequest{
+		DriverIds: []string{defaultDriverID},
+	})
+	require.NoError(t, err)
+	// The headers are sent once subscribed.
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	hub.Publish(newLocationUpdate("bar", insideParis))
+	hub.Publish(newLocationUpdate(defaultDriverID, insideParis))
+	actual, err := stream.Recv()
+	require.NoError(t, err)
+
+	assert.Equal(t, defaultDriverID, actual.DriverId)
+	assert.Equal(t, insideParis.Latitude, actual.Latitude)
+	assert.Equal(t, insideParis.Longitude, actual.Longitude)
+	assert.Equal(t, baseTime, actual.UpdatedAt.AsTime())
+}
+
+func TestGRPC_StreamLocations_StreamEndsWhenHubCloses(t *testing.T) {
+	t.Parallel()
+	client, _, hub := setupGRPCServer(t)
+	stream, err := client.StreamLocations(context.Background(), &driverlocpb.StreamLocationsRequest{
+		Bbox: &driverlocpb.BoundingBox{
+			MinLatitude: 48.815573, MinLongitude: 2.224199, MaxLatitude: 48.902145, MaxLongitude: 2.469920,
+		},
+	})
+	require.NoError(t, err)
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	hub.Close()
+	_, err = stream.Recv()
+
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestGRPC_StreamLocations_InvalidFilter(t *testing.T) {
+	t.Parallel()
+	client, _, _ := setupGRPCServer(t)
+	stream, err := client.StreamLocations(context.Background(), &driverlocpb.StreamLocationsRequest{})
+	require.NoError(t, err)
+
+	_, err = stream.Recv()
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func setupGRPCServer(t *testing.T) (driverlocpb.DriverLocationClient, *mocks.GetterService, *driverloc.Hub) {
+	logger := log.New()
+	logger.Level = log.ErrorLevel
+	serviceMock := &mocks.GetterService{}
+	hub := driverloc.NewHub(logger, 16 /* bufferSize */)
+	server := grpc.NewServer()
+	driverloc.NewGRPCServer(serviceMock, hub, logger).Register(server)
+	listener := bufconn.Listen(1024 * 1024)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(
+		"bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return driverlocpb.NewDriverLocationClient(conn), serviceMock, hub
+}
@@ -0,0 +1,160 @@
+package driverloc
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	DefaultHistoryLimit  = 100
+	MaxHistoryLimit      = 1000
+	historyScanChunkSize = 500
+)
+
+var ErrInvalidCursor = errors.New("cursor is invalid")
+
+type HistoryService interface {
+	QueryHistory(ctx context.Context, driverID string, query *HistoryQuery) (*HistoryPage, error)
+}
+
+// ReaderService is everything the HTTP API reads from the service.
+type ReaderService interface {
+	GetterService
+	HistoryService
+}
+
+//go:generate mockery --name ReaderService
+
+// HistoryQuery selects driver locations updated within [From, To).
+type HistoryQuery struct {
+	From time.Time
+	// To defaults to the current time.
+	To time.Time
+	// Limit is the maximum number of locations in the page, DefaultHistoryLimit if not positive.
+	Limit int
+	// Cursor is the NextCursor of the previous page, the query params must be the same for all pages.
+	Cursor string
+	// Downsample keeps only the first location of every Downsample long interval starting from From.
+	// Zero keeps all locations.
+	Downsample time.Duration
+}
+
+type HistoryPage struct {
+	Locations []*Location `json:"locations"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// historyCursor is a position in the driver locations sorted set: Skip members after the first one with Score.
+// Positions stay valid while new locations are added, since they are always added at the end.
+type historyCursor struct {
+	Score float64 `json:"score"`
+	Skip  int64   `json:"skip"`
+	// MinTime drops the locations of the downsampling intervals already returned, in unix nanoseconds.
+	MinTime int64 `json:"min_time,omitempty"`
+}
+
+func encodeHistoryCursor(c *historyCursor) string {
+	data, _ := json.Marshal(c) // Marshaling a struct of numbers can't fail.
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeHistoryCursor(cursor string) (*historyCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errors.WithStack(ErrInvalidCursor)
+	}
+	c := &historyCursor{}
+	if err := json.Unmarshal(data, c); err != nil || c.Skip < 0 {
+		return nil, errors.WithStack(ErrInvalidCursor)
+	}
+	return c, nil
+}
+
+// QueryHistory returns a page of the driver locations within an absolute time range, oldest first.
+// It returns ErrInvalidCursor if the query cursor can't be decoded.
+func (s *ServiceImpl) QueryHistory(ctx context.Context, driverID string, query *HistoryQuery) (*HistoryPage, error) {
+	to := query.To
+	if to.IsZero() {
+		to = s.timeNowFn()
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	position := &historyCursor{Score: timeToRedisScore(query.From)}
+	if query.Cursor != "" {
+		var err error
+		if position, err = decodeHistoryCursor(query.Cursor); err != nil {
+			return nil, err
+		}
+	}
+	minTime := query.From
+	if position.MinTime != 0 {
+		minTime = time.Unix(0, position.MinTime)
+	}
+	maxScore := strconv.FormatFloat(timeToRedisScore(to), 'f', -1, 64)
+	ctxLogger := s.logger.WithField("driver_id", driverID)
+	ctxLogger.WithFields(log.Fields{
+		"from":       query.From,
+		"to":         to,
+		"limit":      limit,
+		"downsample": query.Downsample,
+	}).Info("Query driver locations history from Redis")
+
+	page := &HistoryPage{Locations: make([]*Location, 0, limit)}
+	for len(page.Locations) < limit {
+		members, err := s.redis.ZRangeByScoreWithScores(ctx, driverID, &redis.ZRangeBy{
+			Min:    strconv.FormatFloat(position.Score, 'f', -1, 64),
+			Max:    maxScore,
+			Offset: position.Skip,
+			Count:  historyScanChunkSize,
+		}).Result()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get driver locations history from Redis")
+		}
+		if len(members) == 0 {
+			ctxLogger.WithField("locations_num", len(page.Locations)).Info("Reached the end of driver locations history")
+			return page, nil
+		}
+		for _, member := range members {
+			if member.Score == position.Score {
+				position.Skip++
+			} else {
+				position.Score, position.Skip = member.Score, 1
+			}
+			data, _ := member.Member.(string)
+			loc, err := DecodeLocation([]byte(data))
+			if err != nil {
+				return nil, err
+			}
+			if loc.Time.Before(minTime) || !loc.Time.Before(to) {
+				continue
+			}
+			page.Locations = append(page.Locations, loc)
+			if query.Downsample > 0 {
+				intervalsNum := loc.Time.Sub(query.From) / query.Downsample
+				minTime = query.From.Add((intervalsNum + 1) * query.Downsample)
+				position.MinTime = minTime.UnixNano()
+				// Jump over the rest of the interval instead of scanning it.
+				if nextScore := timeToRedisScore(minTime); nextScore > position.Score {
+					position.Score, position.Skip = nextScore, 0
+					break
+				}
+			}
+			if len(page.Locations) == limit {
+				break
+			}
+		}
+	}
+	page.NextCursor = encodeHistoryCursor(position)
+	ctxLogger.WithField("locations_num", len(page.Locations)).Info("Retrieved a page of driver locations history")
+	return page, nil
+}
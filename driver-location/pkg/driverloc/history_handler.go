@@ -0,0 +1,304 @@
+package driverloc
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/driver-app/platform/httpapi"
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+)
+
+const (
+	ExportFormatNDJSON  = "ndjson"
+	ExportFormatCSV     = "csv"
+	ExportFormatGeoJSON = "geojson"
+)
+
+const (
+	exportMaxDriversNum = 1000
+	nextCursorHeader    = "X-Next-Cursor"
+)
+
+func (ha *httpAPI) getHistory(w http.ResponseWriter, r *http.Request) {
+	driverID := mux.Vars(r)["id"]
+	ctxLogger := ha.logger.WithField("driver_id", driverID)
+	query, err := parseHistoryQuery(r)
+	if err == nil {
+		query.Limit, err = parseLimitParam(r)
+	}
+	if err != nil {
+		ctxLogger.WithError(err).Info("Query params are invalid, return 400")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	query.Cursor = r.URL.Query().Get("cursor")
+	format, ok := negotiateFormat(w, r, ctxLogger)
+	if !ok {
+		return
+	}
+
+	ctxLogger.Info("Request driver locations history from the service")
+	page, err := ha.service.QueryHistory(r.Context(), driverID, query)
+	if err != nil {
+		if errors.Is(err, ErrInvalidCursor) {
+			ctxLogger.WithError(err).Info("Cursor is invalid, return 400")
+			http.Error(w, "'cursor' query param is invalid", http.StatusBadRequest)
+			return
+		}
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to request locations history from the service"))
+		httpapi.InternalServerError(w)
+		return
+	}
+
+	var data interface{} = page
+	if format != FormatJSON {
+		// Other formats only hold the locations, the cursor is passed in the header.
+		if page.NextCursor != "" {
+			w.Header().Set(nextCursorHeader, page.NextCursor)
+		}
+		data = FormatLocations(page.Locations, format)
+	}
+	if err := httpapi.ReturnJSONDataAs(w, FormatMediaType(format), data); err != nil {
+		logging.LogUnhandledError(ctxLogger, err)
+		httpapi.InternalServerError(w)
+		return
+	}
+}
+
+// exportHistory streams the locations history of multiple drivers, driver by driver, oldest locations first.
+// The response status is sent before the history is read, so the connection is aborted if reading fails midway
+// to not let the client take a truncated export for a complete one.
+func (ha *httpAPI) exportHistory(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		logging.LogUnhandledError(ha.logger, errors.New("response writer doesn't support flushing"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	query, err := parseHistoryQuery(r)
+	var driverIDs []string
+	if err == nil {
+		driverIDs, err = parseExportDriverIDs(r)
+	}
+	var ew exportWriter
+	if err == nil {
+		ew, err = newExportWriter(w, r.URL.Query().Get("format"))
+	}
+	if err != nil {
+		ha.logger.WithError(err).Info("Query params are invalid, return 400")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if query.To.IsZero() {
+		// Fix the range end for all drivers.
+		query.To = time.Now()
+	}
+	query.Limit = MaxHistoryLimit
+
+	ha.logger.WithField("drivers_num", len(driverIDs)).Info("Export drivers locations history")
+	w.Header().Set("Content-Type", ew.contentType())
+	w.WriteHeader(http.StatusOK)
+	if err := ew.writeHeader(); err != nil {
+		ha.logger.WithError(err).Info("Couldn't write into export stream, close it")
+		return
+	}
+	flusher.Flush()
+	for _, driverID := range driverIDs {
+		query.Cursor = ""
+		for {
+			page, err := ha.service.QueryHistory(r.Context(), driverID, query)
+			if err != nil {
+				logging.LogUnhandledError(ha.logger.WithField("driver_id", driverID),
+					errors.Wrap(err, "failed to request locations history from the service"))
+				panic(http.ErrAbortHandler)
+			}
+			for _, loc := range page.Locations {
+				if err := ew.writeLocation(&LocationUpdate{DriverID: driverID, Location: loc}); err != nil {
+					ha.logger.WithError(err).Info("Couldn't write into export stream, close it")
+					return
+				}
+			}
+			if err := ew.flush(); err != nil {
+				ha.logger.WithError(err).Info("Couldn't write into export stream, close it")
+				return
+			}
+			flusher.Flush()
+			if page.NextCursor == "" {
+				break
+			}
+			query.Cursor = page.NextCursor
+		}
+	}
+	if err := ew.writeFooter(); err != nil {
+		ha.logger.WithError(err).Info("Couldn't write into export stream, close it")
+		return
+	}
+}
+
+// parseHistoryQuery reads the time range and downsampling from "from", "to" and "downsample" query params.
+// "from" and "to" are RFC 3339 times, "downsample" is a number of seconds.
+func parseHistoryQuery(r *http.Request) (*HistoryQuery, error) {
+	query := r.URL.Query()
+	fromParam := query.Get("from")
+	if fromParam == "" {
+		return nil, errors.New("'from' query param is missing")
+	}
+	from, err := time.Parse(time.RFC3339Nano, fromParam)
+	if err != nil {
+		return nil, errors.New("'from' query param must be an RFC 3339 time")
+	}
+	hq := &HistoryQuery{From: from}
+	if toParam := query.Get("to"); toParam != "" {
+		if hq.To, err = time.Parse(time.RFC3339Nano, toParam); err != nil {
+			return nil, errors.New("'to' query param must be an RFC 3339 time")
+		}
+		if !hq.To.After(hq.From) {
+			return nil, errors.New("'to' query param must be after 'from'")
+		}
+	}
+	if downsampleParam := query.Get("downsample"); downsampleParam != "" {
+		seconds, err := strconv.Atoi(downsampleParam)
+		if err != nil || seconds <= 0 {
+			return nil, errors.New("'downsample' query param must be a positive number of seconds")
+		}
+		hq.Downsample = time.Duration(seconds) * time.Second
+	}
+	return hq, nil
+}
+
+func parseLimitParam(r *http.Request) (int, error) {
+	limitParam := r.URL.Query().Get("limit")
+	if limitParam == "" {
+		return DefaultHistoryLimit, nil
+	}
+	limit, err := strconv.Atoi(limitParam)
+	if err != nil || limit <= 0 || limit > MaxHistoryLimit {
+		return 0, errors.Errorf("'limit' query param must be a number from 1 to %d", MaxHistoryLimit)
+	}
+	return limit, nil
+}
+
+func parseExportDriverIDs(r *http.Request) ([]string, error) {
+	var driverIDs []string
+	seen := make(map[string]bool)
+	for _, driverID := range strings.Split(r.URL.Query().Get("driver_ids"), ",") {
+		if driverID = strings.TrimSpace(driverID); driverID != "" && !seen[driverID] {
+			seen[driverID] = true
+			driverIDs = append(driverIDs, driverID)
+		}
+	}
+	if len(driverIDs) == 0 {
+		return nil, errors.New("'driver_ids' query param is missing")
+	}
+	if len(driverIDs) > exportMaxDriversNum {
+		return nil, errors.Errorf("'driver_ids' query param must contain at most %d drivers", exportMaxDriversNum)
+	}
+	return driverIDs, nil
+}
+
+// exportWriter encodes the exported locations in one of the export formats.
+type exportWriter interface {
+	contentType() string
+	writeHeader() error
+	writeLocation(update *LocationUpdate) error
+	// flush writes out the buffered locations.
+	flush() error
+	writeFooter() error
+}
+
+func newExportWriter(w io.Writer, format string) (exportWriter, error) {
+	switch format {
+	case "", ExportFormatNDJSON:
+		return &ndjsonExportWriter{encoder: json.NewEncoder(w)}, nil
+	case ExportFormatCSV:
+		return &csvExportWriter{writer: csv.NewWriter(w)}, nil
+	case ExportFormatGeoJSON:
+		return &geoJSONExportWriter{writer: w}, nil
+	default:
+		return nil, errors.Errorf("'format' query param must be one of %s, %s, %s",
+			ExportFormatNDJSON, ExportFormatCSV, ExportFormatGeoJSON)
+	}
+}
+
+// ndjsonExportWriter writes a LocationUpdate JSON per line.
+type ndjsonExportWriter struct {
+	encoder *json.Encoder
+}
+
+func (ew *ndjsonExportWriter) contentType() string { return "application/x-ndjson" }
+
+func (ew *ndjsonExportWriter) writeHeader() error { return nil }
+
+func (ew *ndjsonExportWriter) writeLocation(update *LocationUpdate) error {
+	return errors.WithStack(ew.encoder.Encode(update))
+}
+
+func (ew *ndjsonExportWriter) flush() error { return nil }
+
+func (ew *ndjsonExportWriter) writeFooter() error { return nil }
+
+type csvExportWriter struct {
+	writer *csv.Writer
+}
+
+func (ew *csvExportWriter) contentType() string { return "text/csv" }
+
+func (ew *csvExportWriter) writeHeader() error {
+	return errors.WithStack(ew.writer.Write([]string{"driver_id", "latitude", "longitude", "updated_at"}))
+}
+
+func (ew *csvExportWriter) writeLocation(update *LocationUpdate) error {
+	return errors.WithStack(ew.writer.Write([]string{
+		update.DriverID,
+		strconv.FormatFloat(update.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(update.Longitude, 'f', -1, 64),
+		update.Time.UTC().Format(time.RFC3339Nano),
+	}))
+}
+
+func (ew *csvExportWriter) flush() error {
+	ew.writer.Flush()
+	return errors.WithStack(ew.writer.Error())
+}
+
+func (ew *csvExportWriter) writeFooter() error { return ew.flush() }
+
+// geoJSONExportWriter writes a FeatureCollection with a Point feature per location.
+type geoJSONExportWriter struct {
+	writer      io.Writer
+	featuresNum int
+}
+
+func (ew *geoJSONExportWriter) contentType() string { return MediaTypeGeoJSON }
+
+func (ew *geoJSONExportWriter) writeHeader() error {
+	_, err := io.WriteString(ew.writer, `{"type":"FeatureCollection","features":[`)
+	return errors.WithStack(err)
+}
+
+func (ew *geoJSONExportWriter) writeLocation(update *LocationUpdate) error {
+	data, err := json.Marshal(NewLocationFeature(update.DriverID, update.Location))
+	if err != nil {
+		return errors.Wrap(err, "failed to encode location into geojson")
+	}
+	if ew.featuresNum > 0 {
+		data = append([]byte{','}, data...)
+	}
+	ew.featuresNum++
+	_, err = ew.writer.Write(data)
+	return errors.WithStack(err)
+}
+
+func (ew *geoJSONExportWriter) flush() error { return nil }
+
+func (ew *geoJSONExportWriter) writeFooter() error {
+	_, err := io.WriteString(ew.writer, "]}\n")
+	return errors.WithStack(err)
+}
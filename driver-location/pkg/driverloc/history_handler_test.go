@@ -0,0 +1,327 @@
+package driverloc_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc/mocks"
+)
+
+func TestHTTP_GetHistory(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupHTTPServer()
+	defer ts.Close()
+
+	serviceMock.On(
+		"QueryHistory", mock.Anything /* ctx */, defaultDriverID, &driverloc.HistoryQuery{
+			From:       time.Date(2018, 04, 05, 22, 00, 00, 00, time.UTC),
+			To:         time.Date(2018, 04, 05, 23, 00, 00, 00, time.UTC),
+			Limit:      2,
+			Cursor:     "abc",
+			Downsample: 5 * time.Second,
+		},
+	).Return(&driverloc.HistoryPage{
+		Locations: []*driverloc.Location{
+			{
+				Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+				Time:        time.Date(2018, 04, 05, 22, 36, 16, 00, time.UTC),
+			},
+			{
+				Coordinates: &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
+				Time:        time.Date(2018, 04, 05, 22, 36, 21, 00, time.UTC),
+			},
+		},
+		NextCursor: "def",
+	}, nil)
+
+	response, responseData := callHTTPEndpoint(t, ts.URL, fmt.Sprintf("drivers/%s/locations/history", defaultDriverID),
+		url.Values{
+			"from":       {"2018-04-05T22:00:00Z"},
+			"to":         {"2018-04-05T23:00:00Z"},
+			"limit":      {"2"},
+			"cursor":     {"abc"},
+			"downsample": {"5"},
+		})
+
+	expectedResponseData := `
+	{
+		"locations": [{
+			"latitude": 48.864193,
+			"longitude": 2.350498,
+			"updated_at": "2018-04-05T22:36:16Z"
+		}, {
+			"latitude": 48.863921,
+			"longitude": 2.349211,
+			"updated_at": "2018-04-05T22:36:21Z"
+		}],
+		"next_cursor": "def"
+	}`
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
+	assert.JSONEq(t, expectedResponseData, responseData)
+	serviceMock.AssertExpectations(t)
+}
+
+func TestHTTP_GetHistory_RequestError(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name        string
+		queryParams url.Values
+		expected    string
+	}{
+		{
+			name:        "'from' param is missing",
+			queryParams: url.Values{},
+			expected:    "'from' query param is missing\n",
+		},
+		{
+			name:        "'from' param is not a time",
+			queryParams: url.Values{"from": {"yesterday"}},
+			expected:    "'from' query param must be an RFC 3339 time\n",
+		},
+		{
+			name:        "'to' param is before 'from'",
+			queryParams: url.Values{"from": {"2018-04-05T22:00:00Z"}, "to": {"2018-04-05T21:00:00Z"}},
+			expected:    "'to' query param must be after 'from'\n",
+		},
+		{
+			name:        "'downsample' param is not positive",
+			queryParams: url.Values{"from": {"2018-04-05T22:00:00Z"}, "downsample": {"0"}},
+			expected:    "'downsample' query param must be a positive number of seconds\n",
+		},
+		{
+			name:        "'limit' param is too big",
+			queryParams: url.Values{"from": {"2018-04-05T22:00:00Z"}, "limit": {"1001"}},
+			expected:    "'limit' query param must be a number from 1 to 1000\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ts, serviceMock := setupHTTPServer()
+			defer ts.Close()
+			response, responseData := callHTTPEndpoint(
+				t, ts.URL, fmt.Sprintf("drivers/%s/locations/history", defaultDriverID), tc.queryParams,
+			)
+
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+			assert.Equal(t, tc.expected, responseData)
+			serviceMock.AssertNumberOfCalls(t, "QueryHistory", 0)
+		})
+	}
+}
+
+func TestHTTP_GetHistory_InvalidCursor(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupHTTPServer()
+	defer ts.Close()
+	serviceMock.On("QueryHistory", mock.Anything /* ctx */, defaultDriverID, mock.Anything /* query */).
+		Return(nil, errors.WithStack(driverloc.ErrInvalidCursor))
+
+	response, responseData := callHTTPEndpoint(t, ts.URL, fmt.Sprintf("drivers/%s/locations/history", defaultDriverID),
+		url.Values{"from": {"2018-04-05T22:00:00Z"}, "cursor": {"foo"}})
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, "'cursor' query param is invalid\n", responseData)
+}
+
+func TestHTTP_ExportHistory(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		format              string
+		expectedContentType string
+		expected            string
+	}{
+		{
+			format:              driverloc.ExportFormatNDJSON,
+			expectedContentType: "application/x-ndjson",
+			expected: `{"driver_id":"1","latitude":48.864193,"longitude":2.350498,"updated_at":"2018-04-05T22:36:16Z"}
+{"driver_id":"1","latitude":48.863921,"longitude":2.349211,"updated_at":"2018-04-05T22:36:21Z"}
+{"driver_id":"2","latitude":48.862921,"longitude":2.348211,"updated_at":"2018-04-05T22:36:26Z"}
+`,
+		},
+		{
+			format:              driverloc.ExportFormatCSV,
+			expectedContentType: "text/csv",
+			expected: `driver_id,latitude,longitude,updated_at
+1,48.864193,2.350498,2018-04-05T22:36:16Z
+1,48.863921,2.349211,2018-04-05T22:36:21Z
+2,48.862921,2.348211,2018-04-05T22:36:26Z
+`,
+		},
+		{
+			format:              driverloc.ExportFormatGeoJSON,
+			expectedContentType: "application/geo+json",
+			expected: `{"type":"FeatureCollection","features":[` +
+				`{"type":"Feature","geometry":{"type":"Point","coordinates":[2.350498,48.864193]},` +
+				`"properties":{"driver_id":"1","updated_at":"2018-04-05T22:36:16Z"}},` +
+				`{"type":"Feature","geometry":{"type":"Point","coordinates":[2.349211,48.863921]},` +
+				`"properties":{"driver_id":"1","updated_at":"2018-04-05T22:36:21Z"}},` +
+				`{"type":"Feature","geometry":{"type":"Point","coordinates":[2.348211,48.862921]},` +
+				`"properties":{"driver_id":"2","updated_at":"2018-04-05T22:36:26Z"}}` +
+				"]}\n",
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.format, func(t *testing.T) {
+			t.Parallel()
+			ts, serviceMock := setupHTTPServer()
+			defer ts.Close()
+			mockExportHistory(serviceMock)
+
+			response, responseData := callHTTPEndpoint(t, ts.URL, "locations/export", url.Values{
+				"driver_ids": {"1,2"},
+				"from":       {"2018-04-05T22:00:00Z"},
+				"to":         {"2018-04-05T23:00:00Z"},
+				"format":     {tc.format},
+			})
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			assert.Equal(t, tc.expectedContentType, response.Header.Get("Content-Type"))
+			assert.Equal(t, tc.expected, responseData)
+			serviceMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHTTP_ExportHistory_RequestError(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name        string
+		queryParams url.Values
+		expected    string
+	}{
+		{
+			name:        "'driver_ids' param is missing",
+			queryParams: url.Values{"from": {"2018-04-05T22:00:00Z"}},
+			expected:    "'driver_ids' query param is missing\n",
+		},
+		{
+			name:        "'from' param is missing",
+			queryParams: url.Values{"driver_ids": {"1"}},
+			expected:    "'from' query param is missing\n",
+		},
+		{
+			name:        "unknown format",
+			queryParams: url.Values{"driver_ids": {"1"}, "from": {"2018-04-05T22:00:00Z"}, "format": {"xml"}},
+			expected:    "'format' query param must be one of ndjson, csv, geojson\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ts, serviceMock := setupHTTPServer()
+			defer ts.Close()
+			response, responseData := callHTTPEndpoint(t, ts.URL, "locations/export", tc.queryParams)
+
+			assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+			assert.Equal(t, tc.expected, responseData)
+			serviceMock.AssertNumberOfCalls(t, "QueryHistory", 0)
+		})
+	}
+}
+
+func TestHTTP_ExportHistory_ServiceErrorAbortsResponse(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupHTTPServer()
+	defer ts.Close()
+	serviceMock.On("QueryHistory", mock.Anything /* ctx */, "1", mock.Anything /* query */).
+		Return(nil, errors.New("redis is down"))
+
+	reqURL := ts.URL + "/locations/export?driver_ids=1&from=2018-04-05T22:00:00Z"
+	response, err := http.Get(reqURL)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	_, err = ioutil.ReadAll(response.Body)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Error(t, err)
+}
+
+// mockExportHistory makes the service return two pages of locations for driver "1" and one page for driver "2".
+func mockExportHistory(serviceMock *mocks.ReaderService) {
+	expectedQuery := func(cursor string) interface{} {
+		return mock.MatchedBy(func(query *driverloc.HistoryQuery) bool {
+			return query.From.Equal(time.Date(2018, 04, 05, 22, 00, 00, 00, time.UTC)) &&
+				query.To.Equal(time.Date(2018, 04, 05, 23, 00, 00, 00, time.UTC)) &&
+				query.Limit == driverloc.MaxHistoryLimit && query.Cursor == cursor
+		})
+	}
+	serviceMock.On("QueryHistory", mock.Anything /* ctx */, "1", expectedQuery("")).Return(&driverloc.HistoryPage{
+		Locations: []*driverloc.Location{{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			Time:        time.Date(2018, 04, 05, 22, 36, 16, 00, time.UTC),
+		}},
+		NextCursor: "next",
+	}, nil)
+	serviceMock.On("QueryHistory", mock.Anything /* ctx */, "1", expectedQuery("next")).Return(&driverloc.HistoryPage{
+		Locations: []*driverloc.Location{{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
+			Time:        time.Date(2018, 04, 05, 22, 36, 21, 00, time.UTC),
+		}},
+	}, nil)
+	serviceMock.On("QueryHistory", mock.Anything /* ctx */, "2", expectedQuery("")).Return(&driverloc.HistoryPage{
+		Locations: []*driverloc.Location{{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.862921, Longitude: 2.348211},
+			Time:        time.Date(2018, 04, 05, 22, 36, 26, 00, time.UTC),
+		}},
+	}, nil)
+}
+
+func callHTTPEndpoint(t *testing.T, serverURL, path string, query url.Values) (*http.Response, string) {
+	t.Helper()
+	reqURL := fmt.Sprintf("%s/%s?%s", serverURL, path, query.Encode())
+	resp, err := http.Get(reqURL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	return resp, string(bodyBytes)
+}
+
+func TestHTTP_GetHistory_GeoJSON(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupHTTPServer()
+	defer ts.Close()
+	serviceMock.On("QueryHistory", mock.Anything /* ctx */, defaultDriverID, mock.Anything /* query */).
+		Return(&driverloc.HistoryPage{
+			Locations: []*driverloc.Location{{
+				Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+				Time:        time.Date(2018, 04, 05, 22, 36, 16, 00, time.UTC),
+			}},
+			NextCursor: "def",
+		}, nil)
+
+	response, responseData := callHTTPEndpoint(t, ts.URL, fmt.Sprintf("drivers/%s/locations/history", defaultDriverID),
+		url.Values{"from": {"2018-04-05T22:00:00Z"}, "format": {"geojson"}})
+
+	expectedResponseData := `
+	{
+		"type": "FeatureCollection",
+		"features": [{
+			"type": "Feature",
+			"geometry": {"type": "Point", "coordinates": [2.350498, 48.864193]},
+			"properties": {"updated_at": "2018-04-05T22:36:16Z"}
+		}]
+	}`
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/geo+json", response.Header.Get("Content-Type"))
+	assert.Equal(t, "def", response.Header.Get("X-Next-Cursor"))
+	assert.JSONEq(t, expectedResponseData, responseData)
+}
@@ -0,0 +1,125 @@
+package driverloc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+)
+
+func TestService_QueryHistory(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name              string
+		query             *driverloc.HistoryQuery
+		expectedLatitudes []float64
+		expectedPagesNum  int
+	}{
+		{
+			name: "single page",
+			query: &driverloc.HistoryQuery{
+				From: baseTime.Add(700 * time.Millisecond), To: baseTime.Add(2500 * time.Millisecond),
+			},
+			expectedLatitudes: []float64{12, 13, 14},
+			expectedPagesNum:  1,
+		},
+		{
+			name: "multiple pages",
+			query: &driverloc.HistoryQuery{
+				From: baseTime.Add(time.Second), To: baseTime.Add(4 * time.Second), Limit: 2,
+			},
+			expectedLatitudes: []float64{12, 13, 14, 15, 16, 17},
+			expectedPagesNum:  4, // The last page is empty, it has no cursor.
+		},
+		{
+			name:              "'to' defaults to now",
+			query:             &driverloc.HistoryQuery{From: baseTime},
+			expectedLatitudes: []float64{10, 11, 12, 13},
+			expectedPagesNum:  1,
+		},
+		{
+			name: "downsampled",
+			query: &driverloc.HistoryQuery{
+				From: baseTime, To: baseTime.Add(time.Minute), Downsample: 2 * time.Second,
+			},
+			expectedLatitudes: []float64{10, 14, 18},
+			expectedPagesNum:  1,
+		},
+		{
+			name: "downsampled multiple pages",
+			query: &driverloc.HistoryQuery{
+				From: baseTime.Add(500 * time.Millisecond), To: baseTime.Add(time.Minute),
+				Downsample: 2 * time.Second, Limit: 2,
+			},
+			expectedLatitudes: []float64{11, 15, 19},
+			expectedPagesNum:  2,
+		},
+		{
+			name:             "range without locations",
+			query:            &driverloc.HistoryQuery{From: baseTime.Add(time.Hour), To: baseTime.Add(2 * time.Hour)},
+			expectedPagesNum: 1,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service, fakeRedis := setupHistoryService(t)
+			defer fakeRedis.Close()
+			service.SetTimeNowFn(func() time.Time { return baseTime.Add(2 * time.Second) })
+
+			var actualLatitudes []float64
+			pagesNum := 0
+			query := *tc.query
+			for {
+				page, err := service.QueryHistory(ctx, defaultDriverID, &query)
+				require.NoError(t, err)
+				pagesNum++
+				if query.Limit > 0 {
+					assert.LessOrEqual(t, len(page.Locations), query.Limit)
+				}
+				for _, loc := range page.Locations {
+					actualLatitudes = append(actualLatitudes, loc.Latitude)
+				}
+				if page.NextCursor == "" {
+					break
+				}
+				query.Cursor = page.NextCursor
+			}
+
+			assert.Equal(t, tc.expectedLatitudes, actualLatitudes)
+			assert.Equal(t, tc.expectedPagesNum, pagesNum)
+		})
+	}
+}
+
+func TestService_QueryHistory_InvalidCursor(t *testing.T) {
+	t.Parallel()
+	service, fakeRedis := setupHistoryService(t)
+	defer fakeRedis.Close()
+
+	for _, cursor := range []string{"not base64!", "bm90IGpzb24" /* "not json" */} {
+		_, err := service.QueryHistory(ctx, defaultDriverID, &driverloc.HistoryQuery{From: baseTime, Cursor: cursor})
+		assert.True(t, errors.Is(err, driverloc.ErrInvalidCursor), cursor)
+	}
+}
+
+// setupHistoryService inserts 10 locations, two per second, with latitudes from 10 to 19.
+func setupHistoryService(t *testing.T) (*driverloc.ServiceImpl, *miniredis.Miniredis) {
+	t.Helper()
+	service, fakeRedis := setupServiceWith(t, driverloc.JSONLocationCodec{}, 100 /* locationsLimit */)
+	inserts := make([]*toInsert, 10)
+	for i := range inserts {
+		inserts[i] = &toInsert{
+			coords:       &driverloc.Coordinates{Latitude: float64(10 + i), Longitude: 2},
+			locationTime: baseTime.Add(time.Duration(i) * 500 * time.Millisecond),
+		}
+	}
+	insertLocations(t, service, inserts)
+	return service, fakeRedis
+}
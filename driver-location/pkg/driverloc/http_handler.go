@@ -1,72 +1,143 @@
 package driverloc
 
 import (
-	"encoding/json"
 	"net/http"
 	"strconv"
 	"time"
 
+	"github.com/georgysavva/driver-app/platform/httpapi"
+	"github.com/georgysavva/driver-app/platform/logging"
 	"github.com/gorilla/mux"
+	"github.com/gorilla/websocket"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
-func MakeHTTPHandler(service GetterService, logger log.FieldLogger) http.Handler {
+// MaxTimeWindow bounds the time interval of the locations endpoints, so huge values can't overflow time.Duration.
+// The zombie-driver predicates are validated against it, so they never request longer intervals.
+const MaxTimeWindow = 24 * time.Hour
+
+func MakeHTTPHandler(service ReaderService, hub *Hub, logger log.FieldLogger) http.Handler {
 	router := mux.NewRouter()
 	ha := &httpAPI{service: service, logger: logger}
 	router.HandleFunc("/drivers/{id}/locations", ha.getLocations).Methods("GET")
+	router.HandleFunc("/drivers/{id}/locations/history", ha.getHistory).Methods("GET")
+	router.HandleFunc("/drivers/{id}/status", ha.getStatus).Methods("GET")
+	router.HandleFunc("/locations/export", ha.exportHistory).Methods("GET")
+	sa := &streamAPI{hub: hub, logger: logger, upgrader: &websocket.Upgrader{}}
+	router.HandleFunc("/locations/stream", sa.streamSSE).Methods("GET")
+	router.HandleFunc("/locations/ws", sa.streamWebSocket).Methods("GET")
 	return router
 }
 
 type httpAPI struct {
-	service GetterService
+	service ReaderService
 	logger  log.FieldLogger
 }
 
 func (ha *httpAPI) getLocations(w http.ResponseWriter, r *http.Request) {
 	driverID := mux.Vars(r)["id"]
 	ctxLogger := ha.logger.WithField("driver_id", driverID)
-	timeIntervalMinutes, err := parseMinutesParam(r)
+	timeInterval, err := parseWindowParam(r)
 	if err != nil {
 		ctxLogger.WithError(err).Info("Query params are invalid, return 400")
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	format, ok := negotiateFormat(w, r, ctxLogger)
+	if !ok {
+		return
+	}
 
-	timeInterval := time.Minute * time.Duration(timeIntervalMinutes)
 	ctxLogger.WithField("time_interval", timeInterval).Info("Request driver locations from the service")
 	locations, err := ha.service.GetLocations(r.Context(), driverID, timeInterval)
 	if err != nil {
-		logUnhandledError(ctxLogger, errors.Wrap(err, "failed to request locations from the service"))
-		internalServerError(w)
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to request locations from the service"))
+		httpapi.InternalServerError(w)
 		return
 	}
 
-	if err := returnJSONData(w, locations); err != nil {
-		logUnhandledError(ctxLogger, err)
-		internalServerError(w)
+	if err := httpapi.ReturnJSONDataAs(w, FormatMediaType(format), FormatLocations(locations, format)); err != nil {
+		logging.LogUnhandledError(ctxLogger, err)
+		httpapi.InternalServerError(w)
 		return
 	}
 }
 
-func parseMinutesParam(r *http.Request) (int, error) {
-	minutesParam := r.URL.Query()["minutes"]
-	if len(minutesParam) == 0 {
-		return 0, errors.New("'minutes' query param is missing")
-	}
-	minutesValue, err := strconv.Atoi(minutesParam[0])
+func (ha *httpAPI) getStatus(w http.ResponseWriter, r *http.Request) {
+	driverID := mux.Vars(r)["id"]
+	ctxLogger := ha.logger.WithField("driver_id", driverID)
+
+	ctxLogger.Info("Request driver status from the service")
+	status, err := ha.service.GetStatus(r.Context(), driverID)
 	if err != nil {
-		return 0, errors.New("'minutes' query param must be a number")
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to request status from the service"))
+		httpapi.InternalServerError(w)
+		return
+	}
+
+	if err := httpapi.ReturnJSONData(w, status); err != nil {
+		logging.LogUnhandledError(ctxLogger, err)
+		httpapi.InternalServerError(w)
+		return
 	}
-	return minutesValue, nil
 }
 
-func internalServerError(w http.ResponseWriter) {
-	http.Error(w, "Internal server error", http.StatusInternalServerError)
+// parseWindowParam reads the time interval from the "window" query param, a Go duration string like "90s"
+// or a number of seconds. The "minutes" query param, a number of minutes, is still accepted for older clients.
+func parseWindowParam(r *http.Request) (time.Duration, error) {
+	query := r.URL.Query()
+	windowParam, minutesParam := query.Get("window"), query.Get("minutes")
+	var window time.Duration
+	switch {
+	case windowParam != "" && minutesParam != "":
+		return 0, errors.New("only one of 'window' and 'minutes' query params must be set")
+	case windowParam != "":
+		if seconds, err := strconv.ParseInt(windowParam, 10, 64); err == nil {
+			if seconds > int64(MaxTimeWindow.Seconds()) {
+				return 0, errors.Errorf("'window' query param must not exceed %s", MaxTimeWindow)
+			}
+			window = time.Duration(seconds) * time.Second
+		} else if window, err = time.ParseDuration(windowParam); err != nil {
+			return 0, errors.New("'window' query param must be a duration like 90s or a number of seconds")
+		}
+		if window <= 0 {
+			return 0, errors.New("'window' query param must be positive")
+		}
+	case minutesParam != "":
+		minutes, err := strconv.Atoi(minutesParam)
+		if err != nil {
+			return 0, errors.New("'minutes' query param must be a number")
+		}
+		if minutes <= 0 {
+			return 0, errors.New("'minutes' query param must be positive")
+		}
+		if minutes > int(MaxTimeWindow.Minutes()) {
+			return 0, errors.Errorf("'minutes' query param must not exceed %d", int(MaxTimeWindow.Minutes()))
+		}
+		window = time.Duration(minutes) * time.Minute
+	default:
+		return 0, errors.New("'window' query param is missing")
+	}
+	if window > MaxTimeWindow {
+		return 0, errors.Errorf("'window' query param must not exceed %s", MaxTimeWindow)
+	}
+	return window, nil
 }
 
-func returnJSONData(w http.ResponseWriter, obj interface{}) error {
-	w.Header().Set("Content-Type", "application/json")
-	err := json.NewEncoder(w).Encode(obj)
-	return errors.Wrap(err, "can not encode or write data into http response")
+// negotiateFormat returns the format of the locations requested by the client.
+// If the format isn't supported, it replies to the client and returns false.
+func negotiateFormat(w http.ResponseWriter, r *http.Request, logger log.FieldLogger) (string, bool) {
+	format, err := NegotiateFormat(r.URL.Query().Get("format"), r.Header.Get("Accept"))
+	if err != nil {
+		if errors.Is(err, ErrNotAcceptable) {
+			logger.WithError(err).Info("Accepted media types aren't supported, return 406")
+			http.Error(w, err.Error(), http.StatusNotAcceptable)
+			return "", false
+		}
+		logger.WithError(err).Info("Query params are invalid, return 400")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", false
+	}
+	return format, true
 }
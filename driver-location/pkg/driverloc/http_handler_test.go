@@ -11,6 +11,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -68,6 +69,35 @@ func TestHTTP_GetLocations(t *testing.T) {
 	serviceMock.AssertExpectations(t)
 }
 
+func TestHTTP_GetLocations_Window(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		window   string
+		expected time.Duration
+	}{
+		{window: "90s", expected: 90 * time.Second},
+		{window: "1m30s", expected: 90 * time.Second},
+		{window: "90", expected: 90 * time.Second},
+		{window: "500ms", expected: 500 * time.Millisecond},
+		{window: "24h", expected: 24 * time.Hour},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.window, func(t *testing.T) {
+			t.Parallel()
+			ts, serviceMock := setupHTTPServer()
+			defer ts.Close()
+			serviceMock.On("GetLocations", mock.Anything /* ctx */, defaultDriverID, tc.expected).
+				Return([]*driverloc.Location{}, nil)
+
+			response, _ := callGetLocationsEndpoint(t, ts, map[string]string{"window": tc.window})
+
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+			serviceMock.AssertExpectations(t)
+		})
+	}
+}
+
 func TestHTTP_GetLocations_RequestError(t *testing.T) {
 	t.Parallel()
 	cases := []struct {
@@ -76,15 +106,55 @@ func TestHTTP_GetLocations_RequestError(t *testing.T) {
 		expected    string
 	}{
 		{
-			name:        "'minutes' param is missing",
+			name:        "'window' param is missing",
 			queryParams: map[string]string{},
-			expected:    "'minutes' query param is missing\n",
+			expected:    "'window' query param is missing\n",
 		},
 		{
 			name:        "'minutes' param is not a number",
 			queryParams: map[string]string{"minutes": "five"},
 			expected:    "'minutes' query param must be a number\n",
 		},
+		{
+			name:        "'minutes' param is negative",
+			queryParams: map[string]string{"minutes": "-5"},
+			expected:    "'minutes' query param must be positive\n",
+		},
+		{
+			name:        "'minutes' param is too big",
+			queryParams: map[string]string{"minutes": "1441"},
+			expected:    "'minutes' query param must not exceed 1440\n",
+		},
+		{
+			name:        "'window' param is not a duration",
+			queryParams: map[string]string{"window": "five minutes"},
+			expected:    "'window' query param must be a duration like 90s or a number of seconds\n",
+		},
+		{
+			name:        "'window' param is negative",
+			queryParams: map[string]string{"window": "-90s"},
+			expected:    "'window' query param must be positive\n",
+		},
+		{
+			name:        "'window' param is zero seconds",
+			queryParams: map[string]string{"window": "0"},
+			expected:    "'window' query param must be positive\n",
+		},
+		{
+			name:        "'window' param is too big",
+			queryParams: map[string]string{"window": "25h"},
+			expected:    "'window' query param must not exceed 24h0m0s\n",
+		},
+		{
+			name:        "'window' param overflows",
+			queryParams: map[string]string{"window": "99999999999999999"},
+			expected:    "'window' query param must not exceed 24h0m0s\n",
+		},
+		{
+			name:        "both 'window' and 'minutes' params are set",
+			queryParams: map[string]string{"window": "90s", "minutes": "5"},
+			expected:    "only one of 'window' and 'minutes' query params must be set\n",
+		},
 	}
 
 	for _, tc := range cases {
@@ -106,6 +176,33 @@ func TestHTTP_GetLocations_RequestError(t *testing.T) {
 	}
 }
 
+func TestHTTP_GetStatus(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupHTTPServer()
+	defer ts.Close()
+	lastSeenAt := time.Date(2018, 04, 05, 22, 36, 21, 00, time.UTC)
+	serviceMock.On("GetStatus", mock.Anything /* ctx */, defaultDriverID).
+		Return(&driverloc.DriverStatus{Status: driverloc.DriverStatusOffline, LastSeenAt: &lastSeenAt}, nil)
+
+	response, responseData := callHTTPEndpoint(t, ts.URL, fmt.Sprintf("drivers/%s/status", defaultDriverID), nil)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, `{"status": "offline", "last_seen_at": "2018-04-05T22:36:21Z"}`, responseData)
+	serviceMock.AssertExpectations(t)
+}
+
+func TestHTTP_GetStatus_ServiceError(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupHTTPServer()
+	defer ts.Close()
+	serviceMock.On("GetStatus", mock.Anything /* ctx */, defaultDriverID).Return(nil, errors.New("redis is down"))
+
+	response, _ := callHTTPEndpoint(t, ts.URL, fmt.Sprintf("drivers/%s/status", defaultDriverID), nil)
+
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	serviceMock.AssertExpectations(t)
+}
+
 func callGetLocationsEndpoint(t *testing.T, ts *httptest.Server, queryParams map[string]string) (
 	*http.Response, string) {
 	t.Helper()
@@ -132,11 +229,73 @@ func callGetLocationsEndpoint(t *testing.T, ts *httptest.Server, queryParams map
 	return resp, bodyText
 }
 
-func setupHTTPServer() (*httptest.Server, *mocks.GetterService) {
+func setupHTTPServer() (*httptest.Server, *mocks.ReaderService) {
 	logger := log.New()
 	logger.Level = log.ErrorLevel
-	serviceMock := &mocks.GetterService{}
-	hh := driverloc.MakeHTTPHandler(serviceMock, logger)
+	serviceMock := &mocks.ReaderService{}
+	hh := driverloc.MakeHTTPHandler(serviceMock, driverloc.NewHub(logger, 1 /* bufferSize */), logger)
 	ts := httptest.NewServer(hh)
 	return ts, serviceMock
 }
+
+func TestHTTP_GetLocations_Formats(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name                string
+		queryParams         url.Values
+		acceptHeader        string
+		expectedStatus      int
+		expectedContentType string
+	}{
+		{
+			name:                "format param",
+			queryParams:         url.Values{"format": {"linestring"}},
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/geo+json",
+		},
+		{
+			name:                "accept header",
+			acceptHeader:        "application/vnd.polyline+json",
+			expectedStatus:      http.StatusOK,
+			expectedContentType: "application/vnd.polyline+json",
+		},
+		{
+			name:           "unknown format param",
+			queryParams:    url.Values{"format": {"kml"}},
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "unsupported accept header",
+			acceptHeader:   "text/html",
+			expectedStatus: http.StatusNotAcceptable,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ts, serviceMock := setupHTTPServer()
+			defer ts.Close()
+			serviceMock.On("GetLocations", mock.Anything /* ctx */, defaultDriverID, 5*time.Minute).
+				Return([]*driverloc.Location{}, nil)
+
+			query := url.Values{"minutes": {"5"}}
+			for k, v := range tc.queryParams {
+				query[k] = v
+			}
+			req, err := http.NewRequest(
+				"GET", fmt.Sprintf("%s/drivers/%s/locations?%s", ts.URL, defaultDriverID, query.Encode()), nil, /* body */
+			)
+			require.NoError(t, err)
+			req.Header.Set("Accept", tc.acceptHeader)
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer response.Body.Close()
+
+			assert.Equal(t, tc.expectedStatus, response.StatusCode)
+			if tc.expectedContentType != "" {
+				assert.Equal(t, tc.expectedContentType, response.Header.Get("Content-Type"))
+			}
+		})
+	}
+}
@@ -0,0 +1,155 @@
+package driverloc
+
+import (
+	"sync"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrHubClosed = errors.New("location hub is closed")
+
+type Publisher interface {
+	Publish(update *LocationUpdate)
+}
+
+// SubscriptionFilter selects location updates delivered to a subscription.
+// An update matches if its driver is in DriverIDs, when they are set,
+// and its coordinates are inside BoundingBox, when it's set.
+type SubscriptionFilter struct {
+	DriverIDs   []string     `json:"driver_ids"`
+	BoundingBox *BoundingBox `json:"bbox"`
+}
+
+func (sf *SubscriptionFilter) Validate() error {
+	if len(sf.DriverIDs) == 0 && sf.BoundingBox == nil {
+		return errors.New("subscription filter must contain driver ids or a bounding box")
+	}
+	if bb := sf.BoundingBox; bb != nil && bb.MinLatitude > bb.MaxLatitude {
+		return errors.New("bounding box min latitude must not exceed max latitude")
+	}
+	return nil
+}
+
+func (sf *SubscriptionFilter) matches(update *LocationUpdate) bool {
+	if len(sf.DriverIDs) > 0 {
+		found := false
+		for _, driverID := range sf.DriverIDs {
+			if driverID == update.DriverID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return sf.BoundingBox == nil || sf.BoundingBox.Contains(update.Point())
+}
+
+// Hub fans location updates out to real-time stream subscribers.
+// A subscriber that doesn't keep up with the updates and fills its buffer is unsubscribed.
+type Hub struct {
+	logger     log.FieldLogger
+	bufferSize int
+
+	mu            sync.RWMutex
+	subscriptions map[*Subscription]struct{}
+	closed        bool
+}
+
+func NewHub(logger log.FieldLogger, bufferSize int) *Hub {
+	return &Hub{
+		logger:        logger,
+		bufferSize:    bufferSize,
+		subscriptions: make(map[*Subscription]struct{}),
+	}
+}
+
+func (h *Hub) Subscribe(filter *SubscriptionFilter) (*Subscription, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil, errors.WithStack(ErrHubClosed)
+	}
+	s := &Subscription{
+		hub:     h,
+		updates: make(chan *LocationUpdate, h.bufferSize),
+		filter:  filter,
+	}
+	h.subscriptions[s] = struct{}{}
+	h.logger.WithField("subscriptions_num", len(h.subscriptions)).Info("New subscription added to the hub")
+	return s, nil
+}
+
+func (h *Hub) Publish(update *LocationUpdate) {
+	var slow []*Subscription
+	h.mu.RLock()
+	for s := range h.subscriptions {
+		if !s.getFilter().matches(update) {
+			continue
+		}
+		select {
+		case s.updates <- update:
+		default:
+			slow = append(slow, s)
+		}
+	}
+	h.mu.RUnlock()
+
+	for _, s := range slow {
+		h.logger.Info("Subscription buffer is full, unsubscribe the slow subscriber")
+		h.unsubscribe(s)
+	}
+}
+
+// Close ends all subscriptions and rejects new ones.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for s := range h.subscriptions {
+		delete(h.subscriptions, s)
+		close(s.updates)
+	}
+	h.closed = true
+}
+
+func (h *Hub) unsubscribe(s *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.subscriptions[s]; !ok {
+		return
+	}
+	delete(h.subscriptions, s)
+	close(s.updates)
+}
+
+type Subscription struct {
+	hub     *Hub
+	updates chan *LocationUpdate
+
+	filterMu sync.RWMutex
+	filter   *SubscriptionFilter
+}
+
+// Updates returns the channel of matching location updates.
+// It's closed when the subscription ends: it was closed, it fell behind or the hub was closed.
+func (s *Subscription) Updates() <-chan *LocationUpdate {
+	return s.updates
+}
+
+func (s *Subscription) SetFilter(filter *SubscriptionFilter) {
+	s.filterMu.Lock()
+	defer s.filterMu.Unlock()
+	s.filter = filter
+}
+
+func (s *Subscription) Close() {
+	s.hub.unsubscribe(s)
+}
+
+func (s *Subscription) getFilter() *SubscriptionFilter {
+	s.filterMu.RLock()
+	defer s.filterMu.RUnlock()
+	return s.filter
+}
@@ -0,0 +1,145 @@
+package driverloc_test
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+)
+
+var (
+	insideParis  = &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498}
+	insideBerlin = &driverloc.Coordinates{Latitude: 52.520008, Longitude: 13.404954}
+	parisBox     = &driverloc.BoundingBox{
+		MinLatitude: 48.815573, MinLongitude: 2.224199, MaxLatitude: 48.902145, MaxLongitude: 2.469920,
+	}
+)
+
+func TestHub_Publish(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		filter   *driverloc.SubscriptionFilter
+		update   *driverloc.LocationUpdate
+		expected bool
+	}{
+		{
+			name:     "driver id matches",
+			filter:   &driverloc.SubscriptionFilter{DriverIDs: []string{"bar", defaultDriverID}},
+			update:   newLocationUpdate(defaultDriverID, insideBerlin),
+			expected: true,
+		},
+		{
+			name:     "driver id doesn't match",
+			filter:   &driverloc.SubscriptionFilter{DriverIDs: []string{"bar"}},
+			update:   newLocationUpdate(defaultDriverID, insideBerlin),
+			expected: false,
+		},
+		{
+			name:     "inside bounding box",
+			filter:   &driverloc.SubscriptionFilter{BoundingBox: parisBox},
+			update:   newLocationUpdate(defaultDriverID, insideParis),
+			expected: true,
+		},
+		{
+			name:     "outside bounding box",
+			filter:   &driverloc.SubscriptionFilter{BoundingBox: parisBox},
+			update:   newLocationUpdate(defaultDriverID, insideBerlin),
+			expected: false,
+		},
+		{
+			name:     "driver id matches but outside bounding box",
+			filter:   &driverloc.SubscriptionFilter{DriverIDs: []string{defaultDriverID}, BoundingBox: parisBox},
+			update:   newLocationUpdate(defaultDriverID, insideBerlin),
+			expected: false,
+		},
+		{
+			name: "inside bounding box crossing antimeridian",
+			filter: &driverloc.SubscriptionFilter{BoundingBox: &driverloc.BoundingBox{
+				MinLatitude: -20, MinLongitude: 170, MaxLatitude: -10, MaxLongitude: -170,
+			}},
+			update:   newLocationUpdate(defaultDriverID, &driverloc.Coordinates{Latitude: -15, Longitude: 179}),
+			expected: true,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			hub := newHub(1 /* bufferSize */)
+			sub, err := hub.Subscribe(tc.filter)
+			require.NoError(t, err)
+
+			hub.Publish(tc.update)
+			hub.Close()
+
+			var received []*driverloc.LocationUpdate
+			for update := range sub.Updates() {
+				received = append(received, update)
+			}
+			if tc.expected {
+				assert.Equal(t, []*driverloc.LocationUpdate{tc.update}, received)
+			} else {
+				assert.Empty(t, received)
+			}
+		})
+	}
+}
+
+func TestHub_Publish_SlowSubscriberIsUnsubscribed(t *testing.T) {
+	t.Parallel()
+	hub := newHub(1 /* bufferSize */)
+	filter := &driverloc.SubscriptionFilter{DriverIDs: []string{defaultDriverID}}
+	slowSub, err := hub.Subscribe(filter)
+	require.NoError(t, err)
+
+	first := newLocationUpdate(defaultDriverID, insideParis)
+	hub.Publish(first)
+	hub.Publish(newLocationUpdate(defaultDriverID, insideParis))
+
+	assert.Equal(t, first, <-slowSub.Updates())
+	_, ok := <-slowSub.Updates()
+	assert.False(t, ok)
+}
+
+func TestHub_Subscribe_ClosedHub(t *testing.T) {
+	t.Parallel()
+	hub := newHub(1 /* bufferSize */)
+	hub.Close()
+
+	_, err := hub.Subscribe(&driverloc.SubscriptionFilter{DriverIDs: []string{defaultDriverID}})
+	assert.True(t, errors.Is(err, driverloc.ErrHubClosed))
+}
+
+func TestSubscription_SetFilter(t *testing.T) {
+	t.Parallel()
+	hub := newHub(1 /* bufferSize */)
+	sub, err := hub.Subscribe(&driverloc.SubscriptionFilter{DriverIDs: []string{"bar"}})
+	require.NoError(t, err)
+
+	sub.SetFilter(&driverloc.SubscriptionFilter{DriverIDs: []string{defaultDriverID}})
+	update := newLocationUpdate(defaultDriverID, insideParis)
+	hub.Publish(update)
+
+	assert.Equal(t, update, <-sub.Updates())
+	sub.Close()
+	_, ok := <-sub.Updates()
+	assert.False(t, ok)
+}
+
+func newLocationUpdate(driverID string, coords *driverloc.Coordinates) *driverloc.LocationUpdate {
+	return &driverloc.LocationUpdate{
+		DriverID: driverID,
+		Location: &driverloc.Location{Coordinates: coords, Time: baseTime},
+	}
+}
+
+func newHub(bufferSize int) *driverloc.Hub {
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	return driverloc.NewHub(logger, bufferSize)
+}
@@ -5,3 +5,5 @@ import (
 )
 
 func (s *ServiceImpl) SetTimeNowFn(fn func() time.Time) { s.timeNowFn = fn }
+
+func (s *MemoryDedupStore) SetTimeNowFn(fn func() time.Time) { s.timeNowFn = fn }
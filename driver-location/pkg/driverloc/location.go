@@ -2,6 +2,8 @@ package driverloc
 
 import (
 	"time"
+
+	"github.com/georgysavva/driver-app/platform/geo"
 )
 
 type Coordinates struct {
@@ -13,3 +15,17 @@ type Location struct {
 	*Coordinates
 	Time time.Time `json:"updated_at"`
 }
+
+// LocationUpdate is a location accepted by the service, as delivered to the real-time stream subscribers.
+type LocationUpdate struct {
+	DriverID string `json:"driver_id"`
+	*Location
+}
+
+// BoundingBox is kept as an alias for the filters decoded by the stream handler.
+type BoundingBox = geo.BoundingBox
+
+// Point converts the coordinates for the geo package.
+func (c *Coordinates) Point() geo.Point {
+	return geo.Point{Latitude: c.Latitude, Longitude: c.Longitude}
+}
@@ -38,3 +38,26 @@ func (_m *GetterService) GetLocations(ctx context.Context, driverID string, time
 
 	return r0, r1
 }
+
+// GetStatus provides a mock function with given fields: ctx, driverID
+func (_m *GetterService) GetStatus(ctx context.Context, driverID string) (*driverloc.DriverStatus, error) {
+	ret := _m.Called(ctx, driverID)
+
+	var r0 *driverloc.DriverStatus
+	if rf, ok := ret.Get(0).(func(context.Context, string) *driverloc.DriverStatus); ok {
+		r0 = rf(ctx, driverID)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*driverloc.DriverStatus)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, string) error); ok {
+		r1 = rf(ctx, driverID)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -4,6 +4,7 @@ package mocks
 
 import (
 	context "context"
+	time "time"
 
 	driverloc "github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
 	mock "github.com/stretchr/testify/mock"
@@ -14,13 +15,41 @@ type UpdaterService struct {
 	mock.Mock
 }
 
-// UpdateLocations provides a mock function with given fields: ctx, driverID, coordinates
-func (_m *UpdaterService) UpdateLocations(ctx context.Context, driverID string, coordinates *driverloc.Coordinates) error {
-	ret := _m.Called(ctx, driverID, coordinates)
+// BatchUpdateLocations provides a mock function with given fields: ctx, locations, locationTime
+func (_m *UpdaterService) BatchUpdateLocations(ctx context.Context, locations []*driverloc.DriverCoordinates, locationTime time.Time) error {
+	ret := _m.Called(ctx, locations, locationTime)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, string, *driverloc.Coordinates) error); ok {
-		r0 = rf(ctx, driverID, coordinates)
+	if rf, ok := ret.Get(0).(func(context.Context, []*driverloc.DriverCoordinates, time.Time) error); ok {
+		r0 = rf(ctx, locations, locationTime)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteLocations provides a mock function with given fields: ctx, driverID
+func (_m *UpdaterService) DeleteLocations(ctx context.Context, driverID string) error {
+	ret := _m.Called(ctx, driverID)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string) error); ok {
+		r0 = rf(ctx, driverID)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// UpdateLocations provides a mock function with given fields: ctx, driverID, coordinates, locationTime
+func (_m *UpdaterService) UpdateLocations(ctx context.Context, driverID string, coordinates *driverloc.Coordinates, locationTime time.Time) error {
+	ret := _m.Called(ctx, driverID, coordinates, locationTime)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, string, *driverloc.Coordinates, time.Time) error); ok {
+		r0 = rf(ctx, driverID, coordinates, locationTime)
 	} else {
 		r0 = ret.Error(0)
 	}
@@ -2,87 +2,168 @@ package driverloc
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
+	"time"
 
+	"github.com/georgysavva/driver-app/platform/envelope"
+	"github.com/georgysavva/driver-app/platform/logging"
 	"github.com/nsqio/go-nsq"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
-const commandUpdateDriverLocations = "update-driver-locations"
-
+// NSQHandler dispatches the commands published by the gateway to their handlers.
+// Messages that can never be processed are sent to the dead letter queue right away,
+// messages failing for other reasons are requeued until the retry policy gives up on them.
+// Messages carrying an id are deduplicated, so redelivered messages are acknowledged without being processed twice.
 type NSQHandler struct {
-	service UpdaterService
-	logger  log.FieldLogger
-}
-
-func NewNSQHandler(service UpdaterService, logger log.FieldLogger) *NSQHandler {
-	return &NSQHandler{service: service, logger: logger}
+	commands    *CommandRegistry
+	dedup       DedupStore
+	deadLetters DeadLetterQueue
+	retryPolicy *RetryPolicy
+	logger      log.FieldLogger
+	timeNowFn   func() time.Time
 }
 
-type nsqRequest struct {
-	Command string `json:"command"`
-	Data    struct {
-		DriverID  *string  `json:"id"`
-		Latitude  *float64 `json:"latitude"`
-		Longitude *float64 `json:"longitude"`
-	} `json:"data"`
+func NewNSQHandler(
+	commands *CommandRegistry, dedup DedupStore, deadLetters DeadLetterQueue, logger log.FieldLogger,
+	retryPolicy *RetryPolicy,
+) *NSQHandler {
+	return &NSQHandler{
+		commands:    commands,
+		dedup:       dedup,
+		deadLetters: deadLetters,
+		retryPolicy: retryPolicy,
+		logger:      logger,
+		timeNowFn:   time.Now,
+	}
 }
 
 func (nh *NSQHandler) HandleMessage(m *nsq.Message) error {
+	// The handler decides itself whether the message is finished, requeued or dead-lettered.
+	m.DisableAutoResponse()
 	ctx := context.Background()
-	ctxLogger := nh.logger.WithField("message_id", getMessageID(m))
+	ctxLogger := nh.logger.WithFields(log.Fields{"message_id": getMessageID(m), "attempts": m.Attempts})
 	ctxLogger.WithField("message_body", string(m.Body)).Info("Received a new message")
 
-	req, err := parseNSQRequest(m)
+	msg, err := envelope.Unmarshal(m.Body)
 	if err != nil {
-		ctxLogger.WithError(err).Info("Couldn't parse nsq message, finish processing")
-		return nil
+		ctxLogger.WithError(err).Info("Couldn't parse nsq message")
+		return nh.rejectMessage(ctxLogger, m, err.Error())
 	}
 
-	ctxLogger = ctxLogger.WithField("command", req.Command)
-	if req.Command != commandUpdateDriverLocations {
+	ctxLogger = ctxLogger.WithFields(log.Fields{"command": msg.Command, "version": msg.Version, "envelope_id": msg.ID})
+	if msg.Trace != nil {
+		ctxLogger = ctxLogger.WithField("traceparent", msg.Trace.TraceParent)
+	}
+	handler, ok := nh.commands.lookup(msg.Command)
+	if !ok {
 		ctxLogger.Info("NSQ request contains unsupported command")
-		return nil
+		return nh.rejectMessage(ctxLogger, m, fmt.Sprintf("unsupported command %q", msg.Command))
+	}
+	payload, err := handler.Decode(msg.Payload)
+	if err != nil {
+		ctxLogger.WithError(err).Info("NSQ request data is invalid")
+		return nh.rejectMessage(ctxLogger, m, err.Error())
 	}
 
+	// Legacy messages have no id, so they can't be deduplicated.
+	if msg.ID != "" {
+		status, err := nh.dedup.Acquire(ctx, msg.ID)
+		if err != nil {
+			err = errors.Wrap(err, "failed to deduplicate message")
+			logging.LogUnhandledError(ctxLogger, err)
+			return nh.retryMessage(ctxLogger, m, err)
+		}
+		switch status {
+		case DedupProcessed:
+			ctxLogger.Info("Message has already been processed, finish processing")
+			m.Finish()
+			return nil
+		case DedupInProgress:
+			// The other delivery may still fail, so this one is kept until it's known.
+			// Nothing has failed, so unlike retryMessage the consumer doesn't back off.
+			delay := nh.retryPolicy.delayAfter(m.Attempts)
+			ctxLogger.WithField("requeue_delay", delay).Info("Message is being processed, requeue it")
+			m.RequeueWithoutBackoff(delay)
+			return nil
+		}
+	}
+
+	// Legacy messages aren't timestamped by the gateway, so the time they reached nsqd is used instead.
+	messageTime := msg.ProducedAt
+	if messageTime.IsZero() {
+		messageTime = time.Unix(0, m.Timestamp).UTC()
+	}
 	ctxLogger.Info("Handle nsq request")
-	if err := nh.updateLocations(ctx, req); err != nil {
-		logUnhandledError(ctxLogger, err)
-		return err
+	if err := handler.Handle(ctx, payload, messageTime); err != nil {
+		logging.LogUnhandledError(ctxLogger, err)
+		nh.releaseMessage(ctx, ctxLogger, msg)
+		return nh.retryMessage(ctxLogger, m, err)
 	}
+	nh.confirmMessage(ctx, ctxLogger, msg)
 
+	m.Finish()
 	return nil
 }
 
-func (nh *NSQHandler) updateLocations(ctx context.Context, req *nsqRequest) error {
-	data := req.Data
-	if data.DriverID == nil || data.Latitude == nil || data.Longitude == nil {
-		nh.logger.Info("NSQ request data is incomplete: " +
-			"'driver_id', 'latitude', 'longitude' fields must be set, finish_processing")
-		return nil
+// confirmMessage lets the redeliveries of the applied message be skipped.
+func (nh *NSQHandler) confirmMessage(ctx context.Context, logger log.FieldLogger, msg *envelope.Envelope) {
+	if msg.ID == "" {
+		return
+	}
+	if err := nh.dedup.Confirm(ctx, msg.ID); err != nil {
+		// The message is finished, only a redelivery after the lease expires would be applied twice.
+		logging.LogUnhandledError(logger, errors.Wrap(err, "failed to confirm message in the dedup store"))
+	}
+}
+
+// releaseMessage lets the failed message be processed again on redelivery.
+func (nh *NSQHandler) releaseMessage(ctx context.Context, logger log.FieldLogger, msg *envelope.Envelope) {
+	if msg.ID == "" {
+		return
 	}
-	coordinates := &Coordinates{
-		Latitude:  *data.Latitude,
-		Longitude: *data.Longitude,
+	if err := nh.dedup.Release(ctx, msg.ID); err != nil {
+		// The redelivered message will be skipped as a duplicate, there is nothing else to do about it.
+		logging.LogUnhandledError(logger, errors.Wrap(err, "failed to release message in the dedup store"))
 	}
-	nh.logger.WithFields(log.Fields{
-		"driver_id":   data.DriverID,
-		"coordinates": coordinates,
-	}).Info("Call service to update driver locations")
-	err := nh.service.UpdateLocations(ctx, *data.DriverID, coordinates)
-	return errors.Wrap(err, "failed to call service to update driver locations")
 }
 
-func parseNSQRequest(m *nsq.Message) (*nsqRequest, error) {
-	if len(m.Body) == 0 {
-		return nil, errors.New("message has an empty body")
+// retryMessage requeues the failed message or, once its attempts are exhausted, sends it to the dead letter queue.
+func (nh *NSQHandler) retryMessage(logger log.FieldLogger, m *nsq.Message, cause error) error {
+	if int(m.Attempts) >= nh.retryPolicy.MaxAttempts {
+		logger.Info("Message attempts are exhausted, send it to the dead letter queue")
+		if err := nh.rejectMessage(logger, m, fmt.Sprintf("attempts exhausted: %v", cause)); err != nil {
+			return err
+		}
+		return cause
 	}
-	req := &nsqRequest{}
-	if err := json.Unmarshal(m.Body, req); err != nil {
-		return nil, errors.Wrap(err, "cannot decode message body into as request struct")
+	delay := nh.retryPolicy.delayAfter(m.Attempts)
+	logger.WithField("requeue_delay", delay).Info("Requeue the failed message")
+	// Requeue with a delay also makes the consumer back off, so a failing dependency isn't hammered by other messages.
+	m.Requeue(delay)
+	return cause
+}
+
+// rejectMessage finishes the message that won't be processed after sending it to the dead letter queue.
+// If the dead letter can't be sent, the message is requeued to not lose it.
+func (nh *NSQHandler) rejectMessage(logger log.FieldLogger, m *nsq.Message, reason string) error {
+	letter := &DeadLetter{
+		MessageID: getMessageID(m),
+		Body:      m.Body,
+		Attempts:  m.Attempts,
+		Reason:    reason,
+		FailedAt:  nh.timeNowFn().UTC(),
 	}
-	return req, nil
+	if err := nh.deadLetters.Send(letter); err != nil {
+		err = errors.Wrap(err, "failed to send message to the dead letter queue")
+		logging.LogUnhandledError(logger, err)
+		m.Requeue(nh.retryPolicy.delayAfter(m.Attempts))
+		return err
+	}
+	logger.WithField("reason", reason).Info("Message sent to the dead letter queue, finish processing")
+	m.Finish()
+	return nil
 }
 
 func getMessageID(m *nsq.Message) string {
@@ -2,10 +2,15 @@ package driverloc_test
 
 import (
 	"context"
+	"encoding/json"
 	"testing"
+	"time"
 
+	"github.com/georgysavva/driver-app/platform/envelope"
 	"github.com/nsqio/go-nsq"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
@@ -21,8 +26,9 @@ func TestNSQHandler_HandleMessage(t *testing.T) {
 		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
 		defaultDriverID,
 		&driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+		mock.AnythingOfType("time.Time"),
 	).Return(nil)
-	nsqHandler := newNSQHandler(serviceMock)
+	nsqHandler := newNSQHandler(serviceMock, &mocks.DeadLetterQueue{})
 
 	body := `
 	{
@@ -33,11 +39,234 @@ func TestNSQHandler_HandleMessage(t *testing.T) {
 			"longitude": 2.350498
 		}
 	}`
-	msg := nsq.NewMessage(nsq.MessageID{1, 2, 3, 4}, []byte(body))
+	msg, delegate := newNSQMessage(body, 1 /* attempts */)
+	err := nsqHandler.HandleMessage(msg)
+	require.NoError(t, err)
+
+	serviceMock.AssertExpectations(t)
+	assert.True(t, delegate.finished)
+}
+
+func TestNSQHandler_HandleMessage_Envelope(t *testing.T) {
+	t.Parallel()
+	for _, encoding := range []string{envelope.EncodingJSON, envelope.EncodingProtobuf} {
+		encoding := encoding
+		t.Run(encoding, func(t *testing.T) {
+			t.Parallel()
+			driverID, latitude, longitude := defaultDriverID, 48.864193, 2.350498
+			msg, err := envelope.New(
+				envelope.CommandUpdateDriverLocations,
+				&envelope.DriverLocationPayload{DriverID: &driverID, Latitude: &latitude, Longitude: &longitude},
+				nil, /* trace */
+			)
+			require.NoError(t, err)
+			serviceMock := &mocks.UpdaterService{}
+			serviceMock.On(
+				"UpdateLocations",
+				mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+				defaultDriverID,
+				&driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+				mock.MatchedBy(msg.ProducedAt.Equal), // the locations are stamped with the time the message was produced
+			).Return(nil)
+			nsqHandler := newNSQHandler(serviceMock, &mocks.DeadLetterQueue{})
+			body, err := envelope.Marshal(msg, encoding)
+			require.NoError(t, err)
+
+			nsqMsg, delegate := newNSQMessage(string(body), 1 /* attempts */)
+			err = nsqHandler.HandleMessage(nsqMsg)
+			require.NoError(t, err)
+
+			serviceMock.AssertExpectations(t)
+			assert.True(t, delegate.finished)
+		})
+	}
+}
+
+func TestNSQHandler_HandleMessage_RedeliveredMessageIsSkipped(t *testing.T) {
+	t.Parallel()
+	serviceMock := &mocks.UpdaterService{}
+	serviceMock.On(
+		"UpdateLocations",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+		defaultDriverID,
+		&driverloc.Coordinates{Latitude: 1, Longitude: 2},
+		mock.AnythingOfType("time.Time"),
+	).Return(nil)
+	nsqHandler := newNSQHandler(serviceMock, &mocks.DeadLetterQueue{})
+	body := newEnvelopeBody(t)
+
+	for attempt := uint16(1); attempt <= 2; attempt++ {
+		msg, delegate := newNSQMessage(body, attempt)
+		err := nsqHandler.HandleMessage(msg)
+		require.NoError(t, err)
+		assert.True(t, delegate.finished)
+	}
+
+	serviceMock.AssertNumberOfCalls(t, "UpdateLocations", 1)
+}
+
+func TestNSQHandler_HandleMessage_FailedMessageIsReleased(t *testing.T) {
+	t.Parallel()
+	serviceMock := &mocks.UpdaterService{}
+	serviceMock.On(
+		"UpdateLocations",
+		mock.Anything /* ctx */, mock.Anything /* driverID */, mock.Anything /* coordinates */, mock.Anything, /* time */
+	).Return(errors.New("redis is down")).Once()
+	serviceMock.On(
+		"UpdateLocations",
+		mock.Anything /* ctx */, mock.Anything /* driverID */, mock.Anything /* coordinates */, mock.Anything, /* time */
+	).Return(nil).Once()
+	nsqHandler := newNSQHandler(serviceMock, &mocks.DeadLetterQueue{})
+	body := newEnvelopeBody(t)
+
+	msg, delegate := newNSQMessage(body, 1 /* attempts */)
+	err := nsqHandler.HandleMessage(msg)
+	require.Error(t, err)
+	require.True(t, delegate.requeued)
+
+	msg, delegate = newNSQMessage(body, 2 /* attempts */)
+	err = nsqHandler.HandleMessage(msg)
+	require.NoError(t, err)
+	assert.True(t, delegate.finished)
+
+	serviceMock.AssertNumberOfCalls(t, "UpdateLocations", 2)
+	// The retry saves the location with the same time, so it replaces the one a failed attempt might have saved.
+	assert.Equal(t, serviceMock.Calls[0].Arguments.Get(3), serviceMock.Calls[1].Arguments.Get(3))
+}
+
+func TestNSQHandler_HandleMessage_DedupStoreError(t *testing.T) {
+	t.Parallel()
+	dedupMock := &mocks.DedupStore{}
+	dedupMock.On("Acquire", mock.Anything /* ctx */, mock.Anything /* messageID */).
+		Return(driverloc.DedupStatus(0), errors.New("redis is down"))
+	serviceMock := &mocks.UpdaterService{}
+	nsqHandler := newNSQHandlerWithDedup(serviceMock, dedupMock, &mocks.DeadLetterQueue{})
+
+	msg, delegate := newNSQMessage(newEnvelopeBody(t), 1 /* attempts */)
+	err := nsqHandler.HandleMessage(msg)
+	require.Error(t, err)
+
+	serviceMock.AssertNumberOfCalls(t, "UpdateLocations", 0)
+	assert.True(t, delegate.requeued)
+}
+
+func TestNSQHandler_HandleMessage_MessageInProgressIsRequeued(t *testing.T) {
+	t.Parallel()
+	dedupMock := &mocks.DedupStore{}
+	dedupMock.On("Acquire", mock.Anything /* ctx */, mock.Anything /* messageID */).
+		Return(driverloc.DedupInProgress, nil)
+	serviceMock := &mocks.UpdaterService{}
+	nsqHandler := newNSQHandlerWithDedup(serviceMock, dedupMock, &mocks.DeadLetterQueue{})
+
+	msg, delegate := newNSQMessage(newEnvelopeBody(t), 1 /* attempts */)
+	err := nsqHandler.HandleMessage(msg)
+	require.NoError(t, err)
+
+	serviceMock.AssertNumberOfCalls(t, "UpdateLocations", 0)
+	assert.True(t, delegate.requeued)
+	assert.Equal(t, time.Second, delegate.requeueDelay)
+	assert.False(t, delegate.backoff)
+}
+
+func TestNSQHandler_HandleMessage_ConfirmError(t *testing.T) {
+	t.Parallel()
+	dedupMock := &mocks.DedupStore{}
+	dedupMock.On("Acquire", mock.Anything /* ctx */, mock.Anything /* messageID */).
+		Return(driverloc.DedupAcquired, nil)
+	dedupMock.On("Confirm", mock.Anything /* ctx */, mock.Anything /* messageID */).
+		Return(errors.New("redis is down"))
+	serviceMock := &mocks.UpdaterService{}
+	serviceMock.On(
+		"UpdateLocations",
+		mock.Anything /* ctx */, mock.Anything /* driverID */, mock.Anything /* coordinates */, mock.Anything, /* time */
+	).Return(nil)
+	nsqHandler := newNSQHandlerWithDedup(serviceMock, dedupMock, &mocks.DeadLetterQueue{})
+
+	msg, delegate := newNSQMessage(newEnvelopeBody(t), 1 /* attempts */)
+	err := nsqHandler.HandleMessage(msg)
+	require.NoError(t, err)
+
+	// The locations are saved, so the message is finished anyway.
+	dedupMock.AssertExpectations(t)
+	assert.True(t, delegate.finished)
+}
+
+func TestNSQHandler_HandleMessage_BatchUpdateDriverLocations(t *testing.T) {
+	t.Parallel()
+	serviceMock := &mocks.UpdaterService{}
+	serviceMock.On(
+		"BatchUpdateLocations",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+		[]*driverloc.DriverCoordinates{
+			{DriverID: "foo", Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498}},
+			{DriverID: "bar", Coordinates: &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211}},
+		},
+		mock.AnythingOfType("time.Time"),
+	).Return(nil)
+	nsqHandler := newNSQHandler(serviceMock, &mocks.DeadLetterQueue{})
+
+	body := `
+	{
+		"command": "batch-update-driver-locations",
+		"data": {
+			"locations": [
+				{"id": "foo", "latitude": 48.864193, "longitude": 2.350498},
+				{"id": "bar", "latitude": 48.863921, "longitude": 2.349211}
+			]
+		}
+	}`
+	msg, delegate := newNSQMessage(body, 1 /* attempts */)
 	err := nsqHandler.HandleMessage(msg)
 	require.NoError(t, err)
 
 	serviceMock.AssertExpectations(t)
+	assert.True(t, delegate.finished)
+}
+
+func TestNSQHandler_HandleMessage_DeleteDriverLocations(t *testing.T) {
+	t.Parallel()
+	serviceMock := &mocks.UpdaterService{}
+	serviceMock.On(
+		"DeleteLocations",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+		defaultDriverID,
+	).Return(nil)
+	nsqHandler := newNSQHandler(serviceMock, &mocks.DeadLetterQueue{})
+
+	msg, delegate := newNSQMessage(`{"command": "delete-driver-locations", "data": {"id": "foo"}}`, 1 /* attempts */)
+	err := nsqHandler.HandleMessage(msg)
+	require.NoError(t, err)
+
+	serviceMock.AssertExpectations(t)
+	assert.True(t, delegate.finished)
+}
+
+func TestNSQHandler_HandleMessage_CustomCommand(t *testing.T) {
+	t.Parallel()
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	commands := driverloc.NewCommandRegistry()
+	handler := &recordingCommandHandler{}
+	commands.Register("foo", handler)
+	retryPolicy := &driverloc.RetryPolicy{MaxAttempts: 1, RequeueDelay: time.Second, MaxRequeueDelay: time.Second}
+	nsqHandler := driverloc.NewNSQHandler(
+		commands, driverloc.NopDedupStore{}, &mocks.DeadLetterQueue{}, logger, retryPolicy,
+	)
+
+	msg, delegate := newNSQMessage(`{"command": "foo", "data": {"bar": 1}}`, 1 /* attempts */)
+	err := nsqHandler.HandleMessage(msg)
+	require.NoError(t, err)
+
+	assert.Equal(t, `{"bar": 1}`, handler.handled)
+	assert.True(t, delegate.finished)
+}
+
+func TestCommandRegistry_Register_Duplicate(t *testing.T) {
+	t.Parallel()
+	commands := driverloc.NewCommandRegistry()
+	commands.Register("foo", &recordingCommandHandler{})
+
+	assert.Panics(t, func() { commands.Register("foo", &recordingCommandHandler{}) })
 }
 
 func TestNSQHandler_HandleMessage_RequestError(t *testing.T) {
@@ -95,6 +324,35 @@ func TestNSQHandler_HandleMessage_RequestError(t *testing.T) {
 				}
 			}`,
 		},
+		{
+			name: "message version is unsupported",
+			body: `{"version": 2, "command": "update-driver-locations", "payload": {}}`,
+		},
+		{
+			name: "data is missing",
+			body: `{"command": "update-driver-locations"}`,
+		},
+		{
+			name: "batch update driver locations is empty",
+			body: `{"command": "batch-update-driver-locations", "data": {"locations": []}}`,
+		},
+		{
+			name: "batch update driver locations item is incomplete",
+			body: `
+			{
+				"command": "batch-update-driver-locations",
+				"data": {
+					"locations": [
+						{"id": "foo", "latitude": 48.864193, "longitude": 2.350498},
+						{"id": "bar", "latitude": 48.864193}
+					]
+				}
+			}`,
+		},
+		{
+			name: "delete driver locations id is missing",
+			body: `{"command": "delete-driver-locations", "data": {}}`,
+		},
 	}
 	for _, tc := range cases {
 		tc := tc
@@ -103,25 +361,180 @@ func TestNSQHandler_HandleMessage_RequestError(t *testing.T) {
 			serviceMock := &mocks.UpdaterService{}
 			serviceMock.On(
 				"UpdateLocations",
-				mock.Anything /* ctx */, mock.Anything /* driverID */, mock.Anything, /* coordinates */
+				mock.Anything /* ctx */, mock.Anything /* driverID */, mock.Anything /* coordinates */, mock.Anything, /* time */
 			).Return(nil)
 
-			nsqHandler := newNSQHandler(serviceMock)
-			msg := newNSQMessage(tc.body)
+			deadLettersMock := &mocks.DeadLetterQueue{}
+			deadLettersMock.On("Send", mock.Anything /* letter */).Return(nil)
+
+			nsqHandler := newNSQHandler(serviceMock, deadLettersMock)
+			msg, delegate := newNSQMessage(tc.body, 1 /* attempts */)
 			err := nsqHandler.HandleMessage(msg)
 			require.NoError(t, err)
 
 			serviceMock.AssertNumberOfCalls(t, "UpdateLocations", 0)
+			serviceMock.AssertNumberOfCalls(t, "DeleteLocations", 0)
+			// Malformed messages are never retried.
+			require.Len(t, deadLettersMock.Calls, 1)
+			letter := deadLettersMock.Calls[0].Arguments.Get(0).(*driverloc.DeadLetter)
+			assert.Equal(t, tc.body, string(letter.Body))
+			assert.Equal(t, uint16(1), letter.Attempts)
+			assert.NotEmpty(t, letter.Reason)
+			assert.True(t, delegate.finished)
+			assert.False(t, delegate.requeued)
 		})
 	}
 }
 
-func newNSQMessage(body string) *nsq.Message {
-	return nsq.NewMessage(nsq.MessageID{1, 2, 3, 4}, []byte(body))
+func TestNSQHandler_HandleMessage_ServiceError(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name                 string
+		attempts             uint16
+		expectedRequeueDelay time.Duration
+		expectedDeadLetter   bool
+	}{
+		{
+			name:                 "first attempt",
+			attempts:             1,
+			expectedRequeueDelay: time.Second,
+		},
+		{
+			name:                 "delay doubles with every attempt",
+			attempts:             3,
+			expectedRequeueDelay: 4 * time.Second,
+		},
+		{
+			name:                 "delay is capped",
+			attempts:             9,
+			expectedRequeueDelay: 10 * time.Second,
+		},
+		{
+			name:               "attempts are exhausted",
+			attempts:           10,
+			expectedDeadLetter: true,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			serviceMock := &mocks.UpdaterService{}
+			serviceMock.On(
+				"UpdateLocations",
+				mock.Anything /* ctx */, mock.Anything /* driverID */, mock.Anything /* coordinates */, mock.Anything, /* time */
+			).Return(errors.New("redis is down"))
+			deadLettersMock := &mocks.DeadLetterQueue{}
+			deadLettersMock.On("Send", mock.Anything /* letter */).Return(nil)
+
+			nsqHandler := newNSQHandler(serviceMock, deadLettersMock)
+			msg, delegate := newNSQMessage(updateLocationsBody, tc.attempts)
+			err := nsqHandler.HandleMessage(msg)
+			require.Error(t, err)
+
+			if tc.expectedDeadLetter {
+				require.Len(t, deadLettersMock.Calls, 1)
+				letter := deadLettersMock.Calls[0].Arguments.Get(0).(*driverloc.DeadLetter)
+				assert.Equal(t, updateLocationsBody, string(letter.Body))
+				assert.Equal(t, tc.attempts, letter.Attempts)
+				assert.Contains(t, letter.Reason, "redis is down")
+				assert.True(t, delegate.finished)
+				assert.False(t, delegate.requeued)
+				return
+			}
+			deadLettersMock.AssertNumberOfCalls(t, "Send", 0)
+			assert.False(t, delegate.finished)
+			assert.True(t, delegate.requeued)
+			assert.Equal(t, tc.expectedRequeueDelay, delegate.requeueDelay)
+			assert.True(t, delegate.backoff)
+		})
+	}
+}
+
+func TestNSQHandler_HandleMessage_DeadLetterQueueError(t *testing.T) {
+	t.Parallel()
+	deadLettersMock := &mocks.DeadLetterQueue{}
+	deadLettersMock.On("Send", mock.Anything /* letter */).Return(errors.New("nsqd is down"))
+	nsqHandler := newNSQHandler(&mocks.UpdaterService{}, deadLettersMock)
+
+	msg, delegate := newNSQMessage(`foo`, 1 /* attempts */)
+	err := nsqHandler.HandleMessage(msg)
+	require.Error(t, err)
+
+	// The message must not be lost.
+	assert.False(t, delegate.finished)
+	assert.True(t, delegate.requeued)
+}
+
+type recordingCommandHandler struct {
+	handled string
+}
+
+func (h *recordingCommandHandler) Decode(data json.RawMessage) (interface{}, error) {
+	return string(data), nil
+}
+
+func (h *recordingCommandHandler) Handle(_ context.Context, payload interface{}, _ time.Time) error {
+	h.handled = payload.(string)
+	return nil
+}
+
+func newEnvelopeBody(t *testing.T) string {
+	t.Helper()
+	driverID, latitude, longitude := defaultDriverID, 1.0, 2.0
+	msg, err := envelope.New(
+		envelope.CommandUpdateDriverLocations,
+		&envelope.DriverLocationPayload{DriverID: &driverID, Latitude: &latitude, Longitude: &longitude},
+		nil, /* trace */
+	)
+	require.NoError(t, err)
+	body, err := envelope.Marshal(msg, envelope.EncodingJSON)
+	require.NoError(t, err)
+	return string(body)
+}
+
+const updateLocationsBody = `{"command": "update-driver-locations", ` +
+	`"data": {"id": "foo", "latitude": 1, "longitude": 2}}`
+
+// messageDelegate records how the handler responded to the message.
+type messageDelegate struct {
+	finished     bool
+	requeued     bool
+	requeueDelay time.Duration
+	backoff      bool
+}
+
+func (md *messageDelegate) OnFinish(_ *nsq.Message) { md.finished = true }
+
+func (md *messageDelegate) OnRequeue(_ *nsq.Message, delay time.Duration, backoff bool) {
+	md.requeued = true
+	md.requeueDelay = delay
+	md.backoff = backoff
+}
+
+func (md *messageDelegate) OnTouch(_ *nsq.Message) {}
+
+func newNSQMessage(body string, attempts uint16) (*nsq.Message, *messageDelegate) {
+	delegate := &messageDelegate{}
+	msg := nsq.NewMessage(nsq.MessageID{1, 2, 3, 4}, []byte(body))
+	msg.Attempts = attempts
+	msg.Delegate = delegate
+	return msg, delegate
+}
+
+func newNSQHandler(serviceMock *mocks.UpdaterService, deadLettersMock *mocks.DeadLetterQueue) *driverloc.NSQHandler {
+	return newNSQHandlerWithDedup(serviceMock, driverloc.NewMemoryDedupStore(time.Minute, 10*time.Second), deadLettersMock)
 }
 
-func newNSQHandler(serviceMock *mocks.UpdaterService) *driverloc.NSQHandler {
+func newNSQHandlerWithDedup(
+	serviceMock *mocks.UpdaterService, dedup driverloc.DedupStore, deadLettersMock *mocks.DeadLetterQueue,
+) *driverloc.NSQHandler {
 	logger := log.New()
 	logger.SetLevel(log.ErrorLevel)
-	return driverloc.NewNSQHandler(serviceMock, logger)
+	commands := driverloc.NewUpdaterCommandRegistry(serviceMock, logger)
+	return driverloc.NewNSQHandler(commands, dedup, deadLettersMock, logger, &driverloc.RetryPolicy{
+		MaxAttempts:     10,
+		RequeueDelay:    time.Second,
+		MaxRequeueDelay: 10 * time.Second,
+	})
 }
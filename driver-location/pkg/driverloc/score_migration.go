@@ -0,0 +1,88 @@
+package driverloc
+
+import (
+	"context"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+type ScoreMigrationStats struct {
+	// DriversNum is the number of location sorted sets found.
+	DriversNum int
+	// RescoredNum is the number of locations whose score has changed.
+	RescoredNum int
+	// SkippedKeysNum is the number of sorted sets that don't hold locations.
+	SkippedKeysNum int
+}
+
+// MigrateScores rescores the locations of all drivers with timeToRedisScore,
+// older versions of the service scored them with the unix time in seconds.
+// Every location gets the score of its own time, so the migration can be interrupted and run again,
+// and it must run after the service is upgraded, since older versions keep writing scores in seconds.
+// Keys are scanned by scanBatchSize, sorted sets whose members aren't locations are left untouched.
+func MigrateScores(ctx context.Context, r *redis.Client, logger log.FieldLogger, scanBatchSize int64) (
+	*ScoreMigrationStats, error) {
+	stats := &ScoreMigrationStats{}
+	var cursor uint64
+	for {
+		keys, nextCursor, err := r.Scan(ctx, cursor, "*" /* match */, scanBatchSize).Result()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan Redis keys")
+		}
+		for _, key := range keys {
+			keyType, err := r.Type(ctx, key).Result()
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get type of Redis key %q", key)
+			}
+			if keyType != "zset" {
+				continue
+			}
+			rescoredNum, ok, err := migrateKeyScores(ctx, r, key)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				logger.WithField("key", key).Info("Sorted set doesn't hold driver locations, skip it")
+				stats.SkippedKeysNum++
+				continue
+			}
+			logger.WithFields(log.Fields{"driver_id": key, "rescored_num": rescoredNum}).
+				Info("Migrated driver locations scores")
+			stats.DriversNum++
+			stats.RescoredNum += rescoredNum
+		}
+		if nextCursor == 0 {
+			return stats, nil
+		}
+		cursor = nextCursor
+	}
+}
+
+// migrateKeyScores returns false if the sorted set members aren't locations.
+func migrateKeyScores(ctx context.Context, r *redis.Client, key string) (int, bool, error) {
+	members, err := r.ZRangeWithScores(ctx, key, 0, -1).Result()
+	if err != nil {
+		return 0, false, errors.Wrapf(err, "failed to get members of Redis sorted set %q", key)
+	}
+	var rescored []*redis.Z
+	for _, member := range members {
+		data, _ := member.Member.(string)
+		loc, err := DecodeLocation([]byte(data))
+		if err != nil || loc.Time.IsZero() {
+			return 0, false, nil
+		}
+		if score := timeToRedisScore(loc.Time); score != member.Score {
+			rescored = append(rescored, &redis.Z{Score: score, Member: data})
+		}
+	}
+	if len(rescored) == 0 {
+		return 0, true, nil
+	}
+	// XX doesn't bring back the locations the service has cleaned in the meantime.
+	if err := r.ZAddXX(ctx, key, rescored...).Err(); err != nil {
+		return 0, false, errors.Wrapf(err, "failed to update scores of Redis sorted set %q", key)
+	}
+	return len(rescored), true, nil
+}
@@ -0,0 +1,58 @@
+package driverloc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+)
+
+func TestMigrateScores(t *testing.T) {
+	t.Parallel()
+	fakeRedis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer fakeRedis.Close()
+	redisClient := redis.NewClient(&redis.Options{Addr: fakeRedis.Addr()})
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+
+	// Scored in seconds by older versions.
+	legacyLocations := []string{
+		`{"latitude":48.864193,"longitude":2.350498,"updated_at":"2020-11-07T00:00:00.9Z"}`,
+		`{"latitude":48.863921,"longitude":2.349211,"updated_at":"2020-11-07T00:00:00.1Z"}`,
+	}
+	for _, member := range legacyLocations {
+		_, err = fakeRedis.ZAdd("1", float64(baseTime.Unix()), member)
+		require.NoError(t, err)
+	}
+	_, err = fakeRedis.ZAdd("2", float64(baseTime.Add(time.Second).UnixNano()/int64(time.Millisecond)),
+		`{"latitude":48.862921,"longitude":2.348211,"updated_at":"2020-11-07T00:00:01Z"}`)
+	require.NoError(t, err)
+	_, err = fakeRedis.ZAdd("leaderboard", 1, "player")
+	require.NoError(t, err)
+	require.NoError(t, fakeRedis.Set("driver-location:message:abc", "1"))
+
+	stats, err := driverloc.MigrateScores(ctx, redisClient, logger, 1 /* scanBatchSize */)
+	require.NoError(t, err)
+
+	assert.Equal(t, &driverloc.ScoreMigrationStats{DriversNum: 2, RescoredNum: 2, SkippedKeysNum: 1}, stats)
+	members, err := fakeRedis.ZMembers("1")
+	require.NoError(t, err)
+	assert.Equal(t, []string{legacyLocations[1], legacyLocations[0]}, members, "ordered by time")
+	score, err := fakeRedis.ZScore("1", legacyLocations[0])
+	require.NoError(t, err)
+	assert.Equal(t, float64(baseTime.Add(900*time.Millisecond).UnixNano()/int64(time.Millisecond)), score)
+	score, err = fakeRedis.ZScore("leaderboard", "player")
+	require.NoError(t, err)
+	assert.Equal(t, float64(1), score)
+
+	stats, err = driverloc.MigrateScores(ctx, redisClient, logger, 1 /* scanBatchSize */)
+	require.NoError(t, err)
+	assert.Equal(t, &driverloc.ScoreMigrationStats{DriversNum: 2, SkippedKeysNum: 1}, stats, "migration is idempotent")
+}
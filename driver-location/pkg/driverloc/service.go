@@ -2,8 +2,8 @@ package driverloc
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -12,13 +12,17 @@ import (
 )
 
 type UpdaterService interface {
-	UpdateLocations(ctx context.Context, driverID string, coordinates *Coordinates) error
+	// UpdateLocations saves the location of the driver at the time, saving the same location again is a no-op.
+	UpdateLocations(ctx context.Context, driverID string, coordinates *Coordinates, locationTime time.Time) error
+	BatchUpdateLocations(ctx context.Context, locations []*DriverCoordinates, locationTime time.Time) error
+	DeleteLocations(ctx context.Context, driverID string) error
 }
 
 //go:generate mockery --name UpdaterService
 
 type GetterService interface {
 	GetLocations(ctx context.Context, driverID string, timeInterval time.Duration) ([]*Location, error)
+	GetStatus(ctx context.Context, driverID string) (*DriverStatus, error)
 }
 
 //go:generate mockery --name GetterService
@@ -26,59 +30,135 @@ type GetterService interface {
 type Service interface {
 	UpdaterService
 	GetterService
+	HistoryService
 }
 
 // Improvement: move redis interaction to a separate storage layer and use an abstraction in service.
 type ServiceImpl struct {
 	redis                *redis.Client
+	publisher            Publisher
+	codec                LocationCodec
 	logger               log.FieldLogger
 	driverLocationsLimit int
+	onlineTimeout        time.Duration
 	timeNowFn            func() time.Time
 }
 
-func NewService(r *redis.Client, logger log.FieldLogger, driverLocationsLimit int) *ServiceImpl {
+func NewService(
+	r *redis.Client, publisher Publisher, codec LocationCodec, logger log.FieldLogger, driverLocationsLimit int,
+	onlineTimeout time.Duration,
+) *ServiceImpl {
 	return &ServiceImpl{
 		redis:                r,
+		publisher:            publisher,
+		codec:                codec,
 		logger:               logger,
 		driverLocationsLimit: driverLocationsLimit,
+		onlineTimeout:        onlineTimeout,
 		timeNowFn:            time.Now,
 	}
 }
 
-func (s *ServiceImpl) UpdateLocations(ctx context.Context, driverID string, coordinates *Coordinates) error {
-	now := s.timeNowFn().UTC()
-	loc := &Location{Coordinates: coordinates, Time: now}
-	locationData, err := json.Marshal(loc)
+// UpdateLocations saves the location and cleans the old ones in a single Redis transaction.
+// The location is stored as is, so a retried update adds no second member.
+func (s *ServiceImpl) UpdateLocations(
+	ctx context.Context, driverID string, coordinates *Coordinates, locationTime time.Time,
+) error {
+	loc := &Location{Coordinates: coordinates, Time: locationTime.UTC()}
+	locationData, err := s.codec.Encode(loc)
 	if err != nil {
-		return errors.Wrap(err, "failed to encode location data into json")
+		return err
 	}
 
 	redisSetMember := &redis.Z{
-		Score:  float64(now.Unix()),
+		Score:  timeToRedisScore(loc.Time),
 		Member: string(locationData),
 	}
 	ctxLogger := s.logger.WithField("driver_id", driverID)
-	ctxLogger.WithField("location", loc).Info("Save new driver location into Redis")
-	if err := s.redis.ZAdd(ctx, driverID, redisSetMember).Err(); err != nil {
+	ctxLogger.WithField("location", loc).Info("Save new driver location into Redis and clean old ones")
+	var cleaned *redis.IntCmd
+	_, err = s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, driverID, redisSetMember)
+		cleaned = pipe.ZRemRangeByRank(ctx, driverID, 0, -1-int64(s.driverLocationsLimit))
+		return nil
+	})
+	if err != nil {
 		return errors.Wrap(err, "failed to save new driver location into Redis")
 	}
+	ctxLogger.WithField("cleaned_num", cleaned.Val()).Info("Cleaned old driver locations in Redis")
+
+	s.publisher.Publish(&LocationUpdate{DriverID: driverID, Location: loc})
+	return nil
+}
+
+// DriverCoordinates are the coordinates of a driver in a batch update.
+type DriverCoordinates struct {
+	DriverID    string
+	Coordinates *Coordinates
+}
+
+// BatchUpdateLocations saves all the locations in a single Redis transaction, so a retried batch never stores
+// the locations saved by a failed attempt twice. The locations of the same driver are a millisecond apart,
+// so they keep their batch order, also when the oldest ones are cleaned by their scores.
+func (s *ServiceImpl) BatchUpdateLocations(
+	ctx context.Context, locations []*DriverCoordinates, locationTime time.Time,
+) error {
+	now := locationTime.UTC()
+	updates := make([]*LocationUpdate, len(locations))
+	var driverIDs []string
+	driverLocationsNum := make(map[string]int)
+	for i, location := range locations {
+		num := driverLocationsNum[location.DriverID]
+		if num == 0 {
+			driverIDs = append(driverIDs, location.DriverID)
+		}
+		driverLocationsNum[location.DriverID] = num + 1
+		updates[i] = &LocationUpdate{
+			DriverID: location.DriverID,
+			Location: &Location{Coordinates: location.Coordinates, Time: now.Add(time.Duration(num) * time.Millisecond)},
+		}
+	}
 
-	ctxLogger.Info("Clean old driver locations in Redis")
-	cleanedNum, err := s.redis.ZRemRangeByRank(ctx, driverID, 0, -1-int64(s.driverLocationsLimit)).Result()
+	s.logger.WithField("locations_num", len(updates)).Info("Save batch of driver locations into Redis")
+	_, err := s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, update := range updates {
+			locationData, err := s.codec.Encode(update.Location)
+			if err != nil {
+				return err
+			}
+			pipe.ZAdd(ctx, update.DriverID, &redis.Z{
+				Score:  timeToRedisScore(update.Time),
+				Member: string(locationData),
+			})
+		}
+		for _, driverID := range driverIDs {
+			pipe.ZRemRangeByRank(ctx, driverID, 0, -1-int64(s.driverLocationsLimit))
+		}
+		return nil
+	})
 	if err != nil {
-		return errors.Wrap(err, "failed to clean old driver locations in Redis")
+		return errors.Wrap(err, "failed to save batch of driver locations into Redis")
 	}
-	ctxLogger.WithField("cleaned_num", cleanedNum).Info("Cleaned old driver locations in Redis")
 
+	for _, update := range updates {
+		s.publisher.Publish(update)
+	}
 	return nil
 }
 
+// DeleteLocations removes the whole location history of the driver, deleting an unknown driver is a no-op.
+func (s *ServiceImpl) DeleteLocations(ctx context.Context, driverID string) error {
+	s.logger.WithField("driver_id", driverID).Info("Delete driver locations from Redis")
+	err := s.redis.Del(ctx, driverID).Err()
+	return errors.Wrap(err, "failed to delete driver locations from Redis")
+}
+
 func (s *ServiceImpl) GetLocations(ctx context.Context, driverID string, timeInterval time.Duration) (
 	[]*Location, error) {
 	now := s.timeNowFn().UTC()
-	minScore := timeToRedisScore(now.Add(-timeInterval))
+	minTime := now.Add(-timeInterval)
 	redisRange := &redis.ZRangeBy{
-		Min: fmt.Sprintf("%f", minScore),
+		Min: fmt.Sprintf("%f", timeToRedisScore(minTime)),
 		Max: "+inf",
 	}
 	ctxLogger := s.logger.WithField("driver_id", driverID)
@@ -93,21 +173,40 @@ func (s *ServiceImpl) GetLocations(ctx context.Context, driverID string, timeInt
 	ctxLogger.WithField("locations_num", len(locationsData)).Info("Retrieved driver locations from Redis")
 
 	locations, err := decodeLocations(locationsData)
-	return locations, errors.WithStack(err)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return sortLocations(locations, minTime), nil
+}
+
+// sortLocations drops the locations older than minTime, which scores can't tell apart below a millisecond,
+// and orders the rest strictly by time. Redis orders locations with the same score by their json instead.
+func sortLocations(locations []*Location, minTime time.Time) []*Location {
+	result := locations[:0]
+	for _, loc := range locations {
+		if !loc.Time.Before(minTime) {
+			result = append(result, loc)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Time.Before(result[j].Time) })
+	return result
 }
 
 func decodeLocations(locationsData []string) ([]*Location, error) {
 	locations := make([]*Location, len(locationsData))
 	for i, data := range locationsData {
-		loc := &Location{}
-		if err := json.Unmarshal([]byte(data), loc); err != nil {
-			return nil, errors.Wrapf(err, "can't decode location data %s", data)
+		loc, err := DecodeLocation([]byte(data))
+		if err != nil {
+			return nil, err
 		}
 		locations[i] = loc
 	}
 	return locations, nil
 }
 
+// timeToRedisScore returns the score of a location saved at the time, its unix time in milliseconds.
+// Scores are float64 which holds integers exactly only up to 2^53, so nanoseconds wouldn't fit.
+// Locations saved by older versions are scored in seconds, see MigrateScores.
 func timeToRedisScore(t time.Time) float64 {
-	return float64(t.Unix())
+	return float64(t.UnixNano() / int64(time.Millisecond))
 }
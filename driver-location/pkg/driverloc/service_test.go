@@ -17,6 +17,7 @@ import (
 const (
 	defaultDriverID      = "foo"
 	driverLocationsLimit = 3
+	onlineTimeout        = time.Minute
 )
 
 var (
@@ -31,12 +32,12 @@ func TestService_UpdateLocations(t *testing.T) {
 
 	insertLocations(t, service, []*toInsert{
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
-			fakeCurrentTime: baseTime.Add(0 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime.Add(0 * time.Second),
 		},
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
-			fakeCurrentTime: baseTime.Add(5 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
+			locationTime: baseTime.Add(5 * time.Second),
 		},
 	})
 
@@ -56,24 +57,24 @@ func TestService_UpdateLocations_OlderLocationsAreCleaned(t *testing.T) {
 
 	insertLocations(t, service, []*toInsert{
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
-			fakeCurrentTime: baseTime.Add(0 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime.Add(0 * time.Second),
 		},
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
-			fakeCurrentTime: baseTime.Add(5 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
+			locationTime: baseTime.Add(5 * time.Second),
 		},
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.862921, Longitude: 2.348211},
-			fakeCurrentTime: baseTime.Add(10 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.862921, Longitude: 2.348211},
+			locationTime: baseTime.Add(10 * time.Second),
 		},
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.861921, Longitude: 2.347211},
-			fakeCurrentTime: baseTime.Add(15 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.861921, Longitude: 2.347211},
+			locationTime: baseTime.Add(15 * time.Second),
 		},
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.860921, Longitude: 2.346211},
-			fakeCurrentTime: baseTime.Add(20 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.860921, Longitude: 2.346211},
+			locationTime: baseTime.Add(20 * time.Second),
 		},
 	})
 
@@ -94,12 +95,12 @@ func TestService_UpdateLocations_DuplicateCoordinatesAtDifferentTimes(t *testing
 
 	insertLocations(t, service, []*toInsert{
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
-			fakeCurrentTime: baseTime.Add(0 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime.Add(0 * time.Second),
 		},
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
-			fakeCurrentTime: baseTime.Add(5 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime.Add(5 * time.Second),
 		},
 	})
 
@@ -119,12 +120,12 @@ func TestService_UpdateLocations_DuplicateCoordinatesAtTheSameTime(t *testing.T)
 
 	insertLocations(t, service, []*toInsert{
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
-			fakeCurrentTime: baseTime,
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime,
 		},
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
-			fakeCurrentTime: baseTime,
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime,
 		},
 	})
 
@@ -143,12 +144,12 @@ func TestService_UpdateLocations_DifferentCoordinatesAtTheSameTime(t *testing.T)
 
 	insertLocations(t, service, []*toInsert{
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
-			fakeCurrentTime: baseTime,
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime,
 		},
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
-			fakeCurrentTime: baseTime,
+			coords:       &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
+			locationTime: baseTime,
 		},
 	})
 
@@ -161,6 +162,89 @@ func TestService_UpdateLocations_DifferentCoordinatesAtTheSameTime(t *testing.T)
 	assert.Equal(t, expected, actual)
 }
 
+func TestService_UpdateLocations_PublishesUpdate(t *testing.T) {
+	t.Parallel()
+	fakeRedis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer fakeRedis.Close()
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	hub := driverloc.NewHub(logger, 1 /* bufferSize */)
+	sub, err := hub.Subscribe(&driverloc.SubscriptionFilter{DriverIDs: []string{defaultDriverID}})
+	require.NoError(t, err)
+	service := driverloc.NewService(
+		redis.NewClient(&redis.Options{Addr: fakeRedis.Addr()}), hub, driverloc.JSONLocationCodec{}, logger,
+		driverLocationsLimit, onlineTimeout,
+	)
+
+	insertLocations(t, service, []*toInsert{
+		{
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime,
+		},
+	})
+
+	expected := &driverloc.LocationUpdate{
+		DriverID: defaultDriverID,
+		Location: &driverloc.Location{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			Time:        baseTime,
+		},
+	}
+	assert.Equal(t, expected, <-sub.Updates())
+}
+
+func TestService_BatchUpdateLocations(t *testing.T) {
+	t.Parallel()
+	service, fakeRedis := setupServiceWith(t, driverloc.JSONLocationCodec{}, 2 /* locationsLimit */)
+	defer fakeRedis.Close()
+	err := service.BatchUpdateLocations(ctx, []*driverloc.DriverCoordinates{
+		{DriverID: defaultDriverID, Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498}},
+		{DriverID: "bar", Coordinates: &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211}},
+		{DriverID: defaultDriverID, Coordinates: &driverloc.Coordinates{Latitude: 48.862921, Longitude: 2.348211}},
+		{DriverID: defaultDriverID, Coordinates: &driverloc.Coordinates{Latitude: 48.861921, Longitude: 2.347211}},
+	}, baseTime)
+	require.NoError(t, err)
+	service.SetTimeNowFn(func() time.Time { return baseTime })
+
+	// The locations of a driver keep their batch order and the oldest ones are cleaned.
+	actual, err := service.GetLocations(ctx, defaultDriverID, time.Minute)
+	require.NoError(t, err)
+	expected := []*driverloc.Location{
+		{Coordinates: &driverloc.Coordinates{Latitude: 48.862921, Longitude: 2.348211}, Time: baseTime.Add(time.Millisecond)},
+		{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.861921, Longitude: 2.347211},
+			Time:        baseTime.Add(2 * time.Millisecond),
+		},
+	}
+	assert.Equal(t, expected, actual)
+	barMembers, err := fakeRedis.ZMembers("bar")
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		`{"latitude":48.863921,"longitude":2.349211,"updated_at":"2020-11-07T00:00:00Z"}`,
+	}, barMembers)
+}
+
+func TestService_DeleteLocations(t *testing.T) {
+	t.Parallel()
+	service, fakeRedis := setupService(t)
+	defer fakeRedis.Close()
+	insertLocations(t, service, []*toInsert{
+		{
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime,
+		},
+	})
+
+	err := service.DeleteLocations(context.Background(), defaultDriverID)
+	require.NoError(t, err)
+
+	assert.False(t, fakeRedis.Exists(defaultDriverID))
+	// Deleting a driver without locations is a no-op.
+	err = service.DeleteLocations(context.Background(), defaultDriverID)
+	require.NoError(t, err)
+}
+
 func TestService_GetLocations(t *testing.T) {
 	t.Parallel()
 	service, fakeRedis := setupService(t)
@@ -168,16 +252,16 @@ func TestService_GetLocations(t *testing.T) {
 
 	insertLocations(t, service, []*toInsert{
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
-			fakeCurrentTime: baseTime.Add(0 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime.Add(0 * time.Second),
 		},
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
-			fakeCurrentTime: baseTime.Add(5 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
+			locationTime: baseTime.Add(5 * time.Second),
 		},
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.862921, Longitude: 2.348211},
-			fakeCurrentTime: baseTime.Add(10 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.862921, Longitude: 2.348211},
+			locationTime: baseTime.Add(10 * time.Second),
 		},
 	})
 
@@ -208,8 +292,8 @@ func TestService_GetLocations_NoLocationsInTheTimeInterval(t *testing.T) {
 
 	insertLocations(t, service, []*toInsert{
 		{
-			coords:          &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
-			fakeCurrentTime: baseTime.Add(0 * time.Second),
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime.Add(0 * time.Second),
 		},
 	})
 
@@ -238,30 +322,115 @@ func TestService_GetLocations_NoLocationsAtAll(t *testing.T) {
 	assert.Empty(t, actual)
 }
 
+func TestService_GetLocations_SameSecondBurst(t *testing.T) {
+	t.Parallel()
+	service, fakeRedis := setupService(t)
+	defer fakeRedis.Close()
+
+	// Locations json sorts in the reverse order of their time.
+	insertLocations(t, service, []*toInsert{
+		{
+			coords:       &driverloc.Coordinates{Latitude: 48.9, Longitude: 2.3},
+			locationTime: baseTime.Add(100 * time.Millisecond),
+		},
+		{
+			coords:       &driverloc.Coordinates{Latitude: 48.5, Longitude: 2.3},
+			locationTime: baseTime.Add(200*time.Millisecond + 100),
+		},
+		{
+			coords:       &driverloc.Coordinates{Latitude: 48.1, Longitude: 2.3},
+			locationTime: baseTime.Add(200*time.Millisecond + 200), // The same millisecond.
+		},
+	})
+
+	service.SetTimeNowFn(func() time.Time {
+		return baseTime.Add(time.Second)
+	})
+	actual, err := service.GetLocations(ctx, defaultDriverID, 10*time.Second)
+	require.NoError(t, err)
+
+	expected := []*driverloc.Location{
+		{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.9, Longitude: 2.3},
+			Time:        baseTime.Add(100 * time.Millisecond),
+		},
+		{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.5, Longitude: 2.3},
+			Time:        baseTime.Add(200*time.Millisecond + 100),
+		},
+		{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.1, Longitude: 2.3},
+			Time:        baseTime.Add(200*time.Millisecond + 200),
+		},
+	}
+	assert.Equal(t, expected, actual)
+}
+
+func TestService_GetLocations_SubSecondBoundary(t *testing.T) {
+	t.Parallel()
+	service, fakeRedis := setupService(t)
+	defer fakeRedis.Close()
+
+	insertLocations(t, service, []*toInsert{
+		{
+			coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+			locationTime: baseTime.Add(400 * time.Millisecond),
+		},
+		{
+			coords:       &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
+			locationTime: baseTime.Add(500*time.Millisecond + 100),
+		},
+		{
+			coords:       &driverloc.Coordinates{Latitude: 48.862921, Longitude: 2.348211},
+			locationTime: baseTime.Add(500*time.Millisecond + 300),
+		},
+	})
+
+	service.SetTimeNowFn(func() time.Time {
+		return baseTime.Add(10*time.Second + 500*time.Millisecond + 200)
+	})
+	actual, err := service.GetLocations(ctx, defaultDriverID, 10*time.Second)
+	require.NoError(t, err)
+
+	expected := []*driverloc.Location{
+		{
+			Coordinates: &driverloc.Coordinates{Latitude: 48.862921, Longitude: 2.348211},
+			Time:        baseTime.Add(500*time.Millisecond + 300),
+		},
+	}
+	assert.Equal(t, expected, actual)
+}
+
 func setupService(t *testing.T) (*driverloc.ServiceImpl, *miniredis.Miniredis) {
+	t.Helper()
+	return setupServiceWith(t, driverloc.JSONLocationCodec{}, driverLocationsLimit)
+}
+
+func setupServiceWith(t *testing.T, codec driverloc.LocationCodec, locationsLimit int) (
+	*driverloc.ServiceImpl, *miniredis.Miniredis) {
 	t.Helper()
 	fakeRedis, err := miniredis.Run()
 	require.NoError(t, err)
 	redisClient := redis.NewClient(&redis.Options{Addr: fakeRedis.Addr()})
 	logger := log.New()
 	logger.SetLevel(log.ErrorLevel)
-	s := driverloc.NewService(redisClient, logger, driverLocationsLimit)
+	s := driverloc.NewService(
+		redisClient, driverloc.NewHub(logger, 1 /* bufferSize */), codec, logger, locationsLimit,
+		onlineTimeout,
+	)
 	return s, fakeRedis
 }
 
 type toInsert struct {
-	coords          *driverloc.Coordinates
-	fakeCurrentTime time.Time
+	coords       *driverloc.Coordinates
+	locationTime time.Time
 }
 
 func insertLocations(t *testing.T, s *driverloc.ServiceImpl, inserts []*toInsert) {
 	t.Helper()
 	for _, insert := range inserts {
 		insert := insert
-		s.SetTimeNowFn(func() time.Time {
-			return insert.fakeCurrentTime
-		})
-		err := s.UpdateLocations(ctx, defaultDriverID, insert.coords)
+		err := s.UpdateLocations(ctx, defaultDriverID, insert.coords, insert.locationTime)
 		require.NoError(t, err)
 	}
 }
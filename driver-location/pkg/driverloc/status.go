@@ -0,0 +1,46 @@
+package driverloc
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// DriverStatusOnline means the driver has sent a location within the online timeout.
+	DriverStatusOnline = "online"
+	// DriverStatusOffline means the last location of the driver is older than the online timeout.
+	DriverStatusOffline = "offline"
+	// DriverStatusNeverSeen means the service has no locations of the driver.
+	DriverStatusNeverSeen = "never_seen"
+)
+
+type DriverStatus struct {
+	Status string `json:"status"`
+	// LastSeenAt is the time of the latest location, it's omitted for never seen drivers.
+	LastSeenAt *time.Time `json:"last_seen_at,omitempty"`
+}
+
+// GetStatus tells whether the driver is online by the time of their latest location.
+// The latest location is never cleaned, so it's the last-seen time until the locations are deleted.
+func (s *ServiceImpl) GetStatus(ctx context.Context, driverID string) (*DriverStatus, error) {
+	s.logger.WithField("driver_id", driverID).Info("Get latest driver location from Redis")
+	locationsData, err := s.redis.ZRevRange(ctx, driverID, 0, 0).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get latest driver location from Redis")
+	}
+	if len(locationsData) == 0 {
+		return &DriverStatus{Status: DriverStatusNeverSeen}, nil
+	}
+	loc, err := DecodeLocation([]byte(locationsData[0]))
+	if err != nil {
+		return nil, err
+	}
+	lastSeenAt := loc.Time
+	status := &DriverStatus{Status: DriverStatusOnline, LastSeenAt: &lastSeenAt}
+	if s.timeNowFn().Sub(lastSeenAt) > s.onlineTimeout {
+		status.Status = DriverStatusOffline
+	}
+	return status, nil
+}
@@ -0,0 +1,65 @@
+package driverloc_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+)
+
+func TestService_GetStatus(t *testing.T) {
+	t.Parallel()
+	lastSeenAt := baseTime.Add(5 * time.Second)
+	cases := []struct {
+		name        string
+		currentTime time.Time
+		expected    *driverloc.DriverStatus
+	}{
+		{
+			name:        "online",
+			currentTime: lastSeenAt.Add(onlineTimeout),
+			expected:    &driverloc.DriverStatus{Status: driverloc.DriverStatusOnline, LastSeenAt: &lastSeenAt},
+		},
+		{
+			name:        "offline",
+			currentTime: lastSeenAt.Add(onlineTimeout + time.Millisecond),
+			expected:    &driverloc.DriverStatus{Status: driverloc.DriverStatusOffline, LastSeenAt: &lastSeenAt},
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			service, fakeRedis := setupService(t)
+			defer fakeRedis.Close()
+			insertLocations(t, service, []*toInsert{
+				{
+					coords:       &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+					locationTime: baseTime,
+				},
+				{
+					coords:       &driverloc.Coordinates{Latitude: 48.863921, Longitude: 2.349211},
+					locationTime: lastSeenAt,
+				},
+			})
+			service.SetTimeNowFn(func() time.Time { return tc.currentTime })
+
+			actual, err := service.GetStatus(ctx, defaultDriverID)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestService_GetStatus_NeverSeen(t *testing.T) {
+	t.Parallel()
+	service, fakeRedis := setupService(t)
+	defer fakeRedis.Close()
+
+	actual, err := service.GetStatus(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Equal(t, &driverloc.DriverStatus{Status: driverloc.DriverStatusNeverSeen}, actual)
+}
@@ -0,0 +1,208 @@
+package driverloc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/georgysavva/driver-app/platform/httpapi"
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/gorilla/websocket"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	sseHeartbeatInterval = 15 * time.Second
+	wsPingInterval       = 30 * time.Second
+	wsPongTimeout        = wsPingInterval + 10*time.Second
+	wsWriteTimeout       = 10 * time.Second
+	bboxParamPartsNum    = 4
+)
+
+type streamAPI struct {
+	hub      *Hub
+	logger   log.FieldLogger
+	upgrader *websocket.Upgrader
+}
+
+// streamSSE streams location updates as Server-Sent Events, one "location" event per update.
+func (sa *streamAPI) streamSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		logging.LogUnhandledError(sa.logger, errors.New("response writer doesn't support flushing"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	filter, err := parseSubscriptionFilter(r)
+	if err != nil {
+		sa.logger.WithError(err).Info("Subscription filter is invalid, return 400")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub, err := sa.hub.Subscribe(filter)
+	if err != nil {
+		logging.LogUnhandledError(sa.logger, errors.Wrap(err, "failed to subscribe to the hub"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(sseHeartbeatInterval)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case update, ok := <-sub.Updates():
+			if !ok {
+				sa.logger.Info("Subscription ended, close SSE stream")
+				return
+			}
+			data, err := json.Marshal(update)
+			if err != nil {
+				logging.LogUnhandledError(sa.logger, errors.Wrap(err, "failed to encode location update"))
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: location\ndata: %s\n\n", data); err != nil {
+				sa.logger.WithError(err).Info("Couldn't write into SSE stream, close it")
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				sa.logger.WithError(err).Info("Couldn't write into SSE stream, close it")
+				return
+			}
+		case <-r.Context().Done():
+			sa.logger.Info("Client closed SSE stream")
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// streamWebSocket streams location updates as JSON WebSocket messages.
+// The initial filter comes from the query params, the client can replace it
+// at any moment by sending a SubscriptionFilter JSON message.
+func (sa *streamAPI) streamWebSocket(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseSubscriptionFilter(r)
+	if err != nil {
+		sa.logger.WithError(err).Info("Subscription filter is invalid, return 400")
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	sub, err := sa.hub.Subscribe(filter)
+	if err != nil {
+		logging.LogUnhandledError(sa.logger, errors.Wrap(err, "failed to subscribe to the hub"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	defer sub.Close()
+
+	conn, err := sa.upgrader.Upgrade(w, r, nil /* responseHeader */)
+	if err != nil {
+		// Upgrade has already replied to the client.
+		sa.logger.WithError(err).Info("Couldn't upgrade connection to WebSocket")
+		return
+	}
+	defer conn.Close() // nolint: errcheck
+
+	clientGone := make(chan struct{})
+	go sa.readWebSocketFilters(conn, sub, clientGone)
+
+	ping := time.NewTicker(wsPingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case update, ok := <-sub.Updates():
+			if !ok {
+				sa.logger.Info("Subscription ended, close WebSocket stream")
+				closeMessage := websocket.FormatCloseMessage(websocket.CloseGoingAway, "subscription ended")
+				_ = conn.WriteControl(websocket.CloseMessage, closeMessage, time.Now().Add(wsWriteTimeout)) // nolint: errcheck
+				return
+			}
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout)) // nolint: errcheck
+			if err := conn.WriteJSON(update); err != nil {
+				sa.logger.WithError(err).Info("Couldn't write into WebSocket stream, close it")
+				return
+			}
+		case <-ping.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteTimeout)); err != nil {
+				sa.logger.WithError(err).Info("Couldn't ping WebSocket client, close the stream")
+				return
+			}
+		case <-clientGone:
+			sa.logger.Info("Client closed WebSocket stream")
+			return
+		}
+	}
+}
+
+func (sa *streamAPI) readWebSocketFilters(conn *websocket.Conn, sub *Subscription, clientGone chan<- struct{}) {
+	defer close(clientGone)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongTimeout)) // nolint: errcheck
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+	for {
+		filter := &SubscriptionFilter{}
+		if err := conn.ReadJSON(filter); err != nil {
+			var syntaxErr *json.SyntaxError
+			var typeErr *json.UnmarshalTypeError
+			if errors.As(err, &syntaxErr) || errors.As(err, &typeErr) {
+				sa.logger.WithError(err).Info("Received malformed subscription filter, ignore it")
+				continue
+			}
+			return
+		}
+		if err := filter.Validate(); err != nil {
+			sa.logger.WithError(err).Info("Received invalid subscription filter, ignore it")
+			continue
+		}
+		sub.SetFilter(filter)
+	}
+}
+
+// parseSubscriptionFilter reads the filter from "driver_ids" and "bbox" query params.
+// "driver_ids" is a comma-separated list, "bbox" is "min_latitude,min_longitude,max_latitude,max_longitude".
+func parseSubscriptionFilter(r *http.Request) (*SubscriptionFilter, error) {
+	query := r.URL.Query()
+	filter := &SubscriptionFilter{}
+	if driverIDs := query.Get("driver_ids"); driverIDs != "" {
+		for _, driverID := range strings.Split(driverIDs, ",") {
+			if driverID = strings.TrimSpace(driverID); driverID != "" {
+				filter.DriverIDs = append(filter.DriverIDs, driverID)
+			}
+		}
+	}
+	if bboxParam := query.Get("bbox"); bboxParam != "" {
+		parts := strings.Split(bboxParam, ",")
+		if len(parts) != bboxParamPartsNum {
+			return nil, errors.New("'bbox' query param must contain exactly 4 comma-separated numbers")
+		}
+		values := make([]float64, len(parts))
+		for i, part := range parts {
+			value, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+			if err != nil {
+				return nil, errors.New("'bbox' query param must contain exactly 4 comma-separated numbers")
+			}
+			values[i] = value
+		}
+		filter.BoundingBox = &BoundingBox{
+			MinLatitude:  values[0],
+			MinLongitude: values[1],
+			MaxLatitude:  values[2],
+			MaxLongitude: values[3],
+		}
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return filter, nil
+}
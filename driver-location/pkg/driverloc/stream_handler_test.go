@@ -0,0 +1,170 @@
+package driverloc_test
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc/mocks"
+)
+
+func TestHTTP_StreamSSE(t *testing.T) {
+	t.Parallel()
+	ts, hub := setupStreamServer()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/locations/stream?driver_ids=" + defaultDriverID)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	hub.Publish(newLocationUpdate("bar", insideParis))
+	hub.Publish(newLocationUpdate(defaultDriverID, insideParis))
+
+	reader := bufio.NewReader(resp.Body)
+	event, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	data, err := reader.ReadString('\n')
+	require.NoError(t, err)
+
+	assert.Equal(t, "event: location\n", event)
+	require.True(t, strings.HasPrefix(data, "data: "))
+	expectedData := `
+	{
+		"driver_id": "foo",
+		"latitude": 48.864193,
+		"longitude": 2.350498,
+		"updated_at": "2020-11-07T00:00:00Z"
+	}`
+	assert.JSONEq(t, expectedData, strings.TrimPrefix(data, "data: "))
+}
+
+func TestHTTP_StreamSSE_StreamEndsWhenHubCloses(t *testing.T) {
+	t.Parallel()
+	ts, hub := setupStreamServer()
+	defer ts.Close()
+
+	resp, err := http.Get(ts.URL + "/locations/stream?bbox=48.815573,2.224199,48.902145,2.469920")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	hub.Close()
+	_, err = bufio.NewReader(resp.Body).ReadString('\n')
+
+	assert.Error(t, err)
+}
+
+func TestHTTP_StreamSSE_RequestError(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{
+			name:     "no filter",
+			query:    "",
+			expected: "subscription filter must contain driver ids or a bounding box\n",
+		},
+		{
+			name:     "bbox is not 4 numbers",
+			query:    "bbox=1,2,3",
+			expected: "'bbox' query param must contain exactly 4 comma-separated numbers\n",
+		},
+		{
+			name:     "bbox latitudes are swapped",
+			query:    "bbox=48.902145,2.224199,48.815573,2.469920",
+			expected: "bounding box min latitude must not exceed max latitude\n",
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ts, _ := setupStreamServer()
+			defer ts.Close()
+
+			resp, err := http.Get(ts.URL + "/locations/stream?" + tc.query)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			body, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+			assert.Equal(t, tc.expected, string(body))
+		})
+	}
+}
+
+func TestHTTP_StreamWebSocket(t *testing.T) {
+	t.Parallel()
+	ts, hub := setupStreamServer()
+	defer ts.Close()
+
+	conn := dialWebSocket(t, ts, "driver_ids=bar")
+	defer conn.Close()
+
+	update := newLocationUpdate("bar", insideParis)
+	hub.Publish(update)
+	received := &driverloc.LocationUpdate{}
+	require.NoError(t, conn.ReadJSON(received))
+	assert.Equal(t, update.DriverID, received.DriverID)
+	assert.Equal(t, update.Coordinates, received.Coordinates)
+
+	// The new filter is applied asynchronously, so keep publishing for both filters until it takes effect.
+	require.NoError(t, conn.WriteJSON(&driverloc.SubscriptionFilter{DriverIDs: []string{defaultDriverID}}))
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				hub.Publish(newLocationUpdate("bar", insideParis))
+				hub.Publish(newLocationUpdate(defaultDriverID, insideBerlin))
+			case <-done:
+				return
+			}
+		}
+	}()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	for received.DriverID != defaultDriverID {
+		require.NoError(t, conn.ReadJSON(received))
+	}
+	assert.Equal(t, insideBerlin, received.Coordinates)
+}
+
+func dialWebSocket(t *testing.T, ts *httptest.Server, rawQuery string) *websocket.Conn {
+	t.Helper()
+	serverURL, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+	wsURL := fmt.Sprintf("ws://%s/locations/ws?%s", serverURL.Host, rawQuery)
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, nil /* requestHeader */)
+	require.NoError(t, err)
+	resp.Body.Close()
+	return conn
+}
+
+func setupStreamServer() (*httptest.Server, *driverloc.Hub) {
+	logger := log.New()
+	logger.Level = log.ErrorLevel
+	hub := driverloc.NewHub(logger, 16 /* bufferSize */)
+	hh := driverloc.MakeHTTPHandler(&mocks.ReaderService{}, hub, logger)
+	ts := httptest.NewServer(hh)
+	return ts, hub
+}
@@ -0,0 +1,237 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package driverlocpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion7
+
+// DriverLocationClient is the client API for DriverLocation service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type DriverLocationClient interface {
+	GetLocations(ctx context.Context, in *GetLocationsRequest, opts ...grpc.CallOption) (*GetLocationsResponse, error)
+	// BatchGetLocations returns the locations of the drivers in the order of the requested ids.
+	BatchGetLocations(ctx context.Context, in *BatchGetLocationsRequest, opts ...grpc.CallOption) (*BatchGetLocationsResponse, error)
+	GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*DriverStatus, error)
+	// StreamLocations streams the accepted locations matching the filter until the client cancels the call.
+	StreamLocations(ctx context.Context, in *StreamLocationsRequest, opts ...grpc.CallOption) (DriverLocation_StreamLocationsClient, error)
+}
+
+type driverLocationClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewDriverLocationClient(cc grpc.ClientConnInterface) DriverLocationClient {
+	return &driverLocationClient{cc}
+}
+
+func (c *driverLocationClient) GetLocations(ctx context.Context, in *GetLocationsRequest, opts ...grpc.CallOption) (*GetLocationsResponse, error) {
+	out := new(GetLocationsResponse)
+	err := c.cc.Invoke(ctx, "/driverapp.driverloc.DriverLocation/GetLocations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverLocationClient) BatchGetLocations(ctx context.Context, in *BatchGetLocationsRequest, opts ...grpc.CallOption) (*BatchGetLocationsResponse, error) {
+	out := new(BatchGetLocationsResponse)
+	err := c.cc.Invoke(ctx, "/driverapp.driverloc.DriverLocation/BatchGetLocations", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverLocationClient) GetStatus(ctx context.Context, in *GetStatusRequest, opts ...grpc.CallOption) (*DriverStatus, error) {
+	out := new(DriverStatus)
+	err := c.cc.Invoke(ctx, "/driverapp.driverloc.DriverLocation/GetStatus", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *driverLocationClient) StreamLocations(ctx context.Context, in *StreamLocationsRequest, opts ...grpc.CallOption) (DriverLocation_StreamLocationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_DriverLocation_serviceDesc.Streams[0], "/driverapp.driverloc.DriverLocation/StreamLocations", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &driverLocationStreamLocationsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type DriverLocation_StreamLocationsClient interface {
+	Recv() (*LocationUpdate, error)
+	grpc.ClientStream
+}
+
+type driverLocationStreamLocationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *driverLocationStreamLocationsClient) Recv() (*LocationUpdate, error) {
+	m := new(LocationUpdate)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// DriverLocationServer is the server API for DriverLocation service.
+// All implementations must embed UnimplementedDriverLocationServer
+// for forward compatibility
+type DriverLocationServer interface {
+	GetLocations(context.Context, *GetLocationsRequest) (*GetLocationsResponse, error)
+	// BatchGetLocations returns the locations of the drivers in the order of the requested ids.
+	BatchGetLocations(context.Context, *BatchGetLocationsRequest) (*BatchGetLocationsResponse, error)
+	GetStatus(context.Context, *GetStatusRequest) (*DriverStatus, error)
+	// StreamLocations streams the accepted locations matching the filter until the client cancels the call.
+	StreamLocations(*StreamLocationsRequest, DriverLocation_StreamLocationsServer) error
+	mustEmbedUnimplementedDriverLocationServer()
+}
+
+// UnimplementedDriverLocationServer must be embedded to have forward compatible implementations.
+type UnimplementedDriverLocationServer struct {
+}
+
+func (UnimplementedDriverLocationServer) GetLocations(context.Context, *GetLocationsRequest) (*GetLocationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetLocations not implemented")
+}
+func (UnimplementedDriverLocationServer) BatchGetLocations(context.Context, *BatchGetLocationsRequest) (*BatchGetLocationsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetLocations not implemented")
+}
+func (UnimplementedDriverLocationServer) GetStatus(context.Context, *GetStatusRequest) (*DriverStatus, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetStatus not implemented")
+}
+func (UnimplementedDriverLocationServer) StreamLocations(*StreamLocationsRequest, DriverLocation_StreamLocationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLocations not implemented")
+}
+func (UnimplementedDriverLocationServer) mustEmbedUnimplementedDriverLocationServer() {}
+
+// UnsafeDriverLocationServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to DriverLocationServer will
+// result in compilation errors.
+type UnsafeDriverLocationServer interface {
+	mustEmbedUnimplementedDriverLocationServer()
+}
+
+func RegisterDriverLocationServer(s grpc.ServiceRegistrar, srv DriverLocationServer) {
+	s.RegisterService(&_DriverLocation_serviceDesc, srv)
+}
+
+func _DriverLocation_GetLocations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetLocationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverLocationServer).GetLocations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/driverapp.driverloc.DriverLocation/GetLocations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverLocationServer).GetLocations(ctx, req.(*GetLocationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DriverLocation_BatchGetLocations_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetLocationsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverLocationServer).BatchGetLocations(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/driverapp.driverloc.DriverLocation/BatchGetLocations",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverLocationServer).BatchGetLocations(ctx, req.(*BatchGetLocationsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DriverLocation_GetStatus_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetStatusRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(DriverLocationServer).GetStatus(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/driverapp.driverloc.DriverLocation/GetStatus",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(DriverLocationServer).GetStatus(ctx, req.(*GetStatusRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _DriverLocation_StreamLocations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamLocationsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(DriverLocationServer).StreamLocations(m, &driverLocationStreamLocationsServer{stream})
+}
+
+type DriverLocation_StreamLocationsServer interface {
+	Send(*LocationUpdate) error
+	grpc.ServerStream
+}
+
+type driverLocationStreamLocationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *driverLocationStreamLocationsServer) Send(m *LocationUpdate) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+var _DriverLocation_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "driverapp.driverloc.DriverLocation",
+	HandlerType: (*DriverLocationServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetLocations",
+			Handler:    _DriverLocation_GetLocations_Handler,
+		},
+		{
+			MethodName: "BatchGetLocations",
+			Handler:    _DriverLocation_BatchGetLocations_Handler,
+		},
+		{
+			MethodName: "GetStatus",
+			Handler:    _DriverLocation_GetStatus_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "StreamLocations",
+			Handler:       _DriverLocation_StreamLocations_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "driverloc.proto",
+}
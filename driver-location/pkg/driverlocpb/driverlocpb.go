@@ -0,0 +1,4 @@
+// Package driverlocpb contains the gRPC API of the driver location service generated from driverloc.proto.
+package driverlocpb
+
+//go:generate protoc --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. driverloc.proto
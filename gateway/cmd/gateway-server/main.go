@@ -1,65 +1,31 @@
 package main
 
 import (
-	"context"
-	"fmt"
-	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
-
-	"github.com/nsqio/go-nsq"
-	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
+	"github.com/georgysavva/driver-app/platform/runner"
 
 	"github.com/georgysavva/driver-app/gateway/pkg/config"
 	"github.com/georgysavva/driver-app/gateway/pkg/gateway"
-	"github.com/georgysavva/driver-app/gateway/pkg/httpmiddleware"
 )
 
-// Improvement: allow to pass a custom config path.
-const defaultConfigPath = "config.yaml"
+const configEnvPrefix = "GATEWAY"
 
 func main() {
-	logger := log.New()
-	logger.SetFormatter(&log.JSONFormatter{})
-	conf, err := config.ParseConfig(defaultConfigPath)
-	if err != nil {
-		logger.WithError(err).Fatal("Failed to parse config")
-	}
-	nsqProducer, err := nsq.NewProducer(conf.NSQ.DaemonAddress, nsq.NewConfig())
-	if err != nil {
-		logger.WithError(err).Fatal("Failed to connect nsq producer to daemon")
-	}
+	app := runner.New()
+	logger := app.Logger()
+	conf := &config.Config{}
+	app.LoadConfig(configEnvPrefix, conf)
+
+	nsqProducer := app.StartNSQProducer(conf.NSQ)
 	nsqProxyFactory := gateway.NewNSQProxyFactory(nsqProducer, logger.WithField("component", "nsq-proxy"))
 
-	gatewayHandler, err := gateway.NewGateway(nsqProxyFactory, conf.URLs)
+	grpcProxyFactory := gateway.NewGRPCProxyFactory(logger.WithField("component", "grpc-proxy"))
+	app.AddCloser("gRPC connections", grpcProxyFactory)
+
+	gatewayHandler, err := gateway.NewGateway(nsqProxyFactory, grpcProxyFactory, conf.URLs)
 	if err != nil {
 		logger.WithError(err).Fatal("Couldn't setup gateway handler")
 	}
+	app.StartHTTPServer(conf.HTTPServer, gatewayHandler)
 
-	gatewayHandler = httpmiddleware.NewLoggingMiddleware(gatewayHandler, logger)
-	httpServer := http.Server{Addr: fmt.Sprintf(":%d", conf.HTTPServer.Port), Handler: gatewayHandler}
-	go func() {
-		if err := httpServer.ListenAndServe(); err != nil && errors.Is(err, http.ErrServerClosed) {
-			logger.WithError(err).Fatal("HTTP server unexpectedly stopped")
-		}
-	}()
-	logger.WithField("http_port", conf.HTTPServer.Port).Info("HTTP server successfully started")
-
-	terminationChan := make(chan os.Signal, 1)
-	signal.Notify(terminationChan, syscall.SIGINT, syscall.SIGTERM)
-	<-terminationChan
-
-	logger.WithField("shutdown_timeout", conf.HTTPServer.ShutdownTimeout).Info("Stopping http server")
-	ctx, cancel := context.WithTimeout(context.Background(), conf.HTTPServer.ShutdownTimeout)
-	defer cancel()
-	if err := httpServer.Shutdown(ctx); err != nil {
-		logger.WithError(err).Fatal("Couldn't properly shutdown http server")
-	}
-	logger.Info("HTTP server was successfully shutdown")
-
-	logger.Info("Stopping NSQ producer")
-	nsqProducer.Stop()
-	logger.Info("NSQ producer stopped")
+	app.Wait()
 }
@@ -1,39 +1,25 @@
 package config
 
 import (
-	"io/ioutil"
-	"path/filepath"
-	"time"
-
+	"github.com/georgysavva/driver-app/platform/runner"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 
 	"github.com/georgysavva/driver-app/gateway/pkg/gateway"
 )
 
 type Config struct {
-	URLs []*gateway.Endpoint `yaml:"urls"`
+	URLs []*gateway.Endpoint `yaml:"urls" validate:"required"`
 
-	HTTPServer *struct {
-		Port            int           `yaml:"port"`
-		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
-	} `yaml:"http_server"`
+	HTTPServer *runner.HTTPServerConfig `yaml:"http_server"`
 
-	NSQ *struct {
-		DaemonAddress string `yaml:"daemon_address"`
-	} `yaml:"nsq"`
+	NSQ *runner.NSQProducerConfig `yaml:"nsq"`
 }
 
-func ParseConfig(configPath string) (*Config, error) {
-	fileContent, err := ioutil.ReadFile(filepath.Clean(configPath))
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to read config file content")
-	}
-
-	conf := &Config{}
-	if err := yaml.Unmarshal(fileContent, conf); err != nil {
-		return nil, errors.Wrap(err, "failed to parse yaml content into Config struct")
+func (c *Config) Validate() error {
+	for i, endpoint := range c.URLs {
+		if err := endpoint.Validate(); err != nil {
+			return errors.Wrapf(err, "urls.%d", i)
+		}
 	}
-
-	return conf, nil
+	return nil
 }
@@ -12,10 +12,12 @@ import (
 const httpUpstreamScheme = "http"
 
 type Endpoint struct {
-	Path   string         `yaml:"path"`
-	Method string         `yaml:"method"`
-	NSQ    *NSQProxyConf  `yaml:"nsq"`
-	HTTP   *HTTPProxyConf `yaml:"http"`
+	Path   string           `yaml:"path"`
+	Method string           `yaml:"method"`
+	NSQ    *NSQProxyConf    `yaml:"nsq"`
+	HTTP   *HTTPProxyConf   `yaml:"http"`
+	Stream *StreamProxyConf `yaml:"stream"`
+	GRPC   *GRPCProxyConf   `yaml:"grpc"`
 }
 
 type NSQProxyConf struct {
@@ -25,30 +27,80 @@ type NSQProxyConf struct {
 
 type NSQMessageConf struct {
 	Command string `yaml:"command"`
+	// Encoding of the message envelope: "json", the default, or "protobuf".
+	Encoding string `yaml:"encoding"`
 }
 
 type HTTPProxyConf struct {
 	Host string `yaml:"host"`
 }
 
-func NewGateway(nsqFactory *NSQProxyFactory, endpoints []*Endpoint) (http.Handler, error) {
+// StreamProxyConf configures proxying of long-lived streaming requests:
+// WebSocket connections are upgraded end-to-end and Server-Sent Events are flushed to the client immediately.
+type StreamProxyConf struct {
+	Host string `yaml:"host"`
+}
+
+// GRPCProxyConf configures transcoding of JSON requests into gRPC calls of the method,
+// the upstream must have the gRPC server reflection enabled.
+type GRPCProxyConf struct {
+	Host string `yaml:"host"`
+	// Method is the full name of the method, e.g. "driverapp.zombiedriver.ZombieDriver/GetDriver".
+	Method string `yaml:"method"`
+}
+
+func (e *Endpoint) Validate() error {
+	proxiesNum := 0
+	for _, isSet := range []bool{e.NSQ != nil, e.HTTP != nil, e.Stream != nil, e.GRPC != nil} {
+		if isSet {
+			proxiesNum++
+		}
+	}
+	if proxiesNum > 1 {
+		return errors.Errorf("endpoint must contain exactly one of nsq, http, stream or grpc proxy configs, not many: %+v", e)
+	}
+	if proxiesNum == 0 {
+		return errors.Errorf("endpoint must contain exactly one of nsq, http, stream or grpc proxy configs, not none: %+v", e)
+	}
+	if e.HTTP != nil && e.HTTP.Host == "" {
+		return errors.Errorf("endpoint has an empty http host: %+v", e)
+	}
+	if e.Stream != nil && e.Stream.Host == "" {
+		return errors.Errorf("endpoint has an empty stream host: %+v", e)
+	}
+	if e.GRPC != nil && (e.GRPC.Host == "" || e.GRPC.Method == "") {
+		return errors.Errorf("endpoint has an empty grpc host or method: %+v", e)
+	}
+	return nil
+}
+
+func NewGateway(
+	nsqFactory *NSQProxyFactory, grpcFactory *GRPCProxyFactory, endpoints []*Endpoint,
+) (http.Handler, error) {
 	router := mux.NewRouter()
 	for _, endpoint := range endpoints {
-		if endpoint.HTTP != nil && endpoint.NSQ != nil {
-			return nil, errors.Errorf("endpoint must contain either nsq or http proxy configs, not both: %+v", endpoint)
-		}
-		if endpoint.HTTP == nil && endpoint.NSQ == nil {
-			return nil, errors.Errorf("endpoint must contain either nsq or http proxy configs, not none: %+v", endpoint)
+		if err := endpoint.Validate(); err != nil {
+			return nil, errors.WithStack(err)
 		}
 		var proxyHandler http.Handler
-		if endpoint.HTTP != nil {
+		switch {
+		case endpoint.HTTP != nil:
 			proxyConf := endpoint.HTTP
-			if proxyConf.Host == "" {
-				return nil, errors.Errorf("endpoint has an empty http host: %+v", endpoint)
-			}
 			targetURL := &url.URL{Scheme: httpUpstreamScheme, Host: proxyConf.Host}
 			proxyHandler = httputil.NewSingleHostReverseProxy(targetURL)
-		} else {
+		case endpoint.Stream != nil:
+			proxyConf := endpoint.Stream
+			targetURL := &url.URL{Scheme: httpUpstreamScheme, Host: proxyConf.Host}
+			streamProxy := httputil.NewSingleHostReverseProxy(targetURL)
+			// ReverseProxy handles connection upgrades itself, so only events flushing needs to be configured.
+			streamProxy.FlushInterval = -1
+			proxyHandler = streamProxy
+		case endpoint.GRPC != nil:
+			var err error
+			if proxyHandler, err = grpcFactory.NewProxy(endpoint.GRPC); err != nil {
+				return nil, errors.Wrapf(err, "can't initialize grpc proxy for endpoint: %+v", endpoint)
+			}
+		default:
 			proxyConf := endpoint.NSQ
 			var err error
 			if proxyHandler, err = nsqFactory.NewProxy(proxyConf); err != nil {
@@ -6,8 +6,11 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 
+	"github.com/georgysavva/driver-app/platform/envelope"
+	"github.com/gorilla/websocket"
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -36,7 +39,7 @@ func TestNSQProxy(t *testing.T) {
 			},
 		},
 	}
-	gatewayHandler, err := gateway.NewGateway(proxyFactory, endpoints)
+	gatewayHandler, err := gateway.NewGateway(proxyFactory, nil /* grpcFactory */, endpoints)
 	require.NoError(t, err)
 	ts := httptest.NewServer(gatewayHandler)
 	defer ts.Close()
@@ -46,6 +49,144 @@ func TestNSQProxy(t *testing.T) {
 	assert.Equal(t, response.StatusCode, http.StatusOK)
 	assert.Equal(t, responseText, "OK\n")
 	producerMock.AssertExpectations(t)
+	msg, err := envelope.Unmarshal(producerMock.Calls[0].Arguments.Get(1).([]byte))
+	require.NoError(t, err)
+	assert.Equal(t, envelope.CurrentVersion, msg.Version)
+	assert.Equal(t, "test_command", msg.Command)
+	assert.NotEmpty(t, msg.ID)
+}
+
+func TestNSQProxy_TypedPayload(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name               string
+		encoding           string
+		body               string
+		expectedStatusCode int
+	}{
+		{
+			name:               "json",
+			encoding:           envelope.EncodingJSON,
+			body:               `{"latitude": 48.864193, "longitude": 2.350498}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "protobuf",
+			encoding:           envelope.EncodingProtobuf,
+			body:               `{"latitude": 48.864193, "longitude": 2.350498}`,
+			expectedStatusCode: http.StatusOK,
+		},
+		{
+			name:               "payload is invalid",
+			encoding:           envelope.EncodingJSON,
+			body:               `{"latitude": 48.864193}`,
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			producerMock := &mocks.NSQProducer{}
+			producerMock.On("Publish", "locations", mock.AnythingOfType("[]uint8")).Return(nil)
+			logger := log.New()
+			logger.SetLevel(log.ErrorLevel)
+			endpoints := []*gateway.Endpoint{
+				{
+					Path:   "/drivers/{driver_id}/locations",
+					Method: "PATCH",
+					NSQ: &gateway.NSQProxyConf{
+						Topic: "locations",
+						Message: &gateway.NSQMessageConf{
+							Command:  envelope.CommandUpdateDriverLocations,
+							Encoding: tc.encoding,
+						},
+					},
+				},
+			}
+			gatewayHandler, err := gateway.NewGateway(
+				gateway.NewNSQProxyFactory(producerMock, logger), nil /* grpcFactory */, endpoints,
+			)
+			require.NoError(t, err)
+			ts := httptest.NewServer(gatewayHandler)
+			defer ts.Close()
+
+			req, err := http.NewRequest("PATCH", ts.URL+"/drivers/42/locations", strings.NewReader(tc.body))
+			require.NoError(t, err)
+			req.Header.Set("traceparent", "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01")
+			response, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			response.Body.Close()
+
+			require.Equal(t, tc.expectedStatusCode, response.StatusCode)
+			if tc.expectedStatusCode != http.StatusOK {
+				producerMock.AssertNumberOfCalls(t, "Publish", 0)
+				return
+			}
+			msg, err := envelope.Unmarshal(producerMock.Calls[0].Arguments.Get(1).([]byte))
+			require.NoError(t, err)
+			assert.Equal(t, envelope.CommandUpdateDriverLocations, msg.Command)
+			assert.Equal(t, "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01", msg.Trace.TraceParent)
+			assert.JSONEq(t, `{"driver_id": "42", "latitude": 48.864193, "longitude": 2.350498}`, string(msg.Payload))
+		})
+	}
+}
+
+func TestNSQProxy_IdempotencyKey(t *testing.T) {
+	t.Parallel()
+	producerMock := &mocks.NSQProducer{}
+	producerMock.On("Publish", "locations", mock.AnythingOfType("[]uint8")).Return(nil)
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	endpoints := []*gateway.Endpoint{
+		{
+			Path:   "/drivers/{driver_id}/locations",
+			Method: "PATCH",
+			NSQ: &gateway.NSQProxyConf{
+				Topic:   "locations",
+				Message: &gateway.NSQMessageConf{Command: envelope.CommandUpdateDriverLocations},
+			},
+		},
+	}
+	gatewayHandler, err := gateway.NewGateway(
+		gateway.NewNSQProxyFactory(producerMock, logger), nil /* grpcFactory */, endpoints,
+	)
+	require.NoError(t, err)
+	ts := httptest.NewServer(gatewayHandler)
+	defer ts.Close()
+
+	requests := []struct{ driverID, idempotencyKey string }{
+		{driverID: "42", idempotencyKey: "1"},
+		{driverID: "42", idempotencyKey: "1"}, // A retry.
+		{driverID: "43", idempotencyKey: "1"}, // Another driver reusing the key.
+		{driverID: "42", idempotencyKey: ""},
+	}
+	for _, request := range requests {
+		req, err := http.NewRequest(
+			"PATCH", ts.URL+"/drivers/"+request.driverID+"/locations",
+			strings.NewReader(`{"latitude": 48.864193, "longitude": 2.350498}`),
+		)
+		require.NoError(t, err)
+		req.Header.Set("Idempotency-Key", request.idempotencyKey)
+		response, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		response.Body.Close()
+		require.Equal(t, http.StatusOK, response.StatusCode)
+	}
+
+	messageIDs := make([]string, len(producerMock.Calls))
+	for i, call := range producerMock.Calls {
+		msg, err := envelope.Unmarshal(call.Arguments.Get(1).([]byte))
+		require.NoError(t, err)
+		messageIDs[i] = msg.ID
+	}
+	require.Len(t, messageIDs, 4)
+	assert.Equal(t, messageIDs[0], messageIDs[1])
+	assert.NotEqual(t, messageIDs[0], messageIDs[2])
+	assert.NotEqual(t, messageIDs[0], messageIDs[3])
+	for _, messageID := range messageIDs {
+		assert.Len(t, messageID, 32)
+	}
 }
 
 func TestHTTPProxy(t *testing.T) {
@@ -67,7 +208,7 @@ func TestHTTPProxy(t *testing.T) {
 			},
 		},
 	}
-	gatewayHandler, err := gateway.NewGateway(nil /* nsqFactory */, endpoints)
+	gatewayHandler, err := gateway.NewGateway(nil /* nsqFactory */, nil /* grpcFactory */, endpoints)
 	require.NoError(t, err)
 	ts := httptest.NewServer(gatewayHandler)
 	defer ts.Close()
@@ -78,6 +219,49 @@ func TestHTTPProxy(t *testing.T) {
 	assert.Equal(t, responseText, "OK\n")
 }
 
+func TestStreamProxy(t *testing.T) {
+	t.Parallel()
+	upgrader := &websocket.Upgrader{}
+	backendServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil /* responseHeader */)
+		require.NoError(t, err)
+		defer conn.Close()
+		messageType, message, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.NoError(t, conn.WriteMessage(messageType, message))
+	}))
+	defer backendServer.Close()
+	backendServerURL, err := url.Parse(backendServer.URL)
+	require.NoError(t, err)
+
+	endpoints := []*gateway.Endpoint{
+		{
+			Path:   "/ws",
+			Method: "GET",
+			Stream: &gateway.StreamProxyConf{
+				Host: backendServerURL.Host,
+			},
+		},
+	}
+	gatewayHandler, err := gateway.NewGateway(nil /* nsqFactory */, nil /* grpcFactory */, endpoints)
+	require.NoError(t, err)
+	ts := httptest.NewServer(gatewayHandler)
+	defer ts.Close()
+	serverURL, err := url.Parse(ts.URL)
+	require.NoError(t, err)
+
+	conn, resp, err := websocket.DefaultDialer.Dial(fmt.Sprintf("ws://%s/ws", serverURL.Host), nil /* requestHeader */)
+	require.NoError(t, err)
+	defer conn.Close()
+	resp.Body.Close()
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("ping")))
+	_, message, err := conn.ReadMessage()
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "ping", string(message))
+}
+
 func callEndpoint(t *testing.T, ts *httptest.Server) (*http.Response, string) {
 	t.Helper()
 	serverURL, err := url.Parse(ts.URL)
@@ -0,0 +1,367 @@
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/georgysavva/driver-app/platform/httpapi"
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// resolveTimeout bounds the server reflection call, the method is resolved apart from the request that needs it.
+const resolveTimeout = 5 * time.Second
+
+// responseMarshalOptions produce the same JSON as the HTTP APIs of the services: their protos name the fields
+// like the JSON and mark the fields omitted from it as optional, so they are only emitted when set.
+var responseMarshalOptions = protojson.MarshalOptions{UseProtoNames: true, EmitUnpopulated: true}
+
+// GRPCProxyFactory shares a single connection between all the proxies to the same upstream host.
+type GRPCProxyFactory struct {
+	logger log.FieldLogger
+
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn
+}
+
+func NewGRPCProxyFactory(logger log.FieldLogger) *GRPCProxyFactory {
+	return &GRPCProxyFactory{logger: logger, conns: make(map[string]*grpc.ClientConn)}
+}
+
+// GRPCProxy transcodes HTTP requests into calls of a gRPC method and the response messages into JSON.
+// It learns the method messages from the upstream server reflection on the first request,
+// so the gateway doesn't depend on the upstream protos and starts even if the upstream is down.
+// The messages are resolved once, the gateway must be restarted to pick up the fields added later.
+type GRPCProxy struct {
+	conn       *grpc.ClientConn
+	logger     log.FieldLogger
+	service    protoreflect.FullName
+	methodName protoreflect.Name
+	fullMethod string
+
+	mu     sync.Mutex
+	method protoreflect.MethodDescriptor
+}
+
+func (gpf *GRPCProxyFactory) NewProxy(conf *GRPCProxyConf) (*GRPCProxy, error) {
+	service, methodName, err := parseGRPCMethod(conf.Method)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := gpf.getConn(conf.Host)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCProxy{
+		conn:       conn,
+		logger:     gpf.logger.WithField("grpc_method", conf.Method),
+		service:    protoreflect.FullName(service),
+		methodName: protoreflect.Name(methodName),
+		fullMethod: "/" + conf.Method,
+	}, nil
+}
+
+// Close closes the connections to all upstreams.
+func (gpf *GRPCProxyFactory) Close() error {
+	gpf.mu.Lock()
+	defer gpf.mu.Unlock()
+	var firstErr error
+	for host, conn := range gpf.conns {
+		if err := conn.Close(); err != nil && firstErr == nil {
+			firstErr = errors.Wrapf(err, "failed to close grpc connection to %s", host)
+		}
+		delete(gpf.conns, host)
+	}
+	return firstErr
+}
+
+func (gpf *GRPCProxyFactory) getConn(host string) (*grpc.ClientConn, error) {
+	gpf.mu.Lock()
+	defer gpf.mu.Unlock()
+	if conn, ok := gpf.conns[host]; ok {
+		return conn, nil
+	}
+	// The connection is established lazily on the first call.
+	conn, err := grpc.Dial(host, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't initialize grpc connection to %s", host)
+	}
+	gpf.conns[host] = conn
+	return conn, nil
+}
+
+// parseGRPCMethod splits the full method name, e.g. "driverapp.zombiedriver.ZombieDriver/GetDriver",
+// into the service and the method names.
+func parseGRPCMethod(fullMethod string) (string, string, error) {
+	parts := strings.Split(fullMethod, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", errors.Errorf("grpc method %q must be of the form package.Service/Method", fullMethod)
+	}
+	return parts[0], parts[1], nil
+}
+
+// ServeHTTP merges the path vars, the query params and the JSON body into the request message.
+// Unary responses are returned as a JSON object, server streaming ones as newline delimited JSON objects.
+func (gp *GRPCProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	method, err := gp.resolveMethod()
+	if err != nil {
+		logging.LogUnhandledError(gp.logger, errors.Wrap(err, "can't resolve upstream grpc method"))
+		http.Error(w, "Bad gateway", http.StatusBadGateway)
+		return
+	}
+	request, err := decodeGRPCRequest(r, method.Input())
+	if err != nil {
+		gp.logger.WithError(err).Info("Request data is invalid for the grpc method")
+		http.Error(w, errors.Wrap(err, "request data validation failed").Error(), http.StatusBadRequest)
+		return
+	}
+	ctx := r.Context()
+	if trace := getTraceContext(r); trace != nil {
+		ctx = metadata.AppendToOutgoingContext(ctx, "traceparent", trace.TraceParent, "tracestate", trace.TraceState)
+	}
+	if method.IsStreamingServer() {
+		gp.serveStream(ctx, w, method, request)
+		return
+	}
+
+	response := dynamicpb.NewMessage(method.Output())
+	if err := gp.conn.Invoke(ctx, gp.fullMethod, request, response); err != nil {
+		gp.writeError(w, err)
+		return
+	}
+	data, err := responseMarshalOptions.Marshal(response)
+	if err != nil {
+		logging.LogUnhandledError(gp.logger, errors.Wrap(err, "can't encode grpc response into json"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		gp.logger.WithError(err).Info("Couldn't write grpc response to the client")
+	}
+}
+
+// serveStream replies with the upstream error if the stream fails before the headers are received,
+// later failures can only end the response.
+func (gp *GRPCProxy) serveStream(
+	ctx context.Context, w http.ResponseWriter, method protoreflect.MethodDescriptor, request proto.Message,
+) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		logging.LogUnhandledError(gp.logger, errors.New("response writer doesn't support flushing"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := gp.conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true}, gp.fullMethod)
+	if err != nil {
+		gp.writeError(w, err)
+		return
+	}
+	if err := stream.SendMsg(request); err != nil {
+		gp.writeError(w, err)
+		return
+	}
+	if err := stream.CloseSend(); err != nil {
+		gp.writeError(w, err)
+		return
+	}
+	if _, err := stream.Header(); err != nil {
+		gp.writeError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	for {
+		response := dynamicpb.NewMessage(method.Output())
+		if err := stream.RecvMsg(response); err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				gp.logger.WithError(err).Info("Upstream grpc stream failed, close the response")
+			}
+			return
+		}
+		data, err := responseMarshalOptions.Marshal(response)
+		if err != nil {
+			logging.LogUnhandledError(gp.logger, errors.Wrap(err, "can't encode grpc response into json"))
+			return
+		}
+		if _, err := w.Write(append(data, '\n')); err != nil {
+			gp.logger.WithError(err).Info("Couldn't write into the stream, close it")
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+func (gp *GRPCProxy) writeError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	statusCode := httpStatusFromCode(st.Code())
+	if statusCode == http.StatusInternalServerError {
+		logging.LogUnhandledError(gp.logger, errors.Wrap(err, "upstream grpc call failed"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	gp.logger.WithError(err).Infof("Upstream grpc call failed, return %d", statusCode)
+	http.Error(w, st.Message(), statusCode)
+}
+
+// resolveMethod returns the resolved method or asks the upstream server reflection for it.
+// The reflection call is made without the lock, so a slow upstream doesn't block the requests of a resolved method,
+// concurrent first requests may resolve it more than once.
+func (gp *GRPCProxy) resolveMethod() (protoreflect.MethodDescriptor, error) {
+	gp.mu.Lock()
+	method := gp.method
+	gp.mu.Unlock()
+	if method != nil {
+		return method, nil
+	}
+	method, err := gp.fetchMethod()
+	if err != nil {
+		return nil, err
+	}
+	gp.mu.Lock()
+	defer gp.mu.Unlock()
+	if gp.method == nil {
+		gp.method = method
+	}
+	return gp.method, nil
+}
+
+func (gp *GRPCProxy) fetchMethod() (protoreflect.MethodDescriptor, error) {
+	// The request context isn't used, the request may be canceled while the method is still needed by others.
+	ctx, cancel := context.WithTimeout(context.Background(), resolveTimeout)
+	defer cancel()
+	files, err := fetchFileDescriptors(ctx, gp.conn, string(gp.service))
+	if err != nil {
+		return nil, err
+	}
+	descriptor, err := files.FindDescriptorByName(gp.service)
+	if err != nil {
+		return nil, errors.Wrapf(err, "can't find grpc service %s", gp.service)
+	}
+	service, ok := descriptor.(protoreflect.ServiceDescriptor)
+	if !ok {
+		return nil, errors.Errorf("%s isn't a grpc service", gp.service)
+	}
+	method := service.Methods().ByName(gp.methodName)
+	if method == nil {
+		return nil, errors.Errorf("grpc service %s has no method %s", gp.service, gp.methodName)
+	}
+	if method.IsStreamingClient() {
+		return nil, errors.Errorf("client streaming grpc method %s can't be transcoded", gp.fullMethod)
+	}
+	return method, nil
+}
+
+// fetchFileDescriptors asks the upstream server reflection for the file defining the symbol,
+// the response includes all the files it depends on.
+func fetchFileDescriptors(ctx context.Context, conn *grpc.ClientConn, symbol string) (*protoregistry.Files, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := reflectionpb.NewServerReflectionClient(conn).ServerReflectionInfo(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to call grpc server reflection")
+	}
+	err = stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_FileContainingSymbol{FileContainingSymbol: symbol},
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to send grpc server reflection request")
+	}
+	response, err := stream.Recv()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to receive grpc server reflection response")
+	}
+	if errorResponse := response.GetErrorResponse(); errorResponse != nil {
+		return nil, errors.Errorf("grpc server reflection failed: %s", errorResponse.ErrorMessage)
+	}
+	fileSet := &descriptorpb.FileDescriptorSet{}
+	for _, data := range response.GetFileDescriptorResponse().GetFileDescriptorProto() {
+		file := &descriptorpb.FileDescriptorProto{}
+		if err := proto.Unmarshal(data, file); err != nil {
+			return nil, errors.Wrap(err, "can't decode file descriptor from grpc server reflection")
+		}
+		fileSet.File = append(fileSet.File, file)
+	}
+	files, err := protodesc.NewFiles(fileSet)
+	return files, errors.Wrap(err, "can't build file descriptors from grpc server reflection")
+}
+
+// decodeGRPCRequest builds the request message from the request data like the nsq proxy builds the payload,
+// the query params are added as strings, so they must be string or number fields.
+func decodeGRPCRequest(r *http.Request, messageDescriptor protoreflect.MessageDescriptor) (proto.Message, error) {
+	requestData := map[string]interface{}{}
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "request body parsing failed")
+	}
+	for key, values := range r.URL.Query() {
+		if _, ok := requestData[key]; ok {
+			continue
+		}
+		if len(values) == 1 {
+			requestData[key] = values[0]
+		} else {
+			requestData[key] = values
+		}
+	}
+	data, err := json.Marshal(mergeRequestData(mux.Vars(r), requestData))
+	if err != nil {
+		return nil, errors.Wrap(err, "can't encode request data")
+	}
+	message := dynamicpb.NewMessage(messageDescriptor)
+	if err := protojson.Unmarshal(data, message); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return message, nil
+}
+
+// httpStatusFromCode maps the gRPC status codes to the HTTP ones like the gRPC HTTP mapping guidelines.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return http.StatusOK
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return http.StatusBadRequest
+	case codes.Unauthenticated:
+		return http.StatusUnauthorized
+	case codes.PermissionDenied:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.AlreadyExists, codes.Aborted:
+		return http.StatusConflict
+	case codes.ResourceExhausted:
+		return http.StatusTooManyRequests
+	case codes.Unimplemented:
+		return http.StatusNotImplemented
+	case codes.Unavailable:
+		return http.StatusServiceUnavailable
+	case codes.DeadlineExceeded:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
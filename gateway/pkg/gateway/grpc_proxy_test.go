@@ -0,0 +1,207 @@
+package gateway_test
+
+import (
+	"bufio"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/georgysavva/driver-app/gateway/pkg/gateway"
+)
+
+func TestGRPCProxy_Unary(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name                 string
+		path                 string
+		expectedStatusCode   int
+		expectedResponseText string
+	}{
+		{
+			name:                 "path var",
+			path:                 "/health/test-service",
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseText: `{"status":"SERVING"}` + "\n",
+		},
+		{
+			name:                 "query param",
+			path:                 "/health?service=test-service",
+			expectedStatusCode:   http.StatusOK,
+			expectedResponseText: `{"status":"SERVING"}` + "\n",
+		},
+		{
+			name:                 "not found",
+			path:                 "/health/unknown-service",
+			expectedStatusCode:   http.StatusNotFound,
+			expectedResponseText: "unknown service\n",
+		},
+		{
+			name:               "invalid request data",
+			path:               "/health?unknown_field=foo",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+	}
+	upstreamHost, _ := setupGRPCUpstream(t)
+	ts := setupGRPCGateway(t, []*gateway.Endpoint{
+		{
+			Path:   "/health/{service}",
+			Method: "GET",
+			GRPC:   &gateway.GRPCProxyConf{Host: upstreamHost, Method: "grpc.health.v1.Health/Check"},
+		},
+		{
+			Path:   "/health",
+			Method: "GET",
+			GRPC:   &gateway.GRPCProxyConf{Host: upstreamHost, Method: "grpc.health.v1.Health/Check"},
+		},
+	})
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			resp, err := http.Get(ts.URL + tc.path)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			bodyBytes, err := ioutil.ReadAll(resp.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatusCode, resp.StatusCode)
+			if tc.expectedResponseText != "" {
+				assert.Equal(t, tc.expectedResponseText, string(bodyBytes))
+			}
+		})
+	}
+}
+
+func TestGRPCProxy_Stream(t *testing.T) {
+	t.Parallel()
+	upstreamHost, healthServer := setupGRPCUpstream(t)
+	ts := setupGRPCGateway(t, []*gateway.Endpoint{
+		{
+			Path:   "/health/{service}/watch",
+			Method: "GET",
+			GRPC:   &gateway.GRPCProxyConf{Host: upstreamHost, Method: "grpc.health.v1.Health/Watch"},
+		},
+	})
+
+	resp, err := http.Get(ts.URL + "/health/test-service/watch")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/x-ndjson", resp.Header.Get("Content-Type"))
+	reader := bufio.NewReader(resp.Body)
+	line, err := reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, `{"status":"SERVING"}`+"\n", line)
+
+	healthServer.SetServingStatus("test-service", healthpb.HealthCheckResponse_NOT_SERVING)
+	line, err = reader.ReadString('\n')
+	require.NoError(t, err)
+	assert.Equal(t, `{"status":"NOT_SERVING"}`+"\n", line)
+}
+
+func TestGRPCProxy_ConcurrentFirstRequests(t *testing.T) {
+	t.Parallel()
+	upstreamHost, _ := setupGRPCUpstream(t)
+	ts := setupGRPCGateway(t, []*gateway.Endpoint{
+		{
+			Path:   "/health/{service}",
+			Method: "GET",
+			GRPC:   &gateway.GRPCProxyConf{Host: upstreamHost, Method: "grpc.health.v1.Health/Check"},
+		},
+	})
+
+	// The requests race to resolve the method, each of them must get a response.
+	statusCodes := make([]int, 10)
+	var wg sync.WaitGroup
+	for i := range statusCodes {
+		i := i
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := http.Get(ts.URL + "/health/test-service")
+			if err != nil {
+				return
+			}
+			defer resp.Body.Close()
+			statusCodes[i] = resp.StatusCode
+		}()
+	}
+	wg.Wait()
+
+	for _, statusCode := range statusCodes {
+		assert.Equal(t, http.StatusOK, statusCode)
+	}
+}
+
+func TestGRPCProxy_UnknownMethod(t *testing.T) {
+	t.Parallel()
+	upstreamHost, _ := setupGRPCUpstream(t)
+	ts := setupGRPCGateway(t, []*gateway.Endpoint{
+		{
+			Path:   "/health",
+			Method: "GET",
+			GRPC:   &gateway.GRPCProxyConf{Host: upstreamHost, Method: "grpc.health.v1.Health/Unknown"},
+		},
+	})
+
+	resp, err := http.Get(ts.URL + "/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+}
+
+func TestGRPCProxy_InvalidMethod(t *testing.T) {
+	t.Parallel()
+	grpcFactory := gateway.NewGRPCProxyFactory(log.New())
+	defer func() { _ = grpcFactory.Close() }()
+	endpoints := []*gateway.Endpoint{
+		{
+			Path:   "/health",
+			Method: "GET",
+			GRPC:   &gateway.GRPCProxyConf{Host: "localhost:1", Method: "grpc.health.v1.Health.Check"},
+		},
+	}
+
+	_, err := gateway.NewGateway(nil /* nsqFactory */, grpcFactory, endpoints)
+
+	assert.Error(t, err)
+}
+
+func setupGRPCUpstream(t *testing.T) (string, *health.Server) {
+	t.Helper()
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("test-service", healthpb.HealthCheckResponse_SERVING)
+	server := grpc.NewServer()
+	healthpb.RegisterHealthServer(server, healthServer)
+	reflection.Register(server)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+	return listener.Addr().String(), healthServer
+}
+
+func setupGRPCGateway(t *testing.T, endpoints []*gateway.Endpoint) *httptest.Server {
+	t.Helper()
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	grpcFactory := gateway.NewGRPCProxyFactory(logger)
+	t.Cleanup(func() { _ = grpcFactory.Close() })
+	gatewayHandler, err := gateway.NewGateway(nil /* nsqFactory */, grpcFactory, endpoints)
+	require.NoError(t, err)
+	ts := httptest.NewServer(gatewayHandler)
+	t.Cleanup(ts.Close)
+	return ts
+}
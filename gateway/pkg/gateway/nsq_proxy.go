@@ -1,16 +1,23 @@
 package gateway
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 
+	"github.com/georgysavva/driver-app/platform/envelope"
+	"github.com/georgysavva/driver-app/platform/httpapi"
+	"github.com/georgysavva/driver-app/platform/logging"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
+const maxIdempotencyKeyLength = 255
+
 type NSQProducer interface {
 	Publish(topic string, body []byte) error
 }
@@ -33,24 +40,32 @@ type NSQProxy struct {
 	producer NSQProducer
 	logger   log.FieldLogger
 	conf     *NSQProxyConf
+	encoding string
 }
 
 func (npf *NSQProxyFactory) NewProxy(conf *NSQProxyConf) (*NSQProxy, error) {
 	if conf.Topic == "" {
 		return nil, errors.New("NSQ proxy config has an empty topic")
 	}
+	if conf.Message == nil || conf.Message.Command == "" {
+		return nil, errors.New("NSQ proxy config has an empty message command")
+	}
+	encoding := conf.Message.Encoding
+	switch encoding {
+	case "":
+		encoding = envelope.EncodingJSON
+	case envelope.EncodingJSON, envelope.EncodingProtobuf:
+	default:
+		return nil, errors.Errorf("NSQ proxy config has an unknown message encoding %q", encoding)
+	}
 	return &NSQProxy{
 		producer: npf.producer,
 		logger:   npf.logger,
 		conf:     conf,
+		encoding: encoding,
 	}, nil
 }
 
-type Message struct {
-	Command string                 `json:"command"`
-	Data    map[string]interface{} `json:"data"`
-}
-
 func (np *NSQProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	requestVars := mux.Vars(r)
 	var requestData map[string]interface{}
@@ -59,30 +74,87 @@ func (np *NSQProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, errors.Wrap(err, "request body parsing failed").Error(), http.StatusBadRequest)
 		return
 	}
-	msg := &Message{
-		Command: np.conf.Message.Command,
-		Data:    mergeRequestData(requestVars, requestData),
+	payload, err := buildPayload(np.conf.Message.Command, mergeRequestData(requestVars, requestData))
+	if err != nil {
+		np.logger.WithError(err).Info("Request data is invalid for the message command")
+		http.Error(w, errors.Wrap(err, "request data validation failed").Error(), http.StatusBadRequest)
+		return
+	}
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		np.logger.Info("Idempotency key is too long, return 400")
+		http.Error(w, fmt.Sprintf("Idempotency-Key must not exceed %d characters", maxIdempotencyKeyLength),
+			http.StatusBadRequest)
+		return
+	}
+	msg, err := envelope.New(np.conf.Message.Command, payload, getTraceContext(r))
+	if err != nil {
+		logging.LogUnhandledError(np.logger, errors.Wrap(err, "can't build nsq message"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	// Consumers deduplicate messages by id, so a client retrying a request with the same key
+	// doesn't get it applied twice.
+	if idempotencyKey != "" {
+		msg.ID = idempotentMessageID(idempotencyKey, msg, r)
 	}
-	mesBody, err := json.Marshal(msg)
+	mesBody, err := envelope.Marshal(msg, np.encoding)
 	if err != nil {
-		logUnhandledError(np.logger, errors.Wrap(err, "can't encode nsq message body"))
-		internalServerError(w)
+		logging.LogUnhandledError(np.logger, errors.Wrap(err, "can't encode nsq message body"))
+		httpapi.InternalServerError(w)
 		return
 	}
 	if err := np.producer.Publish(np.conf.Topic, mesBody); err != nil {
-		logUnhandledError(np.logger, errors.Wrap(err, "failed to proxy message to nsq topic"))
-		internalServerError(w)
+		logging.LogUnhandledError(np.logger, errors.Wrap(err, "failed to proxy message to nsq topic"))
+		httpapi.InternalServerError(w)
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	if _, err := fmt.Fprintln(w, "OK"); err != nil {
-		logUnhandledError(np.logger, errors.Wrap(err, "failed to write 'OK' response to the client"))
-		internalServerError(w)
+		logging.LogUnhandledError(np.logger, errors.Wrap(err, "failed to write 'OK' response to the client"))
+		httpapi.InternalServerError(w)
 		return
 	}
 }
 
+// idempotentMessageID derives the message id from the idempotency key and the request it came with,
+// so the same key sent by other clients or for other drivers doesn't make the consumers drop their messages.
+func idempotentMessageID(idempotencyKey string, msg *envelope.Envelope, r *http.Request) string {
+	hash := sha256.New()
+	for _, part := range []string{msg.Command, r.URL.Path, string(msg.Payload), idempotencyKey} {
+		_, _ = hash.Write([]byte(part))
+		_, _ = hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil)[:16])
+}
+
+// buildPayload converts the request data into the payload type of the command, so malformed requests
+// are rejected by the gateway instead of the consumer. The data of commands without a payload type is passed as is.
+func buildPayload(command string, requestData map[string]interface{}) (interface{}, error) {
+	payload, ok := envelope.NewPayload(command)
+	if !ok {
+		return requestData, nil
+	}
+	data, err := json.Marshal(requestData)
+	if err != nil {
+		return nil, errors.Wrap(err, "can't encode request data")
+	}
+	if err := envelope.DecodePayload(data, payload); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return payload, nil
+}
+
+// getTraceContext returns the W3C Trace Context of the request, if any, to pass it along with the message.
+func getTraceContext(r *http.Request) *envelope.TraceContext {
+	traceParent := r.Header.Get("traceparent")
+	if traceParent == "" {
+		return nil
+	}
+	return &envelope.TraceContext{TraceParent: traceParent, TraceState: r.Header.Get("tracestate")}
+}
+
 func mergeRequestData(requestVars map[string]string, requestData map[string]interface{}) map[string]interface{} {
 	resultMap := make(map[string]interface{}, len(requestVars)+len(requestData))
 	for k, v := range requestVars {
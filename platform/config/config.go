@@ -0,0 +1,293 @@
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+// Config structs are described with the following field tags:
+//   - yaml: the key of the field in the config file, it also forms the environment variable name;
+//   - default: the value used when neither the config file nor the environment set the field;
+//   - validate: comma-separated rules checked after loading: "required", "min=N" and "max=N".
+//
+// A section containing an "enabled" boolean field set to false isn't validated.
+const (
+	tagYAML     = "yaml"
+	tagDefault  = "default"
+	tagValidate = "validate"
+
+	enabledFieldName = "enabled"
+)
+
+// Validator can be implemented by a config struct or any of its sections to perform checks
+// that can't be expressed via the validate tag.
+type Validator interface {
+	Validate() error
+}
+
+// ValidationError aggregates all problems found in a config.
+type ValidationError struct {
+	Problems []string
+}
+
+func (ve *ValidationError) Error() string {
+	return "invalid config:\n  - " + strings.Join(ve.Problems, "\n  - ")
+}
+
+// Load fills conf, which must be a pointer to a config struct, from the following layers,
+// each one overriding the previous: default tags, the yaml file at configPath and environment variables.
+// The environment variable of a field is its yaml path in upper case prefixed with envPrefix,
+// e.g. DRIVERLOC_REDIS_ADDRESS for the redis.address field and "DRIVERLOC" prefix.
+// Nil sections are always allocated, so the loaded config can be safely dereferenced after validation.
+func Load(configPath, envPrefix string, conf interface{}) error {
+	root := reflect.ValueOf(conf)
+	if root.Kind() != reflect.Ptr || root.Elem().Kind() != reflect.Struct {
+		return errors.Errorf("config must be a pointer to a struct, got %T", conf)
+	}
+
+	if err := applyDefaults(root.Elem()); err != nil {
+		return errors.Wrap(err, "failed to apply config defaults")
+	}
+	if err := Parse(configPath, conf); err != nil {
+		return errors.WithStack(err)
+	}
+	if err := applyEnv(root.Elem(), envPrefix); err != nil {
+		return errors.Wrap(err, "failed to apply environment variables to config")
+	}
+	return errors.WithStack(Validate(conf))
+}
+
+// Parse reads the yaml file at configPath into conf, which must be a pointer to a config struct.
+// Keys that don't correspond to any config field are reported as errors.
+func Parse(configPath string, conf interface{}) error {
+	fileContent, err := ioutil.ReadFile(filepath.Clean(configPath))
+	if err != nil {
+		return errors.Wrap(err, "failed to read config file content")
+	}
+
+	if err := yaml.UnmarshalStrict(fileContent, conf); err != nil {
+		return errors.Wrap(err, "failed to parse yaml content into config struct")
+	}
+
+	return nil
+}
+
+// Validate checks validate tags of all conf fields and calls Validate of every section implementing Validator.
+// It returns a *ValidationError listing all found problems.
+func Validate(conf interface{}) error {
+	var problems []string
+	err := walk(reflect.ValueOf(conf).Elem(), nil, func(path []string, v reflect.Value, sf reflect.StructField) error {
+		problems = append(problems, checkRules(strings.Join(path, "."), v, sf.Tag.Get(tagValidate))...)
+		return nil
+	}, func(path []string, v reflect.Value) bool {
+		if isDisabled(v) {
+			return false
+		}
+		validator, ok := v.Addr().Interface().(Validator)
+		if !ok {
+			return true
+		}
+		if err := validator.Validate(); err != nil {
+			problem := err.Error()
+			if len(path) > 0 {
+				problem = strings.Join(path, ".") + ": " + problem
+			}
+			problems = append(problems, problem)
+		}
+		return true
+	})
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+func applyDefaults(root reflect.Value) error {
+	return walk(root, nil, func(path []string, v reflect.Value, sf reflect.StructField) error {
+		defaultValue, ok := sf.Tag.Lookup(tagDefault)
+		if !ok {
+			return nil
+		}
+		return errors.Wrapf(setFromString(v, defaultValue), "invalid default for %s", strings.Join(path, "."))
+	}, nil)
+}
+
+func applyEnv(root reflect.Value, envPrefix string) error {
+	return walk(root, nil, func(path []string, v reflect.Value, _ reflect.StructField) error {
+		name := EnvName(envPrefix, path)
+		envValue, ok := os.LookupEnv(name)
+		if !ok {
+			return nil
+		}
+		return errors.Wrapf(setFromString(v, envValue), "invalid %s environment variable", name)
+	}, nil)
+}
+
+// EnvName returns the environment variable name for the config field at the given yaml path.
+func EnvName(envPrefix string, path []string) string {
+	parts := append([]string{envPrefix}, path...)
+	return strings.ToUpper(strings.Join(parts, "_"))
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// walk calls leafFn for all non-struct fields reachable from the root struct, allocating nil struct pointers
+// on the way. It calls sectionFn, if set, for the root and every nested struct before visiting its fields,
+// the fields are skipped if sectionFn returns false.
+func walk(
+	v reflect.Value, path []string,
+	leafFn func(path []string, v reflect.Value, sf reflect.StructField) error,
+	sectionFn func(path []string, v reflect.Value) bool,
+) error {
+	if sectionFn != nil && !sectionFn(path, v) {
+		return nil
+	}
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name := strings.Split(sf.Tag.Get(tagYAML), ",")[0]
+		if sf.PkgPath != "" || name == "" || name == "-" {
+			continue
+		}
+		fieldPath := append(append([]string{}, path...), name)
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Ptr && fv.Type().Elem().Kind() == reflect.Struct {
+			if fv.IsNil() {
+				fv.Set(reflect.New(fv.Type().Elem()))
+			}
+			fv = fv.Elem()
+		}
+		if fv.Kind() == reflect.Struct {
+			if err := walk(fv, fieldPath, leafFn, sectionFn); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := leafFn(fieldPath, fv, sf); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isDisabled(section reflect.Value) bool {
+	t := section.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if strings.Split(sf.Tag.Get(tagYAML), ",")[0] == enabledFieldName && sf.Type.Kind() == reflect.Bool {
+			return !section.Field(i).Bool()
+		}
+	}
+	return false
+}
+
+func setFromString(v reflect.Value, s string) error {
+	if v.Type() == durationType {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		v.SetInt(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		v.SetFloat(f)
+	case reflect.Slice:
+		if v.Type().Elem().Kind() != reflect.String {
+			return errors.Errorf("unsupported slice type %s", v.Type())
+		}
+		var items []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		v.Set(reflect.ValueOf(items))
+	default:
+		return errors.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
+
+func checkRules(path string, v reflect.Value, rules string) []string {
+	if rules == "" {
+		return nil
+	}
+	var problems []string
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		switch name {
+		case "required":
+			if v.IsZero() || (v.Kind() == reflect.Slice && v.Len() == 0) {
+				problems = append(problems, fmt.Sprintf("%s: must be set", path))
+			}
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: invalid %q validation rule", path, rule))
+				continue
+			}
+			value, ok := numericValue(v)
+			if !ok {
+				problems = append(problems, fmt.Sprintf("%s: %q rule applied to a non-numeric field", path, rule))
+				continue
+			}
+			if name == "min" && value < limit {
+				problems = append(problems, fmt.Sprintf("%s: must be at least %s", path, arg))
+			}
+			if name == "max" && value > limit {
+				problems = append(problems, fmt.Sprintf("%s: must be at most %s", path, arg))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("%s: unknown %q validation rule", path, rule))
+		}
+	}
+	return problems
+}
+
+func numericValue(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.Slice:
+		return float64(v.Len()), true
+	default:
+		return 0, false
+	}
+}
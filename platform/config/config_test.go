@@ -0,0 +1,141 @@
+package config_test
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/platform/config"
+)
+
+type testConfig struct {
+	Redis *struct {
+		Address  string   `yaml:"address" validate:"required"`
+		Replicas []string `yaml:"replicas"`
+	} `yaml:"redis"`
+
+	HTTPServer *struct {
+		Port            int           `yaml:"port" validate:"required,min=1,max=65535"`
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout" default:"5s" validate:"min=0"`
+	} `yaml:"http_server"`
+
+	Limits limits `yaml:"limits"`
+
+	Cache *struct {
+		Enabled bool   `yaml:"enabled"`
+		Address string `yaml:"address" validate:"required"`
+	} `yaml:"cache"`
+}
+
+type limits struct {
+	Soft int `yaml:"soft" default:"10"`
+	Hard int `yaml:"hard" default:"100"`
+}
+
+func (l *limits) Validate() error {
+	if l.Soft > l.Hard {
+		return errors.New("soft limit must not exceed the hard one")
+	}
+	return nil
+}
+
+func TestLoad(t *testing.T) {
+	conf := &testConfig{}
+	err := config.Load("testdata/valid.yaml", "TEST", conf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "redis:6379", conf.Redis.Address)
+	assert.Empty(t, conf.Redis.Replicas)
+	assert.Equal(t, 8010, conf.HTTPServer.Port)
+	assert.Equal(t, 5*time.Second, conf.HTTPServer.ShutdownTimeout)
+	assert.Equal(t, limits{Soft: 10, Hard: 100}, conf.Limits)
+}
+
+func TestLoad_EnvOverrides(t *testing.T) {
+	setEnv(t, map[string]string{
+		"TEST_REDIS_ADDRESS":                "localhost:6379",
+		"TEST_REDIS_REPLICAS":               "replica-1:6379, replica-2:6379",
+		"TEST_HTTP_SERVER_SHUTDOWN_TIMEOUT": "1m",
+		"TEST_LIMITS_SOFT":                  "50",
+	})
+
+	conf := &testConfig{}
+	err := config.Load("testdata/valid.yaml", "TEST", conf)
+	require.NoError(t, err)
+
+	assert.Equal(t, "localhost:6379", conf.Redis.Address)
+	assert.Equal(t, []string{"replica-1:6379", "replica-2:6379"}, conf.Redis.Replicas)
+	assert.Equal(t, time.Minute, conf.HTTPServer.ShutdownTimeout)
+	assert.Equal(t, limits{Soft: 50, Hard: 100}, conf.Limits)
+}
+
+func TestLoad_InvalidEnvValue(t *testing.T) {
+	setEnv(t, map[string]string{"TEST_HTTP_SERVER_PORT": "eighty"})
+
+	conf := &testConfig{}
+	err := config.Load("testdata/valid.yaml", "TEST", conf)
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "invalid TEST_HTTP_SERVER_PORT environment variable")
+}
+
+func TestLoad_ValidationError(t *testing.T) {
+	setEnv(t, map[string]string{"TEST_LIMITS_SOFT": "1000"})
+
+	conf := &testConfig{}
+	err := config.Load("testdata/invalid.yaml", "TEST", conf)
+
+	var validationErr *config.ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []string{
+		"redis.address: must be set",
+		"http_server.port: must be set",
+		"http_server.port: must be at least 1",
+		"http_server.shutdown_timeout: must be at least 0",
+		"limits: soft limit must not exceed the hard one",
+	}, validationErr.Problems)
+}
+
+func TestLoad_DisabledSectionIsNotValidated(t *testing.T) {
+	setEnv(t, map[string]string{"TEST_CACHE_ENABLED": "true"})
+
+	conf := &testConfig{}
+	err := config.Load("testdata/valid.yaml", "TEST", conf)
+	require.EqualError(t, err, "invalid config:\n  - cache.address: must be set")
+
+	require.NoError(t, os.Setenv("TEST_CACHE_ENABLED", "false"))
+	conf = &testConfig{}
+	err = config.Load("testdata/valid.yaml", "TEST", conf)
+	require.NoError(t, err)
+}
+
+func TestLoad_UnknownField(t *testing.T) {
+	t.Parallel()
+	conf := &testConfig{}
+	err := config.Load("testdata/unknown_field.yaml", "TEST", conf)
+	require.Error(t, err)
+
+	assert.Contains(t, err.Error(), "field adress not found")
+}
+
+func TestEnvName(t *testing.T) {
+	t.Parallel()
+	actual := config.EnvName("driverloc", []string{"http_server", "port"})
+	assert.Equal(t, "DRIVERLOC_HTTP_SERVER_PORT", actual)
+}
+
+func setEnv(t *testing.T, env map[string]string) {
+	t.Helper()
+	for k, v := range env {
+		require.NoError(t, os.Setenv(k, v))
+	}
+	t.Cleanup(func() {
+		for k := range env {
+			os.Unsetenv(k) // nolint: errcheck
+		}
+	})
+}
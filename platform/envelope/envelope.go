@@ -0,0 +1,151 @@
+// Package envelope defines the NSQ message format shared by all producers and consumers.
+//
+// Every message carries its version, so the format can evolve: consumers upgrade messages of older
+// versions and reject the ones of newer versions than they know, which happens if a producer is upgraded first.
+// Consumers must be deployed before producers start emitting a new version.
+package envelope
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// LegacyVersion is the version of messages produced before the envelope, shaped as {"command": ..., "data": ...}.
+	LegacyVersion = 0
+	Version1      = 1
+	// CurrentVersion is the version produced by New.
+	CurrentVersion = Version1
+)
+
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+const idBytesNum = 16
+
+var ErrUnsupportedVersion = errors.New("unsupported message version")
+
+// Envelope is the NSQ message wrapping a command payload.
+type Envelope struct {
+	ID         string          `json:"id"`
+	Version    int             `json:"version"`
+	ProducedAt time.Time       `json:"produced_at"`
+	Trace      *TraceContext   `json:"trace,omitempty"`
+	Command    string          `json:"command"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+// TraceContext carries the W3C Trace Context of the request that produced the message.
+type TraceContext struct {
+	TraceParent string `json:"traceparent"`
+	TraceState  string `json:"tracestate,omitempty"`
+}
+
+// New wraps the payload of the command into an envelope of the current version.
+func New(command string, payload interface{}, trace *TraceContext) (*Envelope, error) {
+	payloadData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode message payload into json")
+	}
+	id, err := newID()
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{
+		ID:         id,
+		Version:    CurrentVersion,
+		ProducedAt: time.Now().UTC(),
+		Trace:      trace,
+		Command:    command,
+		Payload:    payloadData,
+	}, nil
+}
+
+// DecodePayload decodes and validates the envelope payload.
+func (e *Envelope) DecodePayload(payload Payload) error {
+	return DecodePayload(e.Payload, payload)
+}
+
+// DecodePayload decodes and validates an envelope payload.
+func DecodePayload(data json.RawMessage, payload Payload) error {
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return errors.New("message has no payload")
+	}
+	if err := json.Unmarshal(data, payload); err != nil {
+		return errors.Wrap(err, "cannot decode message payload")
+	}
+	return errors.WithStack(payload.Validate())
+}
+
+// Marshal encodes the envelope with the given encoding.
+func Marshal(e *Envelope, encoding string) ([]byte, error) {
+	switch encoding {
+	case EncodingJSON:
+		data, err := json.Marshal(e)
+		return data, errors.Wrap(err, "failed to encode message into json")
+	case EncodingProtobuf:
+		return marshalProtobuf(e)
+	default:
+		return nil, errors.Errorf("unknown message encoding %q", encoding)
+	}
+}
+
+// Unmarshal decodes the message regardless of its encoding and upgrades it to the current version.
+// It returns ErrUnsupportedVersion for messages of newer versions.
+func Unmarshal(data []byte) (*Envelope, error) {
+	if len(data) == 0 {
+		return nil, errors.New("message has an empty body")
+	}
+	var e *Envelope
+	var err error
+	if isProtobuf(data) {
+		e, err = unmarshalProtobuf(data)
+	} else {
+		e, err = unmarshalJSON(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if e.Version > CurrentVersion {
+		return nil, errors.Wrapf(ErrUnsupportedVersion, "version %d, the latest known version is %d",
+			e.Version, CurrentVersion)
+	}
+	if e.Command == "" {
+		return nil, errors.New("message has no command")
+	}
+	return e, nil
+}
+
+func unmarshalJSON(data []byte) (*Envelope, error) {
+	var raw struct {
+		Envelope
+		Data json.RawMessage `json:"data"` // Only set by legacy messages.
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, errors.Wrap(err, "cannot decode message body into an envelope")
+	}
+	e := &raw.Envelope
+	if e.Version == LegacyVersion {
+		payload, err := upgradeLegacyPayload(e.Command, raw.Data)
+		if err != nil {
+			return nil, err
+		}
+		e.Payload = payload
+	}
+	return e, nil
+}
+
+func newID() (string, error) {
+	b := make([]byte, idBytesNum)
+	if _, err := rand.Read(b); err != nil {
+		return "", errors.Wrap(err, "failed to generate message id")
+	}
+	return hex.EncodeToString(b), nil
+}
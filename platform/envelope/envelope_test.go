@@ -0,0 +1,227 @@
+package envelope_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+
+	"github.com/georgysavva/driver-app/platform/envelope"
+)
+
+func TestMarshalUnmarshal(t *testing.T) {
+	t.Parallel()
+	for _, encoding := range []string{envelope.EncodingJSON, envelope.EncodingProtobuf} {
+		encoding := encoding
+		t.Run(encoding, func(t *testing.T) {
+			t.Parallel()
+			driverID, latitude, longitude := "42", 48.864193, 2.350498
+			expected, err := envelope.New(
+				envelope.CommandUpdateDriverLocations,
+				&envelope.DriverLocationPayload{DriverID: &driverID, Latitude: &latitude, Longitude: &longitude},
+				&envelope.TraceContext{TraceParent: "00-0af7651916cd43dd8448eb211c80319c-b7ad6b7169203331-01"},
+			)
+			require.NoError(t, err)
+
+			data, err := envelope.Marshal(expected, encoding)
+			require.NoError(t, err)
+			actual, err := envelope.Unmarshal(data)
+			require.NoError(t, err)
+
+			assert.Equal(t, expected.ID, actual.ID)
+			assert.Equal(t, envelope.CurrentVersion, actual.Version)
+			assert.True(t, expected.ProducedAt.Equal(actual.ProducedAt))
+			assert.Equal(t, expected.Trace, actual.Trace)
+			assert.Equal(t, expected.Command, actual.Command)
+			payload := &envelope.DriverLocationPayload{}
+			require.NoError(t, actual.DecodePayload(payload))
+			assert.Equal(t, &envelope.DriverLocationPayload{
+				DriverID: &driverID, Latitude: &latitude, Longitude: &longitude,
+			}, payload)
+		})
+	}
+}
+
+func TestUnmarshal_LegacyMessage(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name            string
+		body            string
+		expectedPayload string
+	}{
+		{
+			name:            "update driver locations",
+			body:            `{"command": "update-driver-locations", "data": {"id": "42", "latitude": 1, "longitude": 2}}`,
+			expectedPayload: `{"driver_id": "42", "latitude": 1, "longitude": 2}`,
+		},
+		{
+			name: "batch update driver locations",
+			body: `
+			{
+				"command": "batch-update-driver-locations",
+				"data": {"locations": [{"id": "42", "latitude": 1}]}
+			}`,
+			expectedPayload: `{"locations": [{"driver_id": "42", "latitude": 1}]}`,
+		},
+		{
+			name:            "delete driver locations",
+			body:            `{"command": "delete-driver-locations", "data": {"id": "42"}}`,
+			expectedPayload: `{"driver_id": "42"}`,
+		},
+		{
+			name:            "unknown command",
+			body:            `{"command": "foo", "data": {"id": "42"}}`,
+			expectedPayload: `{"id": "42"}`,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			actual, err := envelope.Unmarshal([]byte(tc.body))
+			require.NoError(t, err)
+
+			assert.Equal(t, envelope.LegacyVersion, actual.Version)
+			assert.JSONEq(t, tc.expectedPayload, string(actual.Payload))
+		})
+	}
+}
+
+func TestUnmarshal_UnsupportedVersion(t *testing.T) {
+	t.Parallel()
+	e := &envelope.Envelope{
+		ID:         "1",
+		Version:    envelope.CurrentVersion + 1,
+		ProducedAt: time.Now(),
+		Command:    "foo",
+		Payload:    json.RawMessage(`{}`),
+	}
+	for _, encoding := range []string{envelope.EncodingJSON, envelope.EncodingProtobuf} {
+		data, err := envelope.Marshal(e, encoding)
+		require.NoError(t, err)
+
+		_, err = envelope.Unmarshal(data)
+		assert.True(t, errors.Is(err, envelope.ErrUnsupportedVersion), encoding)
+	}
+}
+
+func TestUnmarshal_ProtobufUnknownFieldsAreSkipped(t *testing.T) {
+	t.Parallel()
+	e := &envelope.Envelope{ID: "1", Version: envelope.CurrentVersion, Command: "foo", Payload: json.RawMessage(`{}`)}
+	data, err := envelope.Marshal(e, envelope.EncodingProtobuf)
+	require.NoError(t, err)
+	data = protowire.AppendTag(data, 100, protowire.BytesType)
+	data = protowire.AppendString(data, "bar")
+
+	actual, err := envelope.Unmarshal(data)
+	require.NoError(t, err)
+
+	assert.Equal(t, e, actual)
+}
+
+func TestMarshal_ProtobufWireFormat(t *testing.T) {
+	t.Parallel()
+	producedAt := time.Date(2020, 11, 7, 0, 0, 0, 0, time.UTC)
+	e := &envelope.Envelope{
+		ID:         "1",
+		Version:    envelope.CurrentVersion,
+		ProducedAt: producedAt,
+		Trace:      &envelope.TraceContext{TraceParent: "tp", TraceState: "ts"},
+		Command:    "foo",
+		Payload:    json.RawMessage(`{}`),
+	}
+
+	actual, err := envelope.Marshal(e, envelope.EncodingProtobuf)
+	require.NoError(t, err)
+
+	// The messages produced by the earlier versions of the service must stay readable.
+	var expected []byte
+	expected = protowire.AppendTag(expected, 1, protowire.VarintType)
+	expected = protowire.AppendVarint(expected, envelope.CurrentVersion)
+	expected = protowire.AppendTag(expected, 2, protowire.BytesType)
+	expected = protowire.AppendString(expected, "1")
+	expected = protowire.AppendTag(expected, 3, protowire.VarintType)
+	expected = protowire.AppendVarint(expected, uint64(producedAt.UnixNano()))
+	var trace []byte
+	trace = protowire.AppendTag(trace, 1, protowire.BytesType)
+	trace = protowire.AppendString(trace, "tp")
+	trace = protowire.AppendTag(trace, 2, protowire.BytesType)
+	trace = protowire.AppendString(trace, "ts")
+	expected = protowire.AppendTag(expected, 4, protowire.BytesType)
+	expected = protowire.AppendBytes(expected, trace)
+	expected = protowire.AppendTag(expected, 5, protowire.BytesType)
+	expected = protowire.AppendString(expected, "foo")
+	expected = protowire.AppendTag(expected, 6, protowire.BytesType)
+	expected = protowire.AppendString(expected, "{}")
+	assert.Equal(t, expected, actual)
+}
+
+func TestUnmarshal_InvalidMessage(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name string
+		body []byte
+	}{
+		{name: "empty body", body: []byte{}},
+		{name: "body is not a json", body: []byte(`foo`)},
+		{name: "command is missing", body: []byte(`{"version": 1, "payload": {}}`)},
+		{name: "truncated protobuf", body: []byte{0x08, 0x01, 0x12, 0x05, 'a'}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := envelope.Unmarshal(tc.body)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestEnvelope_DecodePayload_NoPayload(t *testing.T) {
+	t.Parallel()
+	e, err := envelope.Unmarshal([]byte(`{"command": "update-driver-locations"}`))
+	require.NoError(t, err)
+
+	err = e.DecodePayload(&envelope.DriverLocationPayload{})
+	assert.EqualError(t, err, "message has no payload")
+}
+
+func TestNewPayload(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		command string
+		data    string
+		isValid bool
+	}{
+		{
+			command: envelope.CommandUpdateDriverLocations,
+			data:    `{"driver_id": "42", "latitude": 1, "longitude": 2}`,
+			isValid: true,
+		},
+		{command: envelope.CommandUpdateDriverLocations, data: `{"driver_id": "42", "latitude": 1}`},
+		{
+			command: envelope.CommandBatchUpdateDriverLocations,
+			data:    `{"locations": [{"driver_id": "42", "latitude": 1, "longitude": 2}]}`,
+			isValid: true,
+		},
+		{command: envelope.CommandBatchUpdateDriverLocations, data: `{"locations": []}`},
+		{command: envelope.CommandBatchUpdateDriverLocations, data: `{"locations": [null]}`},
+		{command: envelope.CommandDeleteDriverLocations, data: `{"driver_id": "42"}`, isValid: true},
+		{command: envelope.CommandDeleteDriverLocations, data: `{}`},
+	}
+	for _, tc := range cases {
+		payload, ok := envelope.NewPayload(tc.command)
+		require.True(t, ok, tc.command)
+		require.NoError(t, json.Unmarshal([]byte(tc.data), payload))
+
+		err := payload.Validate()
+		assert.Equal(t, tc.isValid, err == nil, tc.data)
+	}
+
+	_, ok := envelope.NewPayload("foo")
+	assert.False(t, ok)
+}
@@ -0,0 +1,4 @@
+// Package envelopepb contains the Protobuf encoding of the message envelope generated from envelope.proto.
+package envelopepb
+
+//go:generate protoc --go_out=paths=source_relative:. envelope.proto
@@ -0,0 +1,119 @@
+package envelope
+
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+// Commands understood by the services, each one has its payload type below.
+const (
+	CommandUpdateDriverLocations      = "update-driver-locations"
+	CommandBatchUpdateDriverLocations = "batch-update-driver-locations"
+	CommandDeleteDriverLocations      = "delete-driver-locations"
+)
+
+// Payload is implemented by all command payloads.
+type Payload interface {
+	Validate() error
+}
+
+// NewPayload returns an empty payload of the command, ok is false for unknown commands.
+func NewPayload(command string) (payload Payload, ok bool) {
+	switch command {
+	case CommandUpdateDriverLocations:
+		return &DriverLocationPayload{}, true
+	case CommandBatchUpdateDriverLocations:
+		return &BatchDriverLocationsPayload{}, true
+	case CommandDeleteDriverLocations:
+		return &DriverPayload{}, true
+	default:
+		return nil, false
+	}
+}
+
+// Fields are pointers to tell missing fields from zero values.
+type DriverLocationPayload struct {
+	DriverID  *string  `json:"driver_id"`
+	Latitude  *float64 `json:"latitude"`
+	Longitude *float64 `json:"longitude"`
+}
+
+func (p *DriverLocationPayload) Validate() error {
+	if p.DriverID == nil || p.Latitude == nil || p.Longitude == nil {
+		return errors.New("'driver_id', 'latitude', 'longitude' fields must be set")
+	}
+	return nil
+}
+
+type BatchDriverLocationsPayload struct {
+	Locations []*DriverLocationPayload `json:"locations"`
+}
+
+func (p *BatchDriverLocationsPayload) Validate() error {
+	if len(p.Locations) == 0 {
+		return errors.New("'locations' field must contain at least one location")
+	}
+	for i, location := range p.Locations {
+		if location == nil {
+			return errors.Errorf("locations.%d: location must be an object", i)
+		}
+		if err := location.Validate(); err != nil {
+			return errors.Wrapf(err, "locations.%d", i)
+		}
+	}
+	return nil
+}
+
+type DriverPayload struct {
+	DriverID *string `json:"driver_id"`
+}
+
+func (p *DriverPayload) Validate() error {
+	if p.DriverID == nil {
+		return errors.New("'driver_id' field must be set")
+	}
+	return nil
+}
+
+// upgradeLegacyPayload converts the data of a legacy message into the current payload,
+// legacy messages name the driver id field "id".
+func upgradeLegacyPayload(command string, data json.RawMessage) (json.RawMessage, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	switch command {
+	case CommandUpdateDriverLocations, CommandDeleteDriverLocations:
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return nil, errors.Wrap(err, "cannot decode legacy message data")
+		}
+		renameLegacyDriverID(fields)
+		return marshalUpgraded(fields)
+	case CommandBatchUpdateDriverLocations:
+		var batch struct {
+			Locations []map[string]json.RawMessage `json:"locations"`
+		}
+		if err := json.Unmarshal(data, &batch); err != nil {
+			return nil, errors.Wrap(err, "cannot decode legacy message data")
+		}
+		for _, location := range batch.Locations {
+			renameLegacyDriverID(location)
+		}
+		return marshalUpgraded(batch)
+	default:
+		return data, nil
+	}
+}
+
+func renameLegacyDriverID(fields map[string]json.RawMessage) {
+	if id, ok := fields["id"]; ok {
+		fields["driver_id"] = id
+		delete(fields, "id")
+	}
+}
+
+func marshalUpgraded(v interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	return data, errors.Wrap(err, "failed to encode upgraded legacy message data")
+}
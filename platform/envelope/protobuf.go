@@ -0,0 +1,60 @@
+package envelope
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/georgysavva/driver-app/platform/envelope/envelopepb"
+)
+
+var fieldVersion = (&envelopepb.Envelope{}).ProtoReflect().Descriptor().Fields().ByName("version").Number()
+
+// isProtobuf reports whether the message starts with the version field tag, JSON messages start with '{' or a space.
+func isProtobuf(data []byte) bool {
+	num, typ, n := protowire.ConsumeTag(data)
+	return n > 0 && num == fieldVersion && typ == protowire.VarintType
+}
+
+func marshalProtobuf(e *Envelope) ([]byte, error) {
+	pb := &envelopepb.Envelope{
+		Version: proto.Uint32(uint32(e.Version)),
+		Id:      e.ID,
+		Command: e.Command,
+		Payload: e.Payload,
+	}
+	if !e.ProducedAt.IsZero() {
+		pb.ProducedAtUnixNano = e.ProducedAt.UnixNano()
+	}
+	if e.Trace != nil {
+		pb.Trace = &envelopepb.TraceContext{Traceparent: e.Trace.TraceParent, Tracestate: e.Trace.TraceState}
+	}
+	// The fields are encoded in the order of their numbers, so the version comes first.
+	data, err := proto.Marshal(pb)
+	return data, errors.Wrap(err, "failed to encode message into protobuf")
+}
+
+func unmarshalProtobuf(data []byte) (*Envelope, error) {
+	pb := &envelopepb.Envelope{}
+	// Unknown fields are skipped, they may come from a newer producer.
+	if err := proto.Unmarshal(data, pb); err != nil {
+		return nil, errors.Wrap(err, "cannot decode protobuf message into an envelope")
+	}
+	e := &Envelope{
+		Version: int(pb.GetVersion()),
+		ID:      pb.Id,
+		Command: pb.Command,
+	}
+	if pb.ProducedAtUnixNano != 0 {
+		e.ProducedAt = time.Unix(0, pb.ProducedAtUnixNano).UTC()
+	}
+	if pb.Trace != nil {
+		e.Trace = &TraceContext{TraceParent: pb.Trace.Traceparent, TraceState: pb.Trace.Tracestate}
+	}
+	if len(pb.Payload) > 0 {
+		e.Payload = pb.Payload
+	}
+	return e, nil
+}
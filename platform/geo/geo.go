@@ -0,0 +1,111 @@
+// Package geo provides geospatial primitives on a spherical Earth shared by the services:
+// bounding boxes, bearings, destination points, polygons and geohashes.
+package geo
+
+import (
+	"math"
+)
+
+// MeanEarthRadius is the IUGG mean radius of the Earth in meters.
+const MeanEarthRadius float64 = 6371008.8
+
+// Point is a location in degrees.
+type Point struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// BoundingBox is a latitude/longitude rectangle. MinLongitude is greater than MaxLongitude
+// when the box crosses the antimeridian.
+type BoundingBox struct {
+	MinLatitude  float64 `json:"min_latitude"`
+	MinLongitude float64 `json:"min_longitude"`
+	MaxLatitude  float64 `json:"max_latitude"`
+	MaxLongitude float64 `json:"max_longitude"`
+}
+
+// NewBoundingBox returns the smallest box containing the circle around the center with the radius in meters.
+// The box spans all longitudes if the circle covers a pole.
+func NewBoundingBox(center Point, radius float64) BoundingBox {
+	angularRadius := radius / MeanEarthRadius
+	lat, lon := toRadians(center.Latitude), toRadians(center.Longitude)
+	minLat, maxLat := lat-angularRadius, lat+angularRadius
+	if minLat <= -math.Pi/2 || maxLat >= math.Pi/2 {
+		return BoundingBox{
+			MinLatitude:  toDegrees(math.Max(minLat, -math.Pi/2)),
+			MinLongitude: -180,
+			MaxLatitude:  toDegrees(math.Min(maxLat, math.Pi/2)),
+			MaxLongitude: 180,
+		}
+	}
+	deltaLon := math.Asin(math.Sin(angularRadius) / math.Cos(lat))
+	return BoundingBox{
+		MinLatitude:  toDegrees(minLat),
+		MinLongitude: normalizeLongitude(toDegrees(lon - deltaLon)),
+		MaxLatitude:  toDegrees(maxLat),
+		MaxLongitude: normalizeLongitude(toDegrees(lon + deltaLon)),
+	}
+}
+
+// Contains reports whether the point is inside the box or on its border.
+func (bb BoundingBox) Contains(p Point) bool {
+	if p.Latitude < bb.MinLatitude || p.Latitude > bb.MaxLatitude {
+		return false
+	}
+	if bb.MinLongitude <= bb.MaxLongitude {
+		return p.Longitude >= bb.MinLongitude && p.Longitude <= bb.MaxLongitude
+	}
+	return p.Longitude >= bb.MinLongitude || p.Longitude <= bb.MaxLongitude
+}
+
+// Center returns the middle of the box.
+func (bb BoundingBox) Center() Point {
+	maxLon := bb.MaxLongitude
+	if bb.MinLongitude > maxLon {
+		maxLon += 360
+	}
+	return Point{
+		Latitude:  (bb.MinLatitude + bb.MaxLatitude) / 2,
+		Longitude: normalizeLongitude((bb.MinLongitude + maxLon) / 2),
+	}
+}
+
+// Bearing returns the initial great-circle bearing from one point to another
+// in degrees clockwise from north, in the [0, 360) range.
+func Bearing(from, to Point) float64 {
+	lat1, lat2 := toRadians(from.Latitude), toRadians(to.Latitude)
+	deltaLon := toRadians(to.Longitude - from.Longitude)
+	y := math.Sin(deltaLon) * math.Cos(lat2)
+	x := math.Cos(lat1)*math.Sin(lat2) - math.Sin(lat1)*math.Cos(lat2)*math.Cos(deltaLon)
+	return math.Mod(toDegrees(math.Atan2(y, x))+360, 360)
+}
+
+// Destination returns the point reached by traveling the distance in meters
+// along the great circle starting at the bearing in degrees.
+func Destination(from Point, bearing, distance float64) Point {
+	angularDistance := distance / MeanEarthRadius
+	theta := toRadians(bearing)
+	lat1, lon1 := toRadians(from.Latitude), toRadians(from.Longitude)
+	sinLat2 := math.Sin(lat1)*math.Cos(angularDistance) + math.Cos(lat1)*math.Sin(angularDistance)*math.Cos(theta)
+	lat2 := math.Asin(sinLat2)
+	y := math.Sin(theta) * math.Sin(angularDistance) * math.Cos(lat1)
+	x := math.Cos(angularDistance) - math.Sin(lat1)*sinLat2
+	lon2 := lon1 + math.Atan2(y, x)
+	return Point{Latitude: toDegrees(lat2), Longitude: normalizeLongitude(toDegrees(lon2))}
+}
+
+func normalizeLongitude(lon float64) float64 {
+	lon = math.Mod(lon+180, 360)
+	if lon < 0 {
+		lon += 360
+	}
+	return lon - 180
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
+}
+
+func toDegrees(radians float64) float64 {
+	return radians * 180 / math.Pi
+}
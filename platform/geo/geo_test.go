@@ -0,0 +1,108 @@
+package geo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/georgysavva/driver-app/platform/geo"
+)
+
+func dms(d, m, s float64) float64 {
+	if d < 0 {
+		return d - m/60 - s/3600
+	}
+	return d + m/60 + s/3600
+}
+
+func TestBearing(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		from, to geo.Point
+		expected float64
+	}{
+		{
+			name:     "north",
+			from:     geo.Point{Latitude: 10, Longitude: 20},
+			to:       geo.Point{Latitude: 11, Longitude: 20},
+			expected: 0,
+		},
+		{
+			name:     "west along the equator",
+			from:     geo.Point{Latitude: 0, Longitude: 20},
+			to:       geo.Point{Latitude: 0, Longitude: 19},
+			expected: 270,
+		},
+		{
+			name:     "Land's End to John o' Groats",
+			from:     geo.Point{Latitude: dms(50, 3, 59), Longitude: dms(-5, 42, 53)},
+			to:       geo.Point{Latitude: dms(58, 38, 38), Longitude: dms(-3, 4, 12)},
+			expected: dms(9, 7, 11),
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.InDelta(t, tc.expected, geo.Bearing(tc.from, tc.to), 1e-3)
+		})
+	}
+}
+
+func TestDestination(t *testing.T) {
+	t.Parallel()
+	from := geo.Point{Latitude: dms(53, 19, 14), Longitude: dms(-1, 43, 47)}
+
+	actual := geo.Destination(from, dms(96, 1, 18), 124800)
+
+	assert.InDelta(t, dms(53, 11, 18), actual.Latitude, 1e-3)
+	assert.InDelta(t, dms(0, 8, 0), actual.Longitude, 1e-3)
+}
+
+func TestDestination_AcrossAntimeridian(t *testing.T) {
+	t.Parallel()
+	actual := geo.Destination(geo.Point{Latitude: 0, Longitude: 179.9}, 90, 22239)
+
+	assert.InDelta(t, 0, actual.Latitude, 1e-6)
+	assert.InDelta(t, -179.9, actual.Longitude, 1e-3)
+}
+
+func TestNewBoundingBox(t *testing.T) {
+	t.Parallel()
+	center := geo.Point{Latitude: 48.864193, Longitude: 2.350498}
+	radius := 1000.0
+
+	bb := geo.NewBoundingBox(center, radius)
+
+	// The points at the radius to the north, east, south and west lie on the box border.
+	assert.InDelta(t, bb.MaxLatitude, geo.Destination(center, 0, radius).Latitude, 1e-9)
+	assert.InDelta(t, bb.MinLatitude, geo.Destination(center, 180, radius).Latitude, 1e-9)
+	assert.InDelta(t, bb.MaxLongitude, geo.Destination(center, 90, radius).Longitude, 1e-5)
+	assert.InDelta(t, bb.MinLongitude, geo.Destination(center, 270, radius).Longitude, 1e-5)
+	for bearing := 0.0; bearing < 360; bearing += 15 {
+		assert.True(t, bb.Contains(geo.Destination(center, bearing, radius*0.999)), "bearing %v", bearing)
+	}
+	assert.False(t, bb.Contains(geo.Destination(center, 45, radius*1.5)))
+}
+
+func TestNewBoundingBox_AcrossAntimeridian(t *testing.T) {
+	t.Parallel()
+	bb := geo.NewBoundingBox(geo.Point{Latitude: 0, Longitude: 179.99}, 10000)
+
+	assert.Greater(t, bb.MinLongitude, bb.MaxLongitude)
+	assert.True(t, bb.Contains(geo.Point{Latitude: 0, Longitude: -179.99}))
+	assert.True(t, bb.Contains(geo.Point{Latitude: 0, Longitude: 179.95}))
+	assert.False(t, bb.Contains(geo.Point{Latitude: 0, Longitude: 0}))
+	assert.InDelta(t, 179.99, bb.Center().Longitude, 1e-9)
+}
+
+func TestNewBoundingBox_CoversPole(t *testing.T) {
+	t.Parallel()
+	bb := geo.NewBoundingBox(geo.Point{Latitude: 89.99, Longitude: 10}, 10000)
+
+	assert.Equal(t, 90.0, bb.MaxLatitude)
+	assert.Equal(t, -180.0, bb.MinLongitude)
+	assert.Equal(t, 180.0, bb.MaxLongitude)
+	assert.True(t, bb.Contains(geo.Point{Latitude: 89.99, Longitude: -170}))
+}
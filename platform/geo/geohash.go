@@ -0,0 +1,146 @@
+package geo
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	geohashAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+	// MaxGeohashPrecision is the longest geohash that fits the float64 precision.
+	MaxGeohashPrecision = 12
+)
+
+// Direction of a neighboring geohash cell.
+type Direction int
+
+const (
+	North Direction = iota
+	NorthEast
+	East
+	SouthEast
+	South
+	SouthWest
+	West
+	NorthWest
+)
+
+// Cell offsets in the latitude and longitude of each direction.
+var directionOffsets = [...][2]float64{
+	North:     {1, 0},
+	NorthEast: {1, 1},
+	East:      {0, 1},
+	SouthEast: {-1, 1},
+	South:     {-1, 0},
+	SouthWest: {-1, -1},
+	West:      {0, -1},
+	NorthWest: {1, -1},
+}
+
+// EncodeGeohash returns the geohash of the point with the precision number of characters.
+func EncodeGeohash(p Point, precision int) (string, error) {
+	if precision < 1 || precision > MaxGeohashPrecision {
+		return "", errors.Errorf("geohash precision must be between 1 and %d, got %d", MaxGeohashPrecision, precision)
+	}
+	if p.Latitude < -90 || p.Latitude > 90 || p.Longitude < -180 || p.Longitude > 180 {
+		return "", errors.Errorf("invalid point %+v", p)
+	}
+	latRange, lonRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	var sb strings.Builder
+	sb.Grow(precision)
+	isLonBit := true
+	for sb.Len() < precision {
+		var index int
+		for bit := 0; bit < 5; bit++ {
+			index <<= 1
+			if isLonBit {
+				index |= bisect(&lonRange, p.Longitude)
+			} else {
+				index |= bisect(&latRange, p.Latitude)
+			}
+			isLonBit = !isLonBit
+		}
+		sb.WriteByte(geohashAlphabet[index])
+	}
+	return sb.String(), nil
+}
+
+// bisect narrows the range to the half containing the value and returns 1 for the upper half.
+func bisect(r *[2]float64, value float64) int {
+	mid := (r[0] + r[1]) / 2
+	if value >= mid {
+		r[0] = mid
+		return 1
+	}
+	r[1] = mid
+	return 0
+}
+
+// DecodeGeohash returns the cell covered by the geohash.
+func DecodeGeohash(hash string) (BoundingBox, error) {
+	if hash == "" || len(hash) > MaxGeohashPrecision {
+		return BoundingBox{}, errors.Errorf("geohash must be 1 to %d characters long, got %q", MaxGeohashPrecision, hash)
+	}
+	latRange, lonRange := [2]float64{-90, 90}, [2]float64{-180, 180}
+	isLonBit := true
+	for _, c := range strings.ToLower(hash) {
+		index := strings.IndexRune(geohashAlphabet, c)
+		if index < 0 {
+			return BoundingBox{}, errors.Errorf("invalid geohash character %q in %q", c, hash)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			r := &latRange
+			if isLonBit {
+				r = &lonRange
+			}
+			mid := (r[0] + r[1]) / 2
+			if index>>bit&1 == 1 {
+				r[0] = mid
+			} else {
+				r[1] = mid
+			}
+			isLonBit = !isLonBit
+		}
+	}
+	return BoundingBox{
+		MinLatitude:  latRange[0],
+		MinLongitude: lonRange[0],
+		MaxLatitude:  latRange[1],
+		MaxLongitude: lonRange[1],
+	}, nil
+}
+
+// GeohashNeighbor returns the adjacent cell of the same precision in the direction.
+// Cells wrap around the antimeridian, there is no neighbor beyond a pole and an empty string is returned.
+func GeohashNeighbor(hash string, direction Direction) (string, error) {
+	if direction < North || direction > NorthWest {
+		return "", errors.Errorf("unknown direction %d", direction)
+	}
+	cell, err := DecodeGeohash(hash)
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	center := cell.Center()
+	offset := directionOffsets[direction]
+	lat := center.Latitude + offset[0]*(cell.MaxLatitude-cell.MinLatitude)
+	if lat < -90 || lat > 90 {
+		return "", nil
+	}
+	lon := normalizeLongitude(center.Longitude + offset[1]*(cell.MaxLongitude-cell.MinLongitude))
+	neighbor, err := EncodeGeohash(Point{Latitude: lat, Longitude: lon}, len(hash))
+	return neighbor, errors.WithStack(err)
+}
+
+// GeohashNeighbors returns the 8 adjacent cells indexed by Direction, starting from North clockwise.
+func GeohashNeighbors(hash string) ([]string, error) {
+	neighbors := make([]string, len(directionOffsets))
+	for direction := range directionOffsets {
+		neighbor, err := GeohashNeighbor(hash, Direction(direction))
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		neighbors[direction] = neighbor
+	}
+	return neighbors, nil
+}
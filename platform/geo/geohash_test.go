@@ -0,0 +1,84 @@
+package geo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/platform/geo"
+)
+
+func TestEncodeGeohash(t *testing.T) {
+	t.Parallel()
+	actual, err := geo.EncodeGeohash(geo.Point{Latitude: 57.64911, Longitude: 10.40744}, 11)
+	require.NoError(t, err)
+	assert.Equal(t, "u4pruydqqvj", actual)
+}
+
+func TestEncodeGeohash_Invalid(t *testing.T) {
+	t.Parallel()
+	_, err := geo.EncodeGeohash(geo.Point{Latitude: 0, Longitude: 0}, 13)
+	assert.EqualError(t, err, "geohash precision must be between 1 and 12, got 13")
+
+	_, err = geo.EncodeGeohash(geo.Point{Latitude: 91, Longitude: 0}, 5)
+	assert.EqualError(t, err, "invalid point {Latitude:91 Longitude:0}")
+}
+
+func TestDecodeGeohash(t *testing.T) {
+	t.Parallel()
+	cell, err := geo.DecodeGeohash("ezs42")
+	require.NoError(t, err)
+
+	assert.InDelta(t, 42.583, cell.MinLatitude, 1e-3)
+	assert.InDelta(t, 42.627, cell.MaxLatitude, 1e-3)
+	assert.InDelta(t, -5.625, cell.MinLongitude, 1e-3)
+	assert.InDelta(t, -5.581, cell.MaxLongitude, 1e-3)
+	assert.True(t, cell.Contains(geo.Point{Latitude: 42.6, Longitude: -5.6}))
+}
+
+func TestDecodeGeohash_Invalid(t *testing.T) {
+	t.Parallel()
+	_, err := geo.DecodeGeohash("ezs4a")
+	assert.EqualError(t, err, `invalid geohash character 'a' in "ezs4a"`)
+
+	_, err = geo.DecodeGeohash("")
+	assert.EqualError(t, err, `geohash must be 1 to 12 characters long, got ""`)
+}
+
+func TestGeohash_RoundTrip(t *testing.T) {
+	t.Parallel()
+	point := geo.Point{Latitude: 48.864193, Longitude: 2.350498}
+	for precision := 1; precision <= geo.MaxGeohashPrecision; precision++ {
+		hash, err := geo.EncodeGeohash(point, precision)
+		require.NoError(t, err)
+		cell, err := geo.DecodeGeohash(hash)
+		require.NoError(t, err)
+		assert.True(t, cell.Contains(point), "precision %d", precision)
+	}
+}
+
+func TestGeohashNeighbors(t *testing.T) {
+	t.Parallel()
+	actual, err := geo.GeohashNeighbors("dqcjq")
+	require.NoError(t, err)
+
+	expected := []string{"dqcjw", "dqcjx", "dqcjr", "dqcjp", "dqcjn", "dqcjj", "dqcjm", "dqcjt"}
+	assert.Equal(t, expected, actual)
+}
+
+func TestGeohashNeighbor_Edges(t *testing.T) {
+	t.Parallel()
+	// The cells at the antimeridian wrap around.
+	east, err := geo.GeohashNeighbor("xbpb", geo.East)
+	require.NoError(t, err)
+	assert.Equal(t, "8000", east)
+
+	// There is nothing north of the north pole.
+	north, err := geo.GeohashNeighbor("zzzz", geo.North)
+	require.NoError(t, err)
+	assert.Empty(t, north)
+
+	_, err = geo.GeohashNeighbor("dqcjq", geo.Direction(8))
+	assert.EqualError(t, err, "unknown direction 8")
+}
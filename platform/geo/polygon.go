@@ -0,0 +1,65 @@
+package geo
+
+// Ring is a closed line of points, the last point may or may not repeat the first one.
+type Ring []Point
+
+// Polygon is an exterior ring followed by optional holes, the same layout as GeoJSON polygon coordinates.
+// Edges are treated as straight lines in latitude/longitude, which is accurate for city-sized polygons.
+type Polygon []Ring
+
+// Contains reports whether the point is inside the exterior ring and outside all the holes.
+func (p Polygon) Contains(point Point) bool {
+	if len(p) == 0 || !p[0].Contains(point) {
+		return false
+	}
+	for _, hole := range p[1:] {
+		if hole.Contains(point) {
+			return false
+		}
+	}
+	return true
+}
+
+// BoundingBox returns the smallest box containing the exterior ring.
+func (p Polygon) BoundingBox() BoundingBox {
+	if len(p) == 0 || len(p[0]) == 0 {
+		return BoundingBox{}
+	}
+	bb := BoundingBox{
+		MinLatitude:  p[0][0].Latitude,
+		MinLongitude: p[0][0].Longitude,
+		MaxLatitude:  p[0][0].Latitude,
+		MaxLongitude: p[0][0].Longitude,
+	}
+	for _, point := range p[0][1:] {
+		if point.Latitude < bb.MinLatitude {
+			bb.MinLatitude = point.Latitude
+		}
+		if point.Latitude > bb.MaxLatitude {
+			bb.MaxLatitude = point.Latitude
+		}
+		if point.Longitude < bb.MinLongitude {
+			bb.MinLongitude = point.Longitude
+		}
+		if point.Longitude > bb.MaxLongitude {
+			bb.MaxLongitude = point.Longitude
+		}
+	}
+	return bb
+}
+
+// Contains reports whether the point is inside the ring using the even-odd ray casting rule.
+func (r Ring) Contains(point Point) bool {
+	inside := false
+	for i, j := 0, len(r)-1; i < len(r); j, i = i, i+1 {
+		a, b := r[i], r[j]
+		if (a.Latitude > point.Latitude) == (b.Latitude > point.Latitude) {
+			continue
+		}
+		crossingLon := a.Longitude + (point.Latitude-a.Latitude)*(b.Longitude-a.Longitude)/(b.Latitude-a.Latitude)
+		if point.Longitude < crossingLon {
+			inside = !inside
+		}
+	}
+	return inside
+}
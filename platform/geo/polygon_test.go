@@ -0,0 +1,57 @@
+package geo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/georgysavva/driver-app/platform/geo"
+)
+
+func TestPolygon_Contains(t *testing.T) {
+	t.Parallel()
+	// A square with a square hole in the middle.
+	polygon := geo.Polygon{
+		{{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 10}, {Latitude: 10, Longitude: 10}, {Latitude: 10, Longitude: 0}},
+		{{Latitude: 4, Longitude: 4}, {Latitude: 4, Longitude: 6}, {Latitude: 6, Longitude: 6}, {Latitude: 6, Longitude: 4}},
+	}
+	cases := []struct {
+		name     string
+		point    geo.Point
+		expected bool
+	}{
+		{name: "inside", point: geo.Point{Latitude: 2, Longitude: 2}, expected: true},
+		{name: "in the hole", point: geo.Point{Latitude: 5, Longitude: 5}, expected: false},
+		{name: "outside", point: geo.Point{Latitude: 11, Longitude: 5}, expected: false},
+		{name: "level with a vertex", point: geo.Point{Latitude: 4, Longitude: 8}, expected: true},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			assert.Equal(t, tc.expected, polygon.Contains(tc.point))
+		})
+	}
+}
+
+func TestRing_Contains_Concave(t *testing.T) {
+	t.Parallel()
+	// A "U" shape, the notch between its arms is outside.
+	ring := geo.Ring{
+		{Latitude: 0, Longitude: 0}, {Latitude: 0, Longitude: 3}, {Latitude: 3, Longitude: 3}, {Latitude: 3, Longitude: 2},
+		{Latitude: 1, Longitude: 2}, {Latitude: 1, Longitude: 1}, {Latitude: 3, Longitude: 1}, {Latitude: 3, Longitude: 0},
+		{Latitude: 0, Longitude: 0},
+	}
+
+	assert.True(t, ring.Contains(geo.Point{Latitude: 2, Longitude: 0.5}))
+	assert.True(t, ring.Contains(geo.Point{Latitude: 0.5, Longitude: 1.5}))
+	assert.False(t, ring.Contains(geo.Point{Latitude: 2, Longitude: 1.5}))
+}
+
+func TestPolygon_BoundingBox(t *testing.T) {
+	t.Parallel()
+	polygon := geo.Polygon{{{Latitude: 1, Longitude: 5}, {Latitude: -2, Longitude: 7}, {Latitude: 3, Longitude: 6}}}
+
+	expected := geo.BoundingBox{MinLatitude: -2, MinLongitude: 5, MaxLatitude: 3, MaxLongitude: 7}
+	assert.Equal(t, expected, polygon.BoundingBox())
+}
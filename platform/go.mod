@@ -0,0 +1,13 @@
+module github.com/georgysavva/driver-app/platform
+
+go 1.14
+
+require (
+	github.com/nsqio/go-nsq v1.0.8
+	github.com/pkg/errors v0.9.1
+	github.com/sirupsen/logrus v1.7.0
+	github.com/stretchr/testify v1.7.0
+	google.golang.org/grpc v1.43.0
+	google.golang.org/protobuf v1.25.0
+	gopkg.in/yaml.v2 v2.3.0
+)
@@ -0,0 +1,24 @@
+package grpcmiddleware
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+)
+
+func NewLoggingUnaryInterceptor(logger log.FieldLogger) grpc.UnaryServerInterceptor {
+	return func(
+		ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler,
+	) (interface{}, error) {
+		logger.WithField("grpc_method", info.FullMethod).Info()
+		return handler(ctx, req)
+	}
+}
+
+func NewLoggingStreamInterceptor(logger log.FieldLogger) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		logger.WithField("grpc_method", info.FullMethod).Info()
+		return handler(srv, ss)
+	}
+}
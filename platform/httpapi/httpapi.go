@@ -0,0 +1,23 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+func InternalServerError(w http.ResponseWriter) {
+	http.Error(w, "Internal server error", http.StatusInternalServerError)
+}
+
+func ReturnJSONData(w http.ResponseWriter, obj interface{}) error {
+	return ReturnJSONDataAs(w, "application/json", obj)
+}
+
+// ReturnJSONDataAs encodes the object into json and sends it with a json based Content-Type, e.g. application/geo+json.
+func ReturnJSONDataAs(w http.ResponseWriter, contentType string, obj interface{}) error {
+	w.Header().Set("Content-Type", contentType)
+	err := json.NewEncoder(w).Encode(obj)
+	return errors.Wrap(err, "can not encode or write data into http response")
+}
@@ -0,0 +1,37 @@
+package httpmiddleware
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func NewLoggingMiddleware(next http.Handler, logger log.FieldLogger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.WithFields(log.Fields{"method": r.Method, "path": r.RequestURI}).Info()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// NewBearerAuthMiddleware rejects requests without the token in the "Authorization: Bearer <token>" header.
+func NewBearerAuthMiddleware(next http.Handler, token string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestToken, hasScheme := trimBearerScheme(r.Header.Get("Authorization"))
+		if token == "" || !hasScheme || subtle.ConstantTimeCompare([]byte(requestToken), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func trimBearerScheme(authorization string) (string, bool) {
+	const scheme = "Bearer "
+	if len(authorization) < len(scheme) || !strings.EqualFold(authorization[:len(scheme)], scheme) {
+		return "", false
+	}
+	return authorization[len(scheme):], true
+}
@@ -0,0 +1,43 @@
+package httpmiddleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/georgysavva/driver-app/platform/httpmiddleware"
+)
+
+func TestBearerAuthMiddleware(t *testing.T) {
+	t.Parallel()
+	next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusNoContent) })
+	handler := httpmiddleware.NewBearerAuthMiddleware(next, "secret")
+
+	cases := []struct {
+		name           string
+		authorization  string
+		expectedStatus int
+	}{
+		{name: "valid token", authorization: "Bearer secret", expectedStatus: http.StatusNoContent},
+		{name: "invalid token", authorization: "Bearer guess", expectedStatus: http.StatusUnauthorized},
+		{name: "token without scheme", authorization: "secret", expectedStatus: http.StatusUnauthorized},
+		{name: "no header", expectedStatus: http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			request := httptest.NewRequest(http.MethodGet, "/admin", nil)
+			if tc.authorization != "" {
+				request.Header.Set("Authorization", tc.authorization)
+			}
+			recorder := httptest.NewRecorder()
+
+			handler.ServeHTTP(recorder, request)
+
+			assert.Equal(t, tc.expectedStatus, recorder.Code)
+		})
+	}
+}
@@ -0,0 +1,15 @@
+package logging
+
+import (
+	log "github.com/sirupsen/logrus"
+)
+
+func NewLogger() *log.Logger {
+	logger := log.New()
+	logger.SetFormatter(&log.JSONFormatter{})
+	return logger
+}
+
+func LogUnhandledError(logger log.FieldLogger, err error) {
+	logger.WithError(err).Error("Unhandled error occurred")
+}
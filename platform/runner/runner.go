@@ -0,0 +1,265 @@
+package runner
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/nsqio/go-nsq"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+
+	"github.com/georgysavva/driver-app/platform/config"
+	"github.com/georgysavva/driver-app/platform/grpcmiddleware"
+	"github.com/georgysavva/driver-app/platform/httpmiddleware"
+	"github.com/georgysavva/driver-app/platform/logging"
+)
+
+const (
+	defaultConfigPath      = "config.yaml"
+	defaultShutdownTimeout = 5 * time.Second
+)
+
+type HTTPServerConfig struct {
+	Port            int           `yaml:"port" validate:"required,min=1,max=65535"`
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" default:"5s" validate:"min=0"`
+}
+
+// GRPCServerConfig configures the gRPC server exposed alongside the HTTP one.
+type GRPCServerConfig struct {
+	Enabled bool `yaml:"enabled"`
+	Port    int  `yaml:"port" validate:"required,min=1,max=65535"`
+}
+
+type NSQProducerConfig struct {
+	DaemonAddress string `yaml:"daemon_address" validate:"required"`
+}
+
+type NSQConsumerConfig struct {
+	Topic           string   `yaml:"topic" validate:"required"`
+	Channel         string   `yaml:"channel" validate:"required"`
+	DaemonAddresses []string `yaml:"daemon_addresses" validate:"required"`
+	WorkersNum      int      `yaml:"workers_num" default:"1" validate:"min=1"`
+	// MaxAttempts is the number of deliveries after which go-nsq drops a message, 0 means no limit.
+	// A handler applying its own attempts policy needs it to be 0 or above its own limit.
+	MaxAttempts int `yaml:"max_attempts" default:"5" validate:"min=0,max=65535"`
+}
+
+// Runner drives the lifecycle of a service process: it owns the logger, starts servers and consumers
+// and, once a termination signal is received, runs the registered shutdown hooks in reverse order.
+type Runner struct {
+	logger          *log.Logger
+	shutdownTimeout time.Duration
+	hooks           []*shutdownHook
+}
+
+type shutdownHook struct {
+	name string
+	fn   func(ctx context.Context) error
+}
+
+func New() *Runner {
+	return &Runner{
+		logger:          logging.NewLogger(),
+		shutdownTimeout: defaultShutdownTimeout,
+	}
+}
+
+func (r *Runner) Logger() *log.Logger {
+	return r.logger
+}
+
+// LoadConfig loads the service config into conf, see config.Load for the details.
+// The config file path is taken from the --config command line flag.
+// If the --check-config flag is passed, LoadConfig only reports whether the config is valid and exits.
+// Otherwise, it terminates the process with the list of problems if the config is invalid.
+func (r *Runner) LoadConfig(envPrefix string, conf interface{}) {
+	flags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	configPath := flags.String("config", defaultConfigPath, "path to the yaml config file")
+	checkConfig := flags.Bool("check-config", false, "validate the config and exit")
+	_ = flags.Parse(os.Args[1:]) // nolint: errcheck
+
+	err := config.Load(*configPath, envPrefix, conf)
+	if *checkConfig {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		fmt.Printf("Config %s is valid\n", *configPath)
+		os.Exit(0)
+	}
+	if err != nil {
+		r.logger.WithError(err).WithField("config_path", *configPath).Fatal("Failed to load config")
+	}
+}
+
+// OnShutdown registers a hook called during the graceful shutdown.
+// Hooks are called in the reverse order of their registration.
+func (r *Runner) OnShutdown(name string, fn func(ctx context.Context) error) {
+	r.hooks = append(r.hooks, &shutdownHook{name: name, fn: fn})
+}
+
+// AddCloser registers a component, e.g. a redis client, to be closed during the graceful shutdown.
+func (r *Runner) AddCloser(name string, closer io.Closer) {
+	r.OnShutdown(name, func(_ context.Context) error {
+		return closer.Close()
+	})
+}
+
+func (r *Runner) StartHTTPServer(conf *HTTPServerConfig, handler http.Handler) {
+	handler = httpmiddleware.NewLoggingMiddleware(handler, r.logger)
+	httpServer := &http.Server{Addr: fmt.Sprintf(":%d", conf.Port), Handler: handler}
+	go func() {
+		if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			r.logger.WithError(err).Fatal("HTTP server unexpectedly stopped")
+		}
+	}()
+	r.logger.WithField("http_port", conf.Port).Info("HTTP server successfully started")
+
+	if conf.ShutdownTimeout > 0 {
+		r.shutdownTimeout = conf.ShutdownTimeout
+	}
+	r.OnShutdown("HTTP server", httpServer.Shutdown)
+}
+
+// StartGRPCServer serves the services registered by the register function.
+// Server reflection is enabled, so clients like the gateway can learn the message types at runtime.
+// The graceful shutdown waits for in-flight calls, so never-ending streams must be ended before.
+func (r *Runner) StartGRPCServer(conf *GRPCServerConfig, register func(server *grpc.Server)) {
+	grpcServer := grpc.NewServer(
+		grpc.UnaryInterceptor(grpcmiddleware.NewLoggingUnaryInterceptor(r.logger)),
+		grpc.StreamInterceptor(grpcmiddleware.NewLoggingStreamInterceptor(r.logger)),
+	)
+	register(grpcServer)
+	reflection.Register(grpcServer)
+	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", conf.Port))
+	if err != nil {
+		r.logger.WithError(err).Fatal("Couldn't listen on the gRPC server port")
+	}
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil && !errors.Is(err, grpc.ErrServerStopped) {
+			r.logger.WithError(err).Fatal("gRPC server unexpectedly stopped")
+		}
+	}()
+	r.logger.WithField("grpc_port", conf.Port).Info("gRPC server successfully started")
+
+	r.OnShutdown("gRPC server", func(ctx context.Context) error {
+		stopped := make(chan struct{})
+		go func() {
+			grpcServer.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+			return nil
+		case <-ctx.Done():
+			grpcServer.Stop()
+			return errors.Wrap(ctx.Err(), "in-flight grpc calls weren't finished in time")
+		}
+	})
+}
+
+func (r *Runner) StartNSQConsumer(conf *NSQConsumerConfig, handler nsq.Handler) *nsq.Consumer {
+	nsqConf := nsq.NewConfig()
+	nsqConf.MaxAttempts = uint16(conf.MaxAttempts)
+	nsqConsumer, err := nsq.NewConsumer(conf.Topic, conf.Channel, nsqConf)
+	if err != nil {
+		r.logger.WithError(err).Fatal("Couldn't initialize nsq consumer")
+	}
+	nsqConsumer.AddConcurrentHandlers(handler, conf.WorkersNum)
+	if err := nsqConsumer.ConnectToNSQDs(conf.DaemonAddresses); err != nil {
+		r.logger.WithError(err).Fatal("Couldn't connect nsq consumer to nsqds")
+	}
+	r.logger.WithFields(log.Fields{"topic": conf.Topic, "channel": conf.Channel}).
+		Info("NSQ consumer successfully started")
+
+	r.OnShutdown("NSQ consumer", func(ctx context.Context) error {
+		nsqConsumer.Stop()
+		select {
+		case <-nsqConsumer.StopChan:
+			return nil
+		case <-ctx.Done():
+			return errors.Wrap(ctx.Err(), "in-flight nsq messages weren't processed in time")
+		}
+	})
+	return nsqConsumer
+}
+
+func (r *Runner) StartNSQProducer(conf *NSQProducerConfig) *nsq.Producer {
+	nsqProducer, err := nsq.NewProducer(conf.DaemonAddress, nsq.NewConfig())
+	if err != nil {
+		r.logger.WithError(err).Fatal("Failed to connect nsq producer to daemon")
+	}
+
+	r.OnShutdown("NSQ producer", func(_ context.Context) error {
+		nsqProducer.Stop()
+		return nil
+	})
+	return nsqProducer
+}
+
+// StartBackground runs fn in a separate goroutine. During the graceful shutdown
+// the context passed to fn is canceled and the runner waits for fn to return.
+func (r *Runner) StartBackground(name string, fn func(ctx context.Context)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(ctx)
+	}()
+	r.logger.WithField("component", name).Info("Background component successfully started")
+
+	r.OnShutdown(name, func(shutdownCtx context.Context) error {
+		cancel()
+		select {
+		case <-done:
+			return nil
+		case <-shutdownCtx.Done():
+			return errors.Wrap(shutdownCtx.Err(), "background component didn't stop in time")
+		}
+	})
+}
+
+// Wait blocks until the process receives a termination signal and then gracefully shuts the service down.
+func (r *Runner) Wait() {
+	terminationChan := make(chan os.Signal, 1)
+	signal.Notify(terminationChan, syscall.SIGINT, syscall.SIGTERM)
+	<-terminationChan
+
+	if err := r.Shutdown(); err != nil {
+		r.logger.WithError(err).Fatal("Couldn't properly shutdown the service")
+	}
+}
+
+// Shutdown calls all registered hooks, latest registered first, within the shutdown timeout.
+// It doesn't stop on a failed hook and returns the first error that occurred.
+func (r *Runner) Shutdown() error {
+	r.logger.WithField("shutdown_timeout", r.shutdownTimeout).Info("Shutting down the service")
+	ctx, cancel := context.WithTimeout(context.Background(), r.shutdownTimeout)
+	defer cancel()
+
+	var firstErr error
+	for i := len(r.hooks) - 1; i >= 0; i-- {
+		hook := r.hooks[i]
+		ctxLogger := r.logger.WithField("component", hook.name)
+		ctxLogger.Info("Stopping component")
+		if err := hook.fn(ctx); err != nil {
+			ctxLogger.WithError(err).Error("Couldn't properly stop component")
+			if firstErr == nil {
+				firstErr = errors.Wrapf(err, "failed to stop %s", hook.name)
+			}
+			continue
+		}
+		ctxLogger.Info("Component stopped")
+	}
+	return firstErr
+}
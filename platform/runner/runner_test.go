@@ -0,0 +1,109 @@
+package runner_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+
+	"github.com/georgysavva/driver-app/platform/runner"
+)
+
+func TestRunner_Shutdown(t *testing.T) {
+	t.Parallel()
+	app := newRunner()
+	var stopped []string
+	for _, name := range []string{"redis", "http", "nsq"} {
+		name := name
+		app.OnShutdown(name, func(_ context.Context) error {
+			stopped = append(stopped, name)
+			return nil
+		})
+	}
+
+	err := app.Shutdown()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{"nsq", "http", "redis"}, stopped)
+}
+
+func TestRunner_Shutdown_HookFails(t *testing.T) {
+	t.Parallel()
+	app := newRunner()
+	var stopped []string
+	app.AddCloser("first", closerFunc(func() error {
+		stopped = append(stopped, "first")
+		return nil
+	}))
+	app.OnShutdown("second", func(_ context.Context) error {
+		stopped = append(stopped, "second")
+		return errors.New("boom")
+	})
+
+	err := app.Shutdown()
+	require.EqualError(t, err, "failed to stop second: boom")
+
+	assert.Equal(t, []string{"second", "first"}, stopped)
+}
+
+func TestRunner_StartBackground(t *testing.T) {
+	t.Parallel()
+	app := newRunner()
+	started := make(chan struct{})
+	stopped := false
+	app.StartBackground("worker", func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		stopped = true
+	})
+	<-started
+
+	err := app.Shutdown()
+	require.NoError(t, err)
+
+	assert.True(t, stopped)
+}
+
+func TestRunner_StartGRPCServer(t *testing.T) {
+	t.Parallel()
+	app := newRunner()
+	port := freePort(t)
+	app.StartGRPCServer(&runner.GRPCServerConfig{Enabled: true, Port: port}, func(server *grpc.Server) {
+		healthpb.RegisterHealthServer(server, health.NewServer())
+	})
+	conn, err := grpc.Dial(net.JoinHostPort("localhost", fmt.Sprint(port)), grpc.WithInsecure(), grpc.WithBlock())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	response, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	require.NoError(t, err)
+	assert.Equal(t, healthpb.HealthCheckResponse_SERVING, response.Status)
+
+	err = app.Shutdown()
+	require.NoError(t, err)
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error { return f() }
+
+func freePort(t *testing.T) int {
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port
+}
+
+func newRunner() *runner.Runner {
+	app := runner.New()
+	app.Logger().SetLevel(log.ErrorLevel)
+	return app
+}
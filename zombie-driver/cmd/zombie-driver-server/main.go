@@ -2,65 +2,189 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
+	"time"
 
+	"github.com/georgysavva/driver-app/driver-location/pkg/clients/driverlocgrpc"
 	"github.com/georgysavva/driver-app/driver-location/pkg/clients/driverlochttp"
-	"github.com/pkg/errors"
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/platform/httpmiddleware"
+	"github.com/georgysavva/driver-app/platform/runner"
+	"github.com/go-redis/redis/v8"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/georgysavva/driver-app/zombie-driver/pkg/config"
-	"github.com/georgysavva/driver-app/zombie-driver/pkg/httpmiddleware"
 	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
 )
 
-// Improvement: allow to pass a custom config path.
-const defaultConfigPath = "config.yaml"
+const configEnvPrefix = "ZOMBIEDRIVER"
 
 func main() {
-	logger := log.New()
-	logger.SetFormatter(&log.JSONFormatter{})
-	conf, err := config.ParseConfig(defaultConfigPath)
-	if err != nil {
-		logger.WithError(err).Fatal("Failed to parse config")
-	}
-	httpClient := http.DefaultClient
+	app := runner.New()
+	logger := app.Logger()
+	conf := &config.Config{}
+	app.LoadConfig(configEnvPrefix, conf)
 
-	driverLocationClient, err := driverlochttp.NewClient(httpClient, conf.DriverLocationService.BaseURL)
-	if err != nil {
-		logger.WithError(err).Fatal("Failed initialize driver-location service http client")
+	var (
+		driverLocationClient driverloc.GetterService
+		err                  error
+	)
+	switch driverLocationConf := conf.DriverLocationService; driverLocationConf.Transport {
+	case config.DriverLocationTransportGRPC:
+		conn, err := driverlocgrpc.Dial(driverLocationConf.GRPCAddress)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed initialize driver-location service grpc client")
+		}
+		app.AddCloser("gRPC client", conn)
+		driverLocationClient = driverlocgrpc.NewClient(conn)
+	default:
+		httpClient := http.DefaultClient
+		app.OnShutdown("HTTP client", func(_ context.Context) error {
+			httpClient.CloseIdleConnections()
+			return nil
+		})
+		driverLocationClient, err = driverlochttp.NewClient(httpClient, driverLocationConf.BaseURL)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed initialize driver-location service http client")
+		}
 	}
 
+	var cache zombiedriver.DriverCache = zombiedriver.NopCache{}
+	var cacheMaxAge time.Duration
+	if cacheConf := conf.Cache; cacheConf.Enabled {
+		cacheMaxAge = cacheConf.TTL
+		switch cacheConf.Backend {
+		case config.CacheBackendRedis:
+			redisClient := redis.NewClient(&redis.Options{Addr: cacheConf.Redis.Address})
+			app.AddCloser("Redis client", redisClient)
+			cache = zombiedriver.NewRedisCache(redisClient, cacheConf.TTL)
+		default:
+			cache = zombiedriver.NewLRUCache(cacheConf.Size, cacheConf.TTL)
+		}
+	}
+	var zones *zombiedriver.ZoneSet
+	if zonesConf := conf.Zones; zonesConf.Enabled {
+		zoneList, err := zombiedriver.LoadZones(
+			zonesConf.File, conf.App.ZombiePredicate, logger.WithField("component", "zones"),
+		)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to load zones")
+		}
+		logger.WithField("zones_num", len(zoneList)).Info("Loaded zones")
+		zones = zombiedriver.NewZoneSet(zoneList, zonesConf.OnlyInZones)
+	}
+	var campaigns *zombiedriver.CampaignSchedule
+	if campaignsConf := conf.Campaigns; campaignsConf.Enabled {
+		campaigns, err = zombiedriver.NewCampaignSchedule(campaignsConf.List, conf.App.ZombiePredicate)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to parse campaigns")
+		}
+		logger.WithField("campaigns_num", len(campaignsConf.List)).Info("Loaded campaign schedule")
+	}
+	var experiment *zombiedriver.Experiment
+	if experimentConf := conf.Experiment; experimentConf.Enabled {
+		experiment, err = zombiedriver.NewExperiment(
+			experimentConf.Name, experimentConf.Arms, conf.App.ZombiePredicate,
+			logger.WithField("component", "experiment"),
+		)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to parse experiment")
+		}
+		logger.WithFields(log.Fields{
+			"experiment": experimentConf.Name,
+			"arms_num":   len(experimentConf.Arms),
+		}).Info("Loaded experiment")
+	}
+	var overrides zombiedriver.OverrideStore = zombiedriver.NopOverrideStore{}
+	if overridesConf := conf.Overrides; overridesConf.Enabled {
+		switch overridesConf.Backend {
+		case config.OverridesBackendRedis:
+			redisClient := redis.NewClient(&redis.Options{Addr: overridesConf.Redis.Address})
+			app.AddCloser("Overrides Redis client", redisClient)
+			overrides = zombiedriver.NewRedisOverrideStore(redisClient, overridesConf.Redis.AuditMaxLen)
+		default:
+			overrides, err = zombiedriver.NewFileOverrideStore(overridesConf.Dir)
+			if err != nil {
+				logger.WithError(err).Fatal("Failed to load overrides")
+			}
+		}
+	}
+	var history *zombiedriver.HistoryRecorder
+	if historyConf := conf.History; historyConf.Enabled {
+		var sink zombiedriver.HistorySink
+		switch historyConf.Sink {
+		case config.HistorySinkRedis:
+			redisClient := redis.NewClient(&redis.Options{Addr: historyConf.Redis.Address})
+			app.AddCloser("History Redis client", redisClient)
+			sink = zombiedriver.NewRedisHistorySink(redisClient, historyConf.Redis.MaxLen)
+		default:
+			sink = zombiedriver.NewFileHistorySink(historyConf.File)
+		}
+		history = zombiedriver.NewHistoryRecorder(
+			sink, historyConf.MaxDrivers, historyConf.Lookback, logger.WithField("component", "history"),
+		)
+	}
+	// The streaming mode and the status events share the location windows, fed by a single consumer.
+	streamingConf, statusEvents := conf.Streaming, conf.StatusEvents
+	var windows *zombiedriver.LocationWindows
+	if streamingConf.Enabled || statusEvents.Enabled {
+		predicate := conf.App.ZombiePredicate
+		windows, err = zombiedriver.NewLocationWindows(
+			predicate.TimeInterval, predicate.DistanceAlgorithm, streamingConf.OnlineTimeout,
+			logger.WithField("component", "location-windows"),
+		)
+		if err != nil {
+			logger.WithError(err).Fatal("Failed to initialize location windows")
+		}
+	}
+	var serviceWindows *zombiedriver.LocationWindows
+	if streamingConf.Enabled {
+		serviceWindows = windows
+	}
 	service := zombiedriver.NewService(
-		driverLocationClient, logger.WithField("component", "service"), conf.App.ZombiePredicate,
+		driverLocationClient, cache, logger.WithField("component", "service"), conf.App.ZombiePredicate,
+		zones, campaigns, experiment, overrides, history, serviceWindows,
 	)
 
-	httpHandler := zombiedriver.MakeHTTPHandler(service, logger.WithField("component", "http-handler"))
-
-	httpHandler = httpmiddleware.NewLoggingMiddleware(httpHandler, logger)
-	httpServer := http.Server{Addr: fmt.Sprintf(":%d", conf.HTTPServer.Port), Handler: httpHandler}
-	go func() {
-		if err := httpServer.ListenAndServe(); err != nil && errors.Is(err, http.ErrServerClosed) {
-			logger.WithError(err).Fatal("HTTP server unexpectedly stopped")
-		}
-	}()
-	logger.WithField("http_port", conf.HTTPServer.Port).Info("HTTP server successfully started")
-
-	terminationChan := make(chan os.Signal, 1)
-	signal.Notify(terminationChan, syscall.SIGINT, syscall.SIGTERM)
-	<-terminationChan
+	consumerConf := streamingConf.Consumer
+	if !streamingConf.Enabled {
+		consumerConf = statusEvents.Consumer
+	}
+	switch {
+	case statusEvents.Enabled:
+		nsqProducer := app.StartNSQProducer(statusEvents.Producer)
+		tracker := zombiedriver.NewTracker(
+			nsqProducer, statusEvents.Topic, logger.WithField("component", "tracker"), conf.App.ZombiePredicate,
+			zones, campaigns, experiment, overrides, windows,
+		)
+		app.StartBackground("Tracker sweeper", func(ctx context.Context) {
+			tracker.Run(ctx, statusEvents.EvaluationInterval)
+		})
+		nsqHandler := zombiedriver.NewNSQHandler(tracker, logger.WithField("component", "nsq-handler"))
+		app.StartNSQConsumer(consumerConf, nsqHandler)
+	case streamingConf.Enabled:
+		app.StartBackground("Location windows sweeper", func(ctx context.Context) {
+			windows.Run(ctx, streamingConf.SweepInterval)
+		})
+		windowsHandler := zombiedriver.NewNSQHandler(windows, logger.WithField("component", "windows-nsq-handler"))
+		app.StartNSQConsumer(consumerConf, windowsHandler)
+	}
 
-	logger.WithField("shutdown_timeout", conf.HTTPServer.ShutdownTimeout).Info("Stopping http server")
-	ctx, cancel := context.WithTimeout(context.Background(), conf.HTTPServer.ShutdownTimeout)
-	defer cancel()
-	if err := httpServer.Shutdown(ctx); err != nil {
-		logger.WithError(err).Fatal("Couldn't properly shutdown http server")
+	var httpHandler http.Handler = zombiedriver.MakeHTTPHandler(
+		service, cacheMaxAge, logger.WithField("component", "http-handler"),
+	)
+	if conf.Overrides.Enabled {
+		adminHandler := zombiedriver.MakeAdminHTTPHandler(service, logger.WithField("component", "admin-http-handler"))
+		mux := http.NewServeMux()
+		mux.Handle("/admin/", httpmiddleware.NewBearerAuthMiddleware(adminHandler, conf.Overrides.AdminToken))
+		mux.Handle("/", httpHandler)
+		httpHandler = mux
+	}
+	app.StartHTTPServer(conf.HTTPServer, httpHandler)
+	if conf.GRPCServer.Enabled {
+		grpcServer := zombiedriver.NewGRPCServer(service, logger.WithField("component", "grpc-server"))
+		app.StartGRPCServer(conf.GRPCServer, grpcServer.Register)
 	}
-	logger.Info("HTTP server was successfully shutdown")
 
-	httpClient.CloseIdleConnections()
+	app.Wait()
 }
-
@@ -1,12 +1,10 @@
 package config
 
 import (
-	"io/ioutil"
-	"path/filepath"
 	"time"
 
+	"github.com/georgysavva/driver-app/platform/runner"
 	"github.com/pkg/errors"
-	"gopkg.in/yaml.v2"
 
 	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
 )
@@ -16,26 +14,188 @@ type Config struct {
 		ZombiePredicate *zombiedriver.ZombiePredicate `yaml:"zombie_predicate"`
 	} `yaml:"app"`
 
-	DriverLocationService *struct {
-		BaseURL string `yaml:"base_url"`
-	} `yaml:"driver_location_service"`
+	DriverLocationService *DriverLocationServiceConfig `yaml:"driver_location_service"`
 
-	HTTPServer *struct {
-		Port            int           `yaml:"port"`
-		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
-	} `yaml:"http_server"`
+	Campaigns *struct {
+		Enabled bool `yaml:"enabled"`
+		// List of the campaigns, zombies are shown only while one of them is active.
+		List []*zombiedriver.Campaign `yaml:"list"`
+	} `yaml:"campaigns"`
+
+	Experiment *struct {
+		Enabled bool `yaml:"enabled"`
+		// Name salts the driver id hashes, renaming the experiment reshuffles the drivers between the arms.
+		Name string                        `yaml:"name" validate:"required"`
+		Arms []*zombiedriver.ExperimentArm `yaml:"arms"`
+	} `yaml:"experiment"`
+
+	HTTPServer *runner.HTTPServerConfig `yaml:"http_server"`
+
+	GRPCServer *runner.GRPCServerConfig `yaml:"grpc_server"`
+
+	Cache *CacheConfig `yaml:"cache"`
+
+	Overrides *OverridesConfig `yaml:"overrides"`
+
+	History *HistoryConfig `yaml:"history"`
+
+	Zones *struct {
+		Enabled bool `yaml:"enabled"`
+		// File is a GeoJSON feature collection of the zone polygons with predicate overrides in their properties.
+		File string `yaml:"file" validate:"required"`
+		// OnlyInZones makes drivers outside all zones alive instead of judging them by the default predicate.
+		OnlyInZones bool `yaml:"only_in_zones"`
+	} `yaml:"zones"`
+
+	Streaming *struct {
+		Enabled bool `yaml:"enabled"`
+		// OnlineTimeout must match the driver-location app.online_timeout.
+		OnlineTimeout time.Duration `yaml:"online_timeout" default:"1m" validate:"min=1"`
+		SweepInterval time.Duration `yaml:"sweep_interval" default:"5s" validate:"min=1"`
+		// Consumer.Channel must be unique per replica, each replica needs the locations of all drivers.
+		Consumer *runner.NSQConsumerConfig `yaml:"consumer"`
+	} `yaml:"streaming"`
+
+	StatusEvents *struct {
+		Enabled            bool          `yaml:"enabled"`
+		Topic              string        `yaml:"topic" validate:"required"`
+		EvaluationInterval time.Duration `yaml:"evaluation_interval" default:"5s" validate:"min=1"`
+		// Consumer is replaced by the Streaming one when streaming is enabled, both share the location windows.
+		Consumer *runner.NSQConsumerConfig `yaml:"consumer"`
+		Producer *runner.NSQProducerConfig `yaml:"producer"`
+	} `yaml:"status_events"`
+}
+
+const (
+	DriverLocationTransportHTTP = "http"
+	DriverLocationTransportGRPC = "grpc"
+)
+
+type DriverLocationServiceConfig struct {
+	// Transport of the calls to the driver-location service, grpc saves the JSON encoding at both ends.
+	Transport   string `yaml:"transport" default:"http"`
+	BaseURL     string `yaml:"base_url"`     // Only used by the http transport.
+	GRPCAddress string `yaml:"grpc_address"` // Only used by the grpc transport.
+}
+
+func (dc *DriverLocationServiceConfig) Validate() error {
+	switch dc.Transport {
+	case DriverLocationTransportHTTP:
+		if dc.BaseURL == "" {
+			return errors.New("base_url must be set for the http transport")
+		}
+		return nil
+	case DriverLocationTransportGRPC:
+		if dc.GRPCAddress == "" {
+			return errors.New("grpc_address must be set for the grpc transport")
+		}
+		return nil
+	default:
+		return errors.Errorf(
+			"unknown transport %q, must be %q or %q",
+			dc.Transport, DriverLocationTransportHTTP, DriverLocationTransportGRPC,
+		)
+	}
+}
+
+const (
+	CacheBackendMemory = "memory"
+	CacheBackendRedis  = "redis"
+)
+
+type CacheConfig struct {
+	Enabled bool          `yaml:"enabled"`
+	TTL     time.Duration `yaml:"ttl" default:"5s" validate:"min=1"`
+	Backend string        `yaml:"backend" default:"memory"`
+	Size    int           `yaml:"size" default:"10000" validate:"min=1"` // Only used by the memory backend.
+
+	Redis *struct {
+		Address string `yaml:"address"`
+	} `yaml:"redis"`
 }
 
-func ParseConfig(configPath string) (*Config, error) {
-	fileContent, err := ioutil.ReadFile(filepath.Clean(configPath))
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to read config file content")
+func (cc *CacheConfig) Validate() error {
+	switch cc.Backend {
+	case CacheBackendMemory:
+		return nil
+	case CacheBackendRedis:
+		if cc.Redis.Address == "" {
+			return errors.New("redis.address must be set for the redis backend")
+		}
+		return nil
+	default:
+		return errors.Errorf("unknown backend %q, must be %q or %q", cc.Backend, CacheBackendMemory, CacheBackendRedis)
 	}
+}
 
-	conf := &Config{}
-	if err := yaml.Unmarshal(fileContent, conf); err != nil {
-		return nil, errors.Wrap(err, "failed to parse yaml content into Config struct")
+const (
+	OverridesBackendFile  = "file"
+	OverridesBackendRedis = "redis"
+)
+
+type OverridesConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// AdminToken authenticates the admin API requests as "Authorization: Bearer <token>".
+	// It's better set via the environment than kept in the config file.
+	AdminToken string `yaml:"admin_token" validate:"required"`
+	Backend    string `yaml:"backend" default:"file"`
+	Dir        string `yaml:"dir" default:"overrides"` // Only used by the file backend.
+
+	Redis *struct {
+		Address string `yaml:"address"`
+		// AuditMaxLen is the number of the latest audit log entries kept.
+		AuditMaxLen int64 `yaml:"audit_max_len" default:"100000" validate:"min=1"`
+	} `yaml:"redis"`
+}
+
+func (oc *OverridesConfig) Validate() error {
+	switch oc.Backend {
+	case OverridesBackendFile:
+		return nil
+	case OverridesBackendRedis:
+		if oc.Redis.Address == "" {
+			return errors.New("redis.address must be set for the redis backend")
+		}
+		return nil
+	default:
+		return errors.Errorf(
+			"unknown backend %q, must be %q or %q", oc.Backend, OverridesBackendFile, OverridesBackendRedis,
+		)
 	}
+}
+
+const (
+	HistorySinkFile  = "file"
+	HistorySinkRedis = "redis"
+)
 
-	return conf, nil
+type HistoryConfig struct {
+	Enabled bool   `yaml:"enabled"`
+	Sink    string `yaml:"sink" default:"file"`
+	File    string `yaml:"file" default:"status-history.jsonl"` // Only used by the file sink.
+	// MaxDrivers is the number of drivers whose last state is remembered to record only the changes,
+	// the least recently observed ones are forgotten.
+	MaxDrivers int `yaml:"max_drivers" default:"100000" validate:"min=1"`
+	// Lookback is how long before the stats period the transitions are read to know the states at its start.
+	Lookback time.Duration `yaml:"lookback" default:"24h" validate:"min=0"`
+
+	Redis *struct {
+		Address string `yaml:"address"`
+		// MaxLen is the approximate number of the latest transitions kept in the stream.
+		MaxLen int64 `yaml:"max_len" default:"1000000" validate:"min=1"`
+	} `yaml:"redis"`
+}
+
+func (hc *HistoryConfig) Validate() error {
+	switch hc.Sink {
+	case HistorySinkFile:
+		return nil
+	case HistorySinkRedis:
+		if hc.Redis.Address == "" {
+			return errors.New("redis.address must be set for the redis sink")
+		}
+		return nil
+	default:
+		return errors.Errorf("unknown sink %q, must be %q or %q", hc.Sink, HistorySinkFile, HistorySinkRedis)
+	}
 }
@@ -2,23 +2,146 @@ package distance
 
 import (
 	"math"
+
+	"github.com/georgysavva/driver-app/platform/geo"
+	"github.com/pkg/errors"
+)
+
+// Algorithm names, used to select the calculator in the config.
+const (
+	AlgorithmHaversine       = "haversine"
+	AlgorithmVincenty        = "vincenty"
+	AlgorithmEquirectangular = "equirectangular"
+)
+
+// Calculator returns the distance in meters between two points given in degrees.
+type Calculator interface {
+	Calculate(lat1, lon1, lat2, lon2 float64) float64
+}
+
+// MeanEarthRadius is the IUGG mean radius of the Earth in meters.
+const MeanEarthRadius = geo.MeanEarthRadius
+
+// WGS-84 ellipsoid parameters.
+const (
+	wgs84SemiMajorAxis float64 = 6378137
+	wgs84Flattening    float64 = 1 / 298.257223563
+	wgs84SemiMinorAxis         = wgs84SemiMajorAxis * (1 - wgs84Flattening)
 )
 
-const earthRadius float64 = 6378100
+var calculators = map[string]Calculator{
+	AlgorithmHaversine:       Haversine{},
+	AlgorithmVincenty:        Vincenty{},
+	AlgorithmEquirectangular: Equirectangular{},
+}
+
+// ByName returns the calculator of the algorithm, an empty name selects haversine.
+func ByName(algorithm string) (Calculator, error) {
+	if algorithm == "" {
+		return Haversine{}, nil
+	}
+	calculator, ok := calculators[algorithm]
+	if !ok {
+		return nil, errors.Errorf("unknown distance algorithm %q, must be %q, %q or %q",
+			algorithm, AlgorithmHaversine, AlgorithmVincenty, AlgorithmEquirectangular)
+	}
+	return calculator, nil
+}
+
+// Calculate returns the haversine distance, kept for the callers that don't choose the algorithm.
+func Calculate(lat1, lon1, lat2, lon2 float64) float64 {
+	return Haversine{}.Calculate(lat1, lon1, lat2, lon2)
+}
+
+// Haversine is the great-circle distance on a sphere with the mean Earth radius, it's off by up to 0.5%.
+type Haversine struct{}
 
 func hsin(theta float64) float64 {
 	return math.Pow(math.Sin(theta/2), 2)
 }
 
-// Implementation taken from https://gist.github.com/cdipaolo/d3f8db3848278b49db68
-func Calculate(lat1, lon1, lat2, lon2 float64) float64 {
-	var la1, lo1, la2, lo2 float64
-	la1 = lat1 * math.Pi / 180
-	lo1 = lon1 * math.Pi / 180
-	la2 = lat2 * math.Pi / 180
-	lo2 = lon2 * math.Pi / 180
+func (Haversine) Calculate(lat1, lon1, lat2, lon2 float64) float64 {
+	la1, lo1, la2, lo2 := toRadians(lat1), toRadians(lon1), toRadians(lat2), toRadians(lon2)
 
 	h := hsin(la2-la1) + math.Cos(la1)*math.Cos(la2)*hsin(lo2-lo1)
 
-	return 2 * earthRadius * math.Asin(math.Sqrt(h))
+	return 2 * MeanEarthRadius * math.Asin(math.Sqrt(h))
+}
+
+// Equirectangular projects the points onto a plane, it's the fastest and is accurate for the short distances
+// between consecutive driver locations, but degrades over long distances and near the poles.
+type Equirectangular struct{}
+
+func (Equirectangular) Calculate(lat1, lon1, lat2, lon2 float64) float64 {
+	la1, lo1, la2, lo2 := toRadians(lat1), toRadians(lon1), toRadians(lat2), toRadians(lon2)
+	dLon := lo2 - lo1
+	// Take the shorter way around the antimeridian.
+	if dLon > math.Pi {
+		dLon -= 2 * math.Pi
+	} else if dLon < -math.Pi {
+		dLon += 2 * math.Pi
+	}
+
+	x := dLon * math.Cos((la1+la2)/2)
+	y := la2 - la1
+	return MeanEarthRadius * math.Sqrt(x*x+y*y)
+}
+
+// Vincenty is the distance on the WGS-84 ellipsoid computed with the Vincenty inverse formula,
+// it's accurate to within a millimeter. For nearly antipodal points, where the formula doesn't converge,
+// it falls back to haversine.
+type Vincenty struct{}
+
+const (
+	vincentyMaxIterations = 200
+	vincentyPrecision     = 1e-12
+)
+
+func (Vincenty) Calculate(lat1, lon1, lat2, lon2 float64) float64 {
+	const a, b, f = wgs84SemiMajorAxis, wgs84SemiMinorAxis, wgs84Flattening
+	L := toRadians(lon2 - lon1)
+	U1 := math.Atan((1 - f) * math.Tan(toRadians(lat1)))
+	U2 := math.Atan((1 - f) * math.Tan(toRadians(lat2)))
+	sinU1, cosU1 := math.Sincos(U1)
+	sinU2, cosU2 := math.Sincos(U2)
+
+	lambda := L
+	var sinSigma, cosSigma, sigma, cosSqAlpha, cos2SigmaM float64
+	converged := false
+	for i := 0; i < vincentyMaxIterations; i++ {
+		sinLambda, cosLambda := math.Sincos(lambda)
+		sinSigma = math.Sqrt(math.Pow(cosU2*sinLambda, 2) + math.Pow(cosU1*sinU2-sinU1*cosU2*cosLambda, 2))
+		if sinSigma == 0 {
+			return 0 // Coincident points.
+		}
+		cosSigma = sinU1*sinU2 + cosU1*cosU2*cosLambda
+		sigma = math.Atan2(sinSigma, cosSigma)
+		sinAlpha := cosU1 * cosU2 * sinLambda / sinSigma
+		cosSqAlpha = 1 - sinAlpha*sinAlpha
+		cos2SigmaM = 0
+		if cosSqAlpha != 0 { // Both points are on the equator otherwise.
+			cos2SigmaM = cosSigma - 2*sinU1*sinU2/cosSqAlpha
+		}
+		C := f / 16 * cosSqAlpha * (4 + f*(4-3*cosSqAlpha))
+		prevLambda := lambda
+		lambda = L + (1-C)*f*sinAlpha*(sigma+C*sinSigma*(cos2SigmaM+C*cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)))
+		if math.Abs(lambda-prevLambda) < vincentyPrecision {
+			converged = true
+			break
+		}
+	}
+	if !converged {
+		return Haversine{}.Calculate(lat1, lon1, lat2, lon2)
+	}
+
+	uSq := cosSqAlpha * (a*a - b*b) / (b * b)
+	A := 1 + uSq/16384*(4096+uSq*(-768+uSq*(320-175*uSq)))
+	B := uSq / 1024 * (256 + uSq*(-128+uSq*(74-47*uSq)))
+	deltaSigma := B * sinSigma * (cos2SigmaM + B/4*(cosSigma*(-1+2*cos2SigmaM*cos2SigmaM)-
+		B/6*cos2SigmaM*(-3+4*sinSigma*sinSigma)*(-3+4*cos2SigmaM*cos2SigmaM)))
+	return b * A * (sigma - deltaSigma)
+}
+
+func toRadians(degrees float64) float64 {
+	return degrees * math.Pi / 180
 }
@@ -1,9 +1,11 @@
 package distance_test
 
 import (
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"github.com/georgysavva/driver-app/zombie-driver/pkg/distance"
 )
@@ -11,5 +13,102 @@ import (
 func TestCalculate(t *testing.T) {
 	t.Parallel()
 	actual := distance.Calculate(48.864193, 2.350498, 48.863193, 2.351498)
-	assert.Equal(t, 133.24684781984962, actual)
+	assert.Equal(t, 133.09870338071255, actual)
+}
+
+func degrees(d, m, s float64) float64 {
+	if d < 0 {
+		return d - m/60 - s/3600
+	}
+	return d + m/60 + s/3600
+}
+
+// Reference distances on the WGS-84 ellipsoid.
+var referenceLines = []struct {
+	name                   string
+	lat1, lon1, lat2, lon2 float64
+	expected               float64 // In meters.
+}{
+	{
+		// The Geoscience Australia example of the Vincenty inverse formula.
+		name: "Flinders Peak to Buninyong",
+		lat1: degrees(-37, 57, 3.72030), lon1: degrees(144, 25, 29.52440),
+		lat2: degrees(-37, 39, 10.15610), lon2: degrees(143, 55, 35.38390),
+		expected: 54972.271,
+	},
+	{
+		name: "Quarter meridian",
+		lat1: 0, lon1: 0,
+		lat2: 90, lon2: 0,
+		expected: 10001965.729,
+	},
+	{
+		name: "Equator degree",
+		lat1: 0, lon1: 0,
+		lat2: 0, lon2: 1,
+		expected: 111319.491,
+	},
+}
+
+func TestCalculator_Accuracy(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		algorithm string
+		tolerance float64 // Relative error.
+	}{
+		{algorithm: distance.AlgorithmVincenty, tolerance: 1e-8},
+		{algorithm: distance.AlgorithmHaversine, tolerance: 5e-3},
+		{algorithm: distance.AlgorithmEquirectangular, tolerance: 5e-3},
+	}
+	for _, tc := range cases {
+		tc := tc
+		calculator, err := distance.ByName(tc.algorithm)
+		require.NoError(t, err)
+		for _, line := range referenceLines {
+			line := line
+			// Equirectangular is only meant for short distances.
+			if tc.algorithm == distance.AlgorithmEquirectangular && line.expected > 100000 {
+				continue
+			}
+			t.Run(fmt.Sprintf("%s/%s", tc.algorithm, line.name), func(t *testing.T) {
+				t.Parallel()
+				actual := calculator.Calculate(line.lat1, line.lon1, line.lat2, line.lon2)
+				assert.InEpsilon(t, line.expected, actual, tc.tolerance)
+			})
+		}
+	}
+}
+
+func TestVincenty_CoincidentAndAntipodalPoints(t *testing.T) {
+	t.Parallel()
+	calculator := distance.Vincenty{}
+
+	assert.Equal(t, 0.0, calculator.Calculate(48.864193, 2.350498, 48.864193, 2.350498))
+	// The formula doesn't converge for antipodal points, so the haversine distance is returned.
+	assert.Equal(t, distance.Haversine{}.Calculate(0, 0, 0.5, 179.7), calculator.Calculate(0, 0, 0.5, 179.7))
+}
+
+func TestByName(t *testing.T) {
+	t.Parallel()
+	calculator, err := distance.ByName("")
+	require.NoError(t, err)
+	assert.Equal(t, distance.Haversine{}, calculator)
+
+	_, err = distance.ByName("manhattan")
+	assert.EqualError(t, err,
+		`unknown distance algorithm "manhattan", must be "haversine", "vincenty" or "equirectangular"`)
+}
+
+func BenchmarkCalculator(b *testing.B) {
+	for _, algorithm := range []string{
+		distance.AlgorithmHaversine, distance.AlgorithmVincenty, distance.AlgorithmEquirectangular,
+	} {
+		calculator, err := distance.ByName(algorithm)
+		require.NoError(b, err)
+		b.Run(algorithm, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				calculator.Calculate(48.864193, 2.350498, 48.863193, 2.351498)
+			}
+		})
+	}
 }
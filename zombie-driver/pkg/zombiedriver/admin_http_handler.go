@@ -0,0 +1,175 @@
+package zombiedriver
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/georgysavva/driver-app/platform/httpapi"
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/gorilla/mux"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// MakeAdminHTTPHandler returns the admin HTTP API for support to manage driver overrides.
+// It has no authentication of its own, the caller must protect it.
+func MakeAdminHTTPHandler(service OverrideService, logger log.FieldLogger) http.Handler {
+	router := mux.NewRouter()
+	aa := &adminHTTPAPI{service: service, logger: logger}
+	router.HandleFunc("/admin/drivers/{id}/override", aa.getOverride).Methods("GET")
+	router.HandleFunc("/admin/drivers/{id}/override", aa.setOverride).Methods("PUT")
+	router.HandleFunc("/admin/drivers/{id}/override", aa.clearOverride).Methods("DELETE")
+	router.HandleFunc("/admin/overrides/audit", aa.getOverrideAudit).Methods("GET")
+	return router
+}
+
+type adminHTTPAPI struct {
+	service OverrideService
+	logger  log.FieldLogger
+}
+
+// setOverrideRequest sets the expiry either as a time or as a duration from now, both are optional.
+// The author is taken as is, the admin token is shared, so it can't tell who the client is.
+type setOverrideRequest struct {
+	State     string     `json:"state"`
+	Reason    string     `json:"reason"`
+	Author    string     `json:"author"`
+	ExpiresAt *time.Time `json:"expires_at"`
+	ExpiresIn string     `json:"expires_in"`
+}
+
+func (aa *adminHTTPAPI) getOverride(w http.ResponseWriter, r *http.Request) {
+	driverID := mux.Vars(r)["id"]
+	ctxLogger := aa.logger.WithField("driver_id", driverID)
+
+	override, err := aa.service.GetOverride(r.Context(), driverID)
+	if err != nil {
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to request override from the service"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	if override == nil {
+		http.Error(w, "No override", http.StatusNotFound)
+		return
+	}
+	if err := httpapi.ReturnJSONData(w, override); err != nil {
+		logging.LogUnhandledError(ctxLogger, errors.WithStack(err))
+		return
+	}
+}
+
+func (aa *adminHTTPAPI) setOverride(w http.ResponseWriter, r *http.Request) {
+	driverID := mux.Vars(r)["id"]
+	ctxLogger := aa.logger.WithField("driver_id", driverID)
+
+	request := &setOverrideRequest{}
+	if err := json.NewDecoder(r.Body).Decode(request); err != nil {
+		http.Error(w, "Invalid json body: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	override := &Override{
+		DriverID:  driverID,
+		State:     request.State,
+		Reason:    request.Reason,
+		Author:    request.Author,
+		ExpiresAt: request.ExpiresAt,
+	}
+	if request.ExpiresIn != "" {
+		if request.ExpiresAt != nil {
+			http.Error(w, "Only one of expires_at and expires_in can be set", http.StatusBadRequest)
+			return
+		}
+		expiresIn, err := time.ParseDuration(request.ExpiresIn)
+		if err != nil {
+			http.Error(w, "Invalid expires_in: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		expiresAt := time.Now().Add(expiresIn).UTC()
+		override.ExpiresAt = &expiresAt
+	}
+
+	ctxLogger.WithFields(log.Fields{"author": override.Author, "state": override.State}).Info("Set driver override")
+	if err := aa.service.SetOverride(r.Context(), override); err != nil {
+		if errors.Is(err, ErrInvalidOverride) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to set override via the service"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	if err := httpapi.ReturnJSONData(w, override); err != nil {
+		logging.LogUnhandledError(ctxLogger, errors.WithStack(err))
+		return
+	}
+}
+
+// clearOverride takes the author and the reason from the query, DELETE requests shouldn't have a body.
+func (aa *adminHTTPAPI) clearOverride(w http.ResponseWriter, r *http.Request) {
+	driverID := mux.Vars(r)["id"]
+	author, reason := r.URL.Query().Get("author"), r.URL.Query().Get("reason")
+	ctxLogger := aa.logger.WithFields(log.Fields{"driver_id": driverID, "author": author})
+
+	ctxLogger.Info("Clear driver override")
+	cleared, err := aa.service.ClearOverride(r.Context(), driverID, author, reason)
+	if err != nil {
+		if errors.Is(err, ErrInvalidOverride) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to clear override via the service"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	if !cleared {
+		http.Error(w, "No override", http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Audit page sizes.
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// getOverrideAudit returns a page of the changes of the driver overrides, optionally filtered by the driver_id
+// query parameter. The page is selected by the offset and limit query parameters.
+func (aa *adminHTTPAPI) getOverrideAudit(w http.ResponseWriter, r *http.Request) {
+	query := &OverrideAuditQuery{DriverID: r.URL.Query().Get("driver_id")}
+	var err error
+	if query.Offset, err = parseIntParam(r, "offset", 0 /* defaultValue */); err != nil || query.Offset < 0 {
+		http.Error(w, "offset must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	query.Limit, err = parseIntParam(r, "limit", defaultAuditLimit)
+	if err != nil || query.Limit < 1 || query.Limit > maxAuditLimit {
+		http.Error(w, "limit must be an integer between 1 and "+strconv.Itoa(maxAuditLimit), http.StatusBadRequest)
+		return
+	}
+	entries, err := aa.service.GetOverrideAudit(r.Context(), query)
+	if err != nil {
+		logging.LogUnhandledError(aa.logger, errors.Wrap(err, "failed to request override audit from the service"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	if entries == nil {
+		entries = []*OverrideAuditEntry{}
+	}
+	if err := httpapi.ReturnJSONData(w, entries); err != nil {
+		logging.LogUnhandledError(aa.logger, errors.WithStack(err))
+		return
+	}
+}
+
+func parseIntParam(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	n, err := strconv.Atoi(value)
+	return n, errors.Wrapf(err, "invalid %s parameter", name)
+}
@@ -0,0 +1,169 @@
+package zombiedriver_test
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver/mocks"
+)
+
+func TestAdminHTTP_SetOverride(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupAdminHTTPServer()
+	defer ts.Close()
+	expiresAt := time.Date(2020, 11, 2, 12, 0, 0, 0, time.UTC)
+	expected := &zombiedriver.Override{
+		DriverID: defaultDriverID, State: zombiedriver.OverrideHuman, Reason: "driver complaint", Author: "alice",
+		ExpiresAt: &expiresAt,
+	}
+	serviceMock.On(
+		"SetOverride",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+		expected,
+	).Return(nil)
+
+	body := `{"state": "human", "reason": "driver complaint", "author": "alice", "expires_at": "2020-11-02T12:00:00Z"}`
+	response := doAdminRequest(t, http.MethodPut, ts.URL+"/admin/drivers/foo/override", body)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	serviceMock.AssertExpectations(t)
+}
+
+func TestAdminHTTP_SetOverride_ExpiresIn(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupAdminHTTPServer()
+	defer ts.Close()
+	serviceMock.On(
+		"SetOverride",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+		mock.MatchedBy(func(override *zombiedriver.Override) bool {
+			return override.ExpiresAt != nil && time.Until(*override.ExpiresAt).Round(time.Minute) == 2*time.Hour
+		}),
+	).Return(nil)
+
+	body := `{"state": "zombie", "reason": "promo partner", "author": "alice", "expires_in": "2h"}`
+	response := doAdminRequest(t, http.MethodPut, ts.URL+"/admin/drivers/foo/override", body)
+
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	serviceMock.AssertExpectations(t)
+}
+
+func TestAdminHTTP_SetOverride_Invalid(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupAdminHTTPServer()
+	defer ts.Close()
+	serviceMock.On(
+		"SetOverride",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+		mock.Anything,
+	).Return(errors.Wrap(zombiedriver.ErrInvalidOverride, "reason must be set"))
+
+	response := doAdminRequest(t, http.MethodPut, ts.URL+"/admin/drivers/foo/override", `{"state": "zombie"}`)
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+	assert.Equal(t, "reason must be set: invalid override\n", string(responseBytes))
+}
+
+func TestAdminHTTP_ClearOverride(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name           string
+		cleared        bool
+		expectedStatus int
+	}{
+		{name: "cleared", cleared: true, expectedStatus: http.StatusNoContent},
+		{name: "no override", cleared: false, expectedStatus: http.StatusNotFound},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ts, serviceMock := setupAdminHTTPServer()
+			defer ts.Close()
+			serviceMock.On(
+				"ClearOverride",
+				mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+				defaultDriverID, "bob", "resolved",
+			).Return(tc.cleared, nil)
+
+			response := doAdminRequest(
+				t, http.MethodDelete, ts.URL+"/admin/drivers/foo/override?author=bob&reason=resolved", "",
+			)
+
+			assert.Equal(t, tc.expectedStatus, response.StatusCode)
+			serviceMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestAdminHTTP_GetOverrideAudit(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupAdminHTTPServer()
+	defer ts.Close()
+	serviceMock.On(
+		"GetOverrideAudit",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+		&zombiedriver.OverrideAuditQuery{DriverID: defaultDriverID, Offset: 10, Limit: 100},
+	).Return([]*zombiedriver.OverrideAuditEntry{{
+		Action: zombiedriver.OverrideActionClear, DriverID: defaultDriverID, Author: "bob", Reason: "resolved",
+		OccurredAt: time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC),
+	}}, nil)
+
+	response := doAdminRequest(t, http.MethodGet, ts.URL+"/admin/overrides/audit?driver_id=foo&offset=10", "")
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	expectedResponseData := `
+	[{
+		"action": "clear",
+		"driver_id": "foo",
+		"author": "bob",
+		"reason": "resolved",
+		"occurred_at": "2020-11-01T12:00:00Z"
+	}]`
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, expectedResponseData, string(responseBytes))
+}
+
+func TestAdminHTTP_GetOverrideAudit_InvalidPage(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupAdminHTTPServer()
+	defer ts.Close()
+
+	for _, query := range []string{"offset=-1", "offset=foo", "limit=0", "limit=1001", "limit=foo"} {
+		response := doAdminRequest(t, http.MethodGet, ts.URL+"/admin/overrides/audit?"+query, "")
+		assert.Equal(t, http.StatusBadRequest, response.StatusCode, query)
+	}
+	serviceMock.AssertNumberOfCalls(t, "GetOverrideAudit", 0)
+}
+
+func doAdminRequest(t *testing.T, method, url, body string) *http.Response {
+	request, err := http.NewRequest(method, url, strings.NewReader(body))
+	require.NoError(t, err)
+	response, err := http.DefaultClient.Do(request)
+	require.NoError(t, err)
+	t.Cleanup(func() { response.Body.Close() })
+	return response
+}
+
+func setupAdminHTTPServer() (*httptest.Server, *mocks.OverrideService) {
+	logger := log.New()
+	logger.Level = log.ErrorLevel
+	serviceMock := &mocks.OverrideService{}
+	ts := httptest.NewServer(zombiedriver.MakeAdminHTTPHandler(serviceMock, logger))
+	return ts, serviceMock
+}
@@ -0,0 +1,126 @@
+package zombiedriver
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const redisCacheKeyPrefix = "zombie-driver:driver:"
+
+// DriverCache stores computed drivers for a limited time.
+type DriverCache interface {
+	// Get returns nil without an error if the driver isn't cached or its entry has expired.
+	Get(ctx context.Context, driverID string) (*Driver, error)
+	Set(ctx context.Context, driver *Driver) error
+}
+
+//go:generate mockery --name DriverCache
+
+// NopCache is used when caching is disabled, it never stores anything.
+type NopCache struct{}
+
+func (NopCache) Get(_ context.Context, _ string) (*Driver, error) { return nil, nil }
+
+func (NopCache) Set(_ context.Context, _ *Driver) error { return nil }
+
+// LRUCache is an in-process cache that keeps up to size drivers, each one for ttl,
+// evicting the least recently used driver when it's full.
+type LRUCache struct {
+	size      int
+	ttl       time.Duration
+	timeNowFn func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Front is the most recently used.
+}
+
+type lruEntry struct {
+	driver    *Driver
+	expiresAt time.Time
+}
+
+func NewLRUCache(size int, ttl time.Duration) *LRUCache {
+	return &LRUCache{
+		size:      size,
+		ttl:       ttl,
+		timeNowFn: time.Now,
+		entries:   make(map[string]*list.Element, size),
+		order:     list.New(),
+	}
+}
+
+func (c *LRUCache) Get(_ context.Context, driverID string) (*Driver, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.entries[driverID]
+	if !ok {
+		return nil, nil
+	}
+	entry := elem.Value.(*lruEntry)
+	if !c.timeNowFn().Before(entry.expiresAt) {
+		c.order.Remove(elem)
+		delete(c.entries, driverID)
+		return nil, nil
+	}
+	c.order.MoveToFront(elem)
+	return entry.driver, nil
+}
+
+func (c *LRUCache) Set(_ context.Context, driver *Driver) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry := &lruEntry{driver: driver, expiresAt: c.timeNowFn().Add(c.ttl)}
+	if elem, ok := c.entries[driver.ID]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return nil
+	}
+	c.entries[driver.ID] = c.order.PushFront(entry)
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry).driver.ID)
+	}
+	return nil
+}
+
+// RedisCache shares computed drivers between all service replicas, Redis expires them after ttl.
+type RedisCache struct {
+	redis *redis.Client
+	ttl   time.Duration
+}
+
+func NewRedisCache(r *redis.Client, ttl time.Duration) *RedisCache {
+	return &RedisCache{redis: r, ttl: ttl}
+}
+
+func (c *RedisCache) Get(ctx context.Context, driverID string) (*Driver, error) {
+	data, err := c.redis.Get(ctx, redisCacheKeyPrefix+driverID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cached driver from Redis")
+	}
+	driver := &Driver{}
+	if err := json.Unmarshal(data, driver); err != nil {
+		return nil, errors.Wrapf(err, "can't decode cached driver data %s", data)
+	}
+	return driver, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, driver *Driver) error {
+	data, err := json.Marshal(driver)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode driver into json")
+	}
+	err = c.redis.Set(ctx, redisCacheKeyPrefix+driver.ID, data, c.ttl).Err()
+	return errors.Wrap(err, "failed to cache driver in Redis")
+}
@@ -0,0 +1,76 @@
+package zombiedriver_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+)
+
+func TestLRUCache_Expiration(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	baseTime := time.Now()
+	cache := zombiedriver.NewLRUCache(10 /* size */, 5*time.Second /* ttl */)
+	cache.SetTimeNowFn(func() time.Time { return baseTime })
+	driver := &zombiedriver.Driver{ID: defaultDriverID, IsZombie: true}
+	require.NoError(t, cache.Set(ctx, driver))
+
+	cache.SetTimeNowFn(func() time.Time { return baseTime.Add(4 * time.Second) })
+	actual, err := cache.Get(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Equal(t, driver, actual)
+
+	cache.SetTimeNowFn(func() time.Time { return baseTime.Add(5 * time.Second) })
+	actual, err = cache.Get(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Nil(t, actual)
+}
+
+func TestLRUCache_LeastRecentlyUsedIsEvicted(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	cache := zombiedriver.NewLRUCache(2 /* size */, time.Minute /* ttl */)
+	require.NoError(t, cache.Set(ctx, &zombiedriver.Driver{ID: "first"}))
+	require.NoError(t, cache.Set(ctx, &zombiedriver.Driver{ID: "second"}))
+	_, err := cache.Get(ctx, "first")
+	require.NoError(t, err)
+
+	require.NoError(t, cache.Set(ctx, &zombiedriver.Driver{ID: "third"}))
+
+	for driverID, expectedCached := range map[string]bool{"first": true, "second": false, "third": true} {
+		actual, err := cache.Get(ctx, driverID)
+		require.NoError(t, err)
+		assert.Equal(t, expectedCached, actual != nil, driverID)
+	}
+}
+
+func TestRedisCache(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fakeRedis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer fakeRedis.Close()
+	cache := zombiedriver.NewRedisCache(redis.NewClient(&redis.Options{Addr: fakeRedis.Addr()}), 5*time.Second)
+
+	actual, err := cache.Get(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Nil(t, actual)
+
+	driver := &zombiedriver.Driver{ID: defaultDriverID, IsZombie: true}
+	require.NoError(t, cache.Set(ctx, driver))
+	actual, err = cache.Get(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Equal(t, driver, actual)
+
+	fakeRedis.FastForward(5 * time.Second)
+	actual, err = cache.Get(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Nil(t, actual)
+}
@@ -0,0 +1,208 @@
+package zombiedriver
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	campaignDateLayout = "2006-01-02"
+	campaignTimeLayout = "15:04"
+)
+
+// Campaign is a period when zombies are shown, e.g. the nights of the show episodes.
+type Campaign struct {
+	Name string `yaml:"name"`
+	// StartDate and EndDate are inclusive "2006-01-02" dates in the campaign time zone, EndDate is optional.
+	StartDate string `yaml:"start_date"`
+	EndDate   string `yaml:"end_date"`
+	// TimeZone is an IANA time zone name, e.g. "Europe/Paris", UTC if empty.
+	TimeZone string `yaml:"time_zone"`
+	// DailyWindows restrict the campaign to a part of each day, the whole day is used if they are empty.
+	DailyWindows []*DailyWindow `yaml:"daily_windows"`
+	// Predicate overrides fields of the default predicate during the campaign.
+	Predicate map[string]interface{} `yaml:"predicate"`
+}
+
+// DailyWindow is a "15:04" local time range, it crosses midnight if End isn't after Start,
+// e.g. 20:00-02:00 is the night starting on each campaign date.
+type DailyWindow struct {
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// ActiveCampaign is the campaign running at the moment.
+type ActiveCampaign struct {
+	Name string `json:"name,omitempty"` // Empty if there is no campaign schedule.
+	// EndsAt is the end of the current daily window or the campaign, nil if the campaign never ends.
+	EndsAt    *time.Time       `json:"ends_at"`
+	Predicate *ZombiePredicate `json:"-"`
+}
+
+// CampaignSchedule tells which campaign is active at a given time.
+// A nil CampaignSchedule means there is no schedule, so the default predicate always applies.
+type CampaignSchedule struct {
+	campaigns []*scheduledCampaign // The first active campaign wins.
+}
+
+type scheduledCampaign struct {
+	name      string
+	location  *time.Location
+	startDate time.Time // Dates are midnights in UTC, so they are compared regardless of the time zone.
+	endDate   time.Time // Zero if the campaign never ends.
+	windows   []dailyWindow
+	predicate *ZombiePredicate
+}
+
+type dailyWindow struct {
+	start, end time.Duration // Since midnight.
+}
+
+// NewCampaignSchedule parses the campaigns, their predicates override fields of the default predicate.
+func NewCampaignSchedule(campaigns []*Campaign, defaultPredicate *ZombiePredicate) (*CampaignSchedule, error) {
+	schedule := &CampaignSchedule{campaigns: make([]*scheduledCampaign, len(campaigns))}
+	for i, campaign := range campaigns {
+		if campaign.Name == "" {
+			return nil, errors.Errorf("campaign %d must have a name", i)
+		}
+		scheduled, err := parseCampaign(campaign, defaultPredicate)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid campaign %q", campaign.Name)
+		}
+		schedule.campaigns[i] = scheduled
+	}
+	return schedule, nil
+}
+
+func parseCampaign(campaign *Campaign, defaultPredicate *ZombiePredicate) (*scheduledCampaign, error) {
+	scheduled := &scheduledCampaign{name: campaign.Name}
+	var err error
+	if scheduled.location, err = time.LoadLocation(campaign.TimeZone); err != nil {
+		return nil, errors.Wrap(err, "invalid time zone")
+	}
+	if scheduled.startDate, err = time.Parse(campaignDateLayout, campaign.StartDate); err != nil {
+		return nil, errors.Wrap(err, "invalid start date")
+	}
+	if campaign.EndDate != "" {
+		if scheduled.endDate, err = time.Parse(campaignDateLayout, campaign.EndDate); err != nil {
+			return nil, errors.Wrap(err, "invalid end date")
+		}
+		if scheduled.endDate.Before(scheduled.startDate) {
+			return nil, errors.New("end date must not be before start date")
+		}
+	}
+	for _, window := range campaign.DailyWindows {
+		start, err := parseTimeOfDay(window.Start)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid daily window start")
+		}
+		end, err := parseTimeOfDay(window.End)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid daily window end")
+		}
+		scheduled.windows = append(scheduled.windows, dailyWindow{start: start, end: end})
+	}
+
+	scheduled.predicate = defaultPredicate
+	if len(campaign.Predicate) > 0 {
+		overrides, err := yaml.Marshal(campaign.Predicate)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to encode predicate overrides")
+		}
+		if scheduled.predicate, err = overridePredicate(defaultPredicate, overrides); err != nil {
+			return nil, errors.Wrap(err, "invalid predicate")
+		}
+	}
+	return scheduled, nil
+}
+
+func parseTimeOfDay(s string) (time.Duration, error) {
+	t, err := time.Parse(campaignTimeLayout, s)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Active returns the first campaign active at the time or nil.
+// A nil schedule returns an unnamed campaign with the default predicate that never ends.
+func (cs *CampaignSchedule) Active(now time.Time, defaultPredicate *ZombiePredicate) *ActiveCampaign {
+	if cs == nil {
+		return &ActiveCampaign{Predicate: defaultPredicate}
+	}
+	for _, campaign := range cs.campaigns {
+		if endsAt, ok := campaign.activeAt(now); ok {
+			return &ActiveCampaign{Name: campaign.name, EndsAt: endsAt, Predicate: campaign.predicate}
+		}
+	}
+	return nil
+}
+
+// MaxTimeInterval returns the longest time interval of the default and campaign predicates in any zone.
+func (cs *CampaignSchedule) MaxTimeInterval(defaultPredicate *ZombiePredicate, zones *ZoneSet) time.Duration {
+	maxInterval := zones.MaxTimeInterval(defaultPredicate, nil /* overrides */)
+	if cs == nil {
+		return maxInterval
+	}
+	for _, campaign := range cs.campaigns {
+		if interval := zones.MaxTimeInterval(campaign.predicate, nil /* overrides */); interval > maxInterval {
+			maxInterval = interval
+		}
+	}
+	return maxInterval
+}
+
+// activeAt reports whether the campaign is active at the time and returns when the activity ends.
+func (sc *scheduledCampaign) activeAt(now time.Time) (*time.Time, bool) {
+	local := now.In(sc.location)
+	year, month, day := local.Date()
+	// The wall clock time, so daily windows follow daylight saving time changes.
+	timeOfDay := time.Duration(local.Hour())*time.Hour + time.Duration(local.Minute())*time.Minute +
+		time.Duration(local.Second())*time.Second + time.Duration(local.Nanosecond())
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+
+	if len(sc.windows) == 0 {
+		if !sc.includesDate(date) {
+			return nil, false
+		}
+		if sc.endDate.IsZero() {
+			return nil, true
+		}
+		endsAt := sc.localTime(sc.endDate.AddDate(0, 0, 1), 0)
+		return &endsAt, true
+	}
+	for _, window := range sc.windows {
+		switch {
+		case window.start < window.end:
+			if timeOfDay >= window.start && timeOfDay < window.end && sc.includesDate(date) {
+				endsAt := sc.localTime(date, window.end)
+				return &endsAt, true
+			}
+		case timeOfDay >= window.start:
+			// The window crosses midnight and started today.
+			if sc.includesDate(date) {
+				endsAt := sc.localTime(date.AddDate(0, 0, 1), window.end)
+				return &endsAt, true
+			}
+		case timeOfDay < window.end:
+			// The window crosses midnight and started yesterday.
+			if sc.includesDate(date.AddDate(0, 0, -1)) {
+				endsAt := sc.localTime(date, window.end)
+				return &endsAt, true
+			}
+		}
+	}
+	return nil, false
+}
+
+func (sc *scheduledCampaign) includesDate(date time.Time) bool {
+	return !date.Before(sc.startDate) && (sc.endDate.IsZero() || !date.After(sc.endDate))
+}
+
+// localTime returns the time of the day at the date in the campaign time zone.
+func (sc *scheduledCampaign) localTime(date time.Time, timeOfDay time.Duration) time.Time {
+	year, month, day := date.Date()
+	return time.Date(year, month, day, 0, int(timeOfDay/time.Minute), 0, 0, sc.location)
+}
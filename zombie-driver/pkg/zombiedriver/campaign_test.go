@@ -0,0 +1,173 @@
+package zombiedriver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+)
+
+func TestCampaignSchedule_Active(t *testing.T) {
+	t.Parallel()
+	paris, err := time.LoadLocation("Europe/Paris")
+	require.NoError(t, err)
+	schedule, err := zombiedriver.NewCampaignSchedule([]*zombiedriver.Campaign{
+		{
+			Name:         "episode-nights",
+			StartDate:    "2020-11-01",
+			EndDate:      "2020-11-30",
+			TimeZone:     "Europe/Paris",
+			DailyWindows: []*zombiedriver.DailyWindow{{Start: "20:00", End: "02:00"}},
+			Predicate:    map[string]interface{}{"distance_threshold": 1000},
+		},
+		{
+			Name:      "finale",
+			StartDate: "2020-12-24",
+			EndDate:   "2020-12-24",
+		},
+	}, defaultPredicate)
+	require.NoError(t, err)
+
+	cases := []struct {
+		name           string
+		now            time.Time
+		expectedName   string
+		expectedEndsAt time.Time
+	}{
+		{
+			name:           "evening of the first date",
+			now:            time.Date(2020, 11, 1, 21, 0, 0, 0, paris),
+			expectedName:   "episode-nights",
+			expectedEndsAt: time.Date(2020, 11, 2, 2, 0, 0, 0, paris),
+		},
+		{
+			name:           "night after the last date",
+			now:            time.Date(2020, 12, 1, 1, 59, 0, 0, paris),
+			expectedName:   "episode-nights",
+			expectedEndsAt: time.Date(2020, 12, 1, 2, 0, 0, 0, paris),
+		},
+		{
+			name: "night before the first date",
+			now:  time.Date(2020, 11, 1, 1, 0, 0, 0, paris),
+		},
+		{
+			name: "daytime",
+			now:  time.Date(2020, 11, 10, 12, 0, 0, 0, paris),
+		},
+		{
+			name: "window end is exclusive",
+			now:  time.Date(2020, 11, 10, 2, 0, 0, 0, paris),
+		},
+		{
+			name:           "whole day in UTC",
+			now:            time.Date(2020, 12, 24, 23, 59, 0, 0, time.UTC),
+			expectedName:   "finale",
+			expectedEndsAt: time.Date(2020, 12, 25, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "after all campaigns",
+			now:  time.Date(2020, 12, 25, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			campaign := schedule.Active(tc.now, defaultPredicate)
+			if tc.expectedName == "" {
+				assert.Nil(t, campaign)
+				return
+			}
+			require.NotNil(t, campaign)
+			assert.Equal(t, tc.expectedName, campaign.Name)
+			require.NotNil(t, campaign.EndsAt)
+			assert.True(t, tc.expectedEndsAt.Equal(*campaign.EndsAt), "ends at %v", campaign.EndsAt)
+		})
+	}
+}
+
+func TestCampaignSchedule_Active_Predicate(t *testing.T) {
+	t.Parallel()
+	schedule, err := zombiedriver.NewCampaignSchedule([]*zombiedriver.Campaign{{
+		Name:      "launch",
+		StartDate: "2020-11-01",
+		Predicate: map[string]interface{}{"distance_threshold": 1000, "time_interval": "10m"},
+	}}, defaultPredicate)
+	require.NoError(t, err)
+
+	campaign := schedule.Active(time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), defaultPredicate)
+
+	require.NotNil(t, campaign)
+	assert.Nil(t, campaign.EndsAt)
+	assert.Equal(t, 1000, campaign.Predicate.DistanceThreshold)
+	assert.Equal(t, 10*time.Minute, campaign.Predicate.TimeInterval)
+	assert.Equal(t, 500, defaultPredicate.DistanceThreshold)
+	assert.Equal(t, 10*time.Minute, schedule.MaxTimeInterval(defaultPredicate, nil /* zones */))
+}
+
+func TestCampaignSchedule_Active_NoSchedule(t *testing.T) {
+	t.Parallel()
+	var schedule *zombiedriver.CampaignSchedule
+
+	campaign := schedule.Active(time.Now(), defaultPredicate)
+
+	assert.Equal(t, &zombiedriver.ActiveCampaign{Predicate: defaultPredicate}, campaign)
+}
+
+func TestNewCampaignSchedule_Invalid(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		campaign *zombiedriver.Campaign
+		expected string
+	}{
+		{
+			name:     "unknown time zone",
+			campaign: &zombiedriver.Campaign{Name: "launch", StartDate: "2020-11-01", TimeZone: "Mars/Olympus"},
+			expected: `invalid campaign "launch": invalid time zone: unknown time zone Mars/Olympus`,
+		},
+		{
+			name:     "end before start",
+			campaign: &zombiedriver.Campaign{Name: "launch", StartDate: "2020-11-01", EndDate: "2020-10-01"},
+			expected: `invalid campaign "launch": end date must not be before start date`,
+		},
+		{
+			name: "invalid window",
+			campaign: &zombiedriver.Campaign{
+				Name: "launch", StartDate: "2020-11-01",
+				DailyWindows: []*zombiedriver.DailyWindow{{Start: "8pm", End: "02:00"}},
+			},
+			expected: `invalid campaign "launch": invalid daily window start: ` +
+				`parsing time "8pm" as "15:04": cannot parse "pm" as ":"`,
+		},
+		{
+			name: "invalid predicate",
+			campaign: &zombiedriver.Campaign{
+				Name: "launch", StartDate: "2020-11-01",
+				Predicate: map[string]interface{}{"distance_threshold": 0},
+			},
+			expected: "invalid campaign \"launch\": invalid predicate: invalid config:\n" +
+				"  - distance_threshold: must be at least 1",
+		},
+		{
+			name: "too long predicate time interval",
+			campaign: &zombiedriver.Campaign{
+				Name: "launch", StartDate: "2020-11-01",
+				Predicate: map[string]interface{}{"time_interval": "48h"},
+			},
+			expected: "invalid campaign \"launch\": invalid predicate: invalid config:\n" +
+				"  - time_interval must not exceed 24h0m0s",
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := zombiedriver.NewCampaignSchedule([]*zombiedriver.Campaign{tc.campaign}, defaultPredicate)
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
@@ -1,6 +1,30 @@
 package zombiedriver
 
+// Driver states, only online drivers are judged by the zombie predicate.
+const (
+	DriverStateZombie = "zombie"
+	DriverStateAlive  = "alive"
+	// DriverStateOffline means the driver has stopped sending locations, so they aren't reported as a zombie.
+	DriverStateOffline = "offline"
+	// DriverStateUnknown means the driver-location service has never seen the driver.
+	DriverStateUnknown = "unknown"
+	// DriverStateInsufficientData means the driver is online, but has too few locations to be judged.
+	DriverStateInsufficientData = "insufficient_data"
+	// DriverStateOutsideCampaign means no zombie campaign is running, so nobody is a zombie.
+	DriverStateOutsideCampaign = "outside_campaign"
+	// DriverStateControl means the driver is in the control arm of the experiment, so they are never a zombie.
+	DriverStateControl = "control"
+)
+
 type Driver struct {
-	ID       string `json:"id"`
+	ID string `json:"id"`
+	// IsZombie is true only in the zombie state, it's kept for the clients that don't know the states.
 	IsZombie bool   `json:"zombie"`
+	State    string `json:"state"`
+	// Zone is the name of the zone whose predicate judged the driver, empty for the default predicate.
+	Zone string `json:"zone,omitempty"`
+	// Arm is the name of the experiment arm of the driver, empty if there is no experiment.
+	Arm string `json:"arm,omitempty"`
+	// Overridden is true if support has forced the driver state, so the predicate wasn't evaluated.
+	Overridden bool `json:"overridden,omitempty"`
 }
@@ -0,0 +1,143 @@
+package zombiedriver
+
+import (
+	"hash/fnv"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// experimentBucketsNum is the number of buckets driver ids are hashed into,
+// arms get ranges of buckets by their weights.
+const experimentBucketsNum = 10000
+
+// ExperimentArm is a group of drivers with its own predicate.
+type ExperimentArm struct {
+	Name string `yaml:"name"`
+	// Weight is the relative share of drivers in the arm.
+	Weight int `yaml:"weight"`
+	// Control arm drivers are never shown as zombies.
+	Control bool `yaml:"control"`
+	// Predicate overrides fields of the predicate for the arm drivers,
+	// they apply last, on top of the campaign and zone predicates.
+	Predicate map[string]interface{} `yaml:"predicate"`
+}
+
+// AssignedArm is the arm of a driver.
+type AssignedArm struct {
+	Name    string
+	Control bool
+	// Overrides apply after the zone ones, they are nil if the arm doesn't override the predicate.
+	Overrides *PredicateOverrides
+}
+
+// Experiment assigns drivers to arms by the hash of the experiment name and the driver id,
+// so the assignment is the same on all replicas and across restarts, and independent between experiments.
+// A nil Experiment assigns no arm.
+type Experiment struct {
+	name       string
+	arms       []*AssignedArm
+	bucketEnds []int // Exclusive end of the bucket range of each arm.
+}
+
+func NewExperiment(
+	name string, arms []*ExperimentArm, defaultPredicate *ZombiePredicate, logger log.FieldLogger,
+) (*Experiment, error) {
+	if name == "" {
+		return nil, errors.New("experiment must have a name")
+	}
+	if len(arms) == 0 {
+		return nil, errors.New("experiment must have arms")
+	}
+	totalWeight := 0
+	names := make(map[string]bool, len(arms))
+	for i, arm := range arms {
+		if arm.Name == "" {
+			return nil, errors.Errorf("experiment arm %d must have a name", i)
+		}
+		if names[arm.Name] {
+			return nil, errors.Errorf("experiment arm %q is defined more than once", arm.Name)
+		}
+		names[arm.Name] = true
+		if arm.Weight < 0 {
+			return nil, errors.Errorf("weight of experiment arm %q must not be negative", arm.Name)
+		}
+		totalWeight += arm.Weight
+	}
+	if totalWeight == 0 {
+		return nil, errors.New("experiment arms must have a positive total weight")
+	}
+
+	experiment := &Experiment{name: name}
+	cumulativeWeight := 0
+	for _, arm := range arms {
+		assigned := &AssignedArm{Name: arm.Name, Control: arm.Control}
+		if len(arm.Predicate) > 0 {
+			overrides, err := yaml.Marshal(arm.Predicate)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to encode predicate overrides of experiment arm %q", arm.Name)
+			}
+			assigned.Overrides, err = NewPredicateOverrides(overrides, defaultPredicate, logger.WithField("arm", arm.Name))
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid predicate of experiment arm %q", arm.Name)
+			}
+		}
+		cumulativeWeight += arm.Weight
+		experiment.arms = append(experiment.arms, assigned)
+		experiment.bucketEnds = append(experiment.bucketEnds, cumulativeWeight*experimentBucketsNum/totalWeight)
+	}
+	return experiment, nil
+}
+
+// Assign returns the arm of the driver or nil if there is no experiment.
+func (e *Experiment) Assign(driverID string) *AssignedArm {
+	if e == nil {
+		return nil
+	}
+	bucket := e.bucket(driverID)
+	for i, end := range e.bucketEnds {
+		if bucket < end {
+			return e.arms[i]
+		}
+	}
+	// Unreachable, the last arm ends at experimentBucketsNum.
+	return e.arms[len(e.arms)-1]
+}
+
+func (e *Experiment) bucket(driverID string) int {
+	h := fnv.New64a()
+	// Writing into a hash never fails.
+	_, _ = h.Write([]byte(e.name))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(driverID))
+	return int(h.Sum64() % experimentBucketsNum)
+}
+
+// MaxTimeInterval returns the longest time interval the arms override, zero without overrides.
+// The arm overrides apply last, so an arm that doesn't override the time interval keeps
+// the one of the default, campaign or zone predicate.
+func (e *Experiment) MaxTimeInterval(defaultPredicate *ZombiePredicate) time.Duration {
+	var maxInterval time.Duration
+	if e == nil {
+		return maxInterval
+	}
+	for _, arm := range e.arms {
+		if arm.Overrides == nil {
+			continue
+		}
+		if interval := arm.Overrides.Apply(defaultPredicate).TimeInterval; interval > maxInterval {
+			maxInterval = interval
+		}
+	}
+	return maxInterval
+}
+
+// overrides returns the predicate overrides of the arm, nil without an arm.
+func (aa *AssignedArm) overrides() *PredicateOverrides {
+	if aa == nil {
+		return nil
+	}
+	return aa.Overrides
+}
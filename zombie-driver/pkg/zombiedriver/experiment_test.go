@@ -0,0 +1,117 @@
+package zombiedriver_test
+
+import (
+	"fmt"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+)
+
+var experimentArms = []*zombiedriver.ExperimentArm{
+	{Name: "control", Weight: 90, Control: true},
+	{Name: "treatment", Weight: 10, Predicate: map[string]interface{}{"distance_threshold": 1000}},
+}
+
+func TestExperiment_Assign_Distribution(t *testing.T) {
+	t.Parallel()
+	experiment, err := zombiedriver.NewExperiment("zombie-icon-rollout", experimentArms, defaultPredicate, log.New())
+	require.NoError(t, err)
+
+	counts := map[string]int{}
+	for i := 0; i < 10000; i++ {
+		counts[experiment.Assign(fmt.Sprintf("driver-%d", i)).Name]++
+	}
+
+	assert.InDelta(t, 9000, counts["control"], 200)
+	assert.InDelta(t, 1000, counts["treatment"], 200)
+}
+
+func TestExperiment_Assign_Stable(t *testing.T) {
+	t.Parallel()
+	// Separately created experiments stand for different replicas or restarts.
+	logger := log.New()
+	first, err := zombiedriver.NewExperiment("zombie-icon-rollout", experimentArms, defaultPredicate, logger)
+	require.NoError(t, err)
+	second, err := zombiedriver.NewExperiment("zombie-icon-rollout", experimentArms, defaultPredicate, logger)
+	require.NoError(t, err)
+	other, err := zombiedriver.NewExperiment("another-experiment", experimentArms, defaultPredicate, logger)
+	require.NoError(t, err)
+
+	reshuffled := 0
+	for i := 0; i < 1000; i++ {
+		driverID := fmt.Sprintf("driver-%d", i)
+		require.Equal(t, first.Assign(driverID), second.Assign(driverID))
+		if first.Assign(driverID).Name != other.Assign(driverID).Name {
+			reshuffled++
+		}
+	}
+	// Experiments bucket drivers independently.
+	assert.Greater(t, reshuffled, 0)
+
+	// The hash must not change between releases, otherwise drivers switch arms.
+	assert.Equal(t, "control", first.Assign(defaultDriverID).Name)
+	assert.Equal(t, "treatment", first.Assign("driver-1").Name)
+}
+
+func TestExperiment_Assign_Arm(t *testing.T) {
+	t.Parallel()
+	experiment, err := zombiedriver.NewExperiment("zombie-icon-rollout", experimentArms, defaultPredicate, log.New())
+	require.NoError(t, err)
+
+	treatment := experiment.Assign("driver-1")
+	assert.False(t, treatment.Control)
+	require.NotNil(t, treatment.Overrides)
+	assert.Equal(t, 1000, treatment.Overrides.Apply(defaultPredicate).DistanceThreshold)
+
+	control := experiment.Assign(defaultDriverID)
+	assert.True(t, control.Control)
+	assert.Nil(t, control.Overrides)
+
+	var noExperiment *zombiedriver.Experiment
+	assert.Nil(t, noExperiment.Assign(defaultDriverID))
+}
+
+func TestNewExperiment_Invalid(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name     string
+		arms     []*zombiedriver.ExperimentArm
+		expected string
+	}{
+		{
+			name:     "no arms",
+			arms:     nil,
+			expected: "experiment must have arms",
+		},
+		{
+			name:     "duplicate arm",
+			arms:     []*zombiedriver.ExperimentArm{{Name: "a", Weight: 1}, {Name: "a", Weight: 1}},
+			expected: `experiment arm "a" is defined more than once`,
+		},
+		{
+			name:     "zero total weight",
+			arms:     []*zombiedriver.ExperimentArm{{Name: "a"}, {Name: "b"}},
+			expected: "experiment arms must have a positive total weight",
+		},
+		{
+			name: "invalid predicate",
+			arms: []*zombiedriver.ExperimentArm{
+				{Name: "a", Weight: 1, Predicate: map[string]interface{}{"distance_algorithm": "manhattan"}},
+			},
+			expected: `invalid predicate of experiment arm "a": invalid config:` + "\n" +
+				`  - unknown distance algorithm "manhattan", must be "haversine", "vincenty" or "equirectangular"`,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			_, err := zombiedriver.NewExperiment("zombie-icon-rollout", tc.arms, defaultPredicate, log.New())
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
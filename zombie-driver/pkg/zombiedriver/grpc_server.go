@@ -0,0 +1,111 @@
+package zombiedriver
+
+import (
+	"context"
+
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriverpb"
+)
+
+// maxBatchSize bounds the number of drivers requested by a single batch call.
+const maxBatchSize = 100
+
+// GRPCServer exposes the drivers over gRPC, alongside the HTTP API.
+type GRPCServer struct {
+	zombiedriverpb.UnimplementedZombieDriverServer
+	service Service
+	logger  log.FieldLogger
+}
+
+func NewGRPCServer(service Service, logger log.FieldLogger) *GRPCServer {
+	return &GRPCServer{service: service, logger: logger}
+}
+
+// Register registers the server on the gRPC server.
+func (gs *GRPCServer) Register(server *grpc.Server) {
+	zombiedriverpb.RegisterZombieDriverServer(server, gs)
+}
+
+func (gs *GRPCServer) GetDriver(ctx context.Context, req *zombiedriverpb.GetDriverRequest) (
+	*zombiedriverpb.Driver, error) {
+	ctxLogger := gs.logger.WithField("driver_id", req.DriverId)
+	if req.DriverId == "" {
+		ctxLogger.Info("Driver id is missing, return InvalidArgument")
+		return nil, status.Error(codes.InvalidArgument, "driver_id is missing")
+	}
+
+	ctxLogger.Info("Request driver from the service")
+	driver, err := gs.service.GetDriver(ctx, req.DriverId)
+	if err != nil {
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to request driver from the service"))
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return driverToProto(driver), nil
+}
+
+// BatchGetDrivers requests all drivers concurrently and fails if any of the requests fails.
+// The drivers share the service cache and the in-flight computations with the single driver requests.
+func (gs *GRPCServer) BatchGetDrivers(ctx context.Context, req *zombiedriverpb.BatchGetDriversRequest) (
+	*zombiedriverpb.BatchGetDriversResponse, error) {
+	if err := validateDriverIDs(req.DriverIds); err != nil {
+		gs.logger.WithError(err).Info("Request is invalid, return InvalidArgument")
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	gs.logger.WithField("drivers_num", len(req.DriverIds)).Info("Request drivers from the service")
+	drivers := make([]*zombiedriverpb.Driver, len(req.DriverIds))
+	group, groupCtx := errgroup.WithContext(ctx)
+	for i, driverID := range req.DriverIds {
+		i, driverID := i, driverID
+		group.Go(func() error {
+			driver, err := gs.service.GetDriver(groupCtx, driverID)
+			if err != nil {
+				return errors.Wrapf(err, "failed to request driver %s from the service", driverID)
+			}
+			drivers[i] = driverToProto(driver)
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		logging.LogUnhandledError(gs.logger, err)
+		return nil, status.Error(codes.Internal, "internal server error")
+	}
+	return &zombiedriverpb.BatchGetDriversResponse{Drivers: drivers}, nil
+}
+
+func validateDriverIDs(driverIDs []string) error {
+	if len(driverIDs) > maxBatchSize {
+		return errors.Errorf("driver_ids must not contain more than %d ids", maxBatchSize)
+	}
+	for _, driverID := range driverIDs {
+		if driverID == "" {
+			return errors.New("driver_ids must not contain empty ids")
+		}
+	}
+	return nil
+}
+
+func driverToProto(driver *Driver) *zombiedriverpb.Driver {
+	result := &zombiedriverpb.Driver{
+		Id:     driver.ID,
+		Zombie: driver.IsZombie,
+		State:  driver.State,
+	}
+	if driver.Zone != "" {
+		result.Zone = &driver.Zone
+	}
+	if driver.Arm != "" {
+		result.Arm = &driver.Arm
+	}
+	if driver.Overridden {
+		result.Overridden = &driver.Overridden
+	}
+	return result
+}
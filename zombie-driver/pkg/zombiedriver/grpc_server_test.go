@@ -0,0 +1,138 @@
+package zombiedriver_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver/mocks"
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriverpb"
+)
+
+func TestGRPC_GetDriver(t *testing.T) {
+	t.Parallel()
+	client, serviceMock := setupGRPCServer(t)
+	serviceMock.On(
+		"GetDriver",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+		defaultDriverID,
+	).Return(&zombiedriver.Driver{
+		ID: defaultDriverID, IsZombie: true, State: zombiedriver.DriverStateZombie, Zone: "paris",
+	}, nil)
+
+	actual, err := client.GetDriver(context.Background(), &zombiedriverpb.GetDriverRequest{DriverId: defaultDriverID})
+	require.NoError(t, err)
+
+	expected := &zombiedriverpb.Driver{
+		Id: defaultDriverID, Zombie: true, State: zombiedriver.DriverStateZombie, Zone: proto.String("paris"),
+	}
+	assert.True(t, proto.Equal(expected, actual), "expected %v, actual %v", expected, actual)
+	serviceMock.AssertExpectations(t)
+}
+
+func TestGRPC_GetDriver_Errors(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name         string
+		driverID     string
+		serviceErr   error
+		expectedCode codes.Code
+	}{
+		{name: "missing driver id", driverID: "", expectedCode: codes.InvalidArgument},
+		{name: "service fails", driverID: defaultDriverID, serviceErr: errors.New("boom"), expectedCode: codes.Internal},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			client, serviceMock := setupGRPCServer(t)
+			if tc.serviceErr != nil {
+				serviceMock.On(
+					"GetDriver",
+					mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+					tc.driverID,
+				).Return(nil, tc.serviceErr)
+			}
+
+			_, err := client.GetDriver(context.Background(), &zombiedriverpb.GetDriverRequest{DriverId: tc.driverID})
+
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			serviceMock.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGRPC_BatchGetDrivers(t *testing.T) {
+	t.Parallel()
+	client, serviceMock := setupGRPCServer(t)
+	for _, driver := range []*zombiedriver.Driver{
+		{ID: defaultDriverID, State: zombiedriver.DriverStateAlive, Arm: "control"},
+		{ID: "bar", State: zombiedriver.DriverStateOffline, Overridden: true},
+	} {
+		serviceMock.On(
+			"GetDriver",
+			mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+			driver.ID,
+		).Return(driver, nil)
+	}
+
+	actual, err := client.BatchGetDrivers(
+		context.Background(), &zombiedriverpb.BatchGetDriversRequest{DriverIds: []string{defaultDriverID, "bar"}},
+	)
+	require.NoError(t, err)
+
+	expected := &zombiedriverpb.BatchGetDriversResponse{Drivers: []*zombiedriverpb.Driver{
+		{Id: defaultDriverID, State: zombiedriver.DriverStateAlive, Arm: proto.String("control")},
+		{Id: "bar", State: zombiedriver.DriverStateOffline, Overridden: proto.Bool(true)},
+	}}
+	assert.True(t, proto.Equal(expected, actual), "expected %v, actual %v", expected, actual)
+	serviceMock.AssertExpectations(t)
+}
+
+func TestGRPC_BatchGetDrivers_TooManyDrivers(t *testing.T) {
+	t.Parallel()
+	client, serviceMock := setupGRPCServer(t)
+	driverIDs := make([]string, 101)
+	for i := range driverIDs {
+		driverIDs[i] = defaultDriverID
+	}
+
+	_, err := client.BatchGetDrivers(context.Background(), &zombiedriverpb.BatchGetDriversRequest{DriverIds: driverIDs})
+
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	serviceMock.AssertExpectations(t)
+}
+
+func setupGRPCServer(t *testing.T) (zombiedriverpb.ZombieDriverClient, *mocks.Service) {
+	logger := log.New()
+	logger.Level = log.ErrorLevel
+	serviceMock := &mocks.Service{}
+	server := grpc.NewServer()
+	zombiedriver.NewGRPCServer(serviceMock, logger).Register(server)
+	listener := bufconn.Listen(1024 * 1024)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial(
+		"bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithInsecure(),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return zombiedriverpb.NewZombieDriverClient(conn), serviceMock
+}
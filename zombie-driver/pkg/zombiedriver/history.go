@@ -0,0 +1,206 @@
+package zombiedriver
+
+import (
+	"container/list"
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// StatusTransition is a change of the computed driver state.
+type StatusTransition struct {
+	DriverID string `json:"driver_id"`
+	State    string `json:"state"`
+	// DistanceDriven is zero unless the state was computed by the predicate.
+	DistanceDriven int       `json:"distance_driven"`
+	OccurredAt     time.Time `json:"occurred_at"`
+}
+
+// HistorySink keeps the status transitions for the analytics.
+type HistorySink interface {
+	Record(ctx context.Context, transition *StatusTransition) error
+	// Transitions returns the kept transitions that occurred from inclusive to exclusive
+	// in the order they were recorded.
+	Transitions(ctx context.Context, from, to time.Time) ([]*StatusTransition, error)
+}
+
+// HistoryRecorder records the computed driver states to the sink when they change.
+// It remembers the last state of up to maxDrivers recently observed drivers in memory, so after a restart
+// or for a forgotten driver the state is recorded again, and each replica records its own transitions.
+// The analytics skip such repetitions.
+// A nil HistoryRecorder records nothing.
+type HistoryRecorder struct {
+	sink       HistorySink
+	maxDrivers int
+	// lookback is how long before the period the transitions are read to know the states at its start.
+	lookback time.Duration
+	logger   log.FieldLogger
+
+	mu         sync.Mutex
+	lastStates map[string]*list.Element
+	order      *list.List // Front is the most recently observed driver.
+}
+
+type lastState struct {
+	driverID string
+	state    string
+}
+
+func NewHistoryRecorder(
+	sink HistorySink, maxDrivers int, lookback time.Duration, logger log.FieldLogger,
+) *HistoryRecorder {
+	return &HistoryRecorder{
+		sink:       sink,
+		maxDrivers: maxDrivers,
+		lookback:   lookback,
+		logger:     logger,
+		lastStates: make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Observe records the driver state if it differs from the previously observed one.
+// The sink failures are only logged, the history must not fail the requests.
+func (r *HistoryRecorder) Observe(ctx context.Context, driver *Driver, distanceDriven int, now time.Time) {
+	if r == nil {
+		return
+	}
+	if !r.swapState(driver.ID, driver.State) {
+		return
+	}
+	transition := &StatusTransition{
+		DriverID:       driver.ID,
+		State:          driver.State,
+		DistanceDriven: distanceDriven,
+		OccurredAt:     now,
+	}
+	if err := r.sink.Record(ctx, transition); err != nil {
+		logging.LogUnhandledError(
+			r.logger.WithField("driver_id", driver.ID), errors.Wrap(err, "failed to record status transition"),
+		)
+		// Forget the state, so the transition is recorded on the next observation.
+		r.forgetState(driver.ID)
+	}
+}
+
+// swapState remembers the driver state and reports whether it differs from the previous one.
+// The least recently observed driver is forgotten when there are too many of them.
+func (r *HistoryRecorder) swapState(driverID, state string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if elem, ok := r.lastStates[driverID]; ok {
+		r.order.MoveToFront(elem)
+		last := elem.Value.(*lastState)
+		changed := last.state != state
+		last.state = state
+		return changed
+	}
+	r.lastStates[driverID] = r.order.PushFront(&lastState{driverID: driverID, state: state})
+	if r.order.Len() > r.maxDrivers {
+		oldest := r.order.Back()
+		r.order.Remove(oldest)
+		delete(r.lastStates, oldest.Value.(*lastState).driverID)
+	}
+	return true
+}
+
+func (r *HistoryRecorder) forgetState(driverID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if elem, ok := r.lastStates[driverID]; ok {
+		r.order.Remove(elem)
+		delete(r.lastStates, driverID)
+	}
+}
+
+// ZombieStats aggregates the status history over the period from From inclusive to To exclusive.
+type ZombieStats struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+	// ZombieDriversNum is the number of drivers who were zombies at any moment of the period.
+	ZombieDriversNum int `json:"zombie_drivers_num"`
+	// Transitions counts the transitions into each state during the period.
+	Transitions map[string]int `json:"transitions"`
+	// ZombieSeconds is the time each of the zombie drivers spent as a zombie during the period.
+	ZombieSeconds map[string]float64 `json:"zombie_seconds"`
+}
+
+// Stats returns the statistics of the period, nil without history.
+// Only the transitions within the lookback before the period tell the states at its start,
+// so a driver whose last transition is older is taken into account since their next transition.
+func (r *HistoryRecorder) Stats(ctx context.Context, from, to time.Time) (*ZombieStats, error) {
+	if r == nil {
+		return nil, nil
+	}
+	transitions, err := r.sink.Transitions(ctx, from.Add(-r.lookback), to)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read status transitions from the sink")
+	}
+	return computeZombieStats(transitions, from, to), nil
+}
+
+// computeZombieStats assumes that a driver stays in a state until their next transition,
+// the last state lasts until the end of the period.
+func computeZombieStats(transitions []*StatusTransition, from, to time.Time) *ZombieStats {
+	stats := &ZombieStats{
+		From:          from,
+		To:            to,
+		Transitions:   map[string]int{},
+		ZombieSeconds: map[string]float64{},
+	}
+	byDriver := map[string][]*StatusTransition{}
+	for _, transition := range transitions {
+		if transition.OccurredAt.Before(to) {
+			byDriver[transition.DriverID] = append(byDriver[transition.DriverID], transition)
+		}
+	}
+	for driverID, driverTransitions := range byDriver {
+		sort.SliceStable(driverTransitions, func(i, j int) bool {
+			return driverTransitions[i].OccurredAt.Before(driverTransitions[j].OccurredAt)
+		})
+		var zombieTime time.Duration
+		wasZombie := false
+		for i, transition := range driverTransitions {
+			if i > 0 && driverTransitions[i-1].State == transition.State {
+				continue
+			}
+			if !transition.OccurredAt.Before(from) {
+				stats.Transitions[transition.State]++
+			}
+			if transition.State != DriverStateZombie {
+				continue
+			}
+			end := to
+			for _, next := range driverTransitions[i+1:] {
+				if next.State != transition.State {
+					end = next.OccurredAt
+					break
+				}
+			}
+			if overlap := overlapDuration(transition.OccurredAt, end, from, to); overlap > 0 {
+				zombieTime += overlap
+				wasZombie = true
+			}
+		}
+		if wasZombie {
+			stats.ZombieDriversNum++
+			stats.ZombieSeconds[driverID] = zombieTime.Seconds()
+		}
+	}
+	return stats
+}
+
+func overlapDuration(start, end, from, to time.Time) time.Duration {
+	if start.Before(from) {
+		start = from
+	}
+	if end.After(to) {
+		end = to
+	}
+	return end.Sub(start)
+}
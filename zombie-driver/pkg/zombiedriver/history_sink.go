@@ -0,0 +1,192 @@
+package zombiedriver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	redisHistoryStreamKey = "zombie-driver:status-history"
+	redisHistoryDataField = "data"
+)
+
+// historyClockSlack widens the part of the history read for a period: the transitions are appended about
+// in the order they occur, concurrent requests and the clocks of the replicas and Redis may reorder them a bit.
+const historyClockSlack = time.Minute
+
+// RedisHistorySink appends the transitions of all service replicas to a Redis stream,
+// keeping about maxLen latest ones.
+type RedisHistorySink struct {
+	redis  *redis.Client
+	maxLen int64
+}
+
+func NewRedisHistorySink(r *redis.Client, maxLen int64) *RedisHistorySink {
+	return &RedisHistorySink{redis: r, maxLen: maxLen}
+}
+
+func (s *RedisHistorySink) Record(ctx context.Context, transition *StatusTransition) error {
+	data, err := json.Marshal(transition)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode status transition into json")
+	}
+	err = s.redis.XAdd(ctx, &redis.XAddArgs{
+		Stream:       redisHistoryStreamKey,
+		MaxLenApprox: s.maxLen,
+		Values:       map[string]interface{}{redisHistoryDataField: data},
+	}).Err()
+	return errors.Wrap(err, "failed to append status transition to Redis stream")
+}
+
+// Transitions reads only the stream ids around the period, the ids start with the append time in milliseconds.
+func (s *RedisHistorySink) Transitions(ctx context.Context, from, to time.Time) ([]*StatusTransition, error) {
+	start := redisStreamTime(from.Add(-historyClockSlack))
+	stop := redisStreamTime(to.Add(historyClockSlack))
+	messages, err := s.redis.XRange(ctx, redisHistoryStreamKey, start, stop).Result()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read status transitions from Redis stream")
+	}
+	transitions := make([]*StatusTransition, 0, len(messages))
+	for _, message := range messages {
+		data, _ := message.Values[redisHistoryDataField].(string)
+		transition := &StatusTransition{}
+		if err := json.Unmarshal([]byte(data), transition); err != nil {
+			return nil, errors.Wrapf(err, "can't decode status transition %s of message %s", data, message.ID)
+		}
+		if occurredWithin(transition, from, to) {
+			transitions = append(transitions, transition)
+		}
+	}
+	return transitions, nil
+}
+
+// redisStreamTime returns the stream id range bound of all ids appended in the millisecond of the time.
+func redisStreamTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano()/int64(time.Millisecond), 10)
+}
+
+// FileHistorySink appends the transitions to a local json lines file, it's meant for a single service replica.
+type FileHistorySink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileHistorySink(path string) *FileHistorySink {
+	return &FileHistorySink{path: path}
+}
+
+func (s *FileHistorySink) Record(_ context.Context, transition *StatusTransition) error {
+	data, err := json.Marshal(transition)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode status transition into json")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "failed to open status history file")
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "failed to append status transition")
+	}
+	return errors.Wrap(f.Close(), "failed to close status history file")
+}
+
+// Transitions binary searches the file for the start of the period, since the transitions are appended
+// in the order they occur, and reads the file until the end of the period.
+func (s *FileHistorySink) Transitions(_ context.Context, from, to time.Time) ([]*StatusTransition, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(s.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open status history file")
+	}
+	defer func() { _ = f.Close() }() // Nothing is written, so closing can't lose data.
+	info, err := f.Stat()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to stat status history file")
+	}
+	size := info.Size()
+
+	// The smallest offset whose next line occurred at or after the period start.
+	start := from.Add(-historyClockSlack)
+	low, high := int64(0), size
+	for low < high {
+		middle := low + (high-low)/2
+		transition, err := readTransitionAt(f, size, middle)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF || !transition.OccurredAt.Before(start) {
+			high = middle
+		} else {
+			low = middle + 1
+		}
+	}
+
+	var transitions []*StatusTransition
+	reader := newLineReaderAt(f, size, low)
+	for {
+		transition, err := readTransition(reader)
+		if err == io.EOF {
+			return transitions, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !transition.OccurredAt.Before(to.Add(historyClockSlack)) {
+			return transitions, nil
+		}
+		if occurredWithin(transition, from, to) {
+			transitions = append(transitions, transition)
+		}
+	}
+}
+
+// newLineReaderAt returns a reader of the file lines starting at or after the offset.
+func newLineReaderAt(f *os.File, size, offset int64) *bufio.Reader {
+	if offset == 0 {
+		return bufio.NewReader(io.NewSectionReader(f, 0, size))
+	}
+	// The previous byte is a newline if the offset starts a line, otherwise the rest of the line is skipped.
+	reader := bufio.NewReader(io.NewSectionReader(f, offset-1, size-offset+1))
+	_, _ = reader.ReadBytes('\n') // The error is returned again by the next read.
+	return reader
+}
+
+// readTransitionAt reads the first line starting at or after the offset, io.EOF means there is none.
+func readTransitionAt(f *os.File, size, offset int64) (*StatusTransition, error) {
+	return readTransition(newLineReaderAt(f, size, offset))
+}
+
+func readTransition(reader *bufio.Reader) (*StatusTransition, error) {
+	line, err := reader.ReadBytes('\n')
+	if len(line) == 0 && err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to read status history file")
+	}
+	transition := &StatusTransition{}
+	if err := json.Unmarshal(line, transition); err != nil {
+		return nil, errors.Wrapf(err, "can't decode status transition %s", line)
+	}
+	return transition, nil
+}
+
+func occurredWithin(transition *StatusTransition, from, to time.Time) bool {
+	return !transition.OccurredAt.Before(from) && transition.OccurredAt.Before(to)
+}
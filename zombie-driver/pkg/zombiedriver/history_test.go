@@ -0,0 +1,201 @@
+package zombiedriver_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+)
+
+func TestHistoryRecorder_Observe(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	sink := newFileHistorySink(t)
+	recorder := newHistoryRecorder(sink, 10 /* maxDrivers */)
+	baseTime := time.Date(2020, 11, 1, 22, 0, 0, 0, time.UTC)
+
+	recorder.Observe(ctx, &zombiedriver.Driver{ID: defaultDriverID, State: zombiedriver.DriverStateAlive}, 800, baseTime)
+	recorder.Observe(
+		ctx, &zombiedriver.Driver{ID: defaultDriverID, State: zombiedriver.DriverStateAlive}, 700, baseTime.Add(time.Minute),
+	)
+	recorder.Observe(
+		ctx, &zombiedriver.Driver{ID: defaultDriverID, IsZombie: true, State: zombiedriver.DriverStateZombie}, 100,
+		baseTime.Add(2*time.Minute),
+	)
+
+	transitions, err := sink.Transitions(ctx, baseTime, baseTime.Add(time.Hour))
+	require.NoError(t, err)
+	expected := []*zombiedriver.StatusTransition{
+		{DriverID: defaultDriverID, State: zombiedriver.DriverStateAlive, DistanceDriven: 800, OccurredAt: baseTime},
+		{
+			DriverID: defaultDriverID, State: zombiedriver.DriverStateZombie, DistanceDriven: 100,
+			OccurredAt: baseTime.Add(2 * time.Minute),
+		},
+	}
+	assert.Equal(t, expected, transitions)
+}
+
+func TestHistoryRecorder_Observe_ForgetsLeastRecentlyObservedDriver(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	sink := newFileHistorySink(t)
+	recorder := newHistoryRecorder(sink, 2 /* maxDrivers */)
+	baseTime := time.Date(2020, 11, 1, 22, 0, 0, 0, time.UTC)
+
+	for i, driverID := range []string{"1", "2", "1", "3", "1", "2"} {
+		driver := &zombiedriver.Driver{ID: driverID, State: zombiedriver.DriverStateAlive}
+		recorder.Observe(ctx, driver, 0 /* distanceDriven */, baseTime.Add(time.Duration(i)*time.Minute))
+	}
+
+	transitions, err := sink.Transitions(ctx, baseTime, baseTime.Add(time.Hour))
+	require.NoError(t, err)
+	var recorded []string
+	for _, transition := range transitions {
+		recorded = append(recorded, transition.DriverID)
+	}
+	// Driver 2 is forgotten when driver 3 is observed, so their unchanged state is recorded again.
+	assert.Equal(t, []string{"1", "2", "3", "2"}, recorded)
+}
+
+func TestHistoryRecorder_Stats(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	sink := newFileHistorySink(t)
+	night := time.Date(2020, 11, 1, 22, 0, 0, 0, time.UTC)
+	transitions := []*zombiedriver.StatusTransition{
+		// A zombie before the period who stays a zombie, the restart of a replica has recorded the state twice.
+		{DriverID: "1", State: zombiedriver.DriverStateZombie, OccurredAt: night.Add(-time.Hour)},
+		{DriverID: "1", State: zombiedriver.DriverStateZombie, OccurredAt: night.Add(time.Hour)},
+		// A zombie for half an hour within the period.
+		{DriverID: "2", State: zombiedriver.DriverStateAlive, OccurredAt: night.Add(-time.Hour)},
+		{DriverID: "2", State: zombiedriver.DriverStateZombie, OccurredAt: night.Add(30 * time.Minute)},
+		{DriverID: "2", State: zombiedriver.DriverStateAlive, OccurredAt: night.Add(time.Hour)},
+		// A zombie only before the period.
+		{DriverID: "3", State: zombiedriver.DriverStateZombie, OccurredAt: night.Add(-2 * time.Hour)},
+		{DriverID: "3", State: zombiedriver.DriverStateOffline, OccurredAt: night.Add(-time.Hour)},
+		// A zombie only after the period.
+		{DriverID: "4", State: zombiedriver.DriverStateZombie, OccurredAt: night.Add(5 * time.Hour)},
+	}
+	// The transitions are recorded in the order they occur.
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].OccurredAt.Before(transitions[j].OccurredAt)
+	})
+	for _, transition := range transitions {
+		require.NoError(t, sink.Record(ctx, transition))
+	}
+	recorder := newHistoryRecorder(sink, 10 /* maxDrivers */)
+
+	actual, err := recorder.Stats(ctx, night, night.Add(4*time.Hour))
+	require.NoError(t, err)
+
+	expected := &zombiedriver.ZombieStats{
+		From:             night,
+		To:               night.Add(4 * time.Hour),
+		ZombieDriversNum: 2,
+		Transitions:      map[string]int{zombiedriver.DriverStateZombie: 1, zombiedriver.DriverStateAlive: 1},
+		ZombieSeconds:    map[string]float64{"1": 4 * 3600, "2": 1800},
+	}
+	assert.Equal(t, expected, actual)
+
+	// The zombie state of driver 1 is before the lookback, so it's unknown at the period start.
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	shortRecorder := zombiedriver.NewHistoryRecorder(sink, 10 /* maxDrivers */, 30*time.Minute, logger)
+	actual, err = shortRecorder.Stats(ctx, night, night.Add(4*time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]float64{"1": 3 * 3600, "2": 1800}, actual.ZombieSeconds)
+}
+
+func TestHistoryRecorder_Stats_NoHistory(t *testing.T) {
+	t.Parallel()
+	var recorder *zombiedriver.HistoryRecorder
+
+	actual, err := recorder.Stats(context.Background(), time.Now().Add(-time.Hour), time.Now())
+
+	require.NoError(t, err)
+	assert.Nil(t, actual)
+}
+
+func TestRedisHistorySink(t *testing.T) {
+	t.Parallel()
+	fakeRedis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer fakeRedis.Close()
+	sink := zombiedriver.NewRedisHistorySink(redis.NewClient(&redis.Options{Addr: fakeRedis.Addr()}), 100)
+
+	testHistorySink(t, sink, fakeRedis.SetTime)
+}
+
+func TestFileHistorySink(t *testing.T) {
+	t.Parallel()
+	testHistorySink(t, newFileHistorySink(t), func(_ time.Time) {})
+}
+
+// testHistorySink records transitions an hour apart and reads periods of them, setTime sets the sink clock.
+func testHistorySink(t *testing.T, sink zombiedriver.HistorySink, setTime func(now time.Time)) {
+	t.Helper()
+	ctx := context.Background()
+	baseTime := time.Date(2020, 11, 1, 22, 0, 0, 0, time.UTC)
+
+	transitions, err := sink.Transitions(ctx, baseTime, baseTime.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, transitions)
+
+	var recorded []*zombiedriver.StatusTransition
+	for i := 0; i < 5; i++ {
+		transition := &zombiedriver.StatusTransition{
+			DriverID:       defaultDriverID,
+			State:          zombiedriver.DriverStateZombie,
+			DistanceDriven: i,
+			OccurredAt:     baseTime.Add(time.Duration(i) * time.Hour),
+		}
+		setTime(transition.OccurredAt)
+		require.NoError(t, sink.Record(ctx, transition))
+		recorded = append(recorded, transition)
+	}
+
+	cases := []struct {
+		name     string
+		from, to time.Time
+		expected []*zombiedriver.StatusTransition
+	}{
+		{name: "all", from: baseTime, to: baseTime.Add(5 * time.Hour), expected: recorded},
+		{name: "middle", from: baseTime.Add(time.Hour), to: baseTime.Add(3 * time.Hour), expected: recorded[1:3]},
+		{name: "between", from: baseTime.Add(90 * time.Minute), to: baseTime.Add(4 * time.Hour), expected: recorded[2:4]},
+		{name: "before", from: baseTime.Add(-time.Hour), to: baseTime, expected: nil},
+		{name: "after", from: baseTime.Add(5 * time.Hour), to: baseTime.Add(6 * time.Hour), expected: nil},
+	}
+	for _, tc := range cases {
+		transitions, err := sink.Transitions(ctx, tc.from, tc.to)
+		require.NoError(t, err)
+		if tc.expected == nil {
+			assert.Empty(t, transitions, tc.name)
+			continue
+		}
+		assert.Equal(t, tc.expected, transitions, tc.name)
+	}
+}
+
+func newFileHistorySink(t *testing.T) *zombiedriver.FileHistorySink {
+	dir, err := ioutil.TempDir("", "history")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	return zombiedriver.NewFileHistorySink(filepath.Join(dir, "status-history.jsonl"))
+}
+
+func newHistoryRecorder(sink zombiedriver.HistorySink, maxDrivers int) *zombiedriver.HistoryRecorder {
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	return zombiedriver.NewHistoryRecorder(sink, maxDrivers, 24*time.Hour /* lookback */, logger)
+}
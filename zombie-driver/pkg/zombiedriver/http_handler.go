@@ -1,24 +1,35 @@
 package zombiedriver
 
 import (
+	"crypto/sha256"
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/georgysavva/driver-app/platform/httpapi"
+	"github.com/georgysavva/driver-app/platform/logging"
 	"github.com/gorilla/mux"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
-func MakeHTTPHandler(service Service, logger log.FieldLogger) http.Handler {
+// MakeHTTPHandler returns the service HTTP API. Responses can be cached by clients for cacheMaxAge,
+// zero means they must be revalidated via the ETag every time.
+func MakeHTTPHandler(service Service, cacheMaxAge time.Duration, logger log.FieldLogger) http.Handler {
 	router := mux.NewRouter()
-	ha := &httpAPI{service: service, logger: logger}
+	ha := &httpAPI{service: service, cacheMaxAge: cacheMaxAge, logger: logger}
 	router.HandleFunc("/drivers/{id}", ha.getDriver).Methods("GET")
+	router.HandleFunc("/campaigns/active", ha.getActiveCampaign).Methods("GET")
+	router.HandleFunc("/stats/zombies", ha.getZombieStats).Methods("GET")
 	return router
 }
 
 type httpAPI struct {
-	service Service
-	logger  log.FieldLogger
+	service     Service
+	cacheMaxAge time.Duration
+	logger      log.FieldLogger
 }
 
 func (ha *httpAPI) getDriver(w http.ResponseWriter, r *http.Request) {
@@ -28,28 +39,111 @@ func (ha *httpAPI) getDriver(w http.ResponseWriter, r *http.Request) {
 	ctxLogger.Info("Request driver from the service")
 	driver, err := ha.service.GetDriver(r.Context(), driverID)
 	if err != nil {
-		logUnhandledError(ctxLogger, errors.Wrap(err, "failed to request driver from the service"))
-		internalServerError(w)
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to request driver from the service"))
+		httpapi.InternalServerError(w)
 		return
 	}
 
-	if err := returnJSONData(w, driver); err != nil {
-		logUnhandledError(ctxLogger, err)
-		internalServerError(w)
+	driverData, err := json.Marshal(driver)
+	if err != nil {
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "can not encode driver into json"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	etag := fmt.Sprintf(`"%x"`, sha256.Sum256(driverData))
+	w.Header().Set("ETag", etag)
+	if ha.cacheMaxAge > 0 {
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(ha.cacheMaxAge.Seconds())))
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if etagMatches(r.Header.Get("If-None-Match"), etag) {
+		ctxLogger.Info("Driver hasn't changed, return 304")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(append(driverData, '\n')); err != nil {
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "can not write data into http response"))
 		return
 	}
 }
 
-func internalServerError(w http.ResponseWriter) {
-	http.Error(w, "Internal server error", http.StatusInternalServerError)
+// getActiveCampaign returns the running campaign or 404 if there is none.
+// Without a campaign schedule the zombies are always shown, so an unnamed campaign that never ends is returned.
+func (ha *httpAPI) getActiveCampaign(w http.ResponseWriter, r *http.Request) {
+	ha.logger.Info("Request active campaign from the service")
+	campaign, err := ha.service.GetActiveCampaign(r.Context())
+	if err != nil {
+		logging.LogUnhandledError(ha.logger, errors.Wrap(err, "failed to request active campaign from the service"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	if campaign == nil {
+		http.Error(w, "No active campaign", http.StatusNotFound)
+		return
+	}
+	if err := httpapi.ReturnJSONData(w, campaign); err != nil {
+		logging.LogUnhandledError(ha.logger, errors.WithStack(err))
+		return
+	}
 }
 
-func returnJSONData(w http.ResponseWriter, obj interface{}) error {
-	w.Header().Set("Content-Type", "application/json")
-	err := json.NewEncoder(w).Encode(obj)
-	return errors.Wrap(err, "can not encode or write data into http response")
+// defaultStatsPeriod is used when the from query parameter isn't set.
+const defaultStatsPeriod = 24 * time.Hour
+
+// getZombieStats returns the statistics of the period between the from and to query parameters in RFC 3339,
+// by default the last 24 hours. It returns 404 if the status history is disabled.
+func (ha *httpAPI) getZombieStats(w http.ResponseWriter, r *http.Request) {
+	to, err := parseTimeParam(r, "to", time.Now().UTC())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	from, err := parseTimeParam(r, "from", to.Add(-defaultStatsPeriod))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !from.Before(to) {
+		http.Error(w, "from must be before to", http.StatusBadRequest)
+		return
+	}
+	ctxLogger := ha.logger.WithFields(log.Fields{"from": from, "to": to})
+
+	ctxLogger.Info("Request zombie stats from the service")
+	stats, err := ha.service.GetZombieStats(r.Context(), from, to)
+	if err != nil {
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to request zombie stats from the service"))
+		httpapi.InternalServerError(w)
+		return
+	}
+	if stats == nil {
+		http.Error(w, "Status history is disabled", http.StatusNotFound)
+		return
+	}
+	if err := httpapi.ReturnJSONData(w, stats); err != nil {
+		logging.LogUnhandledError(ctxLogger, errors.WithStack(err))
+		return
+	}
 }
 
-func logUnhandledError(logger log.FieldLogger, err error) {
-	logger.WithError(err).Error("Unhandled error occurred")
+func parseTimeParam(r *http.Request, name string, defaultValue time.Time) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	return t, errors.Wrapf(err, "invalid %s parameter", name)
+}
+
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
 }
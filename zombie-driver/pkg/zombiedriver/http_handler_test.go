@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
@@ -29,7 +30,7 @@ func TestHTTP_GetLocations(t *testing.T) {
 		"GetDriver",
 		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
 		defaultDriverID,
-	).Return(&zombiedriver.Driver{ID: defaultDriverID, IsZombie: true}, nil)
+	).Return(zombieDriver, nil)
 
 	reqURL := serverURL.ResolveReference(&url.URL{Path: fmt.Sprintf("drivers/%s", defaultDriverID)})
 	response, err := http.Get(reqURL.String())
@@ -42,7 +43,8 @@ func TestHTTP_GetLocations(t *testing.T) {
 	expectedResponseData := `
 	{
 		"id": "foo",
-		"zombie": true
+		"zombie": true,
+		"state": "zombie"
 	}`
 	assert.Equal(t, http.StatusOK, response.StatusCode)
 	assert.Equal(t, "application/json", response.Header.Get("Content-Type"))
@@ -50,11 +52,141 @@ func TestHTTP_GetLocations(t *testing.T) {
 	serviceMock.AssertExpectations(t)
 }
 
+func TestHTTP_GetDriver_CacheHeaders(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupHTTPServer()
+	defer ts.Close()
+	serviceMock.On(
+		"GetDriver",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+		defaultDriverID,
+	).Return(zombieDriver, nil)
+	reqURL := fmt.Sprintf("%s/drivers/%s", ts.URL, defaultDriverID)
+
+	response, err := http.Get(reqURL)
+	require.NoError(t, err)
+	response.Body.Close()
+	etag := response.Header.Get("ETag")
+	require.NotEmpty(t, etag)
+	assert.Equal(t, "private, max-age=5", response.Header.Get("Cache-Control"))
+
+	req, err := http.NewRequest("GET", reqURL, nil /* body */)
+	require.NoError(t, err)
+	req.Header.Set("If-None-Match", etag)
+	response, err = http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusNotModified, response.StatusCode)
+	assert.Equal(t, etag, response.Header.Get("ETag"))
+	assert.Empty(t, responseBytes)
+}
+
+func TestHTTP_GetActiveCampaign(t *testing.T) {
+	t.Parallel()
+	endsAt := time.Date(2020, 11, 2, 1, 0, 0, 0, time.UTC)
+	cases := []struct {
+		name             string
+		campaign         *zombiedriver.ActiveCampaign
+		expectedStatus   int
+		expectedResponse string
+	}{
+		{
+			name:             "active",
+			campaign:         &zombiedriver.ActiveCampaign{Name: "episode-nights", EndsAt: &endsAt},
+			expectedStatus:   http.StatusOK,
+			expectedResponse: `{"name": "episode-nights", "ends_at": "2020-11-02T01:00:00Z"}`,
+		},
+		{
+			name:             "none",
+			campaign:         nil,
+			expectedStatus:   http.StatusNotFound,
+			expectedResponse: "No active campaign\n",
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			ts, serviceMock := setupHTTPServer()
+			defer ts.Close()
+			serviceMock.On(
+				"GetActiveCampaign",
+				mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+			).Return(tc.campaign, nil)
+
+			response, err := http.Get(ts.URL + "/campaigns/active")
+			require.NoError(t, err)
+			defer response.Body.Close()
+			responseBytes, err := ioutil.ReadAll(response.Body)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expectedStatus, response.StatusCode)
+			if tc.expectedStatus == http.StatusOK {
+				assert.JSONEq(t, tc.expectedResponse, string(responseBytes))
+			} else {
+				assert.Equal(t, tc.expectedResponse, string(responseBytes))
+			}
+		})
+	}
+}
+
+func TestHTTP_GetZombieStats(t *testing.T) {
+	t.Parallel()
+	ts, serviceMock := setupHTTPServer()
+	defer ts.Close()
+	from := time.Date(2020, 11, 1, 20, 0, 0, 0, time.UTC)
+	to := time.Date(2020, 11, 2, 2, 0, 0, 0, time.UTC)
+	serviceMock.On(
+		"GetZombieStats",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // match anything of type context.Context
+		from, to,
+	).Return(&zombiedriver.ZombieStats{
+		From:             from,
+		To:               to,
+		ZombieDriversNum: 1,
+		Transitions:      map[string]int{"zombie": 1},
+		ZombieSeconds:    map[string]float64{"42": 1800},
+	}, nil)
+
+	response, err := http.Get(ts.URL + "/stats/zombies?from=2020-11-01T20:00:00Z&to=2020-11-02T02:00:00Z")
+	require.NoError(t, err)
+	defer response.Body.Close()
+	responseBytes, err := ioutil.ReadAll(response.Body)
+	require.NoError(t, err)
+
+	expectedResponseData := `
+	{
+		"from": "2020-11-01T20:00:00Z",
+		"to": "2020-11-02T02:00:00Z",
+		"zombie_drivers_num": 1,
+		"transitions": {"zombie": 1},
+		"zombie_seconds": {"42": 1800}
+	}`
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.JSONEq(t, expectedResponseData, string(responseBytes))
+	serviceMock.AssertExpectations(t)
+}
+
+func TestHTTP_GetZombieStats_InvalidPeriod(t *testing.T) {
+	t.Parallel()
+	ts, _ := setupHTTPServer()
+	defer ts.Close()
+
+	response, err := http.Get(ts.URL + "/stats/zombies?from=2020-11-02T02:00:00Z&to=2020-11-01T20:00:00Z")
+	require.NoError(t, err)
+	defer response.Body.Close()
+
+	assert.Equal(t, http.StatusBadRequest, response.StatusCode)
+}
+
 func setupHTTPServer() (*httptest.Server, *mocks.Service) {
 	logger := log.New()
 	logger.Level = log.ErrorLevel
 	serviceMock := &mocks.Service{}
-	hh := zombiedriver.MakeHTTPHandler(serviceMock, logger)
+	hh := zombiedriver.MakeHTTPHandler(serviceMock, 5*time.Second /* cacheMaxAge */, logger)
 	ts := httptest.NewServer(hh)
 	return ts, serviceMock
 }
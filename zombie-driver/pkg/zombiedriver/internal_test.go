@@ -0,0 +1,30 @@
+package zombiedriver
+
+import (
+	"time"
+)
+
+// SetTimeNowFn sets the time of the tracker and of its location windows.
+func (t *Tracker) SetTimeNowFn(fn func() time.Time) {
+	t.timeNowFn = fn
+	t.windows.timeNowFn = fn
+}
+
+func (c *LRUCache) SetTimeNowFn(fn func() time.Time) { c.timeNowFn = fn }
+
+func (s *ServiceImpl) SetTimeNowFn(fn func() time.Time) { s.timeNowFn = fn }
+
+func (lw *LocationWindows) SetTimeNowFn(fn func() time.Time) { lw.timeNowFn = fn }
+
+func (lw *LocationWindows) Retain(retention time.Duration) { lw.retain(retention) }
+
+func (lw *LocationWindows) SetStartedAt(startedAt time.Time) { lw.startedAt = startedAt }
+
+// DistanceDriven returns the precomputed distance and the number of locations of the driver window.
+func (lw *LocationWindows) DistanceDriven(driverID string, now time.Time) (int, int, bool) {
+	snapshot, ok := lw.snapshot(driverID, now)
+	if !ok {
+		return 0, 0, false
+	}
+	return snapshot.distanceDriven, snapshot.samplesNum, true
+}
@@ -7,6 +7,8 @@ import (
 
 	zombiedriver "github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
 	mock "github.com/stretchr/testify/mock"
+
+	time "time"
 )
 
 // Service is an autogenerated mock type for the Service type
@@ -14,6 +16,29 @@ type Service struct {
 	mock.Mock
 }
 
+// GetActiveCampaign provides a mock function with given fields: ctx
+func (_m *Service) GetActiveCampaign(ctx context.Context) (*zombiedriver.ActiveCampaign, error) {
+	ret := _m.Called(ctx)
+
+	var r0 *zombiedriver.ActiveCampaign
+	if rf, ok := ret.Get(0).(func(context.Context) *zombiedriver.ActiveCampaign); ok {
+		r0 = rf(ctx)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*zombiedriver.ActiveCampaign)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context) error); ok {
+		r1 = rf(ctx)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetDriver provides a mock function with given fields: ctx, driverID
 func (_m *Service) GetDriver(ctx context.Context, driverID string) (*zombiedriver.Driver, error) {
 	ret := _m.Called(ctx, driverID)
@@ -36,3 +61,26 @@ func (_m *Service) GetDriver(ctx context.Context, driverID string) (*zombiedrive
 
 	return r0, r1
 }
+
+// GetZombieStats provides a mock function with given fields: ctx, from, to
+func (_m *Service) GetZombieStats(ctx context.Context, from time.Time, to time.Time) (*zombiedriver.ZombieStats, error) {
+	ret := _m.Called(ctx, from, to)
+
+	var r0 *zombiedriver.ZombieStats
+	if rf, ok := ret.Get(0).(func(context.Context, time.Time, time.Time) *zombiedriver.ZombieStats); ok {
+		r0 = rf(ctx, from, to)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*zombiedriver.ZombieStats)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, time.Time, time.Time) error); ok {
+		r1 = rf(ctx, from, to)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
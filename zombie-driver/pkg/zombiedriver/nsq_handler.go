@@ -0,0 +1,81 @@
+package zombiedriver
+
+import (
+	"time"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/platform/envelope"
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/nsqio/go-nsq"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// NSQHandler consumes the drivers' location messages published by the gateway and feeds them to the tracker.
+// It also passes on the deletions of the driver locations, so the deleted history isn't kept in memory.
+type NSQHandler struct {
+	tracker LocationTracker
+	logger  log.FieldLogger
+}
+
+func NewNSQHandler(tracker LocationTracker, logger log.FieldLogger) *NSQHandler {
+	return &NSQHandler{tracker: tracker, logger: logger}
+}
+
+func (nh *NSQHandler) HandleMessage(m *nsq.Message) error {
+	ctxLogger := nh.logger.WithField("message_id", string(m.ID[:]))
+	ctxLogger.WithField("message_body", string(m.Body)).Info("Received a new message")
+
+	msg, err := envelope.Unmarshal(m.Body)
+	if err != nil {
+		ctxLogger.WithError(err).Info("Couldn't parse nsq message, finish processing")
+		return nil
+	}
+	ctxLogger = ctxLogger.WithField("command", msg.Command)
+	var locations []*envelope.DriverLocationPayload
+	switch msg.Command {
+	case envelope.CommandUpdateDriverLocations:
+		location := &envelope.DriverLocationPayload{}
+		err = msg.DecodePayload(location)
+		locations = append(locations, location)
+	case envelope.CommandBatchUpdateDriverLocations:
+		batch := &envelope.BatchDriverLocationsPayload{}
+		err = msg.DecodePayload(batch)
+		locations = batch.Locations
+	case envelope.CommandDeleteDriverLocations:
+		driver := &envelope.DriverPayload{}
+		if err := msg.DecodePayload(driver); err != nil {
+			ctxLogger.WithError(err).Info("NSQ request payload is invalid, finish processing")
+			return nil
+		}
+		if err := nh.tracker.DeleteLocations(*driver.DriverID); err != nil {
+			logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to delete driver locations"))
+			return errors.WithStack(err)
+		}
+		return nil
+	default:
+		ctxLogger.Info("NSQ request contains unsupported command")
+		return nil
+	}
+	if err != nil {
+		ctxLogger.WithError(err).Info("NSQ request payload is invalid, finish processing")
+		return nil
+	}
+
+	// Legacy messages aren't timestamped by the gateway, so the time they reached nsqd is used instead.
+	locationTime := msg.ProducedAt
+	if locationTime.IsZero() {
+		locationTime = time.Unix(0, m.Timestamp).UTC()
+	}
+	for _, payload := range locations {
+		location := &driverloc.Location{
+			Coordinates: &driverloc.Coordinates{Latitude: *payload.Latitude, Longitude: *payload.Longitude},
+			Time:        locationTime,
+		}
+		if err := nh.tracker.TrackLocation(*payload.DriverID, location); err != nil {
+			logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to track driver location"))
+			return errors.WithStack(err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package zombiedriver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/platform/envelope"
+	"github.com/nsqio/go-nsq"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver/mocks"
+)
+
+func TestNSQHandler_HandleMessage(t *testing.T) {
+	t.Parallel()
+	messageTime := time.Date(2020, 11, 07, 00, 00, 05, 00, time.UTC)
+	trackerMock := &mocks.LocationTracker{}
+	trackerMock.On("TrackLocation", defaultDriverID, &driverloc.Location{
+		Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+		Time:        messageTime,
+	}).Return(nil)
+	nsqHandler := newNSQHandler(trackerMock)
+
+	body := `
+	{
+		"command": "update-driver-locations",
+		"data": {
+			"id": "foo",
+			"latitude": 48.864193,
+			"longitude": 2.350498
+		}
+	}`
+	msg := nsq.NewMessage(nsq.MessageID{1, 2, 3, 4}, []byte(body))
+	msg.Timestamp = messageTime.UnixNano()
+	err := nsqHandler.HandleMessage(msg)
+	require.NoError(t, err)
+
+	trackerMock.AssertExpectations(t)
+}
+
+func TestNSQHandler_HandleMessage_Envelope(t *testing.T) {
+	t.Parallel()
+	trackerMock := &mocks.LocationTracker{}
+	nsqHandler := newNSQHandler(trackerMock)
+	driverID, latitude, longitude := defaultDriverID, 48.864193, 2.350498
+	msg, err := envelope.New(
+		envelope.CommandBatchUpdateDriverLocations,
+		&envelope.BatchDriverLocationsPayload{Locations: []*envelope.DriverLocationPayload{
+			{DriverID: &driverID, Latitude: &latitude, Longitude: &longitude},
+		}},
+		nil, /* trace */
+	)
+	require.NoError(t, err)
+	// The location time comes from the envelope rather than from nsqd.
+	trackerMock.On("TrackLocation", defaultDriverID, &driverloc.Location{
+		Coordinates: &driverloc.Coordinates{Latitude: 48.864193, Longitude: 2.350498},
+		Time:        msg.ProducedAt,
+	}).Return(nil)
+	body, err := envelope.Marshal(msg, envelope.EncodingProtobuf)
+	require.NoError(t, err)
+
+	err = nsqHandler.HandleMessage(nsq.NewMessage(nsq.MessageID{1, 2, 3, 4}, body))
+	require.NoError(t, err)
+
+	trackerMock.AssertExpectations(t)
+}
+
+func TestNSQHandler_HandleMessage_DeleteDriverLocations(t *testing.T) {
+	t.Parallel()
+	trackerMock := &mocks.LocationTracker{}
+	trackerMock.On("DeleteLocations", defaultDriverID).Return(nil)
+	nsqHandler := newNSQHandler(trackerMock)
+
+	body := `{"command": "delete-driver-locations", "data": {"driver_id": "foo"}}`
+	err := nsqHandler.HandleMessage(nsq.NewMessage(nsq.MessageID{1, 2, 3, 4}, []byte(body)))
+	require.NoError(t, err)
+
+	trackerMock.AssertExpectations(t)
+}
+
+func TestNSQHandler_HandleMessage_RequestError(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "body is not a json",
+			body: `foo`,
+		},
+		{
+			name: "unsupported command",
+			body: `{"command": "foo"}`,
+		},
+		{
+			name: "update driver locations latitude is missing",
+			body: `
+			{
+				"command": "update-driver-locations",
+				"data": {
+					"id": "foo",
+					"longitude": 2.350498
+				}
+			}`,
+		},
+		{
+			name: "delete driver locations driver id is missing",
+			body: `{"command": "delete-driver-locations", "data": {}}`,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			trackerMock := &mocks.LocationTracker{}
+			trackerMock.On("TrackLocation", mock.Anything /* driverID */, mock.Anything /* location */).Return(nil)
+
+			nsqHandler := newNSQHandler(trackerMock)
+			msg := nsq.NewMessage(nsq.MessageID{1, 2, 3, 4}, []byte(tc.body))
+			err := nsqHandler.HandleMessage(msg)
+			require.NoError(t, err)
+
+			trackerMock.AssertNumberOfCalls(t, "TrackLocation", 0)
+			trackerMock.AssertNumberOfCalls(t, "DeleteLocations", 0)
+		})
+	}
+}
+
+func newNSQHandler(trackerMock *mocks.LocationTracker) *zombiedriver.NSQHandler {
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	return zombiedriver.NewNSQHandler(trackerMock, logger)
+}
@@ -0,0 +1,190 @@
+package zombiedriver
+
+import (
+	"context"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Override states.
+const (
+	OverrideZombie = "zombie"
+	OverrideHuman  = "human"
+)
+
+// Override audit actions.
+const (
+	OverrideActionSet   = "set"
+	OverrideActionClear = "clear"
+)
+
+// Override forces the driver state regardless of the predicate, e.g. after a driver complaint.
+type Override struct {
+	DriverID string `json:"driver_id"`
+	State    string `json:"state"`
+	Reason   string `json:"reason"`
+	// Author is asserted by the admin API client, all clients share the admin token, so it isn't verified.
+	Author    string     `json:"author"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"` // Nil if the override never expires.
+}
+
+func (o *Override) Validate() error {
+	if o.DriverID == "" {
+		return errors.New("driver id must be set")
+	}
+	if o.State != OverrideZombie && o.State != OverrideHuman {
+		return errors.Errorf("state must be %q or %q, got %q", OverrideZombie, OverrideHuman, o.State)
+	}
+	if o.Reason == "" {
+		return errors.New("reason must be set")
+	}
+	if o.Author == "" {
+		return errors.New("author must be set")
+	}
+	if o.ExpiresAt != nil && !o.ExpiresAt.After(o.CreatedAt) {
+		return errors.New("expiry must be in the future")
+	}
+	return nil
+}
+
+func (o *Override) isExpired(now time.Time) bool {
+	return o.ExpiresAt != nil && !now.Before(*o.ExpiresAt)
+}
+
+// OverrideAuditEntry records a change of a driver override.
+type OverrideAuditEntry struct {
+	Action   string `json:"action"`
+	DriverID string `json:"driver_id"`
+	// Override is the new override for the set action.
+	Override *Override `json:"override,omitempty"`
+	// Author and Reason of the clear action, the set action has them in the override.
+	Author     string    `json:"author,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// OverrideAuditQuery selects a page of the audit log entries, oldest first.
+type OverrideAuditQuery struct {
+	// DriverID selects the entries of the driver, the entries of all drivers if it's empty.
+	DriverID string
+	// Offset is the number of the selected entries to skip.
+	Offset int
+	Limit  int
+}
+
+// OverrideStore keeps the driver overrides and the audit log of their changes.
+type OverrideStore interface {
+	// Get returns nil without an error if the driver has no override.
+	// Expired overrides may be returned, the caller must check the expiry.
+	Get(ctx context.Context, driverID string) (*Override, error)
+	// GetMany returns the overrides of the drivers by their ids in one go, the drivers without one are left out.
+	// Expired overrides may be returned too.
+	GetMany(ctx context.Context, driverIDs []string) (map[string]*Override, error)
+	Set(ctx context.Context, override *Override) error
+	// Delete returns false if the driver had no override.
+	Delete(ctx context.Context, driverID string) (bool, error)
+	AppendAudit(ctx context.Context, entry *OverrideAuditEntry) error
+	// Audit returns the page of the audit log entries selected by the query.
+	Audit(ctx context.Context, query *OverrideAuditQuery) ([]*OverrideAuditEntry, error)
+}
+
+// NopOverrideStore is used when overrides are disabled, it never has any.
+type NopOverrideStore struct{}
+
+func (NopOverrideStore) Get(_ context.Context, _ string) (*Override, error) { return nil, nil }
+
+func (NopOverrideStore) GetMany(_ context.Context, _ []string) (map[string]*Override, error) {
+	return map[string]*Override{}, nil
+}
+
+func (NopOverrideStore) Set(_ context.Context, _ *Override) error {
+	return errors.New("overrides are disabled")
+}
+
+func (NopOverrideStore) Delete(_ context.Context, _ string) (bool, error) { return false, nil }
+
+func (NopOverrideStore) AppendAudit(_ context.Context, _ *OverrideAuditEntry) error { return nil }
+
+func (NopOverrideStore) Audit(_ context.Context, _ *OverrideAuditQuery) ([]*OverrideAuditEntry, error) {
+	return nil, nil
+}
+
+// OverrideService manages driver overrides for the admin API.
+type OverrideService interface {
+	// GetOverride returns nil without an error if the driver has no active override.
+	GetOverride(ctx context.Context, driverID string) (*Override, error)
+	// SetOverride replaces the driver override, CreatedAt is set by the service.
+	SetOverride(ctx context.Context, override *Override) error
+	// ClearOverride returns false if the driver had no override.
+	ClearOverride(ctx context.Context, driverID, author, reason string) (bool, error)
+	GetOverrideAudit(ctx context.Context, query *OverrideAuditQuery) ([]*OverrideAuditEntry, error)
+}
+
+//go:generate mockery --name OverrideService
+
+// ErrInvalidOverride is returned for overrides rejected by the validation.
+var ErrInvalidOverride = errors.New("invalid override")
+
+func (s *ServiceImpl) GetOverride(ctx context.Context, driverID string) (*Override, error) {
+	override, err := s.overrides.Get(ctx, driverID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get override from the store")
+	}
+	if override == nil || override.isExpired(s.timeNowFn()) {
+		return nil, nil
+	}
+	return override, nil
+}
+
+func (s *ServiceImpl) SetOverride(ctx context.Context, override *Override) error {
+	override.CreatedAt = s.timeNowFn().UTC()
+	if err := override.Validate(); err != nil {
+		return errors.Wrap(ErrInvalidOverride, err.Error())
+	}
+	if err := s.overrides.Set(ctx, override); err != nil {
+		return errors.Wrap(err, "failed to put override into the store")
+	}
+	s.logger.WithFields(log.Fields{
+		"driver_id":  override.DriverID,
+		"state":      override.State,
+		"expires_at": override.ExpiresAt,
+	}).Info("Driver override is set")
+	entry := &OverrideAuditEntry{
+		Action:     OverrideActionSet,
+		DriverID:   override.DriverID,
+		Override:   override,
+		OccurredAt: override.CreatedAt,
+	}
+	return errors.Wrap(s.overrides.AppendAudit(ctx, entry), "failed to append override audit entry")
+}
+
+func (s *ServiceImpl) ClearOverride(ctx context.Context, driverID, author, reason string) (bool, error) {
+	if author == "" || reason == "" {
+		return false, errors.Wrap(ErrInvalidOverride, "author and reason must be set")
+	}
+	deleted, err := s.overrides.Delete(ctx, driverID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete override from the store")
+	}
+	if !deleted {
+		return false, nil
+	}
+	s.logger.WithField("driver_id", driverID).Info("Driver override is cleared")
+	entry := &OverrideAuditEntry{
+		Action:     OverrideActionClear,
+		DriverID:   driverID,
+		Author:     author,
+		Reason:     reason,
+		OccurredAt: s.timeNowFn().UTC(),
+	}
+	return true, errors.Wrap(s.overrides.AppendAudit(ctx, entry), "failed to append override audit entry")
+}
+
+func (s *ServiceImpl) GetOverrideAudit(ctx context.Context, query *OverrideAuditQuery) (
+	[]*OverrideAuditEntry, error) {
+	entries, err := s.overrides.Audit(ctx, query)
+	return entries, errors.Wrap(err, "failed to get override audit from the store")
+}
@@ -0,0 +1,327 @@
+package zombiedriver
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/pkg/errors"
+)
+
+const (
+	redisOverrideKeyPrefix = "zombie-driver:override:"
+	redisOverrideAuditKey  = "zombie-driver:override-audit"
+
+	// redisOverridesChunkSize is the number of the overrides got with a single MGET.
+	redisOverridesChunkSize = 1000
+	// redisAuditScanSize is the number of the audit log entries read at once when they are filtered by the driver.
+	redisAuditScanSize = 1000
+
+	overridesFileName     = "overrides.json"
+	overrideAuditFileName = "overrides-audit.jsonl"
+)
+
+// RedisOverrideStore shares overrides between all service replicas, Redis expires them at their expiry.
+// The audit log is a Redis list capped at the latest auditMaxLen entries.
+type RedisOverrideStore struct {
+	redis       *redis.Client
+	auditMaxLen int64
+}
+
+func NewRedisOverrideStore(r *redis.Client, auditMaxLen int64) *RedisOverrideStore {
+	return &RedisOverrideStore{redis: r, auditMaxLen: auditMaxLen}
+}
+
+func (s *RedisOverrideStore) Get(ctx context.Context, driverID string) (*Override, error) {
+	data, err := s.redis.Get(ctx, redisOverrideKeyPrefix+driverID).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get override from Redis")
+	}
+	override := &Override{}
+	if err := json.Unmarshal(data, override); err != nil {
+		return nil, errors.Wrapf(err, "can't decode override data %s", data)
+	}
+	return override, nil
+}
+
+func (s *RedisOverrideStore) GetMany(ctx context.Context, driverIDs []string) (map[string]*Override, error) {
+	overrides := make(map[string]*Override)
+	for start := 0; start < len(driverIDs); start += redisOverridesChunkSize {
+		chunk := driverIDs[start:]
+		if len(chunk) > redisOverridesChunkSize {
+			chunk = chunk[:redisOverridesChunkSize]
+		}
+		keys := make([]string, len(chunk))
+		for i, driverID := range chunk {
+			keys[i] = redisOverrideKeyPrefix + driverID
+		}
+		values, err := s.redis.MGet(ctx, keys...).Result()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get overrides from Redis")
+		}
+		for i, value := range values {
+			data, ok := value.(string)
+			if !ok {
+				continue // The driver has no override.
+			}
+			override := &Override{}
+			if err := json.Unmarshal([]byte(data), override); err != nil {
+				return nil, errors.Wrapf(err, "can't decode override data %s", data)
+			}
+			overrides[chunk[i]] = override
+		}
+	}
+	return overrides, nil
+}
+
+func (s *RedisOverrideStore) Set(ctx context.Context, override *Override) error {
+	data, err := json.Marshal(override)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode override into json")
+	}
+	var ttl time.Duration // Zero means the key never expires.
+	if override.ExpiresAt != nil {
+		ttl = override.ExpiresAt.Sub(override.CreatedAt)
+	}
+	err = s.redis.Set(ctx, redisOverrideKeyPrefix+override.DriverID, data, ttl).Err()
+	return errors.Wrap(err, "failed to put override into Redis")
+}
+
+func (s *RedisOverrideStore) Delete(ctx context.Context, driverID string) (bool, error) {
+	deleted, err := s.redis.Del(ctx, redisOverrideKeyPrefix+driverID).Result()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete override from Redis")
+	}
+	return deleted > 0, nil
+}
+
+func (s *RedisOverrideStore) AppendAudit(ctx context.Context, entry *OverrideAuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode override audit entry into json")
+	}
+	_, err = s.redis.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.RPush(ctx, redisOverrideAuditKey, data)
+		pipe.LTrim(ctx, redisOverrideAuditKey, -s.auditMaxLen, -1)
+		return nil
+	})
+	return errors.Wrap(err, "failed to append override audit entry to Redis")
+}
+
+// Audit reads only the page when the entries of all drivers are selected,
+// the entries of a driver are filtered from the list read in chunks until the page is full.
+func (s *RedisOverrideStore) Audit(ctx context.Context, query *OverrideAuditQuery) ([]*OverrideAuditEntry, error) {
+	page := newAuditPage(query, query.Offset)
+	start, scanSize := int64(0), int64(redisAuditScanSize)
+	if query.DriverID == "" {
+		page = newAuditPage(query, 0 /* skip */)
+		start, scanSize = int64(query.Offset), int64(query.Limit)
+	}
+	for !page.isFull() {
+		items, err := s.redis.LRange(ctx, redisOverrideAuditKey, start, start+scanSize-1).Result()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get override audit from Redis")
+		}
+		for _, item := range items {
+			if err := page.add([]byte(item)); err != nil {
+				return nil, err
+			}
+		}
+		if int64(len(items)) < scanSize {
+			break
+		}
+		start += scanSize
+	}
+	return page.entries, nil
+}
+
+// auditPage collects the audit log entries selected by the query.
+type auditPage struct {
+	query *OverrideAuditQuery
+	// skip is the number of the selected entries still to skip, it's zero if the read starts at the offset.
+	skip    int
+	entries []*OverrideAuditEntry
+}
+
+func newAuditPage(query *OverrideAuditQuery, skip int) *auditPage {
+	return &auditPage{query: query, skip: skip}
+}
+
+func (ap *auditPage) isFull() bool {
+	return len(ap.entries) >= ap.query.Limit
+}
+
+// add decodes the entry and adds it to the page if it's selected and not skipped.
+func (ap *auditPage) add(data []byte) error {
+	if ap.isFull() {
+		return nil
+	}
+	entry := &OverrideAuditEntry{}
+	if err := json.Unmarshal(data, entry); err != nil {
+		return errors.Wrapf(err, "can't decode override audit entry %s", data)
+	}
+	if ap.query.DriverID != "" && entry.DriverID != ap.query.DriverID {
+		return nil
+	}
+	if ap.skip > 0 {
+		ap.skip--
+		return nil
+	}
+	ap.entries = append(ap.entries, entry)
+	return nil
+}
+
+// FileOverrideStore keeps overrides in a json file and the audit log in a json lines file in the directory.
+// It's meant for a single service replica, other replicas don't see its changes until they restart.
+type FileOverrideStore struct {
+	overridesPath string
+	auditPath     string
+
+	mu        sync.Mutex
+	overrides map[string]*Override
+}
+
+// NewFileOverrideStore loads the overrides from the directory, it's created if it doesn't exist yet.
+func NewFileOverrideStore(dir string) (*FileOverrideStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, errors.Wrap(err, "failed to create overrides directory")
+	}
+	s := &FileOverrideStore{
+		overridesPath: filepath.Join(dir, overridesFileName),
+		auditPath:     filepath.Join(dir, overrideAuditFileName),
+		overrides:     map[string]*Override{},
+	}
+	data, err := ioutil.ReadFile(s.overridesPath)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read overrides file")
+	}
+	if err := json.Unmarshal(data, &s.overrides); err != nil {
+		return nil, errors.Wrapf(err, "can't decode overrides file %s", s.overridesPath)
+	}
+	return s, nil
+}
+
+func (s *FileOverrideStore) Get(_ context.Context, driverID string) (*Override, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.overrides[driverID], nil
+}
+
+func (s *FileOverrideStore) GetMany(_ context.Context, driverIDs []string) (map[string]*Override, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	overrides := make(map[string]*Override)
+	for _, driverID := range driverIDs {
+		if override, ok := s.overrides[driverID]; ok {
+			overrides[driverID] = override
+		}
+	}
+	return overrides, nil
+}
+
+// Set also prunes the overrides expired by the time the override is created, so the file doesn't keep them forever.
+func (s *FileOverrideStore) Set(_ context.Context, override *Override) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	overrides := make(map[string]*Override, len(s.overrides)+1)
+	for driverID, existing := range s.overrides {
+		if !existing.isExpired(override.CreatedAt) {
+			overrides[driverID] = existing
+		}
+	}
+	overrides[override.DriverID] = override
+	if err := s.save(overrides); err != nil {
+		return err
+	}
+	s.overrides = overrides
+	return nil
+}
+
+func (s *FileOverrideStore) Delete(_ context.Context, driverID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	previous, existed := s.overrides[driverID]
+	if !existed {
+		return false, nil
+	}
+	delete(s.overrides, driverID)
+	if err := s.save(s.overrides); err != nil {
+		s.overrides[driverID] = previous
+		return false, err
+	}
+	return true, nil
+}
+
+// save replaces the overrides file via a temporary file, so a crash never leaves it half written.
+func (s *FileOverrideStore) save(overrides map[string]*Override) error {
+	data, err := json.MarshalIndent(overrides, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode overrides into json")
+	}
+	tmpFile, err := ioutil.TempFile(filepath.Dir(s.overridesPath), overridesFileName+".*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temporary overrides file")
+	}
+	// Removing fails harmlessly after the rename.
+	defer func() { _ = os.Remove(tmpFile.Name()) }()
+	if _, err := tmpFile.Write(data); err != nil {
+		_ = tmpFile.Close()
+		return errors.Wrap(err, "failed to write temporary overrides file")
+	}
+	if err := tmpFile.Close(); err != nil {
+		return errors.Wrap(err, "failed to close temporary overrides file")
+	}
+	return errors.Wrap(os.Rename(tmpFile.Name(), s.overridesPath), "failed to replace overrides file")
+}
+
+func (s *FileOverrideStore) AppendAudit(_ context.Context, entry *OverrideAuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode override audit entry into json")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.auditPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return errors.Wrap(err, "failed to open override audit file")
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "failed to append override audit entry")
+	}
+	return errors.Wrap(f.Close(), "failed to close override audit file")
+}
+
+// Audit reads the file until the page is full.
+func (s *FileOverrideStore) Audit(_ context.Context, query *OverrideAuditQuery) ([]*OverrideAuditEntry, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.Open(s.auditPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open override audit file")
+	}
+	defer func() { _ = f.Close() }() // Nothing is written, so closing can't lose data.
+	page := newAuditPage(query, query.Offset)
+	scanner := bufio.NewScanner(f)
+	for !page.isFull() && scanner.Scan() {
+		if err := page.add(scanner.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+	return page.entries, errors.Wrap(scanner.Err(), "failed to read override audit file")
+}
@@ -0,0 +1,205 @@
+package zombiedriver_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+)
+
+func TestRedisOverrideStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fakeRedis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer fakeRedis.Close()
+	store := zombiedriver.NewRedisOverrideStore(
+		redis.NewClient(&redis.Options{Addr: fakeRedis.Addr()}), 10, /* auditMaxLen */
+	)
+
+	actual, err := store.Get(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Nil(t, actual)
+
+	override := newOverride(time.Hour)
+	require.NoError(t, store.Set(ctx, override))
+	actual, err = store.Get(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Equal(t, override, actual)
+	many, err := store.GetMany(ctx, []string{defaultDriverID, "bar"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*zombiedriver.Override{defaultDriverID: override}, many)
+
+	fakeRedis.FastForward(time.Hour)
+	actual, err = store.Get(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Nil(t, actual)
+
+	require.NoError(t, store.Set(ctx, newOverride(0)))
+	deleted, err := store.Delete(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.True(t, deleted)
+	deleted, err = store.Delete(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.False(t, deleted)
+
+	testOverrideAudit(t, store)
+}
+
+func TestRedisOverrideStore_AuditIsCapped(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	fakeRedis, err := miniredis.Run()
+	require.NoError(t, err)
+	defer fakeRedis.Close()
+	store := zombiedriver.NewRedisOverrideStore(
+		redis.NewClient(&redis.Options{Addr: fakeRedis.Addr()}), 2, /* auditMaxLen */
+	)
+	occurredAt := time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC)
+	var appended []*zombiedriver.OverrideAuditEntry
+	for i := 0; i < 3; i++ {
+		entry := &zombiedriver.OverrideAuditEntry{
+			Action: zombiedriver.OverrideActionClear, DriverID: defaultDriverID, Author: "bob", Reason: "resolved",
+			OccurredAt: occurredAt.Add(time.Duration(i) * time.Minute),
+		}
+		require.NoError(t, store.AppendAudit(ctx, entry))
+		appended = append(appended, entry)
+	}
+
+	entries, err := store.Audit(ctx, &zombiedriver.OverrideAuditQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Equal(t, appended[1:], entries)
+}
+
+func TestFileOverrideStore(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	dir, err := ioutil.TempDir("", "overrides")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	store, err := zombiedriver.NewFileOverrideStore(dir)
+	require.NoError(t, err)
+
+	actual, err := store.Get(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Nil(t, actual)
+
+	override := newOverride(time.Hour)
+	require.NoError(t, store.Set(ctx, override))
+	require.NoError(t, store.Set(ctx, &zombiedriver.Override{
+		DriverID: "bar", State: zombiedriver.OverrideZombie, Reason: "promo partner", Author: "bob",
+		CreatedAt: override.CreatedAt,
+	}))
+	many, err := store.GetMany(ctx, []string{defaultDriverID, "baz"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]*zombiedriver.Override{defaultDriverID: override}, many)
+	deleted, err := store.Delete(ctx, "bar")
+	require.NoError(t, err)
+	assert.True(t, deleted)
+
+	// Overrides survive restarts.
+	store, err = zombiedriver.NewFileOverrideStore(dir)
+	require.NoError(t, err)
+	actual, err = store.Get(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Equal(t, override, actual)
+	actual, err = store.Get(ctx, "bar")
+	require.NoError(t, err)
+	assert.Nil(t, actual)
+
+	// Setting an override prunes the expired ones.
+	require.NoError(t, store.Set(ctx, &zombiedriver.Override{
+		DriverID: "bar", State: zombiedriver.OverrideZombie, Reason: "promo partner", Author: "bob",
+		CreatedAt: *override.ExpiresAt,
+	}))
+	store, err = zombiedriver.NewFileOverrideStore(dir)
+	require.NoError(t, err)
+	actual, err = store.Get(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Nil(t, actual)
+
+	testOverrideAudit(t, store)
+}
+
+func testOverrideAudit(t *testing.T, store zombiedriver.OverrideStore) {
+	ctx := context.Background()
+	entries, err := store.Audit(ctx, &zombiedriver.OverrideAuditQuery{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	occurredAt := time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC)
+	setEntry := &zombiedriver.OverrideAuditEntry{
+		Action: zombiedriver.OverrideActionSet, DriverID: defaultDriverID, Override: newOverride(0), OccurredAt: occurredAt,
+	}
+	otherEntry := &zombiedriver.OverrideAuditEntry{
+		Action: zombiedriver.OverrideActionClear, DriverID: "bar", Author: "bob", Reason: "expired deal",
+		OccurredAt: occurredAt.Add(time.Minute),
+	}
+	clearEntry := &zombiedriver.OverrideAuditEntry{
+		Action: zombiedriver.OverrideActionClear, DriverID: defaultDriverID, Author: "alice", Reason: "resolved",
+		OccurredAt: occurredAt.Add(time.Hour),
+	}
+	for _, entry := range []*zombiedriver.OverrideAuditEntry{setEntry, otherEntry, clearEntry} {
+		require.NoError(t, store.AppendAudit(ctx, entry))
+	}
+
+	cases := []struct {
+		name     string
+		query    *zombiedriver.OverrideAuditQuery
+		expected []*zombiedriver.OverrideAuditEntry
+	}{
+		{
+			name:     "all drivers",
+			query:    &zombiedriver.OverrideAuditQuery{Limit: 10},
+			expected: []*zombiedriver.OverrideAuditEntry{setEntry, otherEntry, clearEntry},
+		},
+		{
+			name:     "all drivers page",
+			query:    &zombiedriver.OverrideAuditQuery{Offset: 1, Limit: 1},
+			expected: []*zombiedriver.OverrideAuditEntry{otherEntry},
+		},
+		{
+			name:     "driver",
+			query:    &zombiedriver.OverrideAuditQuery{DriverID: defaultDriverID, Limit: 10},
+			expected: []*zombiedriver.OverrideAuditEntry{setEntry, clearEntry},
+		},
+		{
+			name:     "driver page",
+			query:    &zombiedriver.OverrideAuditQuery{DriverID: defaultDriverID, Offset: 1, Limit: 1},
+			expected: []*zombiedriver.OverrideAuditEntry{clearEntry},
+		},
+		{
+			name:  "offset past the end",
+			query: &zombiedriver.OverrideAuditQuery{Offset: 3, Limit: 10},
+		},
+	}
+	for _, tc := range cases {
+		entries, err = store.Audit(ctx, tc.query)
+		require.NoError(t, err, tc.name)
+		assert.Equal(t, tc.expected, entries, tc.name)
+	}
+}
+
+// newOverride returns a human override of the default driver, a zero ttl means it never expires.
+func newOverride(ttl time.Duration) *zombiedriver.Override {
+	override := &zombiedriver.Override{
+		DriverID:  defaultDriverID,
+		State:     zombiedriver.OverrideHuman,
+		Reason:    "driver complaint",
+		Author:    "alice",
+		CreatedAt: time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC),
+	}
+	if ttl > 0 {
+		expiresAt := override.CreatedAt.Add(ttl)
+		override.ExpiresAt = &expiresAt
+	}
+	return override
+}
@@ -0,0 +1,118 @@
+package zombiedriver
+
+import (
+	"math"
+	"time"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/pkg/errors"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/distance"
+)
+
+// Zombie predicate results.
+const (
+	PredicateResultZombie = "zombie"
+	PredicateResultAlive  = "alive"
+	// PredicateResultInsufficientData means there are too few locations to judge the driver,
+	// e.g. their app has just reconnected.
+	PredicateResultInsufficientData = "insufficient_data"
+)
+
+type ZombiePredicate struct {
+	DistanceThreshold int           `yaml:"distance_threshold" default:"500" validate:"min=1"` // In meters.
+	TimeInterval      time.Duration `yaml:"time_interval" default:"5m" validate:"min=1"`
+	// MinSamples is the number of locations within the time interval required to judge the driver.
+	MinSamples int `yaml:"min_samples" default:"2" validate:"min=0"`
+	// MinWindowCoverage is the part of the time interval the locations must span:
+	// the first location must be at least MinWindowCoverage * TimeInterval old.
+	MinWindowCoverage float64 `yaml:"min_window_coverage" default:"0.8" validate:"min=0,max=1"`
+	// InsufficientDataFallback is the result reported instead of insufficient data,
+	// keep it "insufficient_data" to expose the result as is.
+	InsufficientDataFallback string `yaml:"insufficient_data_fallback" default:"insufficient_data"`
+	// DistanceAlgorithm is "haversine", "vincenty" or "equirectangular", see the distance package.
+	DistanceAlgorithm string `yaml:"distance_algorithm" default:"haversine"`
+}
+
+func (zp *ZombiePredicate) Validate() error {
+	// The locations of the time interval are fetched from the driver-location service.
+	if zp.TimeInterval > driverloc.MaxTimeWindow {
+		return errors.Errorf("time_interval must not exceed %s", driverloc.MaxTimeWindow)
+	}
+	if _, err := distance.ByName(zp.DistanceAlgorithm); err != nil {
+		return errors.WithStack(err)
+	}
+	switch zp.InsufficientDataFallback {
+	case PredicateResultZombie, PredicateResultAlive, PredicateResultInsufficientData:
+		return nil
+	default:
+		return errors.Errorf("unknown insufficient_data_fallback %q, must be %q, %q or %q",
+			zp.InsufficientDataFallback, PredicateResultZombie, PredicateResultAlive, PredicateResultInsufficientData)
+	}
+}
+
+func (zp *ZombiePredicate) IsZombie(distanceDriven int) bool {
+	return distanceDriven < zp.DistanceThreshold
+}
+
+// Evaluate judges the driver by their locations ordered by time, now is the end of the time interval.
+// It returns the predicate result with the fallback applied and the distance driven in meters.
+func (zp *ZombiePredicate) Evaluate(locations []*driverloc.Location, now time.Time) (string, int) {
+	distanceDriven := calculateDistanceDriven(zp.calculator(), locations)
+	var firstTime time.Time
+	if len(locations) > 0 {
+		firstTime = locations[0].Time
+	}
+	return zp.judge(distanceDriven, len(locations), firstTime, now), distanceDriven
+}
+
+// judge returns the predicate result with the fallback applied by the distance driven within the time interval
+// and the number of locations there, the first of them at firstTime.
+func (zp *ZombiePredicate) judge(distanceDriven, samplesNum int, firstTime, now time.Time) string {
+	if !zp.hasSufficientData(samplesNum, firstTime, now) {
+		if zp.InsufficientDataFallback == "" {
+			return PredicateResultInsufficientData
+		}
+		return zp.InsufficientDataFallback
+	}
+	if zp.IsZombie(distanceDriven) {
+		return PredicateResultZombie
+	}
+	return PredicateResultAlive
+}
+
+func (zp *ZombiePredicate) calculator() distance.Calculator {
+	calculator, err := distance.ByName(zp.DistanceAlgorithm)
+	if err != nil {
+		// The algorithm is checked on the config validation.
+		return distance.Haversine{}
+	}
+	return calculator
+}
+
+func (zp *ZombiePredicate) hasSufficientData(samplesNum int, firstTime, now time.Time) bool {
+	if samplesNum < zp.MinSamples {
+		return false
+	}
+	if zp.MinWindowCoverage <= 0 {
+		return true
+	}
+	if samplesNum == 0 {
+		return false
+	}
+	minCoverage := time.Duration(float64(zp.TimeInterval) * zp.MinWindowCoverage)
+	return !firstTime.After(now.Add(-minCoverage))
+}
+
+func calculateDistanceDriven(calculator distance.Calculator, locations []*driverloc.Location) int {
+	if len(locations) <= 1 {
+		return 0
+	}
+	var distanceDriven float64
+	for i := 0; i < len(locations)-1; i++ {
+		start, stop := locations[i], locations[i+1]
+		locationsDistance := calculator.Calculate(start.Latitude, start.Longitude, stop.Latitude, stop.Longitude)
+		distanceDriven += locationsDistance
+	}
+	return int(math.Round(distanceDriven))
+}
@@ -0,0 +1,127 @@
+package zombiedriver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+)
+
+func TestZombiePredicate_Evaluate(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2020, 11, 07, 00, 05, 00, 00, time.UTC)
+	newLocations := func(ages ...time.Duration) []*driverloc.Location {
+		locations := make([]*driverloc.Location, len(ages))
+		for i, age := range ages {
+			// Each hop is ~133 meters.
+			locations[i] = &driverloc.Location{
+				Coordinates: &driverloc.Coordinates{
+					Latitude:  48.864193 - float64(i)*0.001,
+					Longitude: 2.350498 + float64(i)*0.001,
+				},
+				Time: now.Add(-age),
+			}
+		}
+		return locations
+	}
+
+	cases := []struct {
+		name             string
+		locations        []*driverloc.Location
+		fallback         string
+		expectedResult   string
+		expectedDistance int
+	}{
+		{
+			name:           "no locations",
+			locations:      nil,
+			expectedResult: zombiedriver.PredicateResultInsufficientData,
+		},
+		{
+			name:           "single location",
+			locations:      newLocations(5 * time.Minute),
+			expectedResult: zombiedriver.PredicateResultInsufficientData,
+		},
+		{
+			name:             "window isn't covered",
+			locations:        newLocations(3*time.Minute, 2*time.Minute, time.Minute),
+			expectedResult:   zombiedriver.PredicateResultInsufficientData,
+			expectedDistance: 266,
+		},
+		{
+			name:             "insufficient data falls back to zombie",
+			locations:        newLocations(3*time.Minute, 2*time.Minute, time.Minute),
+			fallback:         zombiedriver.PredicateResultZombie,
+			expectedResult:   zombiedriver.PredicateResultZombie,
+			expectedDistance: 266,
+		},
+		{
+			name:             "insufficient data falls back to alive",
+			locations:        newLocations(3*time.Minute, 2*time.Minute, time.Minute),
+			fallback:         zombiedriver.PredicateResultAlive,
+			expectedResult:   zombiedriver.PredicateResultAlive,
+			expectedDistance: 266,
+		},
+		{
+			name:             "zombie",
+			locations:        newLocations(4*time.Minute, 2*time.Minute, time.Minute),
+			expectedResult:   zombiedriver.PredicateResultZombie,
+			expectedDistance: 266,
+		},
+		{
+			name:             "alive",
+			locations:        newLocations(5*time.Minute, 4*time.Minute, 3*time.Minute, 2*time.Minute, time.Minute),
+			expectedResult:   zombiedriver.PredicateResultAlive,
+			expectedDistance: 532,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			predicate := &zombiedriver.ZombiePredicate{
+				DistanceThreshold:        500,
+				TimeInterval:             5 * time.Minute,
+				MinSamples:               2,
+				MinWindowCoverage:        0.8,
+				InsufficientDataFallback: zombiedriver.PredicateResultInsufficientData,
+			}
+			if tc.fallback != "" {
+				predicate.InsufficientDataFallback = tc.fallback
+			}
+
+			result, distanceDriven := predicate.Evaluate(tc.locations, now)
+
+			assert.Equal(t, tc.expectedResult, result)
+			assert.Equal(t, tc.expectedDistance, distanceDriven)
+		})
+	}
+}
+
+func TestZombiePredicate_Validate(t *testing.T) {
+	t.Parallel()
+	predicate := &zombiedriver.ZombiePredicate{InsufficientDataFallback: zombiedriver.PredicateResultAlive}
+	require.NoError(t, predicate.Validate())
+
+	predicate.InsufficientDataFallback = "human"
+	assert.EqualError(t, predicate.Validate(),
+		`unknown insufficient_data_fallback "human", must be "zombie", "alive" or "insufficient_data"`)
+
+	predicate = &zombiedriver.ZombiePredicate{
+		InsufficientDataFallback: zombiedriver.PredicateResultAlive,
+		DistanceAlgorithm:        "manhattan",
+	}
+	assert.EqualError(t, predicate.Validate(),
+		`unknown distance algorithm "manhattan", must be "haversine", "vincenty" or "equirectangular"`)
+
+	// The driver-location service doesn't serve longer intervals.
+	predicate = &zombiedriver.ZombiePredicate{
+		InsufficientDataFallback: zombiedriver.PredicateResultAlive,
+		TimeInterval:             25 * time.Hour,
+	}
+	assert.EqualError(t, predicate.Validate(), "time_interval must not exceed 24h0m0s")
+}
@@ -2,74 +2,248 @@ package zombiedriver
 
 import (
 	"context"
-	"math"
 	"time"
 
 	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/platform/logging"
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
-
-	"github.com/georgysavva/driver-app/zombie-driver/pkg/distance"
+	"golang.org/x/sync/singleflight"
 )
 
 type Service interface {
 	GetDriver(ctx context.Context, driverID string) (*Driver, error)
+	// GetActiveCampaign returns the campaign running now or nil if there is none.
+	GetActiveCampaign(ctx context.Context) (*ActiveCampaign, error)
+	// GetZombieStats returns the statistics of the status history over the period or nil if history is disabled.
+	GetZombieStats(ctx context.Context, from, to time.Time) (*ZombieStats, error)
 }
 
 //go:generate mockery --name Service
 
-type ZombiePredicate struct {
-	DistanceThreshold int           `yaml:"distance_threshold"` // In meters.
-	TimeInterval      time.Duration `yaml:"time_interval"`
-}
+// computeTimeout bounds a driver computation shared by concurrent lookups.
+const computeTimeout = 10 * time.Second
 
 type ServiceImpl struct {
-	driverloc driverloc.GetterService
-	logger    log.FieldLogger
-	predicate *ZombiePredicate
+	driverloc  driverloc.GetterService
+	cache      DriverCache
+	logger     log.FieldLogger
+	predicate  *ZombiePredicate
+	zones      *ZoneSet
+	campaigns  *CampaignSchedule
+	experiment *Experiment
+	overrides  OverrideStore
+	history    *HistoryRecorder
+	windows    *LocationWindows
+	timeNowFn  func() time.Time
+	inflight   singleflight.Group
 }
 
-func NewService(dl driverloc.GetterService, logger log.FieldLogger, predicate *ZombiePredicate,
+// NewService returns the service judging drivers by the predicate of their experiment arm or zone
+// during the active campaign, unless support has overridden their state. Zones, campaigns and experiment
+// can be nil to use the default predicate everywhere, all the time and for all drivers,
+// history can be nil to not record the status transitions. Windows can be nil to always judge drivers
+// by the locations fetched from the driver-location service, otherwise they are only fetched
+// when the windows can't judge the driver.
+func NewService(
+	dl driverloc.GetterService, cache DriverCache, logger log.FieldLogger, predicate *ZombiePredicate,
+	zones *ZoneSet, campaigns *CampaignSchedule, experiment *Experiment, overrides OverrideStore,
+	history *HistoryRecorder, windows *LocationWindows,
 ) *ServiceImpl {
 	return &ServiceImpl{
-		driverloc: dl,
-		logger:    logger,
-		predicate: predicate,
+		driverloc:  dl,
+		cache:      cache,
+		logger:     logger,
+		predicate:  predicate,
+		zones:      zones,
+		campaigns:  campaigns,
+		experiment: experiment,
+		overrides:  overrides,
+		history:    history,
+		windows:    windows,
+		timeNowFn:  time.Now,
 	}
 }
 
+func (s *ServiceImpl) GetActiveCampaign(_ context.Context) (*ActiveCampaign, error) {
+	return s.campaigns.Active(s.timeNowFn().UTC(), s.predicate), nil
+}
+
+func (s *ServiceImpl) GetZombieStats(ctx context.Context, from, to time.Time) (*ZombieStats, error) {
+	stats, err := s.history.Stats(ctx, from, to)
+	return stats, errors.WithStack(err)
+}
+
+// GetDriver returns the overridden driver if support has forced their state. Otherwise it returns
+// the cached driver if it's still fresh, or computes the driver.
+// Concurrent lookups of the same driver share a single computation.
 func (s *ServiceImpl) GetDriver(ctx context.Context, driverID string) (*Driver, error) {
+	ctxLogger := s.logger.WithField("driver_id", driverID)
+	override, err := s.GetOverride(ctx, driverID)
+	if err != nil {
+		// Overrides are rare, so the driver is rather judged by the predicate than the request fails.
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to get driver override"))
+	}
+	if override != nil {
+		ctxLogger.WithField("state", override.State).Info("Return overridden driver")
+		driver := overriddenDriver(override)
+		s.history.Observe(ctx, driver, 0 /* distanceDriven */, s.timeNowFn().UTC())
+		return driver, nil
+	}
+
+	driver, err := s.cache.Get(ctx, driverID)
+	if err != nil {
+		// The cache is an optimization, so its failures don't fail the request.
+		logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to get driver from the cache"))
+	}
+	if driver != nil {
+		ctxLogger.Info("Return driver from the cache")
+		return driver, nil
+	}
+
+	resultCh := s.inflight.DoChan(driverID, func() (interface{}, error) {
+		// The computation is shared, so it isn't bound to the request that happened to start it.
+		computeCtx, cancel := context.WithTimeout(context.Background(), computeTimeout)
+		defer cancel()
+		driver, err := s.computeDriver(computeCtx, driverID)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.cache.Set(computeCtx, driver); err != nil {
+			logging.LogUnhandledError(ctxLogger, errors.Wrap(err, "failed to put driver into the cache"))
+		}
+		return driver, nil
+	})
+	select {
+	case result := <-resultCh:
+		if result.Err != nil {
+			return nil, errors.WithStack(result.Err)
+		}
+		if result.Shared {
+			ctxLogger.Info("Driver computation was shared with concurrent requests")
+		}
+		return result.Val.(*Driver), nil
+	case <-ctx.Done():
+		return nil, errors.WithStack(ctx.Err())
+	}
+}
+
+func overriddenDriver(override *Override) *Driver {
+	driver := &Driver{ID: override.DriverID, State: DriverStateAlive, Overridden: true}
+	if override.State == OverrideZombie {
+		driver.IsZombie = true
+		driver.State = DriverStateZombie
+	}
+	return driver
+}
+
+func (s *ServiceImpl) computeDriver(ctx context.Context, driverID string) (*Driver, error) {
+	arm := s.experiment.Assign(driverID)
+	driver, distanceDriven, err := s.judgeDriver(ctx, driverID, arm)
+	if err != nil {
+		return nil, err
+	}
+	if arm != nil {
+		driver.Arm = arm.Name
+	}
+	s.history.Observe(ctx, driver, distanceDriven, s.timeNowFn().UTC())
+	return driver, nil
+}
+
+// judgeDriver returns the driver and the distance they have driven, which is zero unless the predicate was evaluated.
+func (s *ServiceImpl) judgeDriver(ctx context.Context, driverID string, arm *AssignedArm) (*Driver, int, error) {
+	if arm != nil && arm.Control {
+		s.logger.WithFields(log.Fields{
+			"driver_id": driverID,
+			"arm":       arm.Name,
+		}).Info("Driver is in the control arm, they aren't shown as a zombie")
+		return &Driver{ID: driverID, State: DriverStateControl}, 0, nil
+	}
+	now := s.timeNowFn().UTC()
+	campaign := s.campaigns.Active(now, s.predicate)
+	if campaign == nil {
+		s.logger.WithField("driver_id", driverID).Info("No campaign is active, the driver isn't a zombie")
+		return &Driver{ID: driverID, State: DriverStateOutsideCampaign}, 0, nil
+	}
+	if driver, distanceDriven, ok := s.judgeDriverByWindow(driverID, campaign.Predicate, arm, now); ok {
+		return driver, distanceDriven, nil
+	}
+	timeInterval := s.zones.MaxTimeInterval(campaign.Predicate, arm.overrides())
 	ctxLogger := s.logger.WithFields(log.Fields{
 		"driver_id":     driverID,
-		"time_interval": s.predicate.TimeInterval,
+		"campaign":      campaign.Name,
+		"time_interval": timeInterval,
 	})
+	ctxLogger.Info("Request driver status from the driver-location service")
+	status, err := s.driverloc.GetStatus(ctx, driverID)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to get driver status from the driver-location service")
+	}
+	switch status.Status {
+	case driverloc.DriverStatusNeverSeen:
+		ctxLogger.Info("Driver has never been seen, their state is unknown")
+		return &Driver{ID: driverID, State: DriverStateUnknown}, 0, nil
+	case driverloc.DriverStatusOffline:
+		ctxLogger.WithField("last_seen_at", status.LastSeenAt).Info("Driver is offline")
+		return &Driver{ID: driverID, State: DriverStateOffline}, 0, nil
+	}
+
 	ctxLogger.Info("Request driver locations from the driver-location service")
-	locations, err := s.driverloc.GetLocations(ctx, driverID, s.predicate.TimeInterval)
+	locations, err := s.driverloc.GetLocations(ctx, driverID, timeInterval)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get driver locations from the driver-location service")
+		return nil, 0, errors.Wrap(err, "failed to get driver locations from the driver-location service")
 	}
 
-	distanceDriven := calculateDistanceDriven(locations)
-	isZombie := distanceDriven < s.predicate.DistanceThreshold
+	result, distanceDriven, zone := s.zones.Evaluate(campaign.Predicate, arm.overrides(), locations, now)
 	ctxLogger.WithFields(log.Fields{
-		"distance_driven":    distanceDriven,
-		"distance_threshold": s.predicate.DistanceThreshold,
-		"zombie":             isZombie,
-	}).Info("Calculated distance driven for the driver")
+		"locations_num":   len(locations),
+		"distance_driven": distanceDriven,
+		"zone":            zone,
+		"result":          result,
+	}).Info("Evaluated zombie predicate for the driver")
 
-	driver := &Driver{ID: driverID, IsZombie: isZombie}
-	return driver, nil
+	return judgedDriver(driverID, result, zone), distanceDriven, nil
 }
 
-func calculateDistanceDriven(locations []*driverloc.Location) int {
-	if len(locations) <= 1 {
-		return 0
+// judgeDriverByWindow judges the driver by their precomputed location window,
+// it returns false if the window can't judge them, so the locations must be fetched.
+func (s *ServiceImpl) judgeDriverByWindow(
+	driverID string, campaignPredicate *ZombiePredicate, arm *AssignedArm, now time.Time,
+) (*Driver, int, bool) {
+	snapshot, ok := s.windows.snapshot(driverID, now)
+	if !ok {
+		return nil, 0, false
+	}
+	ctxLogger := s.logger.WithField("driver_id", driverID)
+	if now.Sub(snapshot.lastSeenAt) > s.windows.onlineTimeout {
+		ctxLogger.WithField("last_seen_at", snapshot.lastSeenAt).Info("Driver is offline by their location window")
+		return &Driver{ID: driverID, State: DriverStateOffline}, 0, true
 	}
-	var distanceDriven float64
-	for i := 0; i < len(locations)-1; i++ {
-		start, stop := locations[i], locations[i+1]
-		locationsDistance := distance.Calculate(start.Latitude, start.Longitude, stop.Latitude, stop.Longitude)
-		distanceDriven += locationsDistance
+	predicate, zone := s.zones.predicate(campaignPredicate, arm.overrides(), snapshot.latest)
+	if predicate == nil {
+		return &Driver{ID: driverID, State: DriverStateAlive}, snapshot.distanceDriven, true
+	}
+	if !s.windows.covers(predicate) {
+		return nil, 0, false
+	}
+	result := predicate.judge(snapshot.distanceDriven, snapshot.samplesNum, snapshot.firstTime, now)
+	ctxLogger.WithFields(log.Fields{
+		"locations_num":   snapshot.samplesNum,
+		"distance_driven": snapshot.distanceDriven,
+		"zone":            zone,
+		"result":          result,
+	}).Info("Evaluated zombie predicate for the driver by their location window")
+	return judgedDriver(driverID, result, zone), snapshot.distanceDriven, true
+}
+
+func judgedDriver(driverID, result, zone string) *Driver {
+	driver := &Driver{ID: driverID, State: DriverStateAlive, Zone: zone}
+	switch result {
+	case PredicateResultZombie:
+		driver.IsZombie = true
+		driver.State = DriverStateZombie
+	case PredicateResultInsufficientData:
+		driver.State = DriverStateInsufficientData
 	}
-	return int(math.Round(distanceDriven))
+	return driver
 }
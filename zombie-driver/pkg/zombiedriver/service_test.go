@@ -2,6 +2,10 @@ package zombiedriver_test
 
 import (
 	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -17,6 +21,8 @@ import (
 
 const defaultDriverID = "foo"
 
+var zombieDriver = &zombiedriver.Driver{ID: defaultDriverID, IsZombie: true, State: zombiedriver.DriverStateZombie}
+
 func TestService_GetDriver(t *testing.T) {
 	t.Parallel()
 	timeInterval := 5 * time.Minute
@@ -25,17 +31,17 @@ func TestService_GetDriver(t *testing.T) {
 	cases := []struct {
 		name              string
 		distanceThreshold int
-		isZombie          bool
+		expected          *zombiedriver.Driver
 	}{
 		{
 			name:              "zombie",
 			distanceThreshold: 500,
-			isZombie:          true,
+			expected:          &zombiedriver.Driver{ID: defaultDriverID, IsZombie: true, State: zombiedriver.DriverStateZombie},
 		},
 		{
 			name:              "not zombie",
 			distanceThreshold: 200,
-			isZombie:          false,
+			expected:          &zombiedriver.Driver{ID: defaultDriverID, IsZombie: false, State: zombiedriver.DriverStateAlive},
 		},
 	}
 	for _, tc := range cases {
@@ -44,6 +50,7 @@ func TestService_GetDriver(t *testing.T) {
 			t.Parallel()
 
 			driverlocMock := &mocks.GetterService{}
+			onGetStatus(driverlocMock, driverloc.DriverStatusOnline)
 			driverlocMock.On("GetLocations",
 				mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
 				defaultDriverID, timeInterval,
@@ -73,16 +80,373 @@ func TestService_GetDriver(t *testing.T) {
 
 			logger := log.New()
 			logger.SetLevel(log.ErrorLevel)
-			service := zombiedriver.NewService(driverlocMock, logger, &zombiedriver.ZombiePredicate{
+			service := zombiedriver.NewService(driverlocMock, zombiedriver.NopCache{}, logger, &zombiedriver.ZombiePredicate{
 				DistanceThreshold: tc.distanceThreshold,
 				TimeInterval:      timeInterval,
-			})
+			}, nil /* zones */, nil /* campaigns */, nil /* experiment */, zombiedriver.NopOverrideStore{},
+				nil /* history */, nil /* windows */)
 
 			actual, err := service.GetDriver(context.Background(), defaultDriverID)
 			require.NoError(t, err)
 
-			assert.Equal(t, &zombiedriver.Driver{ID: defaultDriverID, IsZombie: tc.isZombie}, actual)
+			assert.Equal(t, tc.expected, actual)
 			driverlocMock.AssertExpectations(t)
 		})
 	}
 }
+
+func TestService_GetDriver_NotOnline(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		status   string
+		expected *zombiedriver.Driver
+	}{
+		{
+			status:   driverloc.DriverStatusOffline,
+			expected: &zombiedriver.Driver{ID: defaultDriverID, State: zombiedriver.DriverStateOffline},
+		},
+		{
+			status:   driverloc.DriverStatusNeverSeen,
+			expected: &zombiedriver.Driver{ID: defaultDriverID, State: zombiedriver.DriverStateUnknown},
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.status, func(t *testing.T) {
+			t.Parallel()
+			driverlocMock := &mocks.GetterService{}
+			onGetStatus(driverlocMock, tc.status)
+			service := newCachingService(driverlocMock)
+
+			actual, err := service.GetDriver(context.Background(), defaultDriverID)
+			require.NoError(t, err)
+
+			assert.Equal(t, tc.expected, actual)
+			// Stale locations of a driver who isn't online don't make them a zombie, so they aren't requested.
+			driverlocMock.AssertNotCalled(t, "GetLocations", mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestService_GetDriver_InsufficientData(t *testing.T) {
+	t.Parallel()
+	driverlocMock := &mocks.GetterService{}
+	onGetStatus(driverlocMock, driverloc.DriverStatusOnline)
+	// The driver has just reconnected, so there is a single recent location.
+	driverlocMock.On("GetLocations",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+		defaultDriverID, 5*time.Minute,
+	).Return([]*driverloc.Location{
+		{
+			Coordinates: &driverloc.Coordinates{
+				Latitude:  48.864193,
+				Longitude: 2.350498,
+			},
+			Time: time.Now().Add(-5 * time.Second),
+		},
+	}, nil)
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	service := zombiedriver.NewService(driverlocMock, zombiedriver.NopCache{}, logger, &zombiedriver.ZombiePredicate{
+		DistanceThreshold:        500,
+		TimeInterval:             5 * time.Minute,
+		MinSamples:               2,
+		MinWindowCoverage:        0.8,
+		InsufficientDataFallback: zombiedriver.PredicateResultInsufficientData,
+	}, nil /* zones */, nil /* campaigns */, nil /* experiment */, zombiedriver.NopOverrideStore{},
+		nil /* history */, nil /* windows */)
+
+	actual, err := service.GetDriver(context.Background(), defaultDriverID)
+	require.NoError(t, err)
+
+	expected := &zombiedriver.Driver{ID: defaultDriverID, State: zombiedriver.DriverStateInsufficientData}
+	assert.Equal(t, expected, actual)
+}
+
+func TestService_GetDriver_OutsideCampaign(t *testing.T) {
+	t.Parallel()
+	driverlocMock := &mocks.GetterService{}
+	predicate := &zombiedriver.ZombiePredicate{DistanceThreshold: 500, TimeInterval: 5 * time.Minute}
+	campaigns, err := zombiedriver.NewCampaignSchedule([]*zombiedriver.Campaign{{
+		Name:         "episode-nights",
+		StartDate:    "2020-11-01",
+		DailyWindows: []*zombiedriver.DailyWindow{{Start: "20:00", End: "02:00"}},
+	}}, predicate)
+	require.NoError(t, err)
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	service := zombiedriver.NewService(
+		driverlocMock, zombiedriver.NopCache{}, logger, predicate, nil /* zones */, campaigns, nil, /* experiment */
+		zombiedriver.NopOverrideStore{}, nil /* history */, nil, /* windows */
+	)
+	service.SetTimeNowFn(func() time.Time { return time.Date(2020, 11, 10, 12, 0, 0, 0, time.UTC) })
+
+	actual, err := service.GetDriver(context.Background(), defaultDriverID)
+	require.NoError(t, err)
+
+	expected := &zombiedriver.Driver{ID: defaultDriverID, State: zombiedriver.DriverStateOutsideCampaign}
+	assert.Equal(t, expected, actual)
+	// Nobody is a zombie outside campaigns, so the driver-location service isn't asked.
+	driverlocMock.AssertNotCalled(t, "GetStatus", mock.Anything, mock.Anything)
+}
+
+func TestService_GetDriver_ControlArm(t *testing.T) {
+	t.Parallel()
+	driverlocMock := &mocks.GetterService{}
+	experiment, err := zombiedriver.NewExperiment("zombie-icon-rollout", []*zombiedriver.ExperimentArm{
+		{Name: "control", Weight: 1, Control: true},
+	}, defaultPredicate, log.New())
+	require.NoError(t, err)
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	service := zombiedriver.NewService(
+		driverlocMock, zombiedriver.NopCache{}, logger, defaultPredicate, nil /* zones */, nil /* campaigns */, experiment,
+		zombiedriver.NopOverrideStore{}, nil /* history */, nil, /* windows */
+	)
+
+	actual, err := service.GetDriver(context.Background(), defaultDriverID)
+	require.NoError(t, err)
+
+	expected := &zombiedriver.Driver{ID: defaultDriverID, State: zombiedriver.DriverStateControl, Arm: "control"}
+	assert.Equal(t, expected, actual)
+	driverlocMock.AssertNotCalled(t, "GetStatus", mock.Anything, mock.Anything)
+}
+
+func TestService_GetDriver_TreatmentArm(t *testing.T) {
+	t.Parallel()
+	driverlocMock := &mocks.GetterService{}
+	onGetStatus(driverlocMock, driverloc.DriverStatusOnline)
+	// The arm predicate looks 10 minutes back instead of 5.
+	driverlocMock.On("GetLocations",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+		defaultDriverID, 10*time.Minute,
+	).Return([]*driverloc.Location{}, nil)
+	experiment, err := zombiedriver.NewExperiment("zombie-icon-rollout", []*zombiedriver.ExperimentArm{
+		{Name: "treatment", Weight: 1, Predicate: map[string]interface{}{"time_interval": "10m", "min_samples": 0}},
+	}, defaultPredicate, log.New())
+	require.NoError(t, err)
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	service := zombiedriver.NewService(
+		driverlocMock, zombiedriver.NopCache{}, logger, defaultPredicate, nil /* zones */, nil /* campaigns */, experiment,
+		zombiedriver.NopOverrideStore{}, nil /* history */, nil, /* windows */
+	)
+
+	actual, err := service.GetDriver(context.Background(), defaultDriverID)
+	require.NoError(t, err)
+
+	expected := &zombiedriver.Driver{
+		ID: defaultDriverID, State: zombiedriver.DriverStateInsufficientData, Arm: "treatment",
+	}
+	assert.Equal(t, expected, actual)
+	driverlocMock.AssertExpectations(t)
+}
+
+func TestService_GetDriver_Overridden(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	driverlocMock := &mocks.GetterService{}
+	onGetStatus(driverlocMock, driverloc.DriverStatusOffline)
+	service := newOverridingService(t, driverlocMock)
+	now := time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC)
+	service.SetTimeNowFn(func() time.Time { return now })
+	expiresAt := now.Add(time.Hour)
+	require.NoError(t, service.SetOverride(ctx, &zombiedriver.Override{
+		DriverID: defaultDriverID, State: zombiedriver.OverrideZombie, Reason: "promo partner", Author: "alice",
+		ExpiresAt: &expiresAt,
+	}))
+
+	actual, err := service.GetDriver(ctx, defaultDriverID)
+	require.NoError(t, err)
+	expected := &zombiedriver.Driver{
+		ID: defaultDriverID, IsZombie: true, State: zombiedriver.DriverStateZombie, Overridden: true,
+	}
+	assert.Equal(t, expected, actual)
+	driverlocMock.AssertNotCalled(t, "GetStatus", mock.Anything, mock.Anything)
+
+	// The driver is judged by the predicate again once the override expires.
+	service.SetTimeNowFn(func() time.Time { return expiresAt })
+	actual, err = service.GetDriver(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Equal(t, &zombiedriver.Driver{ID: defaultDriverID, State: zombiedriver.DriverStateOffline}, actual)
+}
+
+func TestService_OverrideAudit(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	service := newOverridingService(t, &mocks.GetterService{})
+	now := time.Date(2020, 11, 1, 12, 0, 0, 0, time.UTC)
+	service.SetTimeNowFn(func() time.Time { return now })
+	override := &zombiedriver.Override{
+		DriverID: defaultDriverID, State: zombiedriver.OverrideHuman, Reason: "driver complaint", Author: "alice",
+	}
+	require.NoError(t, service.SetOverride(ctx, override))
+	actual, err := service.GetOverride(ctx, defaultDriverID)
+	require.NoError(t, err)
+	assert.Equal(t, override, actual)
+
+	cleared, err := service.ClearOverride(ctx, defaultDriverID, "bob", "resolved")
+	require.NoError(t, err)
+	assert.True(t, cleared)
+	cleared, err = service.ClearOverride(ctx, defaultDriverID, "bob", "resolved")
+	require.NoError(t, err)
+	assert.False(t, cleared)
+
+	entries, err := service.GetOverrideAudit(ctx, &zombiedriver.OverrideAuditQuery{DriverID: defaultDriverID, Limit: 10})
+	require.NoError(t, err)
+	expected := []*zombiedriver.OverrideAuditEntry{
+		{Action: zombiedriver.OverrideActionSet, DriverID: defaultDriverID, Override: override, OccurredAt: now},
+		{
+			Action: zombiedriver.OverrideActionClear, DriverID: defaultDriverID, Author: "bob", Reason: "resolved",
+			OccurredAt: now,
+		},
+	}
+	assert.Equal(t, expected, entries)
+}
+
+func TestService_SetOverride_Invalid(t *testing.T) {
+	t.Parallel()
+	service := newOverridingService(t, &mocks.GetterService{})
+
+	err := service.SetOverride(context.Background(), &zombiedriver.Override{
+		DriverID: defaultDriverID, State: "vampire", Reason: "driver complaint", Author: "alice",
+	})
+
+	assert.True(t, errors.Is(err, zombiedriver.ErrInvalidOverride))
+	assert.EqualError(t, err, `state must be "zombie" or "human", got "vampire": invalid override`)
+}
+
+func TestService_GetDriver_RecordsHistory(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	driverlocMock := &mocks.GetterService{}
+	onGetStatus(driverlocMock, driverloc.DriverStatusOffline)
+	sink := newFileHistorySink(t)
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	service := zombiedriver.NewService(
+		driverlocMock, zombiedriver.NopCache{}, logger, defaultPredicate, nil /* zones */, nil, /* campaigns */
+		nil /* experiment */, zombiedriver.NopOverrideStore{},
+		zombiedriver.NewHistoryRecorder(sink, 10 /* maxDrivers */, time.Hour /* lookback */, logger), nil, /* windows */
+	)
+	now := time.Date(2020, 11, 1, 22, 0, 0, 0, time.UTC)
+	service.SetTimeNowFn(func() time.Time { return now })
+
+	for i := 0; i < 2; i++ {
+		_, err := service.GetDriver(ctx, defaultDriverID)
+		require.NoError(t, err)
+	}
+
+	transitions, err := sink.Transitions(ctx, now, now.Add(time.Minute))
+	require.NoError(t, err)
+	expected := []*zombiedriver.StatusTransition{
+		{DriverID: defaultDriverID, State: zombiedriver.DriverStateOffline, OccurredAt: now},
+	}
+	assert.Equal(t, expected, transitions)
+}
+
+func TestService_GetDriver_Cached(t *testing.T) {
+	t.Parallel()
+	driverlocMock := &mocks.GetterService{}
+	onGetStatus(driverlocMock, driverloc.DriverStatusOnline)
+	driverlocMock.On("GetLocations",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+		defaultDriverID, 5*time.Minute,
+	).Return([]*driverloc.Location{}, nil)
+	service := newCachingService(driverlocMock)
+
+	for i := 0; i < 3; i++ {
+		actual, err := service.GetDriver(context.Background(), defaultDriverID)
+		require.NoError(t, err)
+		assert.Equal(t, zombieDriver, actual)
+	}
+
+	driverlocMock.AssertNumberOfCalls(t, "GetLocations", 1)
+}
+
+func TestService_GetDriver_ConcurrentLookupsAreCoalesced(t *testing.T) {
+	t.Parallel()
+	driverlocMock := &mocks.GetterService{}
+	onGetStatus(driverlocMock, driverloc.DriverStatusOnline)
+	driverlocMock.On("GetLocations",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+		defaultDriverID, 5*time.Minute,
+	).After(100*time.Millisecond).Return([]*driverloc.Location{}, nil)
+	service := newCachingService(driverlocMock)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			actual, err := service.GetDriver(context.Background(), defaultDriverID)
+			assert.NoError(t, err)
+			assert.Equal(t, zombieDriver, actual)
+		}()
+	}
+	wg.Wait()
+
+	driverlocMock.AssertNumberOfCalls(t, "GetLocations", 1)
+}
+
+func TestService_GetDriver_CoalescedLookupOutlivesCanceledRequest(t *testing.T) {
+	t.Parallel()
+	driverlocMock := &mocks.GetterService{}
+	onGetStatus(driverlocMock, driverloc.DriverStatusOnline)
+	driverlocMock.On("GetLocations",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+		defaultDriverID, 5*time.Minute,
+	).After(100*time.Millisecond).Return(
+		[]*driverloc.Location{},
+		func(ctx context.Context, _ string, _ time.Duration) error { return ctx.Err() },
+	)
+	service := newCachingService(driverlocMock)
+
+	// The first request starts the computation and goes away, the second one waits for it.
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	firstErrCh := make(chan error, 1)
+	go func() {
+		_, err := service.GetDriver(ctx, defaultDriverID)
+		firstErrCh <- err
+	}()
+	time.Sleep(10 * time.Millisecond)
+	actual, err := service.GetDriver(context.Background(), defaultDriverID)
+
+	require.NoError(t, err)
+	assert.Equal(t, zombieDriver, actual)
+	assert.True(t, errors.Is(<-firstErrCh, context.DeadlineExceeded))
+	driverlocMock.AssertNumberOfCalls(t, "GetLocations", 1)
+}
+
+func newCachingService(driverlocMock *mocks.GetterService) *zombiedriver.ServiceImpl {
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	cache := zombiedriver.NewLRUCache(10 /* size */, time.Minute /* ttl */)
+	return zombiedriver.NewService(driverlocMock, cache, logger, &zombiedriver.ZombiePredicate{
+		DistanceThreshold: 500,
+		TimeInterval:      5 * time.Minute,
+	}, nil /* zones */, nil /* campaigns */, nil /* experiment */, zombiedriver.NopOverrideStore{},
+		nil /* history */, nil /* windows */)
+}
+
+func newOverridingService(t *testing.T, driverlocMock *mocks.GetterService) *zombiedriver.ServiceImpl {
+	dir, err := ioutil.TempDir("", "overrides")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	overrides, err := zombiedriver.NewFileOverrideStore(dir)
+	require.NoError(t, err)
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	return zombiedriver.NewService(
+		driverlocMock, zombiedriver.NopCache{}, logger, defaultPredicate, nil /* zones */, nil, /* campaigns */
+		nil /* experiment */, overrides, nil /* history */, nil, /* windows */
+	)
+}
+
+func onGetStatus(driverlocMock *mocks.GetterService, status string) {
+	driverlocMock.On("GetStatus",
+		mock.MatchedBy(func(_ context.Context) bool { return true }), // anything of type context.Context
+		defaultDriverID,
+	).Return(&driverloc.DriverStatus{Status: status}, nil)
+}
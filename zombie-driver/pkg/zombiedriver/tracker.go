@@ -0,0 +1,363 @@
+package zombiedriver
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	EventDriverBecameZombie = "driver-became-zombie"
+	EventDriverRevived      = "driver-revived"
+)
+
+// overridesFetchTimeout bounds fetching the overrides, so a slow store doesn't stall tracking.
+const overridesFetchTimeout = time.Second
+
+type NSQProducer interface {
+	Publish(topic string, body []byte) error
+}
+
+//go:generate mockery --name NSQProducer
+
+type LocationTracker interface {
+	TrackLocation(driverID string, location *driverloc.Location) error
+	// DeleteLocations forgets the locations of the driver deleted from the driver-location service.
+	DeleteLocations(driverID string) error
+}
+
+//go:generate mockery --name LocationTracker
+
+// StatusEvent is published when the zombie predicate result of a driver flips.
+type StatusEvent struct {
+	Event          string `json:"event"`
+	DriverID       string `json:"driver_id"`
+	DistanceDriven int    `json:"distance_driven"` // In meters.
+	// Zone is the name of the zone whose predicate judged the driver, empty for the default predicate.
+	Zone string `json:"zone,omitempty"`
+	// Arm is the name of the experiment arm of the driver, empty if there is no experiment.
+	Arm string `json:"arm,omitempty"`
+	// Overridden is true if the status is forced by a driver override.
+	Overridden bool      `json:"overridden,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Tracker evaluates each driver by their locations kept in the location windows, which retain them
+// for the predicate time interval of any zone, and publishes a StatusEvent whenever the driver turns
+// into or out of a zombie. All drivers are considered
+// humans until the predicate says otherwise, so no event is published for a driver who is alive from the start.
+// A driver override forces the status like in GetDriver, its changes are noticed on the next location or sweep.
+type Tracker struct {
+	producer   NSQProducer
+	topic      string
+	logger     log.FieldLogger
+	predicate  *ZombiePredicate
+	zones      *ZoneSet
+	campaigns  *CampaignSchedule
+	experiment *Experiment
+	overrides  OverrideStore
+	windows    *LocationWindows
+	timeNowFn  func() time.Time
+
+	mu      sync.Mutex
+	drivers map[string]*trackedDriver
+}
+
+type trackedDriver struct {
+	isZombie bool
+	override *Override // The last fetched override, nil if the driver has none.
+}
+
+// NewTracker returns a tracker reading the driver locations from the windows,
+// which are made to retain the locations long enough for any predicate.
+// The windows must only be fed by the tracker then, so it evaluates the drivers on every new location.
+func NewTracker(
+	producer NSQProducer, topic string, logger log.FieldLogger, predicate *ZombiePredicate,
+	zones *ZoneSet, campaigns *CampaignSchedule, experiment *Experiment, overrides OverrideStore,
+	windows *LocationWindows,
+) *Tracker {
+	t := &Tracker{
+		producer:   producer,
+		topic:      topic,
+		logger:     logger,
+		predicate:  predicate,
+		zones:      zones,
+		campaigns:  campaigns,
+		experiment: experiment,
+		overrides:  overrides,
+		windows:    windows,
+		timeNowFn:  time.Now,
+		drivers:    make(map[string]*trackedDriver),
+	}
+	windows.retain(t.retention())
+	return t
+}
+
+// TrackLocation adds the location to the driver window and re-evaluates the driver status.
+// Adding a location with the same time and coordinates as a tracked one is a no-op, so redelivered messages are safe.
+func (t *Tracker) TrackLocation(driverID string, location *driverloc.Location) error {
+	if !t.windows.track(driverID, location) {
+		return nil
+	}
+	overrides := t.fetchOverrides([]string{driverID})
+	if event := t.trackLocation(driverID, overrides); event != nil {
+		return t.publish(event)
+	}
+	return nil
+}
+
+func (t *Tracker) trackLocation(driverID string, overrides map[string]*Override) *pendingEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	driver, ok := t.drivers[driverID]
+	if !ok {
+		driver = &trackedDriver{}
+		t.drivers[driverID] = driver
+	}
+	if override, ok := overrides[driverID]; ok {
+		driver.override = override
+	}
+	return t.evaluate(driverID, driver, t.timeNowFn().UTC())
+}
+
+// DeleteLocations forgets the driver along with their locations, a zombie is revived first,
+// so the consumers don't keep them as a zombie forever.
+func (t *Tracker) DeleteLocations(driverID string) error {
+	if err := t.windows.DeleteLocations(driverID); err != nil {
+		return errors.WithStack(err)
+	}
+	if event := t.forget(driverID); event != nil {
+		return t.publish(event)
+	}
+	return nil
+}
+
+func (t *Tracker) forget(driverID string) *pendingEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	driver, ok := t.drivers[driverID]
+	if !ok {
+		return nil
+	}
+	delete(t.drivers, driverID)
+	if !driver.isZombie {
+		return nil
+	}
+	return t.newEvent(driverID, driver, EventDriverRevived, 0 /* distanceDriven */, "" /* zone */, t.timeNowFn().UTC())
+}
+
+// Sweep evicts expired locations from the windows and re-evaluates the statuses of all drivers,
+// so drivers who stopped moving or sending updates turn into zombies without new messages.
+// Drivers left without locations are forgotten, a driver forgotten as a zombie is revived first,
+// so the consumers don't keep them as a zombie forever.
+func (t *Tracker) Sweep() error {
+	t.windows.Sweep()
+	// The overrides are fetched without the lock, so the store I/O doesn't block tracking.
+	overrides := t.fetchOverrides(t.driverIDs())
+	var firstErr error
+	for _, event := range t.sweep(overrides) {
+		if err := t.publish(event); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (t *Tracker) sweep(overrides map[string]*Override) []*pendingEvent {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	now := t.timeNowFn().UTC()
+	var events []*pendingEvent
+	for driverID, driver := range t.drivers {
+		if override, ok := overrides[driverID]; ok {
+			driver.override = override
+		}
+		event := t.evaluate(driverID, driver, now)
+		if !t.windows.hasLocations(driverID) {
+			if event == nil && driver.isZombie {
+				event = t.newEvent(driverID, driver, EventDriverRevived, 0 /* distanceDriven */, "" /* zone */, now)
+			}
+			delete(t.drivers, driverID)
+		}
+		if event != nil {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+func (t *Tracker) driverIDs() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	driverIDs := make([]string, 0, len(t.drivers))
+	for driverID := range t.drivers {
+		driverIDs = append(driverIDs, driverID)
+	}
+	return driverIDs
+}
+
+// fetchOverrides returns the overrides of the drivers in a single store request, nil for the drivers without one.
+// If they can't be fetched, none is returned, so the drivers keep the last fetched overrides.
+func (t *Tracker) fetchOverrides(driverIDs []string) map[string]*Override {
+	ctx, cancel := context.WithTimeout(context.Background(), overridesFetchTimeout)
+	defer cancel()
+	fetched, err := t.overrides.GetMany(ctx, driverIDs)
+	if err != nil {
+		// Overrides are rare, so tracking goes on with the last fetched overrides.
+		logging.LogUnhandledError(t.logger, errors.Wrap(err, "failed to get driver overrides"))
+		return nil
+	}
+	overrides := make(map[string]*Override, len(driverIDs))
+	for _, driverID := range driverIDs {
+		overrides[driverID] = fetched[driverID]
+	}
+	return overrides
+}
+
+// locationIndex returns where the location goes among the locations ordered by time. It goes after the locations
+// with the same time, so the locations of a batch message, which share its time, keep their order.
+// It returns -1 if the location is already there, e.g. from a redelivered message.
+func locationIndex(locations []*driverloc.Location, location *driverloc.Location) int {
+	i := sort.Search(len(locations), func(i int) bool {
+		return locations[i].Time.After(location.Time)
+	})
+	for j := i - 1; j >= 0 && locations[j].Time.Equal(location.Time); j-- {
+		if *locations[j].Coordinates == *location.Coordinates {
+			return -1
+		}
+	}
+	return i
+}
+
+// Run sweeps the tracked drivers every interval until the context is canceled.
+func (t *Tracker) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := t.Sweep(); err != nil {
+				logging.LogUnhandledError(t.logger, errors.Wrap(err, "failed to sweep tracked drivers"))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// retention returns how long the locations are kept to evaluate any predicate.
+func (t *Tracker) retention() time.Duration {
+	retention := t.campaigns.MaxTimeInterval(t.predicate, t.zones)
+	if armsRetention := t.experiment.MaxTimeInterval(t.predicate); armsRetention > retention {
+		return armsRetention
+	}
+	return retention
+}
+
+func (t *Tracker) evaluate(driverID string, driver *trackedDriver, now time.Time) *pendingEvent {
+	var result, zone string
+	var distanceDriven int
+	arm := t.experiment.Assign(driverID)
+	campaign := t.campaigns.Active(now, t.predicate)
+	override := driver.override
+	if override != nil && override.isExpired(now) {
+		override = nil
+	}
+	switch {
+	case override != nil:
+		// The override wins over the campaign and the experiment like in GetDriver.
+		result = PredicateResultAlive
+		if override.State == OverrideZombie {
+			result = PredicateResultZombie
+		}
+	case arm != nil && arm.Control, campaign == nil:
+		// Control arm drivers are never zombies and zombies are revived when the campaign ends.
+		result = PredicateResultAlive
+	default:
+		result, distanceDriven, zone = t.windows.evaluate(driverID, t.zones, campaign.Predicate, arm.overrides(), now)
+	}
+	if result == PredicateResultInsufficientData {
+		// The driver keeps their status until there are enough locations to judge them.
+		return nil
+	}
+	isZombie := result == PredicateResultZombie
+	if isZombie == driver.isZombie {
+		return nil
+	}
+	eventName := EventDriverRevived
+	if isZombie {
+		eventName = EventDriverBecameZombie
+	}
+	pending := t.newEvent(driverID, driver, eventName, distanceDriven, zone, now)
+	pending.event.Overridden = override != nil
+	return pending
+}
+
+// pendingEvent is a status event to publish once the tracker lock is released,
+// so the network I/O doesn't block tracking of other drivers.
+type pendingEvent struct {
+	driver *trackedDriver
+	event  *StatusEvent
+}
+
+// newEvent updates the driver status right away, publish reverts it if the event isn't published.
+func (t *Tracker) newEvent(
+	driverID string, driver *trackedDriver, eventName string, distanceDriven int, zone string, now time.Time,
+) *pendingEvent {
+	event := &StatusEvent{
+		Event:          eventName,
+		DriverID:       driverID,
+		DistanceDriven: distanceDriven,
+		Zone:           zone,
+		OccurredAt:     now,
+	}
+	if arm := t.experiment.Assign(driverID); arm != nil {
+		event.Arm = arm.Name
+	}
+	driver.isZombie = eventName == EventDriverBecameZombie
+	return &pendingEvent{driver: driver, event: event}
+}
+
+func (t *Tracker) publish(pending *pendingEvent) error {
+	event := pending.event
+	eventData, err := json.Marshal(event)
+	if err != nil {
+		t.revert(pending)
+		return errors.Wrap(err, "failed to encode status event into json")
+	}
+	t.logger.WithFields(log.Fields{
+		"driver_id":       event.DriverID,
+		"event":           event.Event,
+		"distance_driven": event.DistanceDriven,
+		"zone":            event.Zone,
+	}).Info("Driver status changed, publish status event")
+	if err := t.producer.Publish(t.topic, eventData); err != nil {
+		t.revert(pending)
+		return errors.Wrap(err, "failed to publish status event")
+	}
+	return nil
+}
+
+// revert restores the driver status, so a failed publication is retried on the next evaluation.
+// A driver forgotten in the meantime is tracked again until the event is published.
+func (t *Tracker) revert(pending *pendingEvent) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	driver, ok := t.drivers[pending.event.DriverID]
+	if !ok {
+		driver = pending.driver
+		t.drivers[pending.event.DriverID] = driver
+	}
+	// The status may have flipped back and been published since.
+	publishedZombie := pending.event.Event == EventDriverBecameZombie
+	if driver.isZombie == publishedZombie {
+		driver.isZombie = !publishedZombie
+	}
+}
@@ -0,0 +1,316 @@
+package zombiedriver_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver/mocks"
+)
+
+const statusEventsTopic = "zombie-status-events"
+
+var trackerBaseTime = time.Date(2020, 11, 07, 00, 00, 00, 00, time.UTC)
+
+func TestTracker_TrackLocation(t *testing.T) {
+	t.Parallel()
+	tracker, events := setupTracker(t, nil /* publishErr */)
+
+	// Each hop is ~133 meters, so the driver drives more than 500 meters after the 5th location.
+	for i := 0; i < 5; i++ {
+		i := i
+		tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(time.Duration(i) * 5 * time.Second) })
+		err := tracker.TrackLocation(defaultDriverID, newTrackedLocation(i, time.Duration(i)*5*time.Second))
+		require.NoError(t, err)
+	}
+
+	expected := []*zombiedriver.StatusEvent{
+		{
+			Event:          zombiedriver.EventDriverBecameZombie,
+			DriverID:       defaultDriverID,
+			DistanceDriven: 0,
+			OccurredAt:     trackerBaseTime,
+		},
+		{
+			Event:          zombiedriver.EventDriverRevived,
+			DriverID:       defaultDriverID,
+			DistanceDriven: 532,
+			OccurredAt:     trackerBaseTime.Add(20 * time.Second),
+		},
+	}
+	assert.Equal(t, expected, *events)
+}
+
+func TestTracker_TrackLocation_DuplicateAndOutOfOrderLocations(t *testing.T) {
+	t.Parallel()
+	tracker, events := setupTracker(t, nil /* publishErr */)
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(time.Minute) })
+
+	for _, i := range []int{4, 0, 2, 2, 1, 3, 0} {
+		err := tracker.TrackLocation(defaultDriverID, newTrackedLocation(i, time.Duration(i)*5*time.Second))
+		require.NoError(t, err)
+	}
+
+	require.Len(t, *events, 2)
+	assert.Equal(t, zombiedriver.EventDriverRevived, (*events)[1].Event)
+	assert.Equal(t, 532, (*events)[1].DistanceDriven)
+}
+
+func TestTracker_TrackLocation_BatchSharingTime(t *testing.T) {
+	t.Parallel()
+	tracker, events := setupTracker(t, nil /* publishErr */)
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime })
+
+	// The locations of a batch message share its time, the message is delivered twice.
+	for attempt := 0; attempt < 2; attempt++ {
+		for i := 0; i < 5; i++ {
+			require.NoError(t, tracker.TrackLocation(defaultDriverID, newTrackedLocation(i, 0)))
+		}
+	}
+
+	require.Len(t, *events, 2)
+	assert.Equal(t, zombiedriver.EventDriverRevived, (*events)[1].Event)
+	assert.Equal(t, 532, (*events)[1].DistanceDriven)
+}
+
+func TestTracker_TrackLocation_ExpiredLocationIsSkipped(t *testing.T) {
+	t.Parallel()
+	tracker, events := setupTracker(t, nil /* publishErr */)
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(time.Hour) })
+
+	err := tracker.TrackLocation(defaultDriverID, newTrackedLocation(0, 0))
+	require.NoError(t, err)
+
+	assert.Empty(t, *events)
+}
+
+func TestTracker_TrackLocation_PublishFails(t *testing.T) {
+	t.Parallel()
+	tracker, events := setupTracker(t, errors.New("nsqd is down"))
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime })
+
+	err := tracker.TrackLocation(defaultDriverID, newTrackedLocation(0, 0))
+	require.EqualError(t, err, "failed to publish status event: nsqd is down")
+	// The event is published again on the next evaluation.
+	err = tracker.Sweep()
+	require.Error(t, err)
+
+	assert.Len(t, *events, 2)
+}
+
+func TestTracker_Sweep(t *testing.T) {
+	t.Parallel()
+	tracker, events := setupTracker(t, nil /* publishErr */)
+	for i := 0; i < 5; i++ {
+		i := i
+		tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(time.Duration(i) * 5 * time.Second) })
+		err := tracker.TrackLocation(defaultDriverID, newTrackedLocation(i, time.Duration(i)*5*time.Second))
+		require.NoError(t, err)
+	}
+	require.Len(t, *events, 2)
+
+	// The first location expires, the remaining 4 are ~399 meters apart.
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(5*time.Minute + time.Second) })
+	err := tracker.Sweep()
+	require.NoError(t, err)
+
+	require.Len(t, *events, 3)
+	assert.Equal(t, &zombiedriver.StatusEvent{
+		Event:          zombiedriver.EventDriverBecameZombie,
+		DriverID:       defaultDriverID,
+		DistanceDriven: 399,
+		OccurredAt:     trackerBaseTime.Add(5*time.Minute + time.Second),
+	}, (*events)[2])
+
+	// All locations expire, the zombie is revived and forgotten.
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(time.Hour) })
+	err = tracker.Sweep()
+	require.NoError(t, err)
+
+	require.Len(t, *events, 4)
+	assert.Equal(t, &zombiedriver.StatusEvent{
+		Event:      zombiedriver.EventDriverRevived,
+		DriverID:   defaultDriverID,
+		OccurredAt: trackerBaseTime.Add(time.Hour),
+	}, (*events)[3])
+	err = tracker.Sweep()
+	require.NoError(t, err)
+	assert.Len(t, *events, 4)
+}
+
+func TestTracker_Sweep_ForgottenZombiePublishFails(t *testing.T) {
+	t.Parallel()
+	publishErr := errors.New("nsqd is down")
+	var published []string
+	producerMock := &mocks.NSQProducer{}
+	producerMock.On("Publish", statusEventsTopic, mock.AnythingOfType("[]uint8")).
+		Return(func(_ string, body []byte) error {
+			event := &zombiedriver.StatusEvent{}
+			require.NoError(t, json.Unmarshal(body, event))
+			if event.Event == zombiedriver.EventDriverRevived && publishErr != nil {
+				return publishErr
+			}
+			published = append(published, event.Event)
+			return nil
+		})
+	tracker := newTestTracker(t, producerMock, zombiedriver.NopOverrideStore{})
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime })
+	require.NoError(t, tracker.TrackLocation(defaultDriverID, newTrackedLocation(0, 0)))
+
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(time.Hour) })
+	err := tracker.Sweep()
+	require.EqualError(t, err, "failed to publish status event: nsqd is down")
+	// The driver is kept until the closing event is published.
+	publishErr = nil
+	err = tracker.Sweep()
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{zombiedriver.EventDriverBecameZombie, zombiedriver.EventDriverRevived}, published)
+}
+
+func TestTracker_DeleteLocations(t *testing.T) {
+	t.Parallel()
+	tracker, events := setupTracker(t, nil /* publishErr */)
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime })
+	require.NoError(t, tracker.TrackLocation(defaultDriverID, newTrackedLocation(0, 0)))
+	require.Len(t, *events, 1)
+
+	// The zombie is revived and forgotten along with their locations.
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(time.Second) })
+	err := tracker.DeleteLocations(defaultDriverID)
+	require.NoError(t, err)
+
+	require.Len(t, *events, 2)
+	assert.Equal(t, &zombiedriver.StatusEvent{
+		Event:      zombiedriver.EventDriverRevived,
+		DriverID:   defaultDriverID,
+		OccurredAt: trackerBaseTime.Add(time.Second),
+	}, (*events)[1])
+	require.NoError(t, tracker.Sweep())
+	require.NoError(t, tracker.DeleteLocations(defaultDriverID))
+	assert.Len(t, *events, 2)
+}
+
+func TestTracker_TrackLocation_PublishesWithoutLock(t *testing.T) {
+	t.Parallel()
+	var tracker *zombiedriver.Tracker
+	producerMock := &mocks.NSQProducer{}
+	producerMock.On("Publish", statusEventsTopic, mock.AnythingOfType("[]uint8")).Return(nil).Once().
+		Run(func(args mock.Arguments) {
+			// Would deadlock if the event was published under the tracker lock.
+			require.NoError(t, tracker.TrackLocation("bar", newTrackedLocation(0, time.Second)))
+		})
+	producerMock.On("Publish", statusEventsTopic, mock.AnythingOfType("[]uint8")).Return(nil)
+	tracker = newTestTracker(t, producerMock, zombiedriver.NopOverrideStore{})
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(time.Second) })
+
+	err := tracker.TrackLocation(defaultDriverID, newTrackedLocation(0, 0))
+
+	require.NoError(t, err)
+	producerMock.AssertNumberOfCalls(t, "Publish", 2)
+}
+
+func TestTracker_TrackLocation_Override(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "overrides")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+	overrides, err := zombiedriver.NewFileOverrideStore(dir)
+	require.NoError(t, err)
+	var events []*zombiedriver.StatusEvent
+	producerMock := &mocks.NSQProducer{}
+	producerMock.On("Publish", statusEventsTopic, mock.AnythingOfType("[]uint8")).Return(nil).
+		Run(func(args mock.Arguments) {
+			event := &zombiedriver.StatusEvent{}
+			require.NoError(t, json.Unmarshal(args.Get(1).([]byte), event))
+			events = append(events, event)
+		})
+	tracker := newTestTracker(t, producerMock, overrides)
+	ctx := context.Background()
+	expiresAt := trackerBaseTime.Add(time.Hour)
+	require.NoError(t, overrides.Set(ctx, &zombiedriver.Override{
+		DriverID:  defaultDriverID,
+		State:     zombiedriver.OverrideZombie,
+		Reason:    "promo partner",
+		Author:    "support",
+		CreatedAt: trackerBaseTime,
+		ExpiresAt: &expiresAt,
+	}))
+
+	// The driver drives more than 500 meters, but stays a zombie while overridden.
+	for i := 0; i < 5; i++ {
+		i := i
+		tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(time.Duration(i) * 5 * time.Second) })
+		err := tracker.TrackLocation(defaultDriverID, newTrackedLocation(i, time.Duration(i)*5*time.Second))
+		require.NoError(t, err)
+	}
+	require.Equal(t, []*zombiedriver.StatusEvent{{
+		Event:      zombiedriver.EventDriverBecameZombie,
+		DriverID:   defaultDriverID,
+		Overridden: true,
+		OccurredAt: trackerBaseTime,
+	}}, events)
+
+	// The predicate judges the driver again once the override is cleared.
+	_, err = overrides.Delete(ctx, defaultDriverID)
+	require.NoError(t, err)
+	tracker.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(30 * time.Second) })
+	require.NoError(t, tracker.Sweep())
+
+	require.Len(t, events, 2)
+	assert.Equal(t, &zombiedriver.StatusEvent{
+		Event:          zombiedriver.EventDriverRevived,
+		DriverID:       defaultDriverID,
+		DistanceDriven: 532,
+		OccurredAt:     trackerBaseTime.Add(30 * time.Second),
+	}, events[1])
+}
+
+// newTrackedLocation returns the i-th location of a driver moving south-east by ~133 meters per step.
+func newTrackedLocation(i int, offset time.Duration) *driverloc.Location {
+	return &driverloc.Location{
+		Coordinates: &driverloc.Coordinates{
+			Latitude:  48.864193 - float64(i)*0.001,
+			Longitude: 2.350498 + float64(i)*0.001,
+		},
+		Time: trackerBaseTime.Add(offset),
+	}
+}
+
+func setupTracker(t *testing.T, publishErr error) (*zombiedriver.Tracker, *[]*zombiedriver.StatusEvent) {
+	t.Helper()
+	var events []*zombiedriver.StatusEvent
+	producerMock := &mocks.NSQProducer{}
+	producerMock.On("Publish", statusEventsTopic, mock.AnythingOfType("[]uint8")).Return(publishErr).
+		Run(func(args mock.Arguments) {
+			event := &zombiedriver.StatusEvent{}
+			require.NoError(t, json.Unmarshal(args.Get(1).([]byte), event))
+			events = append(events, event)
+		})
+	return newTestTracker(t, producerMock, zombiedriver.NopOverrideStore{}), &events
+}
+
+func newTestTracker(
+	t *testing.T, producer zombiedriver.NSQProducer, overrides zombiedriver.OverrideStore,
+) *zombiedriver.Tracker {
+	t.Helper()
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	windows, err := zombiedriver.NewLocationWindows(5*time.Minute, "" /* distanceAlgorithm */, time.Minute, logger)
+	require.NoError(t, err)
+	return zombiedriver.NewTracker(producer, statusEventsTopic, logger, &zombiedriver.ZombiePredicate{
+		DistanceThreshold: 500,
+		TimeInterval:      5 * time.Minute,
+	}, nil /* zones */, nil /* campaigns */, nil /* experiment */, overrides, windows)
+}
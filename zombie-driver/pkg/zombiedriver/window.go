@@ -0,0 +1,291 @@
+package zombiedriver
+
+import (
+	"context"
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/distance"
+)
+
+// LocationWindows keeps the locations of each driver within a sliding time window, fed by the locations topic,
+// and maintains the distance driven within the window incrementally: a new location adds the hop from
+// the previous one and an expired location removes the hop to the next one. So a driver is judged in O(1)
+// without fetching their locations from the driver-location service.
+// The locations are retained longer than the interval if the status tracker evaluates predicates
+// with longer intervals, it reads the locations of the drivers from the same windows.
+// A nil LocationWindows has no drivers, so the drivers are always judged by the fetched locations.
+type LocationWindows struct {
+	interval time.Duration
+	// retention is how long the locations are kept, it's never shorter than the interval.
+	retention         time.Duration
+	distanceAlgorithm string
+	calculator        distance.Calculator
+	// onlineTimeout must match the driver-location one, a driver is offline once their latest location is older.
+	onlineTimeout time.Duration
+	logger        log.FieldLogger
+	timeNowFn     func() time.Time
+	// startedAt is when the windows started to receive locations, they miss the earlier ones.
+	startedAt time.Time
+
+	mu      sync.Mutex
+	drivers map[string]*locationWindow
+}
+
+type locationWindow struct {
+	locations []*driverloc.Location // Ordered by time.
+	// start is the index of the first location within the interval, the earlier ones are only retained.
+	start    int
+	hops     []float64 // hops[i] is the distance between locations[i] and locations[i+1].
+	distance float64   // The sum of the hops from the start.
+	// lastSeenAt is the time of the latest location, it's kept after the location expires.
+	lastSeenAt time.Time
+}
+
+// windowSnapshot is the state of a driver window at a moment.
+type windowSnapshot struct {
+	distanceDriven int
+	samplesNum     int
+	firstTime      time.Time
+	// latest is nil if all the locations have expired.
+	latest     *driverloc.Location
+	lastSeenAt time.Time
+}
+
+// NewLocationWindows returns windows covering the interval that compute distances with the algorithm,
+// the predicates with other intervals or algorithms can't be evaluated by them.
+func NewLocationWindows(
+	interval time.Duration, distanceAlgorithm string, onlineTimeout time.Duration, logger log.FieldLogger,
+) (*LocationWindows, error) {
+	calculator, err := distance.ByName(distanceAlgorithm)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if distanceAlgorithm == "" {
+		distanceAlgorithm = distance.AlgorithmHaversine
+	}
+	return &LocationWindows{
+		interval:          interval,
+		retention:         interval,
+		distanceAlgorithm: distanceAlgorithm,
+		calculator:        calculator,
+		onlineTimeout:     onlineTimeout,
+		logger:            logger,
+		timeNowFn:         time.Now,
+		startedAt:         time.Now(),
+		drivers:           make(map[string]*locationWindow),
+	}, nil
+}
+
+// TrackLocation adds the location to the driver window.
+// Adding a location with the same time and coordinates as a tracked one is a no-op, so redelivered messages are safe.
+func (lw *LocationWindows) TrackLocation(driverID string, location *driverloc.Location) error {
+	lw.track(driverID, location)
+	return nil
+}
+
+// DeleteLocations forgets the driver window, so the deleted locations aren't served from memory.
+func (lw *LocationWindows) DeleteLocations(driverID string) error {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	delete(lw.drivers, driverID)
+	return nil
+}
+
+// retain makes the windows keep the locations for at least the retention.
+func (lw *LocationWindows) retain(retention time.Duration) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	if retention > lw.retention {
+		lw.retention = retention
+	}
+}
+
+// track adds the location to the driver window, it returns false if the location is skipped or already there.
+func (lw *LocationWindows) track(driverID string, location *driverloc.Location) bool {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	now := lw.timeNowFn().UTC()
+	if location.Time.Before(now.Add(-lw.retention)) {
+		lw.logger.WithField("driver_id", driverID).Info("Location is outside the window, skip it")
+		return false
+	}
+	window, ok := lw.drivers[driverID]
+	if !ok {
+		window = &locationWindow{}
+		lw.drivers[driverID] = window
+	}
+	if location.Time.After(window.lastSeenAt) {
+		window.lastSeenAt = location.Time
+	}
+	i := locationIndex(window.locations, location)
+	if i < 0 {
+		return false
+	}
+	if i == len(window.locations) {
+		// The usual case of the latest location.
+		if i > 0 {
+			hop := lw.hop(window.locations[i-1], location)
+			window.hops = append(window.hops, hop)
+			if i-1 >= window.start {
+				window.distance += hop
+			}
+		}
+		window.locations = append(window.locations, location)
+		return true
+	}
+	// A delayed location splits a hop, so the hops are recomputed.
+	window.locations = append(window.locations, nil)
+	copy(window.locations[i+1:], window.locations[i:])
+	window.locations[i] = location
+	lw.recompute(window, now)
+	return true
+}
+
+// evaluate judges the driver like ZoneSet.Evaluate does by the retained locations, but by the precomputed distance
+// if the windows cover the predicate of the zone.
+func (lw *LocationWindows) evaluate(
+	driverID string, zones *ZoneSet, basePredicate *ZombiePredicate, overrides *PredicateOverrides, now time.Time,
+) (string, int, string) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	window, ok := lw.drivers[driverID]
+	if !ok {
+		return zones.Evaluate(basePredicate, overrides, nil /* locations */, now)
+	}
+	lw.evict(window, now)
+	var latest *driverloc.Location
+	if len(window.locations) > 0 {
+		latest = window.locations[len(window.locations)-1]
+	}
+	predicate, zone := zones.predicate(basePredicate, overrides, latest)
+	if predicate == nil || !lw.covers(predicate) {
+		return zones.Evaluate(basePredicate, overrides, window.locations, now)
+	}
+	distanceDriven := int(math.Round(window.distance))
+	var firstTime time.Time
+	if window.start < len(window.locations) {
+		firstTime = window.locations[window.start].Time
+	}
+	return predicate.judge(distanceDriven, len(window.locations)-window.start, firstTime, now), distanceDriven, zone
+}
+
+// hasLocations tells whether the driver window retains any locations.
+func (lw *LocationWindows) hasLocations(driverID string) bool {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	window, ok := lw.drivers[driverID]
+	return ok && len(window.locations) > 0
+}
+
+// Sweep evicts the expired locations of all drivers and forgets the offline drivers left without locations.
+func (lw *LocationWindows) Sweep() {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	now := lw.timeNowFn().UTC()
+	for driverID, window := range lw.drivers {
+		lw.evict(window, now)
+		if len(window.locations) == 0 && now.Sub(window.lastSeenAt) > lw.onlineTimeout {
+			delete(lw.drivers, driverID)
+		}
+	}
+}
+
+// Run sweeps the windows every interval until the context is canceled.
+func (lw *LocationWindows) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			lw.Sweep()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// covers tells whether the predicate can be evaluated by the windows.
+func (lw *LocationWindows) covers(predicate *ZombiePredicate) bool {
+	algorithm := predicate.DistanceAlgorithm
+	if algorithm == "" {
+		algorithm = distance.AlgorithmHaversine
+	}
+	return predicate.TimeInterval == lw.interval && algorithm == lw.distanceAlgorithm
+}
+
+// snapshot returns the driver window at now, false if the windows can't tell anything about the driver:
+// they haven't seen the driver or have been receiving locations for less than the interval.
+func (lw *LocationWindows) snapshot(driverID string, now time.Time) (*windowSnapshot, bool) {
+	if lw == nil || now.Sub(lw.startedAt) < lw.interval {
+		return nil, false
+	}
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+	window, ok := lw.drivers[driverID]
+	if !ok {
+		return nil, false
+	}
+	lw.evict(window, now)
+	snapshot := &windowSnapshot{
+		distanceDriven: int(math.Round(window.distance)),
+		samplesNum:     len(window.locations) - window.start,
+		lastSeenAt:     window.lastSeenAt,
+	}
+	if window.start < len(window.locations) {
+		snapshot.firstTime = window.locations[window.start].Time
+		snapshot.latest = window.locations[len(window.locations)-1]
+	}
+	return snapshot, true
+}
+
+// evict moves the start past the locations outside the interval and drops the ones outside the retention.
+func (lw *LocationWindows) evict(window *locationWindow, now time.Time) {
+	minTime := now.Add(-lw.interval)
+	for window.start < len(window.locations) && window.locations[window.start].Time.Before(minTime) {
+		if window.start < len(window.hops) {
+			window.distance -= window.hops[window.start]
+		}
+		window.start++
+	}
+	if window.start >= len(window.hops) {
+		// Reset the floating point error accumulated by the subtractions.
+		window.distance = 0
+	}
+	minRetainedTime := now.Add(-lw.retention)
+	for len(window.locations) > 0 && window.locations[0].Time.Before(minRetainedTime) {
+		window.locations[0] = nil // Let the location be garbage collected.
+		window.locations = window.locations[1:]
+		if len(window.hops) > 0 {
+			window.hops = window.hops[1:]
+		}
+		// The retention isn't shorter than the interval, so the dropped location was before the start.
+		window.start--
+	}
+}
+
+func (lw *LocationWindows) recompute(window *locationWindow, now time.Time) {
+	minTime := now.Add(-lw.interval)
+	window.start = sort.Search(len(window.locations), func(i int) bool {
+		return !window.locations[i].Time.Before(minTime)
+	})
+	window.hops = window.hops[:0]
+	window.distance = 0
+	for i := 1; i < len(window.locations); i++ {
+		hop := lw.hop(window.locations[i-1], window.locations[i])
+		window.hops = append(window.hops, hop)
+		if i-1 >= window.start {
+			window.distance += hop
+		}
+	}
+}
+
+func (lw *LocationWindows) hop(start, stop *driverloc.Location) float64 {
+	return lw.calculator.Calculate(start.Latitude, start.Longitude, stop.Latitude, stop.Longitude)
+}
@@ -0,0 +1,337 @@
+package zombiedriver_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+)
+
+const windowsOnlineTimeout = time.Minute
+
+func TestLocationWindows_TrackLocation(t *testing.T) {
+	t.Parallel()
+	windows := setupLocationWindows(t)
+	windows.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(time.Minute) })
+
+	// Duplicate and out of order locations of the driver moving by ~133 meters per step.
+	for _, i := range []int{4, 0, 2, 2, 1, 3, 0} {
+		err := windows.TrackLocation(defaultDriverID, newTrackedLocation(i, time.Duration(i)*5*time.Second))
+		require.NoError(t, err)
+	}
+
+	distanceDriven, samplesNum, ok := windows.DistanceDriven(defaultDriverID, trackerBaseTime.Add(time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, 532, distanceDriven)
+	assert.Equal(t, 5, samplesNum)
+
+	// The first location expires, the remaining 4 are ~399 meters apart.
+	distanceDriven, samplesNum, ok = windows.DistanceDriven(
+		defaultDriverID, trackerBaseTime.Add(5*time.Minute+time.Second),
+	)
+	require.True(t, ok)
+	assert.Equal(t, 399, distanceDriven)
+	assert.Equal(t, 4, samplesNum)
+
+	_, _, ok = windows.DistanceDriven("unknown", trackerBaseTime.Add(time.Minute))
+	assert.False(t, ok)
+}
+
+func TestLocationWindows_TrackLocation_BatchSharingTime(t *testing.T) {
+	t.Parallel()
+	windows := setupLocationWindows(t)
+	windows.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(time.Minute) })
+
+	// The locations of a batch message share its time, the message is delivered twice.
+	for attempt := 0; attempt < 2; attempt++ {
+		for i := 0; i < 3; i++ {
+			require.NoError(t, windows.TrackLocation(defaultDriverID, newTrackedLocation(i, 0)))
+		}
+	}
+
+	distanceDriven, samplesNum, ok := windows.DistanceDriven(defaultDriverID, trackerBaseTime.Add(time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, 266, distanceDriven)
+	assert.Equal(t, 3, samplesNum)
+}
+
+func TestLocationWindows_TrackLocation_Retention(t *testing.T) {
+	t.Parallel()
+	windows := setupLocationWindows(t)
+	windows.Retain(10 * time.Minute)
+	windows.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(6 * time.Minute) })
+
+	// The first location is retained, but outside the window, the delayed second one splits no counted hop.
+	for _, i := range []int{0, 2, 3, 1} {
+		err := windows.TrackLocation(defaultDriverID, newTrackedLocation(i, time.Duration(i)*time.Minute))
+		require.NoError(t, err)
+	}
+
+	distanceDriven, samplesNum, ok := windows.DistanceDriven(defaultDriverID, trackerBaseTime.Add(6*time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, 266, distanceDriven)
+	assert.Equal(t, 3, samplesNum)
+
+	// The retained locations are dropped once they are older than the retention.
+	distanceDriven, samplesNum, ok = windows.DistanceDriven(defaultDriverID, trackerBaseTime.Add(14*time.Minute))
+	require.True(t, ok)
+	assert.Equal(t, 0, distanceDriven)
+	assert.Equal(t, 0, samplesNum)
+	windows.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(14 * time.Minute) })
+	windows.Sweep()
+	_, _, ok = windows.DistanceDriven(defaultDriverID, trackerBaseTime.Add(14*time.Minute))
+	assert.False(t, ok)
+}
+
+func TestLocationWindows_DeleteLocations(t *testing.T) {
+	t.Parallel()
+	windows := setupLocationWindows(t)
+	windows.SetTimeNowFn(func() time.Time { return trackerBaseTime })
+	require.NoError(t, windows.TrackLocation(defaultDriverID, newTrackedLocation(0, 0)))
+
+	err := windows.DeleteLocations(defaultDriverID)
+	require.NoError(t, err)
+
+	// The deleted locations aren't served, the driver is judged by the driver-location service.
+	_, _, ok := windows.DistanceDriven(defaultDriverID, trackerBaseTime)
+	assert.False(t, ok)
+}
+
+func TestLocationWindows_Sweep(t *testing.T) {
+	t.Parallel()
+	windows := setupLocationWindows(t)
+	windows.SetTimeNowFn(func() time.Time { return trackerBaseTime })
+	require.NoError(t, windows.TrackLocation(defaultDriverID, newTrackedLocation(0, 0)))
+
+	windows.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(30 * time.Second) })
+	windows.Sweep()
+	_, samplesNum, ok := windows.DistanceDriven(defaultDriverID, trackerBaseTime.Add(30*time.Second))
+	require.True(t, ok)
+	assert.Equal(t, 1, samplesNum)
+
+	// The location has expired and the driver is offline, so they are forgotten.
+	windows.SetTimeNowFn(func() time.Time { return trackerBaseTime.Add(5*time.Minute + time.Second) })
+	windows.Sweep()
+	_, _, ok = windows.DistanceDriven(defaultDriverID, trackerBaseTime.Add(5*time.Minute+time.Second))
+	assert.False(t, ok)
+}
+
+func TestLocationWindows_NotWarmedUp(t *testing.T) {
+	t.Parallel()
+	windows := setupLocationWindows(t)
+	windows.SetStartedAt(trackerBaseTime)
+	windows.SetTimeNowFn(func() time.Time { return trackerBaseTime })
+	require.NoError(t, windows.TrackLocation(defaultDriverID, newTrackedLocation(0, 0)))
+
+	// The windows have missed the locations sent before they started.
+	_, _, ok := windows.DistanceDriven(defaultDriverID, trackerBaseTime.Add(time.Minute))
+	assert.False(t, ok)
+	_, _, ok = windows.DistanceDriven(defaultDriverID, trackerBaseTime.Add(5*time.Minute))
+	assert.True(t, ok)
+}
+
+// TestLocationWindows_ConsistentWithPullMode replays random drives with delayed, duplicate and out of order
+// deliveries and checks that the service judges every driver the same way by the windows
+// as by the locations fetched from the driver-location service.
+func TestLocationWindows_ConsistentWithPullMode(t *testing.T) {
+	t.Parallel()
+	const driversNum = 30
+	random := rand.New(rand.NewSource(42))
+	startTime := trackerBaseTime
+	endTime := startTime.Add(30 * time.Minute)
+	deliveries := generateDeliveries(random, driversNum, startTime, endTime)
+
+	now := startTime
+	timeNowFn := func() time.Time { return now }
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	predicate := &zombiedriver.ZombiePredicate{
+		DistanceThreshold:        500,
+		TimeInterval:             5 * time.Minute,
+		MinSamples:               2,
+		MinWindowCoverage:        0.8,
+		InsufficientDataFallback: zombiedriver.PredicateResultInsufficientData,
+	}
+	windows, err := zombiedriver.NewLocationWindows(predicate.TimeInterval, "", windowsOnlineTimeout, logger)
+	require.NoError(t, err)
+	windows.SetTimeNowFn(timeNowFn)
+	windows.SetStartedAt(startTime)
+	pullDriverloc := &fakeDriverLocation{timeNowFn: timeNowFn}
+	streamingDriverloc := &fakeDriverLocation{timeNowFn: timeNowFn}
+	pullService := zombiedriver.NewService(
+		pullDriverloc, zombiedriver.NopCache{}, logger, predicate, nil /* zones */, nil, /* campaigns */
+		nil /* experiment */, zombiedriver.NopOverrideStore{}, nil /* history */, nil, /* windows */
+	)
+	pullService.SetTimeNowFn(timeNowFn)
+	streamingService := zombiedriver.NewService(
+		streamingDriverloc, zombiedriver.NopCache{}, logger, predicate, nil /* zones */, nil, /* campaigns */
+		nil /* experiment */, zombiedriver.NopOverrideStore{}, nil /* history */, windows,
+	)
+	streamingService.SetTimeNowFn(timeNowFn)
+
+	states := map[string]int{}
+	for now.Before(endTime) {
+		now = now.Add(time.Second)
+		for len(deliveries) > 0 && !deliveries[0].deliveredAt.After(now) {
+			delivery := deliveries[0]
+			deliveries = deliveries[1:]
+			require.NoError(t, windows.TrackLocation(delivery.driverID, delivery.location))
+			pullDriverloc.add(delivery.driverID, delivery.location)
+			streamingDriverloc.add(delivery.driverID, delivery.location)
+		}
+		if now.Sub(startTime)%(15*time.Second) != 0 {
+			continue
+		}
+		if now.Sub(startTime) == predicate.TimeInterval {
+			// The windows are warmed up, from now on they judge all the online drivers.
+			streamingDriverloc.resetCalls()
+		}
+		if now.Sub(startTime)%time.Minute == 0 {
+			windows.Sweep()
+		}
+		for i := 0; i < driversNum; i++ {
+			driverID := fmt.Sprintf("driver-%d", i)
+			expected, err := pullService.GetDriver(context.Background(), driverID)
+			require.NoError(t, err)
+			actual, err := streamingService.GetDriver(context.Background(), driverID)
+			require.NoError(t, err)
+			require.Equal(t, expected, actual, "driver %s at %s", driverID, now.Sub(startTime))
+			states[actual.State]++
+		}
+	}
+
+	// The replay covers all the states and the windows spare the location fetches once warmed up.
+	for _, state := range []string{
+		zombiedriver.DriverStateZombie, zombiedriver.DriverStateAlive, zombiedriver.DriverStateOffline,
+		zombiedriver.DriverStateInsufficientData,
+	} {
+		assert.NotZero(t, states[state], state)
+	}
+	assert.Zero(t, streamingDriverloc.getLocationsCalls)
+	assert.NotZero(t, pullDriverloc.getLocationsCalls)
+}
+
+type locationDelivery struct {
+	driverID    string
+	location    *driverloc.Location
+	deliveredAt time.Time
+}
+
+// generateDeliveries returns the locations of the drivers ordered by the delivery time.
+// Drivers send a location every 5 seconds give or take a second,
+// some of them stand still or go offline for a while.
+func generateDeliveries(random *rand.Rand, driversNum int, startTime, endTime time.Time) []*locationDelivery {
+	var deliveries []*locationDelivery
+	for i := 0; i < driversNum; i++ {
+		driverID := fmt.Sprintf("driver-%d", i)
+		lat, lon := 48.85+random.Float64()*0.02, 2.33+random.Float64()*0.04
+		// Steps are up to ~25 meters, so a driver moving each step drives more than 500 meters in 5 minutes.
+		moveProbability := []float64{0, 0.3, 1}[i%3]
+		for t := startTime.Add(time.Duration(random.Intn(5000)) * time.Millisecond); t.Before(endTime); {
+			if random.Float64() < 0.005 {
+				t = t.Add(time.Duration(1+random.Intn(8)) * time.Minute) // The driver goes offline.
+			}
+			if random.Float64() < moveProbability {
+				lat += (random.Float64() - 0.5) * 0.0004
+				lon += (random.Float64() - 0.5) * 0.0004
+			}
+			location := &driverloc.Location{
+				Coordinates: &driverloc.Coordinates{Latitude: lat, Longitude: lon},
+				Time:        t,
+			}
+			// Deliveries are delayed by up to 3 seconds, so some of them are out of order, and some are repeated.
+			delays := []time.Duration{time.Duration(random.Intn(3000)) * time.Millisecond}
+			if random.Float64() < 0.05 {
+				delays = append(delays, delays[0]+time.Duration(random.Intn(10))*time.Second)
+			}
+			for _, delay := range delays {
+				deliveries = append(deliveries, &locationDelivery{
+					driverID: driverID, location: location, deliveredAt: t.Add(delay),
+				})
+			}
+			t = t.Add(4*time.Second + time.Duration(random.Intn(2000))*time.Millisecond)
+		}
+	}
+	sort.SliceStable(deliveries, func(i, j int) bool {
+		return deliveries[i].deliveredAt.Before(deliveries[j].deliveredAt)
+	})
+	return deliveries
+}
+
+// fakeDriverLocation answers like the driver-location service from the delivered locations.
+type fakeDriverLocation struct {
+	timeNowFn func() time.Time
+
+	mu                sync.Mutex
+	locations         map[string][]*driverloc.Location // Ordered by time.
+	getLocationsCalls int
+}
+
+func (f *fakeDriverLocation) add(driverID string, location *driverloc.Location) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.locations == nil {
+		f.locations = map[string][]*driverloc.Location{}
+	}
+	locations := f.locations[driverID]
+	i := sort.Search(len(locations), func(i int) bool { return !locations[i].Time.Before(location.Time) })
+	if i < len(locations) && locations[i].Time.Equal(location.Time) {
+		return
+	}
+	locations = append(locations, nil)
+	copy(locations[i+1:], locations[i:])
+	locations[i] = location
+	f.locations[driverID] = locations
+}
+
+func (f *fakeDriverLocation) resetCalls() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getLocationsCalls = 0
+}
+
+func (f *fakeDriverLocation) GetLocations(_ context.Context, driverID string, timeInterval time.Duration,
+) ([]*driverloc.Location, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.getLocationsCalls++
+	minTime := f.timeNowFn().Add(-timeInterval)
+	locations := f.locations[driverID]
+	i := sort.Search(len(locations), func(i int) bool { return !locations[i].Time.Before(minTime) })
+	return locations[i:], nil
+}
+
+func (f *fakeDriverLocation) GetStatus(_ context.Context, driverID string) (*driverloc.DriverStatus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	locations := f.locations[driverID]
+	if len(locations) == 0 {
+		return &driverloc.DriverStatus{Status: driverloc.DriverStatusNeverSeen}, nil
+	}
+	lastSeenAt := locations[len(locations)-1].Time
+	status := &driverloc.DriverStatus{Status: driverloc.DriverStatusOnline, LastSeenAt: &lastSeenAt}
+	if f.timeNowFn().Sub(lastSeenAt) > windowsOnlineTimeout {
+		status.Status = driverloc.DriverStatusOffline
+	}
+	return status, nil
+}
+
+func setupLocationWindows(t *testing.T) *zombiedriver.LocationWindows {
+	t.Helper()
+	logger := log.New()
+	logger.SetLevel(log.ErrorLevel)
+	windows, err := zombiedriver.NewLocationWindows(5*time.Minute, "haversine", windowsOnlineTimeout, logger)
+	require.NoError(t, err)
+	windows.SetStartedAt(trackerBaseTime.Add(-time.Hour))
+	return windows
+}
@@ -0,0 +1,287 @@
+package zombiedriver
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sync"
+	"time"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/platform/config"
+	"github.com/georgysavva/driver-app/platform/geo"
+	"github.com/georgysavva/driver-app/platform/logging"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// Zone is a named area with its own zombie predicate rules.
+type Zone struct {
+	Name     string
+	Enabled  bool
+	Polygons []geo.Polygon
+	// Overrides apply on top of the predicate active outside the zones, e.g. the one of the running campaign,
+	// so the fields the zone doesn't override follow that predicate.
+	Overrides *PredicateOverrides
+}
+
+// ZoneSet selects the predicate by the zone the driver is in.
+// A nil ZoneSet has no zones, so the given predicate applies everywhere.
+type ZoneSet struct {
+	zones []*Zone // The first matching zone wins.
+	// onlyInZones makes drivers outside all zones alive, so zombies appear only within the zones.
+	onlyInZones bool
+}
+
+func NewZoneSet(zones []*Zone, onlyInZones bool) *ZoneSet {
+	return &ZoneSet{zones: zones, onlyInZones: onlyInZones}
+}
+
+// Match returns the first enabled zone containing the point or nil.
+func (zs *ZoneSet) Match(p geo.Point) *Zone {
+	if zs == nil {
+		return nil
+	}
+	for _, zone := range zs.zones {
+		if !zone.Enabled {
+			continue
+		}
+		for _, polygon := range zone.Polygons {
+			if polygon.Contains(p) {
+				return zone
+			}
+		}
+	}
+	return nil
+}
+
+// MaxTimeInterval returns the longest time interval of the given predicate and the enabled zone predicates
+// built on top of it, i.e. how far back the driver locations are needed to evaluate them in any zone.
+// The overrides, e.g. of the experiment arm, apply after the zone ones, they can be nil.
+func (zs *ZoneSet) MaxTimeInterval(basePredicate *ZombiePredicate, overrides *PredicateOverrides) time.Duration {
+	maxInterval := overrides.Apply(basePredicate).TimeInterval
+	if zs == nil {
+		return maxInterval
+	}
+	for _, zone := range zs.zones {
+		if !zone.Enabled {
+			continue
+		}
+		if interval := overrides.Apply(zone.Overrides.Apply(basePredicate)).TimeInterval; interval > maxInterval {
+			maxInterval = interval
+		}
+	}
+	return maxInterval
+}
+
+// Evaluate judges the driver by the predicate of the zone of their latest location,
+// the zone overrides apply on top of the given predicate, which judges the drivers outside the zones.
+// The overrides, e.g. of the experiment arm, apply after the zone ones, they can be nil.
+// Locations must be ordered by time and cover MaxTimeInterval,
+// the ones outside the time interval of the zone predicate are ignored.
+// It returns the predicate result, the distance driven in meters and the zone name, empty outside the zones.
+func (zs *ZoneSet) Evaluate(
+	basePredicate *ZombiePredicate, overrides *PredicateOverrides, locations []*driverloc.Location, now time.Time,
+) (string, int, string) {
+	var latest *driverloc.Location
+	if len(locations) > 0 {
+		latest = locations[len(locations)-1]
+	}
+	predicate, zoneName := zs.predicate(basePredicate, overrides, latest)
+	if predicate == nil {
+		return PredicateResultAlive, calculateDistanceDriven(basePredicate.calculator(), locations), ""
+	}
+
+	minTime := now.Add(-predicate.TimeInterval)
+	firstIndex := 0
+	for firstIndex < len(locations) && locations[firstIndex].Time.Before(minTime) {
+		firstIndex++
+	}
+	result, distanceDriven := predicate.Evaluate(locations[firstIndex:], now)
+	return result, distanceDriven, zoneName
+}
+
+// predicate returns the predicate of the zone of the latest location, which can be nil, and the zone name.
+// The predicate is nil outside all zones when only the zones are judged, the driver is alive then.
+func (zs *ZoneSet) predicate(basePredicate *ZombiePredicate, overrides *PredicateOverrides, latest *driverloc.Location,
+) (*ZombiePredicate, string) {
+	var zone *Zone
+	if latest != nil {
+		zone = zs.Match(latest.Point())
+	}
+	if zone != nil {
+		return overrides.Apply(zone.Overrides.Apply(basePredicate)), zone.Name
+	}
+	if zs != nil && zs.onlyInZones {
+		return nil, ""
+	}
+	return overrides.Apply(basePredicate), ""
+}
+
+// zoneFeatureCollection is the subset of a GeoJSON feature collection the zones are read from.
+type zoneFeatureCollection struct {
+	Type     string `json:"type"`
+	Features []*struct {
+		Geometry *struct {
+			Type        string          `json:"type"`
+			Coordinates json.RawMessage `json:"coordinates"`
+		} `json:"geometry"`
+		Properties *struct {
+			Name    string `json:"name"`
+			Enabled *bool  `json:"enabled"`
+			// Predicate contains the overridden predicate fields, named as in the config.
+			Predicate json.RawMessage `json:"predicate"`
+		} `json:"properties"`
+	} `json:"features"`
+}
+
+// LoadZones reads the zones from a GeoJSON feature collection of Polygon and MultiPolygon features.
+// Each feature must have a unique "name" property, "enabled" is true unless set,
+// and "predicate" overrides fields of the predicate active outside the zones.
+// The overrides are validated against the default predicate.
+func LoadZones(path string, defaultPredicate *ZombiePredicate, logger log.FieldLogger) ([]*Zone, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read zones file")
+	}
+	collection := &zoneFeatureCollection{}
+	if err := json.Unmarshal(data, collection); err != nil {
+		return nil, errors.Wrap(err, "failed to decode zones geojson")
+	}
+	if collection.Type != "FeatureCollection" {
+		return nil, errors.Errorf("zones geojson must be a FeatureCollection, got %q", collection.Type)
+	}
+
+	zones := make([]*Zone, 0, len(collection.Features))
+	names := make(map[string]bool, len(collection.Features))
+	for i, feature := range collection.Features {
+		if feature.Properties == nil || feature.Properties.Name == "" {
+			return nil, errors.Errorf("zone feature %d must have a name property", i)
+		}
+		name := feature.Properties.Name
+		if names[name] {
+			return nil, errors.Errorf("zone %q is defined more than once", name)
+		}
+		names[name] = true
+		if feature.Geometry == nil {
+			return nil, errors.Errorf("zone %q must have a geometry", name)
+		}
+		polygons, err := decodePolygons(feature.Geometry.Type, feature.Geometry.Coordinates)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid geometry of zone %q", name)
+		}
+		overrides, err := NewPredicateOverrides(
+			feature.Properties.Predicate, defaultPredicate, logger.WithField("zone", name),
+		)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid predicate of zone %q", name)
+		}
+		zone := &Zone{Name: name, Enabled: true, Polygons: polygons, Overrides: overrides}
+		if feature.Properties.Enabled != nil {
+			zone.Enabled = *feature.Properties.Enabled
+		}
+		zones = append(zones, zone)
+	}
+	return zones, nil
+}
+
+func decodePolygons(geometryType string, coordinates json.RawMessage) ([]geo.Polygon, error) {
+	var rawPolygons [][][][2]float64
+	switch geometryType {
+	case "Polygon":
+		var rawPolygon [][][2]float64
+		if err := json.Unmarshal(coordinates, &rawPolygon); err != nil {
+			return nil, errors.Wrap(err, "failed to decode polygon coordinates")
+		}
+		rawPolygons = [][][][2]float64{rawPolygon}
+	case "MultiPolygon":
+		if err := json.Unmarshal(coordinates, &rawPolygons); err != nil {
+			return nil, errors.Wrap(err, "failed to decode multi polygon coordinates")
+		}
+	default:
+		return nil, errors.Errorf("geometry must be a Polygon or MultiPolygon, got %q", geometryType)
+	}
+
+	polygons := make([]geo.Polygon, len(rawPolygons))
+	for i, rawPolygon := range rawPolygons {
+		if len(rawPolygon) == 0 {
+			return nil, errors.New("polygon must have an exterior ring")
+		}
+		polygon := make(geo.Polygon, len(rawPolygon))
+		for j, rawRing := range rawPolygon {
+			if len(rawRing) < 4 {
+				return nil, errors.New("polygon ring must have at least 4 positions")
+			}
+			ring := make(geo.Ring, len(rawRing))
+			for k, position := range rawRing {
+				// GeoJSON positions are longitude first.
+				ring[k] = geo.Point{Latitude: position[1], Longitude: position[0]}
+			}
+			polygon[j] = ring
+		}
+		polygons[i] = polygon
+	}
+	return polygons, nil
+}
+
+// PredicateOverrides are the raw overridden fields of a predicate, they can apply on top of any predicate.
+type PredicateOverrides struct {
+	raw    []byte
+	logger log.FieldLogger
+
+	mu sync.Mutex
+	// applied caches the predicates by the base predicate, there are only a few configured ones.
+	applied map[ZombiePredicate]*ZombiePredicate
+}
+
+// NewPredicateOverrides checks the overrides by applying them to the default predicate.
+// The fields are validated independently, so the overrides apply to any valid predicate then.
+func NewPredicateOverrides(
+	raw []byte, defaultPredicate *ZombiePredicate, logger log.FieldLogger,
+) (*PredicateOverrides, error) {
+	if _, err := overridePredicate(defaultPredicate, raw); err != nil {
+		return nil, err
+	}
+	return &PredicateOverrides{raw: raw, logger: logger, applied: make(map[ZombiePredicate]*ZombiePredicate)}, nil
+}
+
+// Apply returns a copy of the base predicate with the overridden fields, nil overrides return the base predicate.
+// The base predicate is returned if the overrides don't apply to it, which is logged.
+func (po *PredicateOverrides) Apply(basePredicate *ZombiePredicate) *ZombiePredicate {
+	if po == nil || len(po.raw) == 0 {
+		return basePredicate
+	}
+	po.mu.Lock()
+	defer po.mu.Unlock()
+	if predicate, ok := po.applied[*basePredicate]; ok {
+		return predicate
+	}
+	predicate, err := overridePredicate(basePredicate, po.raw)
+	if err != nil {
+		// The overrides were validated against the default predicate, so it's a bug if they don't apply.
+		logging.LogUnhandledError(
+			po.logger.WithField("base_predicate", basePredicate),
+			errors.Wrap(err, "failed to apply predicate overrides, use the base predicate"),
+		)
+		return basePredicate
+	}
+	po.applied[*basePredicate] = predicate
+	return predicate
+}
+
+// overridePredicate returns a copy of the base predicate with the overridden fields.
+// The overrides are decoded with the yaml tags, so they are named and formatted as in the config.
+func overridePredicate(basePredicate *ZombiePredicate, overrides []byte) (*ZombiePredicate, error) {
+	predicate := *basePredicate
+	if len(overrides) == 0 {
+		return &predicate, nil
+	}
+	// JSON overrides are valid YAML too.
+	if err := yaml.UnmarshalStrict(overrides, &predicate); err != nil {
+		return nil, errors.Wrap(err, "failed to decode predicate overrides")
+	}
+	if err := config.Validate(&predicate); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return &predicate, nil
+}
@@ -0,0 +1,200 @@
+package zombiedriver_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/georgysavva/driver-app/driver-location/pkg/driverloc"
+	"github.com/georgysavva/driver-app/platform/geo"
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/georgysavva/driver-app/zombie-driver/pkg/zombiedriver"
+)
+
+var defaultPredicate = &zombiedriver.ZombiePredicate{
+	DistanceThreshold:        500,
+	TimeInterval:             5 * time.Minute,
+	MinSamples:               2,
+	MinWindowCoverage:        0.8,
+	InsufficientDataFallback: zombiedriver.PredicateResultInsufficientData,
+	DistanceAlgorithm:        "haversine",
+}
+
+func TestLoadZones(t *testing.T) {
+	t.Parallel()
+	zones, err := zombiedriver.LoadZones("testdata/zones.geojson", defaultPredicate, log.New())
+	require.NoError(t, err)
+	require.Len(t, zones, 2)
+
+	downtown := zones[0]
+	assert.Equal(t, "downtown", downtown.Name)
+	assert.True(t, downtown.Enabled)
+	assert.Equal(t, []geo.Polygon{{{
+		{Latitude: 48.85, Longitude: 2.34},
+		{Latitude: 48.85, Longitude: 2.36},
+		{Latitude: 48.87, Longitude: 2.36},
+		{Latitude: 48.87, Longitude: 2.34},
+		{Latitude: 48.85, Longitude: 2.34},
+	}}}, downtown.Polygons)
+	assert.Equal(t, &zombiedriver.ZombiePredicate{
+		DistanceThreshold:        100,
+		TimeInterval:             10 * time.Minute,
+		MinSamples:               0,
+		MinWindowCoverage:        0,
+		InsufficientDataFallback: zombiedriver.PredicateResultInsufficientData,
+		DistanceAlgorithm:        "haversine",
+	}, downtown.Overrides.Apply(defaultPredicate))
+
+	islands := zones[1]
+	assert.Equal(t, "islands", islands.Name)
+	assert.False(t, islands.Enabled)
+	assert.Len(t, islands.Polygons, 2)
+	assert.Same(t, defaultPredicate, islands.Overrides.Apply(defaultPredicate))
+}
+
+func TestZone_OverridesApplyOnTopOfActivePredicate(t *testing.T) {
+	t.Parallel()
+	zones, err := zombiedriver.LoadZones("testdata/zones.geojson", defaultPredicate, log.New())
+	require.NoError(t, err)
+	campaignPredicate := *defaultPredicate
+	campaignPredicate.DistanceThreshold = 1000
+	campaignPredicate.DistanceAlgorithm = "vincenty"
+
+	// The zone fields win, the campaign fields apply to the rest.
+	assert.Equal(t, &zombiedriver.ZombiePredicate{
+		DistanceThreshold:        100,
+		TimeInterval:             10 * time.Minute,
+		MinSamples:               0,
+		MinWindowCoverage:        0,
+		InsufficientDataFallback: zombiedriver.PredicateResultInsufficientData,
+		DistanceAlgorithm:        "vincenty",
+	}, zones[0].Overrides.Apply(&campaignPredicate))
+	assert.Equal(t, "haversine", zones[0].Overrides.Apply(defaultPredicate).DistanceAlgorithm)
+}
+
+func TestLoadZones_Invalid(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		path     string
+		expected string
+	}{
+		{
+			path: "testdata/zones_invalid_predicate.geojson",
+			expected: `invalid predicate of zone "downtown": failed to decode predicate overrides: ` +
+				"yaml: unmarshal errors:\n  line 1: field distance_threshhold not found in type zombiedriver.ZombiePredicate",
+		},
+		{
+			path:     "testdata/zones_invalid_geometry.geojson",
+			expected: `invalid geometry of zone "downtown": geometry must be a Polygon or MultiPolygon, got "Point"`,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.path, func(t *testing.T) {
+			t.Parallel()
+			_, err := zombiedriver.LoadZones(tc.path, defaultPredicate, log.New())
+			assert.EqualError(t, err, tc.expected)
+		})
+	}
+}
+
+func TestZoneSet_Evaluate(t *testing.T) {
+	t.Parallel()
+	zones, err := zombiedriver.LoadZones("testdata/zones.geojson", defaultPredicate, log.New())
+	require.NoError(t, err)
+	now := time.Date(2020, 11, 07, 00, 10, 00, 00, time.UTC)
+	// A single location 8 minutes ago, outside the default predicate time interval.
+	newLocations := func(latitude, longitude float64) []*driverloc.Location {
+		return []*driverloc.Location{{
+			Coordinates: &driverloc.Coordinates{Latitude: latitude, Longitude: longitude},
+			Time:        now.Add(-8 * time.Minute),
+		}}
+	}
+
+	cases := []struct {
+		name           string
+		zones          *zombiedriver.ZoneSet
+		locations      []*driverloc.Location
+		expectedResult string
+		expectedZone   string
+	}{
+		{
+			name:           "no zones",
+			zones:          nil,
+			locations:      newLocations(48.86, 2.35),
+			expectedResult: zombiedriver.PredicateResultInsufficientData,
+		},
+		{
+			name:           "in zone",
+			zones:          zombiedriver.NewZoneSet(zones, false /* onlyInZones */),
+			locations:      newLocations(48.86, 2.35),
+			expectedResult: zombiedriver.PredicateResultZombie,
+			expectedZone:   "downtown",
+		},
+		{
+			name:           "in disabled zone",
+			zones:          zombiedriver.NewZoneSet(zones, false /* onlyInZones */),
+			locations:      newLocations(48.05, 2.05),
+			expectedResult: zombiedriver.PredicateResultInsufficientData,
+		},
+		{
+			name:           "outside zones",
+			zones:          zombiedriver.NewZoneSet(zones, true /* onlyInZones */),
+			locations:      newLocations(48.05, 2.05),
+			expectedResult: zombiedriver.PredicateResultAlive,
+		},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			result, _, zone := tc.zones.Evaluate(defaultPredicate, nil /* overrides */, tc.locations, now)
+
+			assert.Equal(t, tc.expectedResult, result)
+			assert.Equal(t, tc.expectedZone, zone)
+		})
+	}
+}
+
+func TestZoneSet_Evaluate_ArmInZone(t *testing.T) {
+	t.Parallel()
+	zones, err := zombiedriver.LoadZones("testdata/zones.geojson", defaultPredicate, log.New())
+	require.NoError(t, err)
+	zoneSet := zombiedriver.NewZoneSet(zones, false /* onlyInZones */)
+	armOverrides, err := zombiedriver.NewPredicateOverrides(
+		[]byte(`{"distance_threshold": 1000}`), defaultPredicate, log.New(),
+	)
+	require.NoError(t, err)
+	now := time.Date(2020, 11, 07, 00, 10, 00, 00, time.UTC)
+	// ~133 meters within downtown, the first location is only within the zone time interval.
+	locations := []*driverloc.Location{
+		{Coordinates: &driverloc.Coordinates{Latitude: 48.86, Longitude: 2.35}, Time: now.Add(-8 * time.Minute)},
+		{Coordinates: &driverloc.Coordinates{Latitude: 48.861, Longitude: 2.351}, Time: now.Add(-time.Minute)},
+	}
+
+	result, distanceDriven, zone := zoneSet.Evaluate(defaultPredicate, nil /* overrides */, locations, now)
+	assert.Equal(t, zombiedriver.PredicateResultAlive, result)
+	assert.Equal(t, 133, distanceDriven)
+	assert.Equal(t, "downtown", zone)
+
+	// The arm threshold applies on top of the zone predicate, the zone time interval is kept.
+	result, distanceDriven, zone = zoneSet.Evaluate(defaultPredicate, armOverrides, locations, now)
+	assert.Equal(t, zombiedriver.PredicateResultZombie, result)
+	assert.Equal(t, 133, distanceDriven)
+	assert.Equal(t, "downtown", zone)
+
+	assert.Equal(t, 10*time.Minute, zoneSet.MaxTimeInterval(defaultPredicate, armOverrides))
+}
+
+func TestZoneSet_MaxTimeInterval(t *testing.T) {
+	t.Parallel()
+	zones, err := zombiedriver.LoadZones("testdata/zones.geojson", defaultPredicate, log.New())
+	require.NoError(t, err)
+
+	zoneSet := zombiedriver.NewZoneSet(zones, false /* onlyInZones */)
+	assert.Equal(t, 10*time.Minute, zoneSet.MaxTimeInterval(defaultPredicate, nil /* overrides */))
+	var noZones *zombiedriver.ZoneSet
+	assert.Equal(t, 5*time.Minute, noZones.MaxTimeInterval(defaultPredicate, nil /* overrides */))
+}
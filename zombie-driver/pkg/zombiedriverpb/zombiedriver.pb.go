@@ -0,0 +1,412 @@
+// The gRPC API of the zombie driver service, exposed alongside the HTTP one.
+// Field names follow the JSON of the HTTP API, so the gateway transcodes the messages into the same JSON.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.27.1
+// 	protoc        (unknown)
+// source: zombiedriver.proto
+
+package zombiedriverpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type GetDriverRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	DriverId string `protobuf:"bytes,1,opt,name=driver_id,json=driverId,proto3" json:"driver_id,omitempty"`
+}
+
+func (x *GetDriverRequest) Reset() {
+	*x = GetDriverRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_zombiedriver_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *GetDriverRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GetDriverRequest) ProtoMessage() {}
+
+func (x *GetDriverRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zombiedriver_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GetDriverRequest.ProtoReflect.Descriptor instead.
+func (*GetDriverRequest) Descriptor() ([]byte, []int) {
+	return file_zombiedriver_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *GetDriverRequest) GetDriverId() string {
+	if x != nil {
+		return x.DriverId
+	}
+	return ""
+}
+
+type Driver struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id     string `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Zombie bool   `protobuf:"varint,2,opt,name=zombie,proto3" json:"zombie,omitempty"`
+	State  string `protobuf:"bytes,3,opt,name=state,proto3" json:"state,omitempty"`
+	// The optional fields are unset like the omitted fields of the HTTP API.
+	Zone       *string `protobuf:"bytes,4,opt,name=zone,proto3,oneof" json:"zone,omitempty"`
+	Arm        *string `protobuf:"bytes,5,opt,name=arm,proto3,oneof" json:"arm,omitempty"`
+	Overridden *bool   `protobuf:"varint,6,opt,name=overridden,proto3,oneof" json:"overridden,omitempty"`
+}
+
+func (x *Driver) Reset() {
+	*x = Driver{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_zombiedriver_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Driver) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Driver) ProtoMessage() {}
+
+func (x *Driver) ProtoReflect() protoreflect.Message {
+	mi := &file_zombiedriver_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Driver.ProtoReflect.Descriptor instead.
+func (*Driver) Descriptor() ([]byte, []int) {
+	return file_zombiedriver_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *Driver) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *Driver) GetZombie() bool {
+	if x != nil {
+		return x.Zombie
+	}
+	return false
+}
+
+func (x *Driver) GetState() string {
+	if x != nil {
+		return x.State
+	}
+	return ""
+}
+
+func (x *Driver) GetZone() string {
+	if x != nil && x.Zone != nil {
+		return *x.Zone
+	}
+	return ""
+}
+
+func (x *Driver) GetArm() string {
+	if x != nil && x.Arm != nil {
+		return *x.Arm
+	}
+	return ""
+}
+
+func (x *Driver) GetOverridden() bool {
+	if x != nil && x.Overridden != nil {
+		return *x.Overridden
+	}
+	return false
+}
+
+type BatchGetDriversRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	DriverIds []string `protobuf:"bytes,1,rep,name=driver_ids,json=driverIds,proto3" json:"driver_ids,omitempty"`
+}
+
+func (x *BatchGetDriversRequest) Reset() {
+	*x = BatchGetDriversRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_zombiedriver_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchGetDriversRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetDriversRequest) ProtoMessage() {}
+
+func (x *BatchGetDriversRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_zombiedriver_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetDriversRequest.ProtoReflect.Descriptor instead.
+func (*BatchGetDriversRequest) Descriptor() ([]byte, []int) {
+	return file_zombiedriver_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *BatchGetDriversRequest) GetDriverIds() []string {
+	if x != nil {
+		return x.DriverIds
+	}
+	return nil
+}
+
+type BatchGetDriversResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Drivers []*Driver `protobuf:"bytes,1,rep,name=drivers,proto3" json:"drivers,omitempty"`
+}
+
+func (x *BatchGetDriversResponse) Reset() {
+	*x = BatchGetDriversResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_zombiedriver_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *BatchGetDriversResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchGetDriversResponse) ProtoMessage() {}
+
+func (x *BatchGetDriversResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_zombiedriver_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchGetDriversResponse.ProtoReflect.Descriptor instead.
+func (*BatchGetDriversResponse) Descriptor() ([]byte, []int) {
+	return file_zombiedriver_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *BatchGetDriversResponse) GetDrivers() []*Driver {
+	if x != nil {
+		return x.Drivers
+	}
+	return nil
+}
+
+var File_zombiedriver_proto protoreflect.FileDescriptor
+
+var file_zombiedriver_proto_rawDesc = []byte{
+	0x0a, 0x12, 0x7a, 0x6f, 0x6d, 0x62, 0x69, 0x65, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x2e, 0x70,
+	0x72, 0x6f, 0x74, 0x6f, 0x12, 0x16, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x61, 0x70, 0x70, 0x2e,
+	0x7a, 0x6f, 0x6d, 0x62, 0x69, 0x65, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x22, 0x2f, 0x0a, 0x10,
+	0x47, 0x65, 0x74, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74,
+	0x12, 0x1b, 0x0a, 0x09, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x08, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x49, 0x64, 0x22, 0xbb, 0x01,
+	0x0a, 0x06, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x12, 0x0e, 0x0a, 0x02, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16, 0x0a, 0x06, 0x7a, 0x6f, 0x6d, 0x62,
+	0x69, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x08, 0x52, 0x06, 0x7a, 0x6f, 0x6d, 0x62, 0x69, 0x65,
+	0x12, 0x14, 0x0a, 0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x05, 0x73, 0x74, 0x61, 0x74, 0x65, 0x12, 0x17, 0x0a, 0x04, 0x7a, 0x6f, 0x6e, 0x65, 0x18, 0x04,
+	0x20, 0x01, 0x28, 0x09, 0x48, 0x00, 0x52, 0x04, 0x7a, 0x6f, 0x6e, 0x65, 0x88, 0x01, 0x01, 0x12,
+	0x15, 0x0a, 0x03, 0x61, 0x72, 0x6d, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x48, 0x01, 0x52, 0x03,
+	0x61, 0x72, 0x6d, 0x88, 0x01, 0x01, 0x12, 0x23, 0x0a, 0x0a, 0x6f, 0x76, 0x65, 0x72, 0x72, 0x69,
+	0x64, 0x64, 0x65, 0x6e, 0x18, 0x06, 0x20, 0x01, 0x28, 0x08, 0x48, 0x02, 0x52, 0x0a, 0x6f, 0x76,
+	0x65, 0x72, 0x72, 0x69, 0x64, 0x64, 0x65, 0x6e, 0x88, 0x01, 0x01, 0x42, 0x07, 0x0a, 0x05, 0x5f,
+	0x7a, 0x6f, 0x6e, 0x65, 0x42, 0x06, 0x0a, 0x04, 0x5f, 0x61, 0x72, 0x6d, 0x42, 0x0d, 0x0a, 0x0b,
+	0x5f, 0x6f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x64, 0x65, 0x6e, 0x22, 0x37, 0x0a, 0x16, 0x42,
+	0x61, 0x74, 0x63, 0x68, 0x47, 0x65, 0x74, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x73, 0x52, 0x65,
+	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1d, 0x0a, 0x0a, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x5f,
+	0x69, 0x64, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x09, 0x64, 0x72, 0x69, 0x76, 0x65,
+	0x72, 0x49, 0x64, 0x73, 0x22, 0x53, 0x0a, 0x17, 0x42, 0x61, 0x74, 0x63, 0x68, 0x47, 0x65, 0x74,
+	0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x38, 0x0a, 0x07, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x1e, 0x2e, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x61, 0x70, 0x70, 0x2e, 0x7a, 0x6f, 0x6d,
+	0x62, 0x69, 0x65, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x2e, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72,
+	0x52, 0x07, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x73, 0x32, 0xd9, 0x01, 0x0a, 0x0c, 0x5a, 0x6f,
+	0x6d, 0x62, 0x69, 0x65, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x12, 0x55, 0x0a, 0x09, 0x47, 0x65,
+	0x74, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x12, 0x28, 0x2e, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72,
+	0x61, 0x70, 0x70, 0x2e, 0x7a, 0x6f, 0x6d, 0x62, 0x69, 0x65, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72,
+	0x2e, 0x47, 0x65, 0x74, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x1e, 0x2e, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x61, 0x70, 0x70, 0x2e, 0x7a, 0x6f,
+	0x6d, 0x62, 0x69, 0x65, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x2e, 0x44, 0x72, 0x69, 0x76, 0x65,
+	0x72, 0x12, 0x72, 0x0a, 0x0f, 0x42, 0x61, 0x74, 0x63, 0x68, 0x47, 0x65, 0x74, 0x44, 0x72, 0x69,
+	0x76, 0x65, 0x72, 0x73, 0x12, 0x2e, 0x2e, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x61, 0x70, 0x70,
+	0x2e, 0x7a, 0x6f, 0x6d, 0x62, 0x69, 0x65, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x2e, 0x42, 0x61,
+	0x74, 0x63, 0x68, 0x47, 0x65, 0x74, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x73, 0x52, 0x65, 0x71,
+	0x75, 0x65, 0x73, 0x74, 0x1a, 0x2f, 0x2e, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x61, 0x70, 0x70,
+	0x2e, 0x7a, 0x6f, 0x6d, 0x62, 0x69, 0x65, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x2e, 0x42, 0x61,
+	0x74, 0x63, 0x68, 0x47, 0x65, 0x74, 0x44, 0x72, 0x69, 0x76, 0x65, 0x72, 0x73, 0x52, 0x65, 0x73,
+	0x70, 0x6f, 0x6e, 0x73, 0x65, 0x42, 0x44, 0x5a, 0x42, 0x67, 0x69, 0x74, 0x68, 0x75, 0x62, 0x2e,
+	0x63, 0x6f, 0x6d, 0x2f, 0x67, 0x65, 0x6f, 0x72, 0x67, 0x79, 0x73, 0x61, 0x76, 0x76, 0x61, 0x2f,
+	0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x2d, 0x61, 0x70, 0x70, 0x2f, 0x7a, 0x6f, 0x6d, 0x62, 0x69,
+	0x65, 0x2d, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x2f, 0x70, 0x6b, 0x67, 0x2f, 0x7a, 0x6f, 0x6d,
+	0x62, 0x69, 0x65, 0x64, 0x72, 0x69, 0x76, 0x65, 0x72, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f,
+	0x74, 0x6f, 0x33,
+}
+
+var (
+	file_zombiedriver_proto_rawDescOnce sync.Once
+	file_zombiedriver_proto_rawDescData = file_zombiedriver_proto_rawDesc
+)
+
+func file_zombiedriver_proto_rawDescGZIP() []byte {
+	file_zombiedriver_proto_rawDescOnce.Do(func() {
+		file_zombiedriver_proto_rawDescData = protoimpl.X.CompressGZIP(file_zombiedriver_proto_rawDescData)
+	})
+	return file_zombiedriver_proto_rawDescData
+}
+
+var file_zombiedriver_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_zombiedriver_proto_goTypes = []interface{}{
+	(*GetDriverRequest)(nil),        // 0: driverapp.zombiedriver.GetDriverRequest
+	(*Driver)(nil),                  // 1: driverapp.zombiedriver.Driver
+	(*BatchGetDriversRequest)(nil),  // 2: driverapp.zombiedriver.BatchGetDriversRequest
+	(*BatchGetDriversResponse)(nil), // 3: driverapp.zombiedriver.BatchGetDriversResponse
+}
+var file_zombiedriver_proto_depIdxs = []int32{
+	1, // 0: driverapp.zombiedriver.BatchGetDriversResponse.drivers:type_name -> driverapp.zombiedriver.Driver
+	0, // 1: driverapp.zombiedriver.ZombieDriver.GetDriver:input_type -> driverapp.zombiedriver.GetDriverRequest
+	2, // 2: driverapp.zombiedriver.ZombieDriver.BatchGetDrivers:input_type -> driverapp.zombiedriver.BatchGetDriversRequest
+	1, // 3: driverapp.zombiedriver.ZombieDriver.GetDriver:output_type -> driverapp.zombiedriver.Driver
+	3, // 4: driverapp.zombiedriver.ZombieDriver.BatchGetDrivers:output_type -> driverapp.zombiedriver.BatchGetDriversResponse
+	3, // [3:5] is the sub-list for method output_type
+	1, // [1:3] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_zombiedriver_proto_init() }
+func file_zombiedriver_proto_init() {
+	if File_zombiedriver_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_zombiedriver_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*GetDriverRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_zombiedriver_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Driver); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_zombiedriver_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchGetDriversRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_zombiedriver_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*BatchGetDriversResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_zombiedriver_proto_msgTypes[1].OneofWrappers = []interface{}{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_zombiedriver_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_zombiedriver_proto_goTypes,
+		DependencyIndexes: file_zombiedriver_proto_depIdxs,
+		MessageInfos:      file_zombiedriver_proto_msgTypes,
+	}.Build()
+	File_zombiedriver_proto = out.File
+	file_zombiedriver_proto_rawDesc = nil
+	file_zombiedriver_proto_goTypes = nil
+	file_zombiedriver_proto_depIdxs = nil
+}
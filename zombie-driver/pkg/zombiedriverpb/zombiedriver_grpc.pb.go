@@ -0,0 +1,135 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+
+package zombiedriverpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion7
+
+// ZombieDriverClient is the client API for ZombieDriver service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ZombieDriverClient interface {
+	GetDriver(ctx context.Context, in *GetDriverRequest, opts ...grpc.CallOption) (*Driver, error)
+	// BatchGetDrivers returns the drivers in the order of the requested ids.
+	BatchGetDrivers(ctx context.Context, in *BatchGetDriversRequest, opts ...grpc.CallOption) (*BatchGetDriversResponse, error)
+}
+
+type zombieDriverClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewZombieDriverClient(cc grpc.ClientConnInterface) ZombieDriverClient {
+	return &zombieDriverClient{cc}
+}
+
+func (c *zombieDriverClient) GetDriver(ctx context.Context, in *GetDriverRequest, opts ...grpc.CallOption) (*Driver, error) {
+	out := new(Driver)
+	err := c.cc.Invoke(ctx, "/driverapp.zombiedriver.ZombieDriver/GetDriver", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *zombieDriverClient) BatchGetDrivers(ctx context.Context, in *BatchGetDriversRequest, opts ...grpc.CallOption) (*BatchGetDriversResponse, error) {
+	out := new(BatchGetDriversResponse)
+	err := c.cc.Invoke(ctx, "/driverapp.zombiedriver.ZombieDriver/BatchGetDrivers", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ZombieDriverServer is the server API for ZombieDriver service.
+// All implementations must embed UnimplementedZombieDriverServer
+// for forward compatibility
+type ZombieDriverServer interface {
+	GetDriver(context.Context, *GetDriverRequest) (*Driver, error)
+	// BatchGetDrivers returns the drivers in the order of the requested ids.
+	BatchGetDrivers(context.Context, *BatchGetDriversRequest) (*BatchGetDriversResponse, error)
+	mustEmbedUnimplementedZombieDriverServer()
+}
+
+// UnimplementedZombieDriverServer must be embedded to have forward compatible implementations.
+type UnimplementedZombieDriverServer struct {
+}
+
+func (UnimplementedZombieDriverServer) GetDriver(context.Context, *GetDriverRequest) (*Driver, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDriver not implemented")
+}
+func (UnimplementedZombieDriverServer) BatchGetDrivers(context.Context, *BatchGetDriversRequest) (*BatchGetDriversResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method BatchGetDrivers not implemented")
+}
+func (UnimplementedZombieDriverServer) mustEmbedUnimplementedZombieDriverServer() {}
+
+// UnsafeZombieDriverServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ZombieDriverServer will
+// result in compilation errors.
+type UnsafeZombieDriverServer interface {
+	mustEmbedUnimplementedZombieDriverServer()
+}
+
+func RegisterZombieDriverServer(s grpc.ServiceRegistrar, srv ZombieDriverServer) {
+	s.RegisterService(&_ZombieDriver_serviceDesc, srv)
+}
+
+func _ZombieDriver_GetDriver_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDriverRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZombieDriverServer).GetDriver(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/driverapp.zombiedriver.ZombieDriver/GetDriver",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZombieDriverServer).GetDriver(ctx, req.(*GetDriverRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ZombieDriver_BatchGetDrivers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(BatchGetDriversRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ZombieDriverServer).BatchGetDrivers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/driverapp.zombiedriver.ZombieDriver/BatchGetDrivers",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ZombieDriverServer).BatchGetDrivers(ctx, req.(*BatchGetDriversRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _ZombieDriver_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "driverapp.zombiedriver.ZombieDriver",
+	HandlerType: (*ZombieDriverServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetDriver",
+			Handler:    _ZombieDriver_GetDriver_Handler,
+		},
+		{
+			MethodName: "BatchGetDrivers",
+			Handler:    _ZombieDriver_BatchGetDrivers_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "zombiedriver.proto",
+}
@@ -0,0 +1,4 @@
+// Package zombiedriverpb contains the gRPC API of the zombie driver service generated from zombiedriver.proto.
+package zombiedriverpb
+
+//go:generate protoc --go_out=paths=source_relative:. --go-grpc_out=paths=source_relative:. zombiedriver.proto